@@ -12,19 +12,24 @@ import (
 	"github.com/docker/machine/commands/mcndirs"
 	"github.com/docker/machine/drivers/amazonec2"
 	"github.com/docker/machine/drivers/azure"
+	"github.com/docker/machine/drivers/container"
 	"github.com/docker/machine/drivers/digitalocean"
 	"github.com/docker/machine/drivers/exoscale"
 	"github.com/docker/machine/drivers/generic"
 	"github.com/docker/machine/drivers/google"
 	"github.com/docker/machine/drivers/hyperv"
+	"github.com/docker/machine/drivers/lxd"
 	"github.com/docker/machine/drivers/none"
 	"github.com/docker/machine/drivers/openstack"
+	"github.com/docker/machine/drivers/pool"
+	"github.com/docker/machine/drivers/qemu"
 	"github.com/docker/machine/drivers/rackspace"
 	"github.com/docker/machine/drivers/softlayer"
 	"github.com/docker/machine/drivers/virtualbox"
 	"github.com/docker/machine/drivers/vmwarefusion"
 	"github.com/docker/machine/drivers/vmwarevcloudair"
 	"github.com/docker/machine/drivers/vmwarevsphere"
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/drivers/plugin"
 	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
 	"github.com/docker/machine/libmachine/log"
@@ -117,6 +122,18 @@ func main() {
 			Value:  mcndirs.GetBaseDir(),
 			Usage:  "Configures storage path",
 		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_STORE_URL",
+			Name:   "store-url",
+			Usage:  "URL of the store keeping the machines, e.g. db:///path/to/machines.db, defaults to the storage path",
+			Value:  "",
+		},
+		cli.StringFlag{
+			EnvVar: "MACHINE_SECRETS_KEYFILE",
+			Name:   "secrets-keyfile",
+			Usage:  "File holding the key the secrets of the drivers are encrypted with, MACHINE_SECRETS_PASSPHRASE is used otherwise",
+			Value:  "",
+		},
 		cli.StringFlag{
 			EnvVar: "MACHINE_TLS_CA_CERT",
 			Name:   "tls-ca-cert",
@@ -158,6 +175,11 @@ func main() {
 			Usage:  "BugSnag API token for crash reporting",
 			Value:  "",
 		},
+		cli.BoolFlag{
+			EnvVar: "MACHINE_WAIT_LOCK",
+			Name:   "wait-lock",
+			Usage:  "Wait for machines locked by another docker-machine process instead of failing",
+		},
 	}
 
 	if err := app.Run(os.Args); err != nil {
@@ -165,42 +187,59 @@ func main() {
 	}
 }
 
+// coreDrivers create the drivers built in the docker-machine binary.
+var coreDrivers = map[string]func(hostName, storePath string) drivers.Driver{
+	"amazonec2": func(hostName, storePath string) drivers.Driver {
+		return amazonec2.NewDriver(hostName, storePath)
+	},
+	"azure": azure.NewDriver,
+	"container": func(hostName, storePath string) drivers.Driver {
+		return container.NewDriver(hostName, storePath)
+	},
+	"digitalocean": func(hostName, storePath string) drivers.Driver {
+		return digitalocean.NewDriver(hostName, storePath)
+	},
+	"exoscale": exoscale.NewDriver,
+	"generic":  generic.NewDriver,
+	"google": func(hostName, storePath string) drivers.Driver {
+		return google.NewDriver(hostName, storePath)
+	},
+	"hyperv": func(hostName, storePath string) drivers.Driver {
+		return hyperv.NewDriver(hostName, storePath)
+	},
+	"lxd": func(hostName, storePath string) drivers.Driver {
+		return lxd.NewDriver(hostName, storePath)
+	},
+	"none": func(hostName, storePath string) drivers.Driver {
+		return none.NewDriver(hostName, storePath)
+	},
+	"openstack": openstack.NewDriver,
+	"pool": func(hostName, storePath string) drivers.Driver {
+		return pool.NewDriver(hostName, storePath)
+	},
+	"qemu": func(hostName, storePath string) drivers.Driver {
+		return qemu.NewDriver(hostName, storePath)
+	},
+	"rackspace": rackspace.NewDriver,
+	"softlayer": softlayer.NewDriver,
+	"virtualbox": func(hostName, storePath string) drivers.Driver {
+		return virtualbox.NewDriver(hostName, storePath)
+	},
+	"vmwarefusion":    vmwarefusion.NewDriver,
+	"vmwarevcloudair": vmwarevcloudair.NewDriver,
+	"vmwarevsphere":   vmwarevsphere.NewDriver,
+}
+
 func runDriver(driverName string) {
-	switch driverName {
-	case "amazonec2":
-		plugin.RegisterDriver(amazonec2.NewDriver("", ""))
-	case "azure":
-		plugin.RegisterDriver(azure.NewDriver("", ""))
-	case "digitalocean":
-		plugin.RegisterDriver(digitalocean.NewDriver("", ""))
-	case "exoscale":
-		plugin.RegisterDriver(exoscale.NewDriver("", ""))
-	case "generic":
-		plugin.RegisterDriver(generic.NewDriver("", ""))
-	case "google":
-		plugin.RegisterDriver(google.NewDriver("", ""))
-	case "hyperv":
-		plugin.RegisterDriver(hyperv.NewDriver("", ""))
-	case "none":
-		plugin.RegisterDriver(none.NewDriver("", ""))
-	case "openstack":
-		plugin.RegisterDriver(openstack.NewDriver("", ""))
-	case "rackspace":
-		plugin.RegisterDriver(rackspace.NewDriver("", ""))
-	case "softlayer":
-		plugin.RegisterDriver(softlayer.NewDriver("", ""))
-	case "virtualbox":
-		plugin.RegisterDriver(virtualbox.NewDriver("", ""))
-	case "vmwarefusion":
-		plugin.RegisterDriver(vmwarefusion.NewDriver("", ""))
-	case "vmwarevcloudair":
-		plugin.RegisterDriver(vmwarevcloudair.NewDriver("", ""))
-	case "vmwarevsphere":
-		plugin.RegisterDriver(vmwarevsphere.NewDriver("", ""))
-	default:
+	newDriver, ok := coreDrivers[driverName]
+	if !ok {
 		fmt.Fprintf(os.Stderr, "Unsupported driver: %s\n", driverName)
 		os.Exit(1)
 	}
+
+	// The core drivers serve all the machines of the driver from one
+	// plugin process.
+	plugin.RegisterDriverFactory(newDriver)
 }
 
 func cmdNotFound(c *cli.Context, command string) {
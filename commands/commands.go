@@ -1,15 +1,25 @@
 package commands
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/codegangsta/cli"
 	"github.com/docker/machine/commands/mcndirs"
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/crashreport"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
+	"github.com/docker/machine/libmachine/history"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnerror"
@@ -19,7 +29,8 @@ import (
 )
 
 const (
-	defaultMachineName = "default"
+	defaultMachineName     = "default"
+	defaultParallelActions = 10
 )
 
 var (
@@ -30,6 +41,14 @@ var (
 	ErrTooManyArguments   = errors.New("Error: Too many arguments given")
 
 	osExit = func(code int) { os.Exit(code) }
+
+	// parallelFlag bounds how many machines the commands acting on
+	// several machines handle at the same time.
+	parallelFlag = cli.IntFlag{
+		Name:  "parallel",
+		Usage: "Number of machines to act on at the same time, 0 for no limit",
+		Value: defaultParallelActions,
+	}
 )
 
 // CommandLine contains all the information passed to the commands on the command line.
@@ -57,10 +76,67 @@ type CommandLine interface {
 	FlagNames() (names []string)
 
 	Generic(name string) interface{}
+
+	// Ctx returns a context which is cancelled when the user interrupts
+	// the command, e.g. with Ctrl-C.
+	Ctx() context.Context
 }
 
 type contextCommandLine struct {
 	*cli.Context
+	ctx     context.Context
+	ctxOnce sync.Once
+	stop    func()
+}
+
+// Ctx installs the interrupt handler the first time it is called, so that
+// commands which never look at the context keep the default behaviour of
+// exiting on Ctrl-C.
+func (c *contextCommandLine) Ctx() context.Context {
+	c.ctxOnce.Do(func() {
+		c.ctx, c.stop = interruptContext()
+		localbinary.SetClientHandlesInterrupt(true)
+	})
+	return c.ctx
+}
+
+func (c *contextCommandLine) close() {
+	if c.stop != nil {
+		c.stop()
+		localbinary.SetClientHandlesInterrupt(false)
+	}
+}
+
+// interruptContext returns a context which is cancelled on the first
+// interrupt, giving the command a chance to clean up. A second interrupt
+// exits right away.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	stopCh := make(chan struct{})
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case <-sigCh:
+			log.Info("Interrupted, cleaning up (press Ctrl-C again to exit immediately)...")
+			cancel()
+		case <-stopCh:
+			return
+		}
+
+		select {
+		case <-sigCh:
+			osExit(130)
+		case <-stopCh:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(sigCh)
+		close(stopCh)
+		cancel()
+	}
 }
 
 func (c *contextCommandLine) ShowHelp() {
@@ -99,6 +175,10 @@ func runAction(actionName string, c CommandLine, api libmachine.API) error {
 		hostsToLoad []string
 	)
 
+	// Set up interrupt handling before the plugins of the machines are
+	// launched, so that they leave interrupts to us.
+	ctx := c.Ctx()
+
 	// If user did not specify a machine name explicitly, use the 'default'
 	// machine if it exists.  This allows short form commands such as
 	// 'docker-machine stop' for convenience.
@@ -110,7 +190,7 @@ func runAction(actionName string, c CommandLine, api libmachine.API) error {
 
 		hostsToLoad = []string{target}
 	} else {
-		hostsToLoad = c.Args()
+		hostsToLoad = uniqueNames(c.Args())
 	}
 
 	hosts, hostsInError := persist.LoadHosts(api, hostsToLoad)
@@ -127,14 +207,16 @@ func runAction(actionName string, c CommandLine, api libmachine.API) error {
 		return ErrHostLoad
 	}
 
-	if errs := runActionForeachMachine(actionName, hosts); len(errs) > 0 {
-		return consolidateErrs(errs)
+	results := runActionForeachMachine(ctx, api, actionName, hosts, c.Int("parallel"))
+
+	// Only summarize the actions changing the machines, the others print
+	// what was asked for.
+	if isMutatingAction(actionName) && len(results) > 1 {
+		printActionSummary(os.Stderr, results)
 	}
 
-	for _, h := range hosts {
-		if err := api.Save(h); err != nil {
-			return fmt.Errorf("Error saving host to store: %s", err)
-		}
+	if errs := actionErrors(results); len(errs) > 0 {
+		return consolidateErrs(errs)
 	}
 
 	return nil
@@ -151,6 +233,22 @@ func runCommand(command func(commandLine CommandLine, api libmachine.API) error)
 		api.GithubAPIToken = context.GlobalString("github-api-token")
 		api.Filestore.Path = context.GlobalString("storage-path")
 
+		store, err := persist.NewStore(context.GlobalString("store-url"), api.Filestore)
+		if err != nil {
+			log.Error(err)
+			osExit(1)
+			return
+		}
+		api.Store = store
+
+		keyring, err := newKeyring(context.GlobalString("secrets-keyfile"), os.Getenv(secretsPassphraseEnv))
+		if err != nil {
+			log.Error(err)
+			osExit(1)
+			return
+		}
+		persist.SetKeyring(keyring)
+
 		// TODO (nathanleclaire): These should ultimately be accessed
 		// through the libmachine client by the rest of the code and
 		// not through their respective modules.  For now, however,
@@ -159,8 +257,14 @@ func runCommand(command func(commandLine CommandLine, api libmachine.API) error)
 		mcndirs.BaseDir = api.Filestore.Path
 		mcnutils.GithubAPIToken = api.GithubAPIToken
 		ssh.SetDefaultClient(api.SSHClientType)
+		host.SetWaitForLock(context.GlobalBool("wait-lock"))
+		localbinary.SetPluginDir(mcndirs.GetDriversDir())
+		localbinary.SetPidDir(mcndirs.GetPidDir())
 
-		if err := command(&contextCommandLine{context}, api); err != nil {
+		commandLine := &contextCommandLine{Context: context}
+		defer commandLine.close()
+
+		if err := command(commandLine, api); err != nil {
 			log.Error(err)
 
 			if crashErr, ok := err.(crashreport.CrashError); ok {
@@ -217,6 +321,12 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:        "console-log",
+		Usage:       "Print the console output of a machine",
+		Description: "Argument is a machine name. Only the drivers which can read it offer the console output.",
+		Action:      runCommand(cmdConsoleLog),
+	},
 	{
 		Flags:           SharedCreateFlags,
 		Name:            "create",
@@ -225,6 +335,23 @@ var Commands = []cli.Command{
 		Action:          runCommand(cmdCreateOuter),
 		SkipFlagParsing: true,
 	},
+	{
+		Name:        "doctor",
+		Usage:       "Check the machines of the storage path and the driver plugins for problems",
+		Description: "Argument(s) are one or more machine names, all the machines by default.",
+		Action:      runCommand(cmdDoctor),
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "fix",
+				Usage: "Repair the problems which can be repaired",
+			},
+			cli.StringFlag{
+				Name:  "format, f",
+				Usage: "Output format, table or json",
+				Value: "table",
+			},
+		},
+	},
 	{
 		Name:        "env",
 		Usage:       "Display the commands to set up the environment for the Docker client",
@@ -249,6 +376,43 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:        "export",
+		Usage:       "Package a machine with its keys and certificates",
+		Description: "Argument is a machine name.",
+		Action:      runCommand(cmdExport),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "output, o",
+				Usage: "File to write the bundle to, NAME.tar.gz by default",
+			},
+		},
+	},
+	{
+		Name:        "history",
+		Usage:       "Show the commands which changed a machine",
+		Description: "Argument is a machine name.",
+		Action:      runCommand(cmdHistory),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format, f",
+				Usage: "Pretty-print the history using a Go template",
+				Value: "",
+			},
+		},
+	},
+	{
+		Name:        "import",
+		Usage:       "Add a machine packaged by export",
+		Description: "Argument is the path of the bundle.",
+		Action:      runCommand(cmdImport),
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "name",
+				Usage: "Name of the imported machine, the one it was exported with by default",
+			},
+		},
+	},
 	{
 		Name:        "inspect",
 		Usage:       "Inspect information about a machine",
@@ -260,6 +424,10 @@ var Commands = []cli.Command{
 				Usage: "Format the output using the given go template.",
 				Value: "",
 			},
+			cli.BoolFlag{
+				Name:  "show-secrets",
+				Usage: "Show the secrets of the driver, such as its credentials",
+			},
 		},
 	},
 	{
@@ -267,12 +435,18 @@ var Commands = []cli.Command{
 		Usage:       "Get the IP address of a machine",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdIP),
+		Flags: []cli.Flag{
+			parallelFlag,
+		},
 	},
 	{
 		Name:        "kill",
 		Usage:       "Kill a machine",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdKill),
+		Flags: []cli.Flag{
+			parallelFlag,
+		},
 	},
 	{
 		Name:   "ls",
@@ -299,10 +473,22 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:        "pause",
+		Usage:       "Pause a machine, keeping its memory",
+		Description: "Argument(s) are one or more machine names. Only the drivers which can pause their machines offer it.",
+		Action:      runCommand(cmdPause),
+		Flags: []cli.Flag{
+			parallelFlag,
+		},
+	},
 	{
 		Name:   "provision",
 		Usage:  "Re-provision existing machines",
 		Action: runCommand(cmdProvision),
+		Flags: []cli.Flag{
+			parallelFlag,
+		},
 	},
 	{
 		Name:        "regenerate-certs",
@@ -318,6 +504,27 @@ var Commands = []cli.Command{
 				Name:  "client-certs",
 				Usage: "Also regenerate client certificates and CA.",
 			},
+			parallelFlag,
+		},
+	},
+	{
+		Name:        "resize",
+		Usage:       "Change the resources of a machine",
+		Description: "Argument is a machine name. Only the drivers which can resize their machines offer it.",
+		Action:      runCommand(cmdResize),
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name:  "cpus",
+				Usage: "New number of CPUs",
+			},
+			cli.IntFlag{
+				Name:  "memory",
+				Usage: "New size of the memory in MB",
+			},
+			cli.IntFlag{
+				Name:  "disk-size",
+				Usage: "New size of the disk in MB",
+			},
 		},
 	},
 	{
@@ -325,6 +532,9 @@ var Commands = []cli.Command{
 		Usage:       "Restart a machine",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdRestart),
+		Flags: []cli.Flag{
+			parallelFlag,
+		},
 	},
 	{
 		Flags: []cli.Flag{
@@ -336,6 +546,7 @@ var Commands = []cli.Command{
 				Name:  "y",
 				Usage: "Assumes automatic yes to proceed with remove, without prompting further user confirmation",
 			},
+			parallelFlag,
 		},
 		Name:        "rm",
 		Usage:       "Remove a machine",
@@ -381,11 +592,160 @@ var Commands = []cli.Command{
 			},
 		},
 	},
+	{
+		Name:  "driver",
+		Usage: "Manage the driver plugins",
+		Subcommands: []cli.Command{
+			{
+				Name:   "ls",
+				Usage:  "List the core drivers and the driver plugins found",
+				Action: runCommand(cmdDriverLs),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "format, f",
+						Usage: "Pretty-print drivers using a Go template",
+					},
+				},
+			},
+			{
+				Name:   "inspect",
+				Usage:  "Inspect a driver and the flags it adds to create",
+				Action: runCommand(cmdDriverInspect),
+			},
+			{
+				Name:        "install",
+				Usage:       "Install a driver plugin",
+				Description: "Argument is the path of a docker-machine-driver-* binary, or of a .tar.gz, .tgz or .zip archive holding one.",
+				Action:      runCommand(cmdDriverInstall),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "sha256",
+						Usage: "SHA-256 checksum of the binary or archive",
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:  "snapshot",
+		Usage: "Manage the snapshots of a machine",
+		Subcommands: []cli.Command{
+			{
+				Name:        "create",
+				Usage:       "Save the current state of a machine",
+				Description: "Arguments are a machine name and the name of the snapshot.",
+				Action:      runCommand(cmdSnapshotCreate),
+			},
+			{
+				Name:        "ls",
+				Usage:       "List the snapshots of a machine",
+				Description: "Argument is a machine name.",
+				Action:      runCommand(cmdSnapshotLs),
+			},
+			{
+				Name:        "restore",
+				Usage:       "Bring a machine back to a snapshot",
+				Description: "Arguments are a machine name and the name of the snapshot.",
+				Action:      runCommand(cmdSnapshotRestore),
+			},
+			{
+				Name:        "rm",
+				Usage:       "Remove a snapshot of a machine",
+				Description: "Arguments are a machine name and the name of the snapshot.",
+				Action:      runCommand(cmdSnapshotRm),
+			},
+		},
+	},
+	{
+		Name:  "pool",
+		Usage: "Manage the hosts of the pool driver",
+		Subcommands: []cli.Command{
+			{
+				Name:   "status",
+				Usage:  "List the hosts of a pool and the machines they are leased to",
+				Action: runCommand(cmdPoolStatus),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						EnvVar: "POOL_INVENTORY",
+						Name:   "inventory",
+						Usage:  "JSON inventory of the hosts of the pool",
+					},
+					cli.StringFlag{
+						EnvVar: "POOL_LEASE_DIR",
+						Name:   "lease-dir",
+						Usage:  "Directory of the leases of the hosts, next to the inventory by default",
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:  "secrets",
+		Usage: "Manage the encryption of the secrets of the drivers",
+		Subcommands: []cli.Command{
+			{
+				Name:   "rekey",
+				Usage:  "Encrypt the secrets of all the machines with a new key",
+				Action: runCommand(cmdSecretsRekey),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "new-keyfile",
+						Usage: "File holding the new key, " + secretsNewPassphraseEnv + " is used otherwise",
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:  "store",
+		Usage: "Manage the store of the machines",
+		Subcommands: []cli.Command{
+			{
+				Name:        "migrate",
+				Usage:       "Copy the machines to another store",
+				Description: "Argument is the URL of the destination store, e.g. db:///path/to/machines.db.",
+				Action:      runCommand(cmdStoreMigrate),
+			},
+			{
+				Name:   "ls",
+				Usage:  "List the machines of a shared store with their owners",
+				Action: runCommand(cmdStoreLs),
+			},
+			{
+				Name:   "serve",
+				Usage:  "Serve the machines of the storage path to other docker-machine users",
+				Action: runCommand(cmdStoreServe),
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name:  "address",
+						Usage: "Address to listen on",
+						Value: defaultStoreAddress,
+					},
+					cli.StringFlag{
+						Name:  "tls-cert",
+						Usage: "Certificate to serve the store over TLS with",
+					},
+					cli.StringFlag{
+						Name:  "tls-key",
+						Usage: "Private key of the TLS certificate",
+					},
+					cli.StringFlag{
+						EnvVar: "MACHINE_STORE_TOKEN",
+						Name:   "token",
+						Usage:  "Token the clients must send, a random one is generated if empty",
+					},
+				},
+			},
+		},
+	},
 	{
 		Name:        "start",
 		Usage:       "Start a machine",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdStart),
+		Flags: []cli.Flag{
+			parallelFlag,
+		},
 	},
 	{
 		Name:        "status",
@@ -398,12 +758,27 @@ var Commands = []cli.Command{
 		Usage:       "Stop a machine",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdStop),
+		Flags: []cli.Flag{
+			parallelFlag,
+		},
+	},
+	{
+		Name:        "unpause",
+		Usage:       "Resume a paused machine",
+		Description: "Argument(s) are one or more machine names.",
+		Action:      runCommand(cmdUnpause),
+		Flags: []cli.Flag{
+			parallelFlag,
+		},
 	},
 	{
 		Name:        "upgrade",
 		Usage:       "Upgrade a machine to the latest version of Docker",
 		Description: "Argument(s) are one or more machine names.",
 		Action:      runCommand(cmdUpgrade),
+		Flags: []cli.Flag{
+			parallelFlag,
+		},
 	},
 	{
 		Name:        "url",
@@ -433,52 +808,268 @@ func printIP(h *host.Host) func() error {
 
 // machineCommand maps the command name to the corresponding machine command.
 // We run commands concurrently and communicate back an error if there was one.
-func machineCommand(actionName string, host *host.Host, errorChan chan<- error) {
+// lockOperations names the operations reported to other processes finding a
+// machine locked by an action. Actions which don't change the machine, and
+// so don't take its lock, aren't listed.
+var lockOperations = map[string]string{
+	"configureAuth":    "regenerate-certs",
+	"configureAllAuth": "regenerate-certs",
+	"start":            "start",
+	"stop":             "stop",
+	"restart":          "restart",
+	"kill":             "kill",
+	"upgrade":          "upgrade",
+	"provision":        "provision",
+	"pause":            "pause",
+	"unpause":          "unpause",
+}
+
+// actionCapabilities are the capabilities the drivers must offer for the
+// actions to run on their machines.
+var actionCapabilities = map[string]drivers.Capability{
+	"pause":   drivers.CapabilityPause,
+	"unpause": drivers.CapabilityPause,
+}
+
+// runMachineAction runs the action on the machine and saves it. The lock
+// of the machine is held until it is saved, so that no other process
+// changes it in between. The actions changing the machine are recorded in
+// its history.
+func runMachineAction(ctx context.Context, api libmachine.API, actionName string, h *host.Host) error {
+	if capability, ok := actionCapabilities[actionName]; ok {
+		if err := checkCapability(h, capability); err != nil {
+			return err
+		}
+	}
+
+	operation, changesMachine := lockOperations[actionName]
+	if !changesMachine {
+		return machineCommand(ctx, actionName, h)
+	}
+
+	entry := history.NewEntry(operation)
+	err := runLockedAction(ctx, api, actionName, operation, h, entry)
+	recordHistory(api, h.Name, entry, err)
+
+	return err
+}
+
+func runLockedAction(ctx context.Context, api libmachine.API, actionName, operation string, h *host.Host, entry *history.Entry) error {
+	return runLocked(ctx, api, operation, h, func() error {
+		if actionName == "upgrade" {
+			recordDockerVersion(h, entry, "from")
+		}
+
+		if err := machineCommand(ctx, actionName, h); err != nil {
+			return err
+		}
+
+		if actionName == "upgrade" {
+			recordDockerVersion(h, entry, "to")
+		}
+
+		return nil
+	})
+}
+
+// runLocked runs the operation on the machine with its lock held, and saves
+// the machine before releasing it.
+func runLocked(ctx context.Context, api libmachine.API, operation string, h *host.Host, run func() error) error {
+	unlock, err := h.Lock(ctx, operation)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := run(); err != nil {
+		return err
+	}
+
+	if err := api.Save(h); err != nil {
+		return fmt.Errorf("Error saving host to store: %s", err)
+	}
+
+	return nil
+}
+
+// runOperation runs an operation changing the machine which isn't one of
+// the actions, e.g. because it takes arguments, and records it in the
+// history of the machine.
+func runOperation(ctx context.Context, api libmachine.API, operation string, h *host.Host, entry *history.Entry, run func() error) error {
+	err := runLocked(ctx, api, operation, h, run)
+	recordHistory(api, h.Name, entry, err)
+
+	return err
+}
+
+// checkCapability fails when the driver of the machine doesn't offer the
+// capability.
+func checkCapability(h *host.Host, capability drivers.Capability) error {
+	if drivers.HasCapability(h.Driver, capability) {
+		return nil
+	}
+
+	return fmt.Errorf("Machine %q doesn't support %s, its %q driver doesn't offer it", h.Name, capability, h.DriverName)
+}
+
+// loadCapableHost loads the named machine, failing when its driver doesn't
+// offer the capability.
+func loadCapableHost(api libmachine.API, name string, capability drivers.Capability) (*host.Host, error) {
+	h, err := api.Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkCapability(h, capability); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// isMutatingAction tells whether the action changes the machines it runs on.
+func isMutatingAction(actionName string) bool {
+	_, mutating := lockOperations[actionName]
+	return mutating
+}
+
+// uniqueNames removes the duplicates from the machine names, keeping their
+// order, so that a machine named twice isn't acted on twice concurrently.
+func uniqueNames(names []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+
+	return unique
+}
+
+func machineCommand(ctx context.Context, actionName string, host *host.Host) error {
 	// TODO: These actions should have their own type.
 	commands := map[string](func() error){
 		"configureAuth":    host.ConfigureAuth,
 		"configureAllAuth": host.ConfigureAllAuth,
-		"start":            host.Start,
-		"stop":             host.Stop,
-		"restart":          host.Restart,
-		"kill":             host.Kill,
+		"start":            func() error { return host.StartContext(ctx) },
+		"stop":             func() error { return host.StopContext(ctx) },
+		"restart":          func() error { return host.RestartContext(ctx) },
+		"kill":             func() error { return host.KillContext(ctx) },
 		"upgrade":          host.Upgrade,
 		"ip":               printIP(host),
 		"provision":        host.Provision,
+		"pause":            func() error { return drivers.Pause(host.Driver) },
+		"unpause":          func() error { return drivers.Unpause(host.Driver) },
 	}
 
 	log.Debugf("command=%s machine=%s", actionName, host.Name)
 
-	errorChan <- commands[actionName]()
+	return commands[actionName]()
 }
 
-// runActionForeachMachine will run the command across multiple machines
-func runActionForeachMachine(actionName string, machines []*host.Host) []error {
+// actionResult is the outcome of running an action on a single machine.
+type actionResult struct {
+	Name     string
+	Err      error
+	Duration time.Duration
+}
+
+// runActionForeachMachine will run the command across multiple machines, at
+// most parallel at a time so that cloud providers don't rate limit us. A
+// parallel value of 0 or less means no limit. The results are returned in
+// the same order as the machines.
+func runActionForeachMachine(ctx context.Context, api libmachine.API, actionName string, machines []*host.Host, parallel int) []actionResult {
+	names := make([]string, len(machines))
+	for i, machine := range machines {
+		names[i] = machine.Name
+	}
+
+	return runForeachMachine(ctx, names, parallel, func(index int) error {
+		return runMachineAction(ctx, api, actionName, machines[index])
+	})
+}
+
+// runForeachMachine calls action with the index of each of the named
+// machines, at most parallel at a time.
+func runForeachMachine(ctx context.Context, names []string, parallel int, action func(index int) error) []actionResult {
+	if parallel <= 0 || parallel > len(names) {
+		parallel = len(names)
+	}
+
 	var (
-		numConcurrentActions = 0
-		errorChan            = make(chan error)
-		errs                 = []error{}
+		results = make([]actionResult, len(names))
+		indexes = make(chan int)
+		wg      sync.WaitGroup
 	)
 
-	for _, machine := range machines {
-		numConcurrentActions++
-		go machineCommand(actionName, machine, errorChan)
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range indexes {
+				start := time.Now()
+
+				// Don't start on machines still waiting in line once
+				// the user asked us to stop.
+				err := ctx.Err()
+				if err == nil {
+					err = action(index)
+				}
+
+				results[index] = actionResult{
+					Name:     names[index],
+					Err:      err,
+					Duration: time.Since(start),
+				}
+			}
+		}()
+	}
+
+	for index := range names {
+		indexes <- index
 	}
+	close(indexes)
 
-	// TODO: We should probably only do 5-10 of these
-	// at a time, since otherwise cloud providers might
-	// rate limit us.
-	for i := 0; i < numConcurrentActions; i++ {
-		if err := <-errorChan; err != nil {
-			errs = append(errs, err)
+	wg.Wait()
+
+	return results
+}
+
+// actionErrors returns the errors of the failed actions. When the action ran
+// on several machines, each error is attributed to its machine.
+func actionErrors(results []actionResult) []error {
+	errs := []error{}
+	for _, result := range results {
+		if result.Err == nil {
+			continue
+		}
+		if len(results) > 1 {
+			errs = append(errs, fmt.Errorf("%s: %s", result.Name, result.Err))
+		} else {
+			errs = append(errs, result.Err)
 		}
 	}
 
-	close(errorChan)
-
 	return errs
 }
 
+func printActionSummary(w io.Writer, results []actionResult) {
+	tabWriter := tabwriter.NewWriter(w, 5, 1, 3, ' ', 0)
+	defer tabWriter.Flush()
+
+	fmt.Fprintln(tabWriter, "NAME\tRESULT\tDURATION")
+	for _, result := range results {
+		outcome := "Succeeded"
+		if result.Err != nil {
+			outcome = "Failed"
+		}
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\n", result.Name, outcome, result.Duration.Round(time.Millisecond))
+	}
+}
+
 func consolidateErrs(errs []error) error {
 	finalErr := ""
 	for _, err := range errs {
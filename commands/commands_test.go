@@ -1,17 +1,26 @@
 package commands
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/codegangsta/cli"
 	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/errdriver"
 	"github.com/docker/machine/drivers/fakedriver"
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/crashreport"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/libmachinetest"
 	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/state"
@@ -75,7 +84,7 @@ func TestRunActionForeachMachine(t *testing.T) {
 		},
 	}
 
-	runActionForeachMachine("start", machines)
+	runActionForeachMachine(context.Background(), &libmachinetest.FakeAPI{}, "start", machines, 2)
 
 	for _, machine := range machines {
 		machineState, _ := machine.Driver.GetState()
@@ -83,7 +92,7 @@ func TestRunActionForeachMachine(t *testing.T) {
 		assert.Equal(t, state.Running, machineState)
 	}
 
-	runActionForeachMachine("stop", machines)
+	runActionForeachMachine(context.Background(), &libmachinetest.FakeAPI{}, "stop", machines, 0)
 
 	for _, machine := range machines {
 		machineState, _ := machine.Driver.GetState()
@@ -92,6 +101,106 @@ func TestRunActionForeachMachine(t *testing.T) {
 	}
 }
 
+func TestRunActionForeachMachineAttributesErrors(t *testing.T) {
+	machines := []*host.Host{
+		{
+			Name: "foo",
+			Driver: &fakedriver.Driver{
+				MockState: state.Running,
+			},
+		},
+		{
+			Name:   "bar",
+			Driver: &errdriver.Driver{Name: "bar"},
+		},
+	}
+
+	results := runActionForeachMachine(context.Background(), &libmachinetest.FakeAPI{}, "stop", machines, 1)
+
+	assert.Len(t, results, 2)
+	assert.Equal(t, "foo", results[0].Name)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, "bar", results[1].Name)
+	assert.Error(t, results[1].Err)
+
+	errs := actionErrors(results)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, fmt.Sprintf("bar: %s", results[1].Err), errs[0].Error())
+}
+
+func TestRunActionForeachMachineSkipsOnceCancelled(t *testing.T) {
+	machines := []*host.Host{
+		{
+			Name: "foo",
+			Driver: &fakedriver.Driver{
+				MockState: state.Running,
+			},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := runActionForeachMachine(ctx, &libmachinetest.FakeAPI{}, "stop", machines, 1)
+
+	assert.Equal(t, context.Canceled, results[0].Err)
+	machineState, _ := machines[0].Driver.GetState()
+	assert.Equal(t, state.Running, machineState)
+}
+
+func TestRunActionForeachMachineFailsOnBusyMachine(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "machine")
+	defer os.RemoveAll(dir)
+
+	newHost := func() *host.Host {
+		return &host.Host{
+			Name: "foo",
+			Driver: &fakedriver.Driver{
+				MockState: state.Running,
+			},
+			HostOptions: &host.Options{
+				AuthOptions: &auth.Options{StorePath: dir},
+			},
+		}
+	}
+
+	unlock, err := newHost().Lock(context.Background(), "provision")
+	assert.NoError(t, err)
+
+	machines := []*host.Host{newHost()}
+	results := runActionForeachMachine(context.Background(), &libmachinetest.FakeAPI{}, "stop", machines, 1)
+
+	assert.IsType(t, mcnerror.ErrHostBusy{}, results[0].Err)
+	machineState, _ := machines[0].Driver.GetState()
+	assert.Equal(t, state.Running, machineState)
+
+	unlock()
+	results = runActionForeachMachine(context.Background(), &libmachinetest.FakeAPI{}, "stop", machines, 1)
+
+	assert.NoError(t, results[0].Err)
+}
+
+func TestUniqueNames(t *testing.T) {
+	assert.Equal(t, []string{"foo", "bar"}, uniqueNames([]string{"foo", "bar", "foo"}))
+}
+
+func TestIsMutatingAction(t *testing.T) {
+	assert.True(t, isMutatingAction("stop"))
+	assert.True(t, isMutatingAction("configureAuth"))
+	assert.False(t, isMutatingAction("ip"))
+}
+
+func TestPrintActionSummary(t *testing.T) {
+	var buf bytes.Buffer
+
+	printActionSummary(&buf, []actionResult{
+		{Name: "foo", Duration: 1500 * time.Millisecond},
+		{Name: "bar", Err: errors.New("BUG"), Duration: time.Second},
+	})
+
+	assert.Equal(t, "NAME   RESULT      DURATION\nfoo    Succeeded   1.5s\nbar    Failed      1s\n", buf.String())
+}
+
 func TestPrintIPEmptyGivenLocalEngine(t *testing.T) {
 	stdoutGetter := commandstest.NewStdoutGetter()
 	defer stdoutGetter.Stop()
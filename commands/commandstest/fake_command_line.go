@@ -1,6 +1,8 @@
 package commandstest
 
 import (
+	"context"
+
 	"github.com/codegangsta/cli"
 )
 
@@ -12,6 +14,7 @@ type FakeCommandLine struct {
 	LocalFlags, GlobalFlags *FakeFlagger
 	HelpShown, VersionShown bool
 	CliArgs                 []string
+	Context                 context.Context
 }
 
 func (ff FakeFlagger) String(key string) string {
@@ -56,6 +59,9 @@ func (fcli *FakeCommandLine) StringSlice(key string) []string {
 }
 
 func (fcli *FakeCommandLine) Int(key string) int {
+	if fcli.LocalFlags == nil {
+		return 0
+	}
 	return fcli.LocalFlags.Int(key)
 }
 
@@ -98,3 +104,10 @@ func (fcli *FakeCommandLine) Args() cli.Args {
 func (fcli *FakeCommandLine) ShowVersion() {
 	fcli.VersionShown = true
 }
+
+func (fcli *FakeCommandLine) Ctx() context.Context {
+	if fcli.Context == nil {
+		return context.Background()
+	}
+	return fcli.Context
+}
@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+)
+
+func cmdConsoleLog(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		c.ShowHelp()
+		return ErrExpectedOneMachine
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := loadCapableHost(api, target, drivers.CapabilityConsoleLog)
+	if err != nil {
+		return err
+	}
+
+	consoleLog, err := drivers.ConsoleLog(h.Driver)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(consoleLog)
+
+	return nil
+}
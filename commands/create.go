@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"errors"
@@ -22,6 +24,7 @@ import (
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/drivers/rpc"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/history"
 	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnerror"
@@ -130,6 +133,14 @@ var (
 			Usage: "Support extra SANs for TLS certs",
 			Value: &cli.StringSlice{},
 		},
+		cli.BoolFlag{
+			Name:  "resume",
+			Usage: "Resume the creation of a partly created machine, using its stored configuration. The machine is kept if resuming fails",
+		},
+		cli.BoolFlag{
+			Name:  "keep-on-failure",
+			Usage: "Keep the machine if its creation fails after the driver created it, so that it can be resumed with --resume, instead of removing it",
+		},
 	}
 )
 
@@ -149,6 +160,14 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 		return fmt.Errorf("Error creating machine: %s", mcnerror.ErrInvalidHostname)
 	}
 
+	// Set up interrupt handling before the driver plugin is launched, so
+	// that it leaves interrupts to us.
+	c.Ctx()
+
+	if c.Bool("resume") {
+		return resumeCreate(c, api, name)
+	}
+
 	if err := validateSwarmDiscovery(c.String("swarm-discovery")); err != nil {
 		return fmt.Errorf("Error parsing swarm discovery: %s", err)
 	}
@@ -221,11 +240,83 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 	mcnFlags := h.Driver.GetCreateFlags()
 	driverOpts := getDriverOpts(c, mcnFlags)
 
+	warnDeprecatedFlags(c, mcnFlags)
+
+	if err := mcnflag.Validate(mcnFlags, driverOpts.Values); err != nil {
+		return err
+	}
+
 	if err := h.Driver.SetConfigFromFlags(driverOpts); err != nil {
 		return fmt.Errorf("Error setting machine configuration from flags provided: %s", err)
 	}
 
-	if err := api.Create(h); err != nil {
+	return createHost(c, api, h)
+}
+
+// resumeCreate picks up the creation of a partly created machine at the
+// first stage that did not complete.
+func resumeCreate(c CommandLine, api libmachine.API, name string) error {
+	h, err := api.Load(name)
+	if err != nil {
+		return err
+	}
+
+	stage, pending := h.PendingCreateStage()
+	if !pending {
+		return fmt.Errorf("Machine %q is already created, there is nothing to resume", name)
+	}
+
+	// The driver may have allocated the machine before it stopped, running
+	// it again could allocate a second one.
+	if stage == host.StageDriverCreate {
+		return fmt.Errorf("Creation of %q stopped while the driver was creating the machine, which can't be resumed safely. Run '%s rm %s' and create it again", name, os.Args[0], name)
+	}
+
+	log.Infof("Resuming creation of %q at stage %s...", name, stage)
+
+	return createHost(c, api, h)
+}
+
+// createHost creates the machine and records it in its history.
+func createHost(c CommandLine, api libmachine.API, h *host.Host) error {
+	entry := history.NewEntry("create")
+	if c.Bool("resume") {
+		entry.SetDetail("resume", "true")
+	}
+
+	err := runCreate(c, api, h)
+	recordHistory(api, h.Name, entry, err)
+
+	return err
+}
+
+func runCreate(c CommandLine, api libmachine.API, h *host.Host) error {
+	ctx := c.Ctx()
+	if err := api.CreateContext(ctx, h); err != nil {
+		// A machine the driver created is removed when a later stage fails,
+		// unless the user asked to keep it. It is kept as well when the user
+		// interrupted the creation or is resuming it, so that it can be
+		// resumed (again) rather than started over.
+		createErr, _ := err.(mcnerror.ErrDuringCreate)
+		interrupted := ctx.Err() != nil
+		if createErr.Allocated {
+			if interrupted || c.Bool("resume") || c.Bool("keep-on-failure") {
+				log.Warnf("Keeping machine %q. Run '%s create --resume %s' to finish creating it or '%s rm %s' to remove it", h.Name, os.Args[0], h.Name, os.Args[0], h.Name)
+			} else if rollbackErr := rollbackCreate(api, h.Name); rollbackErr != nil {
+				log.Errorf("Rollback of %q failed: %s", h.Name, rollbackErr)
+				log.Errorf("Run '%s rm -f %s' to remove it", os.Args[0], h.Name)
+			} else {
+				log.Infof("Use --keep-on-failure to keep machines which fail to be created, and finish creating them with '%s create --resume'", os.Args[0])
+			}
+		}
+
+		if interrupted {
+			if h.StageCompleted(host.StageDriverCreate) {
+				return fmt.Errorf("Creation of %q was interrupted", h.Name)
+			}
+			return fmt.Errorf("Creation of %q was interrupted. Run '%s rm %s' to remove what was created so far", h.Name, os.Args[0], h.Name)
+		}
+
 		// Wait for all the logs to reach the client
 		time.Sleep(2 * time.Second)
 
@@ -243,15 +334,51 @@ func cmdCreateInner(c CommandLine, api libmachine.API) error {
 		}
 	}
 
+	unlock, err := h.Lock(ctx, "create")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	if err := api.Save(h); err != nil {
 		return fmt.Errorf("Error attempting to save store: %s", err)
 	}
 
-	log.Infof("To see how to connect your Docker Client to the Docker Engine running on this virtual machine, run: %s env %s", os.Args[0], name)
+	log.Infof("To see how to connect your Docker Client to the Docker Engine running on this virtual machine, run: %s env %s", os.Args[0], h.Name)
 
 	return nil
 }
 
+// rollbackCreate removes a machine whose creation failed after the driver had
+// already created it, so that it doesn't linger (and bill) at the provider.
+func rollbackCreate(api libmachine.API, name string) error {
+	log.Infof("Rolling back creation of %q...", name)
+
+	// The plugin serving the machine might have been shut down already if
+	// the creation was interrupted, so start from what is in the store.
+	h, err := api.Load(name)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := h.Lock(context.Background(), "rm")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := drivers.RemoveContext(context.Background(), h.Driver); err != nil {
+		return err
+	}
+
+	if err := removeLocalMachine(name, api); err != nil {
+		return err
+	}
+
+	log.Infof("Removed %q after failed creation", name)
+	return nil
+}
+
 // The following function is needed because the CLI acrobatics that we're doing
 // (with having an "outer" and "inner" function each with their own custom
 // settings and flag parsing needs) are not well supported by codegangsta/cli.
@@ -288,11 +415,42 @@ func flagHackLookup(flagName string) string {
 	return ""
 }
 
+// flagHackIsSet tells whether the given boolean flag was passed, either as
+// '--flag' or '--flag=true'.
+func flagHackIsSet(flagName string) bool {
+	name := strings.TrimLeft(flagName, "-")
+
+	// formats '--flag', '-flag', '--flag=true' or '-flag=1'
+	for _, arg := range os.Args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if arg == name {
+			return true
+		}
+
+		if strings.HasPrefix(arg, name+"=") {
+			set, err := strconv.ParseBool(strings.TrimPrefix(arg, name+"="))
+			return err == nil && set
+		}
+	}
+
+	return false
+}
+
 func cmdCreateOuter(c CommandLine, api libmachine.API) error {
 	const (
 		flagLookupMachineName = "flag-lookup"
 	)
 
+	// A resumed creation uses the configuration the machine was stored
+	// with, so there are no driver flags to add.
+	if flagHackIsSet("--resume") {
+		return c.Application().Run(os.Args)
+	}
+
 	// We didn't recognize the driver name.
 	driverName := flagHackLookup("--driver")
 	if driverName == "" {
@@ -340,7 +498,7 @@ func cmdCreateOuter(c CommandLine, api libmachine.API) error {
 	return c.Application().Run(os.Args)
 }
 
-func getDriverOpts(c CommandLine, mcnflags []mcnflag.Flag) drivers.DriverOptions {
+func getDriverOpts(c CommandLine, mcnflags []mcnflag.Flag) rpcdriver.RPCFlags {
 	// TODO: This function is pretty damn YOLO and would benefit from some
 	// sanity checking around types and assertions.
 	//
@@ -387,32 +545,32 @@ func convertMcnFlagsToCliFlags(mcnFlags []mcnflag.Flag) ([]cli.Flag, error) {
 		case *mcnflag.BoolFlag:
 			f := f.(*mcnflag.BoolFlag)
 			cliFlags = append(cliFlags, cli.BoolFlag{
-				Name:   f.Name,
+				Name:   cliFlagName(f),
 				EnvVar: f.EnvVar,
-				Usage:  f.Usage,
+				Usage:  mcnflag.Usage(f),
 			})
 		case *mcnflag.IntFlag:
 			f := f.(*mcnflag.IntFlag)
 			cliFlags = append(cliFlags, cli.IntFlag{
-				Name:   f.Name,
+				Name:   cliFlagName(f),
 				EnvVar: f.EnvVar,
-				Usage:  f.Usage,
+				Usage:  mcnflag.Usage(f),
 				Value:  f.Value,
 			})
 		case *mcnflag.StringFlag:
 			f := f.(*mcnflag.StringFlag)
 			cliFlags = append(cliFlags, cli.StringFlag{
-				Name:   f.Name,
+				Name:   cliFlagName(f),
 				EnvVar: f.EnvVar,
-				Usage:  f.Usage,
+				Usage:  mcnflag.Usage(f),
 				Value:  f.Value,
 			})
 		case *mcnflag.StringSliceFlag:
 			f := f.(*mcnflag.StringSliceFlag)
 			cliFlags = append(cliFlags, cli.StringSliceFlag{
-				Name:   f.Name,
+				Name:   cliFlagName(f),
 				EnvVar: f.EnvVar,
-				Usage:  f.Usage,
+				Usage:  mcnflag.Usage(f),
 
 				//TODO: Is this used with defaults? Can we convert the literal []string to cli.StringSlice properly?
 				Value: &cli.StringSlice{},
@@ -426,6 +584,22 @@ func convertMcnFlagsToCliFlags(mcnFlags []mcnflag.Flag) ([]cli.Flag, error) {
 	return cliFlags, nil
 }
 
+// cliFlagName returns the name of the cli flag of a driver flag, followed by
+// its deprecated names so that they are still accepted.
+func cliFlagName(f mcnflag.Flag) string {
+	return strings.Join(append([]string{f.String()}, mcnflag.MetadataOf(f).DeprecatedNames...), ", ")
+}
+
+func warnDeprecatedFlags(c CommandLine, mcnFlags []mcnflag.Flag) {
+	for _, f := range mcnFlags {
+		for _, name := range mcnflag.MetadataOf(f).DeprecatedNames {
+			if c.IsSet(name) {
+				log.Warnf("--%s is deprecated, use --%s instead", name, f.String())
+			}
+		}
+	}
+}
+
 func addDriverFlagsToCommand(cliFlags []cli.Flag, cmd *cli.Command) *cli.Command {
 	cmd.Flags = append(SharedCreateFlags, cliFlags...)
 	cmd.SkipFlagParsing = false
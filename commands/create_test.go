@@ -1,10 +1,19 @@
 package commands
 
 import (
+	"errors"
+	"os"
 	"testing"
 
 	"flag"
+	"github.com/codegangsta/cli"
 	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/errdriver"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/crashreport"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/mcnerror"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/stretchr/testify/assert"
 )
@@ -117,3 +126,183 @@ func TestGetDriverOpts(t *testing.T) {
 		assert.Equal(t, tt.expected["stringslice_defaulted"], driverOpts.StringSlice("stringslice_defaulted"))
 	}
 }
+
+func TestRollbackCreate(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "broken",
+				Driver: &fakedriver.Driver{},
+			},
+			{
+				Name:   "machine",
+				Driver: &fakedriver.Driver{},
+			},
+		},
+	}
+
+	err := rollbackCreate(api, "broken")
+
+	assert.NoError(t, err)
+	assert.False(t, libmachinetest.Exists(api, "broken"))
+	assert.True(t, libmachinetest.Exists(api, "machine"))
+}
+
+func TestRollbackCreateKeepsStoreEntryIfRemoveFails(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "broken",
+				Driver: &errdriver.Driver{Name: "broken"},
+			},
+		},
+	}
+
+	err := rollbackCreate(api, "broken")
+
+	assert.Error(t, err)
+	assert.True(t, libmachinetest.Exists(api, "broken"))
+}
+
+func TestResumeCreateRefusesCreatedMachine(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machine",
+				Driver: &fakedriver.Driver{},
+			},
+		},
+	}
+
+	err := resumeCreate(&commandstest.FakeCommandLine{}, api, "machine")
+
+	assert.EqualError(t, err, `Machine "machine" is already created, there is nothing to resume`)
+}
+
+func TestResumeCreate(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machine",
+				Driver: &fakedriver.Driver{},
+				CompletedStages: []host.CreateStage{
+					host.StageCertificates,
+					host.StagePreCreateCheck,
+					host.StageDriverCreate,
+				},
+			},
+		},
+	}
+
+	err := resumeCreate(&commandstest.FakeCommandLine{}, api, "machine")
+
+	assert.NoError(t, err)
+}
+
+func TestResumeCreateRefusesAtDriverCreate(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machine",
+				Driver: &fakedriver.Driver{},
+				CompletedStages: []host.CreateStage{
+					host.StageCertificates,
+					host.StagePreCreateCheck,
+				},
+			},
+		},
+	}
+
+	err := resumeCreate(&commandstest.FakeCommandLine{}, api, "machine")
+
+	assert.Contains(t, err.Error(), "can't be resumed safely")
+}
+
+func TestFlagHackIsSet(t *testing.T) {
+	defer func(args []string) { os.Args = args }(os.Args)
+
+	var tests = []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{"docker-machine", "create", "--resume", "foo"}, true},
+		{[]string{"docker-machine", "create", "-resume", "foo"}, true},
+		{[]string{"docker-machine", "create", "--resume=1", "foo"}, true},
+		{[]string{"docker-machine", "create", "--resume=false", "foo"}, false},
+		{[]string{"docker-machine", "create", "--resumed", "foo"}, false},
+		{[]string{"docker-machine", "create", "resume"}, false},
+	}
+
+	for _, test := range tests {
+		os.Args = test.args
+		assert.Equal(t, test.expected, flagHackIsSet("--resume"), "%v", test.args)
+	}
+}
+
+func TestCreateHostRollsBackAllocatedMachine(t *testing.T) {
+	h := &host.Host{
+		Name:   "broken",
+		Driver: &fakedriver.Driver{},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts:       []*host.Host{h},
+		CreateError: mcnerror.ErrDuringCreate{Cause: errors.New("provisioning failed"), Allocated: true},
+	}
+
+	err := createHost(&commandstest.FakeCommandLine{}, api, h)
+
+	assert.IsType(t, crashreport.CrashError{}, err)
+	assert.False(t, libmachinetest.Exists(api, "broken"))
+}
+
+func TestCreateHostKeepsMachineOnFailureWhenAsked(t *testing.T) {
+	h := &host.Host{
+		Name:   "broken",
+		Driver: &fakedriver.Driver{},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts:       []*host.Host{h},
+		CreateError: mcnerror.ErrDuringCreate{Cause: errors.New("provisioning failed"), Allocated: true},
+	}
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"keep-on-failure": true,
+			},
+		},
+	}
+
+	err := createHost(commandLine, api, h)
+
+	assert.IsType(t, crashreport.CrashError{}, err)
+	assert.True(t, libmachinetest.Exists(api, "broken"))
+}
+
+func TestCreateHostDoesNotRollBackUnallocatedMachine(t *testing.T) {
+	h := &host.Host{
+		Name:   "broken",
+		Driver: &fakedriver.Driver{},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts:       []*host.Host{h},
+		CreateError: mcnerror.ErrDuringCreate{Cause: errors.New("quota exceeded")},
+	}
+
+	err := createHost(&commandstest.FakeCommandLine{}, api, h)
+
+	assert.IsType(t, crashreport.CrashError{}, err)
+	assert.True(t, libmachinetest.Exists(api, "broken"))
+}
+
+func TestConvertMcnFlagsToCliFlagsWithMetadata(t *testing.T) {
+	cliFlags, err := convertMcnFlagsToCliFlags([]mcnflag.Flag{
+		&mcnflag.StringFlag{Name: "foo-size", Usage: "Size", Enum: []string{"small", "large"}, DeprecatedNames: []string{"foo-flavor"}},
+		&mcnflag.IntFlag{Name: "foo-cpus", Usage: "CPUs", Value: 1, Min: mcnflag.Limit(1)},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []cli.Flag{
+		cli.StringFlag{Name: "foo-size, foo-flavor", Usage: "Size (one of small, large; --foo-flavor is deprecated)"},
+		cli.IntFlag{Name: "foo-cpus", Usage: "CPUs (at least 1)", Value: 1},
+	}, cliFlags)
+}
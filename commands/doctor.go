@@ -0,0 +1,310 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/lock"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+// The problems found by doctor.
+const (
+	problemOrphanedDirectory = "orphaned-directory"
+	problemDanglingBackup    = "dangling-backup"
+	problemCorruptConfig     = "corrupt-config"
+	problemMissingSSHKey     = "missing-ssh-key"
+	problemMissingCACert     = "missing-ca-cert"
+	problemInvalidServerCert = "invalid-server-cert"
+	problemOrphanedPlugin    = "orphaned-plugin"
+)
+
+// doctorProblem is a problem found in the directory of a machine.
+type doctorProblem struct {
+	Name     string
+	Problem  string
+	Details  string
+	Fixable  bool
+	Fixed    bool   `json:",omitempty"`
+	FixError string `json:",omitempty"`
+
+	fix func() error
+}
+
+func cmdDoctor(c CommandLine, api libmachine.API) error {
+	format := c.String("format")
+	if format == "" {
+		format = "table"
+	}
+	if format != "table" && format != "json" {
+		return fmt.Errorf("Unknown format %q, use table or json", format)
+	}
+
+	// The machines of the other stores aren't kept in their directory, all
+	// of them would look orphaned.
+	if storeURL := c.GlobalString("store-url"); storeURL != "" && !strings.HasPrefix(storeURL, "file:") {
+		return errors.New("doctor only checks the machines kept in the storage path")
+	}
+
+	store, err := openStore(c.GlobalString("store-url"))
+	if err != nil {
+		return err
+	}
+	fs := store.(*persist.Filestore)
+
+	names := []string(c.Args())
+	if len(names) == 0 {
+		names, err = fs.List()
+		if err != nil {
+			return err
+		}
+	}
+
+	problems := []*doctorProblem{}
+	for _, name := range names {
+		problems = append(problems, checkMachine(fs, api, c, name)...)
+	}
+
+	// The plugins aren't tied to a machine, they are only checked along
+	// with all the machines.
+	if len(c.Args()) == 0 {
+		orphans, err := checkPlugins()
+		if err != nil {
+			return err
+		}
+		problems = append(problems, orphans...)
+	}
+
+	if c.Bool("fix") {
+		fixProblems(problems)
+	}
+
+	if format == "json" {
+		data, err := json.MarshalIndent(problems, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	} else if len(problems) == 0 {
+		log.Info("No problem found")
+	} else {
+		printProblems(os.Stdout, problems, c.Bool("fix"))
+	}
+
+	left := 0
+	for _, p := range problems {
+		if !p.Fixed {
+			left++
+		}
+	}
+	if left > 0 {
+		return fmt.Errorf("Found %d problem(s) to fix", left)
+	}
+
+	return nil
+}
+
+// checkMachine returns the problems of the named machine.
+func checkMachine(fs *persist.Filestore, api libmachine.API, c CommandLine, name string) []*doctorProblem {
+	dir := filepath.Join(fs.GetMachinesDir(), name)
+	configPath := filepath.Join(dir, "config.json")
+	backupPath := filepath.Join(dir, "config.json.bak")
+
+	problem := func(kind, details string, fix func() error) []*doctorProblem {
+		return []*doctorProblem{{
+			Name:    name,
+			Problem: kind,
+			Details: details,
+			Fixable: fix != nil,
+			fix:     fix,
+		}}
+	}
+
+	// The files of the machine are only changed while holding its lock,
+	// so that a machine being created isn't taken for an orphan.
+	locked := func(fix func() error) func() error {
+		return func() error {
+			l, err := lock.Acquire(c.Ctx(), name, dir, "doctor", false)
+			if err != nil {
+				return err
+			}
+			defer l.Release()
+
+			return fix()
+		}
+	}
+
+	backup, err := ioutil.ReadFile(backupPath)
+	hasBackup := err == nil && validConfig(fs, name, backup)
+
+	restoreBackup := func() error {
+		return ioutil.WriteFile(configPath, backup, 0600)
+	}
+
+	data, err := ioutil.ReadFile(configPath)
+	if os.IsNotExist(err) {
+		if hasBackup {
+			return problem(problemDanglingBackup, "config.json is missing but config.json.bak is left from a migration", locked(restoreBackup))
+		}
+		return problem(problemOrphanedDirectory, "The directory has no config.json", locked(func() error {
+			return os.RemoveAll(dir)
+		}))
+	}
+	if err != nil {
+		return problem(problemCorruptConfig, err.Error(), nil)
+	}
+
+	h, _, err := host.MigrateHost(&host.Host{Name: name}, data, fs.Path)
+	if err != nil {
+		if !hasBackup {
+			return problem(problemCorruptConfig, err.Error(), nil)
+		}
+		return problem(problemCorruptConfig, err.Error(), locked(func() error {
+			// The broken config is kept for a closer look.
+			if err := os.Rename(configPath, configPath+".corrupt"); err != nil {
+				return err
+			}
+			return restoreBackup()
+		}))
+	}
+
+	// Partly created machines don't have all their files yet, and the
+	// machines of the none driver are not provisioned.
+	if _, pending := h.PendingCreateStage(); pending || h.DriverName == "none" {
+		return nil
+	}
+
+	problems := []*doctorProblem{}
+
+	sshKeyPath := driverSSHKeyPath(h.RawDriver)
+	if sshKeyPath == "" {
+		sshKeyPath = filepath.Join(dir, "id_rsa")
+	}
+	if _, err := os.Stat(sshKeyPath); err != nil {
+		problems = append(problems, problem(problemMissingSSHKey, err.Error(), nil)...)
+	}
+
+	authOptions := h.AuthOptions()
+	if authOptions == nil {
+		return problems
+	}
+
+	if _, err := os.Stat(authOptions.CaCertPath); err != nil {
+		return append(problems, problem(problemMissingCACert, err.Error(), nil)...)
+	}
+
+	if err := cert.VerifyCertificate(authOptions.ServerCertPath, authOptions.CaCertPath); err != nil {
+		problems = append(problems, problem(problemInvalidServerCert, err.Error(), func() error {
+			h, err := api.Load(name)
+			if err != nil {
+				return err
+			}
+			return runMachineAction(c.Ctx(), api, "configureAuth", h)
+		})...)
+	}
+
+	return problems
+}
+
+// checkPlugins returns the plugin processes left running by a client which
+// exited, named after their driver.
+func checkPlugins() ([]*doctorProblem, error) {
+	orphans, err := localbinary.OrphanedPlugins()
+	if err != nil {
+		return nil, err
+	}
+
+	problems := []*doctorProblem{}
+	for _, orphan := range orphans {
+		problems = append(problems, &doctorProblem{
+			Name:    orphan.DriverName,
+			Problem: problemOrphanedPlugin,
+			Details: fmt.Sprintf("Process %d running %s since %s, launched by process %d which exited", orphan.PID, orphan.BinaryPath, orphan.Started.Local().Format(time.RFC3339), orphan.ClientPID),
+			Fixable: true,
+			fix:     orphan.Kill,
+		})
+	}
+
+	return problems, nil
+}
+
+func validConfig(fs *persist.Filestore, name string, data []byte) bool {
+	_, _, err := host.MigrateHost(&host.Host{Name: name}, data, fs.Path)
+	return err == nil
+}
+
+// driverSSHKeyPath returns the SSH key path of the driver configuration, as
+// set by drivers.BaseDriver.
+func driverSSHKeyPath(rawDriver []byte) string {
+	var driver struct {
+		SSHKeyPath string
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(rawDriver)).Decode(&driver); err != nil {
+		return ""
+	}
+
+	return driver.SSHKeyPath
+}
+
+func fixProblems(problems []*doctorProblem) {
+	for _, p := range problems {
+		if p.fix == nil {
+			continue
+		}
+
+		log.Infof("Fixing %s of %q...", p.Problem, p.Name)
+
+		if err := p.fix(); err != nil {
+			p.FixError = err.Error()
+			continue
+		}
+		p.Fixed = true
+	}
+}
+
+func printProblems(w io.Writer, problems []*doctorProblem, fix bool) {
+	tabWriter := tabwriter.NewWriter(w, 5, 1, 3, ' ', 0)
+	defer tabWriter.Flush()
+
+	if fix {
+		fmt.Fprintln(tabWriter, "NAME\tPROBLEM\tFIXABLE\tRESULT\tDETAILS")
+	} else {
+		fmt.Fprintln(tabWriter, "NAME\tPROBLEM\tFIXABLE\tDETAILS")
+	}
+
+	for _, p := range problems {
+		fixable := "no"
+		if p.Fixable {
+			fixable = "yes"
+		}
+
+		if !fix {
+			fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\n", p.Name, p.Problem, fixable, p.Details)
+			continue
+		}
+
+		result := "-"
+		switch {
+		case p.Fixed:
+			result = "fixed"
+		case p.FixError != "":
+			result = "failed: " + p.FixError
+		}
+		fmt.Fprintf(tabWriter, "%s\t%s\t%s\t%s\t%s\n", p.Name, p.Problem, fixable, result, p.Details)
+	}
+}
@@ -0,0 +1,92 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/stretchr/testify/assert"
+)
+
+func problemKinds(problems []*doctorProblem) []string {
+	kinds := []string{}
+	for _, p := range problems {
+		kinds = append(kinds, p.Problem)
+	}
+	return kinds
+}
+
+func TestDoctor(t *testing.T) {
+	defer func(baseDir string) { mcndirs.BaseDir = baseDir }(mcndirs.BaseDir)
+
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	mcndirs.BaseDir = tmpDir
+
+	fs := persist.NewFilestore(tmpDir, "", "")
+
+	h, err := hosttest.GetDefaultTestHost()
+	assert.NoError(t, err)
+	h.Name = "healthy"
+	assert.NoError(t, fs.Save(h))
+
+	config, err := ioutil.ReadFile(filepath.Join(fs.GetMachinesDir(), "healthy", "config.json"))
+	assert.NoError(t, err)
+
+	writeFile := func(machine, name string, data []byte) {
+		dir := filepath.Join(fs.GetMachinesDir(), machine)
+		assert.NoError(t, os.MkdirAll(dir, 0700))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), data, 0600))
+	}
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(fs.GetMachinesDir(), "orphan"), 0700))
+	writeFile("dangling", "config.json.bak", config)
+	writeFile("corrupt", "config.json", config[:len(config)/2])
+	writeFile("corrupt", "config.json.bak", config)
+	writeFile("broken", "config.json", config[:len(config)/2])
+
+	h.Name = "nokey"
+	h.DriverName = "virtualbox"
+	h.HostOptions.AuthOptions.CaCertPath = filepath.Join(tmpDir, "certs", "ca.pem")
+	assert.NoError(t, fs.Save(h))
+
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags:  &commandstest.FakeFlagger{Data: map[string]interface{}{"format": "json"}},
+		GlobalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+	}
+	api := &libmachinetest.FakeAPI{}
+
+	assert.Empty(t, checkMachine(fs, api, commandLine, "healthy"))
+	assert.Equal(t, []string{problemOrphanedDirectory}, problemKinds(checkMachine(fs, api, commandLine, "orphan")))
+	assert.Equal(t, []string{problemDanglingBackup}, problemKinds(checkMachine(fs, api, commandLine, "dangling")))
+	assert.Equal(t, []string{problemCorruptConfig}, problemKinds(checkMachine(fs, api, commandLine, "corrupt")))
+	assert.Equal(t, []string{problemMissingSSHKey, problemMissingCACert}, problemKinds(checkMachine(fs, api, commandLine, "nokey")))
+
+	broken := checkMachine(fs, api, commandLine, "broken")
+	assert.Equal(t, []string{problemCorruptConfig}, problemKinds(broken))
+	assert.False(t, broken[0].Fixable)
+
+	commandLine.CliArgs = []string{"orphan", "dangling", "corrupt"}
+	commandLine.LocalFlags.Data["fix"] = true
+	assert.NoError(t, cmdDoctor(commandLine, api))
+
+	_, err = os.Stat(filepath.Join(fs.GetMachinesDir(), "orphan"))
+	assert.True(t, os.IsNotExist(err))
+
+	for _, name := range []string{"dangling", "corrupt"} {
+		_, err := fs.Load(name)
+		assert.NoError(t, err)
+	}
+	_, err = os.Stat(filepath.Join(fs.GetMachinesDir(), "corrupt", "config.json.corrupt"))
+	assert.NoError(t, err)
+
+	commandLine.CliArgs = nil
+	assert.EqualError(t, cmdDoctor(commandLine, api), "Found 3 problem(s) to fix")
+}
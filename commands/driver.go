@@ -0,0 +1,507 @@
+package commands
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/version"
+	machineversion "github.com/docker/machine/version"
+)
+
+const (
+	driverDefaultFormat = "table {{ .Name }}\t{{ .Type }}\t{{ .Version }}\t{{ .APIVersion }}\t{{ .Path }}\t{{ .Error }}"
+
+	// driverLookupMachineName is the name of the machine the drivers are
+	// launched for when they are listed or inspected.
+	driverLookupMachineName = "driver-lookup"
+
+	driverTypeCore   = "core"
+	driverTypePlugin = "plugin"
+)
+
+var (
+	driverHeaders = map[string]string{
+		"Name":       "NAME",
+		"Type":       "TYPE",
+		"Version":    "VERSION",
+		"APIVersion": "API",
+		"Path":       "PATH",
+		"Error":      "ERROR",
+	}
+
+	errNoDriverChecksum = errors.New("Error: Expected the SHA-256 checksum of the driver with --sha256")
+)
+
+// DriverItem is a driver as listed by 'driver ls'.
+type DriverItem struct {
+	Name       string
+	Type       string
+	Version    string
+	APIVersion string
+	Path       string
+	Error      string
+}
+
+// inspectedDriver is a driver as shown by 'driver inspect'.
+type inspectedDriver struct {
+	DriverItem
+	Capabilities []drivers.Capability
+	Flags        []mcnflag.Metadata
+}
+
+// driverPlugin is a plugin binary found in the plugin directory or in the
+// PATH.
+type driverPlugin struct {
+	name string
+	path string
+}
+
+func cmdDriverLs(c CommandLine, api libmachine.API) error {
+	items := []DriverItem{}
+	for _, name := range localbinary.CoreDrivers {
+		items = append(items, coreDriverItem(name))
+	}
+
+	for _, plugin := range findDriverPlugins(driverSearchPath()) {
+		items = append(items, pluginDriverItem(api, plugin))
+	}
+
+	return printDrivers(os.Stdout, items, c.String("format"))
+}
+
+func cmdDriverInspect(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		c.ShowHelp()
+		return errors.New("Error: Expected one driver name as an argument")
+	}
+
+	name := c.Args().First()
+
+	var item DriverItem
+	if localbinary.IsCoreDriver(name) {
+		item = coreDriverItem(name)
+	} else {
+		path, err := localbinary.FindDriver(name)
+		if err != nil {
+			return err
+		}
+		item = pluginBinaryItem(driverPlugin{name: name, path: path})
+	}
+
+	driver, err := openDriver(api, name)
+	if err != nil {
+		return err
+	}
+
+	if v, ok := driver.(apiVersioner); ok {
+		item.APIVersion = strconv.Itoa(v.APIVersion())
+	}
+
+	prettyJSON, err := json.MarshalIndent(inspectedDriver{
+		DriverItem:   item,
+		Capabilities: drivers.GetCapabilities(driver),
+		Flags:        newDriverFlags(driver.GetCreateFlags()),
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(prettyJSON))
+
+	return nil
+}
+
+func cmdDriverInstall(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		c.ShowHelp()
+		return errors.New("Error: Expected the path of a driver binary or archive as an argument")
+	}
+
+	checksum := c.String("sha256")
+	if checksum == "" {
+		return errNoDriverChecksum
+	}
+
+	path, err := installDriver(c.Args().First(), checksum, localbinary.PluginDir())
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(path)
+
+	return nil
+}
+
+// apiVersioner is implemented by the drivers launched as plugins, which
+// tell the version of the protocol they speak.
+type apiVersioner interface {
+	APIVersion() int
+}
+
+// openDriver launches the driver, without a machine to drive.
+func openDriver(api libmachine.API, name string) (drivers.Driver, error) {
+	// TODO: Fix hacky JSON solution
+	rawDriver, err := json.Marshal(&drivers.BaseDriver{
+		MachineName: driverLookupMachineName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Error attempting to marshal bare driver data: %s", err)
+	}
+
+	h, err := api.NewHost(name, rawDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Driver, nil
+}
+
+func coreDriverItem(name string) DriverItem {
+	item := DriverItem{
+		Name:       name,
+		Type:       driverTypeCore,
+		Version:    machineversion.Version,
+		APIVersion: strconv.Itoa(version.APIVersion),
+	}
+
+	path, err := localbinary.FindDriver(name)
+	if err != nil {
+		item.Error = err.Error()
+	} else {
+		item.Path = path
+	}
+
+	return item
+}
+
+// pluginBinaryItem returns the plugin as listed, with the version of the
+// module it was built from when known.
+func pluginBinaryItem(plugin driverPlugin) DriverItem {
+	item := DriverItem{
+		Name:    plugin.name,
+		Type:    driverTypePlugin,
+		Version: "unknown",
+		Path:    plugin.path,
+	}
+
+	if info, err := buildinfo.ReadFile(plugin.path); err == nil && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		item.Version = info.Main.Version
+	}
+
+	return item
+}
+
+// pluginDriverItem launches the plugin to get the version of the protocol
+// it speaks.
+func pluginDriverItem(api libmachine.API, plugin driverPlugin) DriverItem {
+	item := pluginBinaryItem(plugin)
+
+	driver, err := openDriver(api, plugin.name)
+	if err != nil {
+		item.Error = err.Error()
+		return item
+	}
+
+	if v, ok := driver.(apiVersioner); ok {
+		item.APIVersion = strconv.Itoa(v.APIVersion())
+	}
+
+	return item
+}
+
+// driverSearchPath returns the directories searched for the driver
+// plugins, in order.
+func driverSearchPath() []string {
+	dirs := []string{}
+	if dir := localbinary.PluginDir(); dir != "" {
+		dirs = append(dirs, dir)
+	}
+
+	return append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+}
+
+// findDriverPlugins returns the plugin binaries in the directories, sorted
+// by name. The first one found for a driver wins, as when it is launched.
+// The binaries named after a core driver are skipped, since they are never
+// launched.
+func findDriverPlugins(dirs []string) []driverPlugin {
+	seen := map[string]bool{}
+	plugins := []driverPlugin{}
+
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, fi := range files {
+			name, ok := pluginDriverName(fi.Name())
+			if !ok || fi.IsDir() || seen[name] || localbinary.IsCoreDriver(name) {
+				continue
+			}
+
+			if runtime.GOOS != "windows" && fi.Mode()&0111 == 0 {
+				continue
+			}
+
+			seen[name] = true
+			plugins = append(plugins, driverPlugin{
+				name: name,
+				path: filepath.Join(dir, fi.Name()),
+			})
+		}
+	}
+
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].name < plugins[j].name
+	})
+
+	return plugins
+}
+
+// pluginDriverName returns the name of the driver served by the plugin
+// binary with the given file name.
+func pluginDriverName(fileName string) (string, bool) {
+	if !strings.HasPrefix(fileName, localbinary.BinaryPrefix) {
+		return "", false
+	}
+
+	name := strings.TrimPrefix(fileName, localbinary.BinaryPrefix)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, ".exe")
+	}
+
+	return name, name != ""
+}
+
+func printDrivers(out io.Writer, items []DriverItem, format string) error {
+	if format == "" {
+		format = driverDefaultFormat
+	}
+
+	template, table, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+
+	w := out
+	if table {
+		tabWriter := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+		defer tabWriter.Flush()
+
+		w = tabWriter
+
+		if err := template.Execute(w, driverHeaders); err != nil {
+			return err
+		}
+	}
+
+	for _, item := range items {
+		if err := template.Execute(w, item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newDriverFlags(mcnFlags []mcnflag.Flag) []mcnflag.Metadata {
+	flags := []mcnflag.Metadata{}
+	for _, f := range mcnFlags {
+		flags = append(flags, mcnflag.MetadataOf(f))
+	}
+
+	return flags
+}
+
+// installDriver copies the plugin binary, or the one in the archive at path,
+// to dir once its checksum is verified, and returns where it was installed.
+func installDriver(path, checksum, dir string) (string, error) {
+	if err := verifyChecksum(path, checksum); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	tmp, err := ioutil.TempFile(dir, ".install.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	fileName, err := copyDriverBinary(tmp, path)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	name, ok := pluginDriverName(fileName)
+	if !ok {
+		return "", fmt.Errorf("The name of the driver binary %q doesn't start with %q", fileName, localbinary.BinaryPrefix)
+	}
+	if localbinary.IsCoreDriver(name) {
+		return "", fmt.Errorf("The %q driver is a core driver, it can't be replaced by a plugin", name)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(dir, localbinary.BinaryName(name))
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return "", err
+	}
+
+	return dest, nil
+}
+
+func verifyChecksum(path, checksum string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return fmt.Errorf("Error reading %s: %s", path, err)
+	}
+
+	if actual := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(actual, strings.TrimSpace(checksum)) {
+		return fmt.Errorf("The SHA-256 checksum of %s is %s, expected %s", path, actual, checksum)
+	}
+
+	return nil
+}
+
+// copyDriverBinary copies the plugin binary at path, or the only one in the
+// archive at path, to w and returns its file name.
+func copyDriverBinary(w io.Writer, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	switch {
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return copyTarGzDriverBinary(w, f, path)
+	case strings.HasSuffix(path, ".zip"):
+		fi, err := f.Stat()
+		if err != nil {
+			return "", err
+		}
+		return copyZipDriverBinary(w, f, fi.Size(), path)
+	}
+
+	if _, err := io.Copy(w, f); err != nil {
+		return "", fmt.Errorf("Error copying the driver binary: %s", err)
+	}
+
+	return filepath.Base(path), nil
+}
+
+func copyTarGzDriverBinary(w io.Writer, r io.Reader, path string) (string, error) {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return "", fmt.Errorf("Error reading %s: %s", path, err)
+	}
+
+	names := []string{}
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("Error reading %s: %s", path, err)
+		}
+
+		if header.Typeflag != tar.TypeReg || !isDriverBinary(header.Name) {
+			continue
+		}
+
+		names = append(names, header.Name)
+		if len(names) == 1 {
+			if _, err := io.Copy(w, tr); err != nil {
+				return "", fmt.Errorf("Error extracting %s from %s: %s", header.Name, path, err)
+			}
+		}
+	}
+
+	return onlyDriverBinary(names, path)
+}
+
+func copyZipDriverBinary(w io.Writer, r io.ReaderAt, size int64, path string) (string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", fmt.Errorf("Error reading %s: %s", path, err)
+	}
+
+	var binary *zip.File
+	names := []string{}
+	for _, f := range zr.File {
+		if f.Mode().IsRegular() && isDriverBinary(f.Name) {
+			names = append(names, f.Name)
+			binary = f
+		}
+	}
+
+	if _, err := onlyDriverBinary(names, path); err != nil {
+		return "", err
+	}
+
+	rc, err := binary.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	if _, err := io.Copy(w, rc); err != nil {
+		return "", fmt.Errorf("Error extracting %s from %s: %s", binary.Name, path, err)
+	}
+
+	return filepath.Base(binary.Name), nil
+}
+
+func isDriverBinary(name string) bool {
+	return strings.HasPrefix(filepath.Base(name), localbinary.BinaryPrefix)
+}
+
+// onlyDriverBinary returns the file name of the only driver binary of an
+// archive.
+func onlyDriverBinary(names []string, path string) (string, error) {
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("No driver binary named %s* in %s", localbinary.BinaryPrefix, path)
+	case 1:
+		return filepath.Base(names[0]), nil
+	default:
+		return "", fmt.Errorf("Several driver binaries in %s: %s", path, strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func checksumOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFindDriverPlugins(t *testing.T) {
+	first, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(first)
+
+	second, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(second)
+
+	for _, path := range []string{
+		filepath.Join(first, localbinary.BinaryName("foo")),
+		filepath.Join(second, localbinary.BinaryName("foo")),
+		filepath.Join(second, localbinary.BinaryName("bar")),
+		filepath.Join(second, localbinary.BinaryName("virtualbox")),
+		filepath.Join(second, "docker-machine"),
+	} {
+		assert.NoError(t, ioutil.WriteFile(path, []byte{}, 0755))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(first, localbinary.BinaryName("notexecutable")), []byte{}, 0644))
+
+	plugins := findDriverPlugins([]string{first, filepath.Join(first, "missing"), second})
+
+	assert.Equal(t, []driverPlugin{
+		{name: "bar", path: filepath.Join(second, localbinary.BinaryName("bar"))},
+		{name: "foo", path: filepath.Join(first, localbinary.BinaryName("foo"))},
+	}, plugins)
+}
+
+func TestPrintDrivers(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	err := printDrivers(out, []DriverItem{
+		{Name: "virtualbox", Type: driverTypeCore, Version: "1.0", APIVersion: "2", Path: "/usr/bin/docker-machine"},
+		{Name: "foo", Type: driverTypePlugin, Version: "unknown", Path: "/usr/bin/docker-machine-driver-foo", Error: "boom"},
+	}, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "NAME         TYPE     VERSION   API   PATH                                 ERROR\n"+
+		"virtualbox   core     1.0       2     /usr/bin/docker-machine              \n"+
+		"foo          plugin   unknown         /usr/bin/docker-machine-driver-foo   boom\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, printDrivers(out, []DriverItem{{Name: "foo", Type: driverTypePlugin}}, "{{ .Name }} {{ .Type }}"))
+	assert.Equal(t, "foo plugin\n", out.String())
+}
+
+func TestNewDriverFlags(t *testing.T) {
+	flags := newDriverFlags([]mcnflag.Flag{
+		&mcnflag.StringFlag{Name: "foo-token", EnvVar: "FOO_TOKEN", Usage: "Token", Sensitive: true, Required: true},
+		mcnflag.IntFlag{Name: "foo-cpus", Usage: "CPUs", Value: 2, Min: mcnflag.Limit(1)},
+		&mcnflag.BoolFlag{Name: "foo-debug", Usage: "Debug", DeprecatedNames: []string{"foo-verbose"}},
+		&mcnflag.StringSliceFlag{Name: "foo-tags", Usage: "Tags", Value: []string{"a"}, Pattern: "[a-z]+"},
+	})
+
+	assert.Equal(t, []mcnflag.Metadata{
+		{Name: "foo-token", Type: "string", Default: "", EnvVar: "FOO_TOKEN", Usage: "Token", Sensitive: true, Required: true},
+		{Name: "foo-cpus", Type: "int", Default: 2, Usage: "CPUs", Min: mcnflag.Limit(1)},
+		{Name: "foo-debug", Type: "bool", Default: false, Usage: "Debug", DeprecatedNames: []string{"foo-verbose"}},
+		{Name: "foo-tags", Type: "string-slice", Default: []string{"a"}, Usage: "Tags", Pattern: "[a-z]+"},
+	}, flags)
+}
+
+func TestInstallDriver(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	binary := []byte("#!/bin/sh\n")
+	src := filepath.Join(tmpDir, localbinary.BinaryName("foo"))
+	assert.NoError(t, ioutil.WriteFile(src, binary, 0644))
+
+	driversDir := filepath.Join(tmpDir, "drivers")
+
+	_, err = installDriver(src, checksumOf([]byte("other")), driversDir)
+	assert.Contains(t, err.Error(), "The SHA-256 checksum of")
+	_, err = os.Stat(driversDir)
+	assert.True(t, os.IsNotExist(err))
+
+	path, err := installDriver(src, checksumOf(binary), driversDir)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(driversDir, localbinary.BinaryName("foo")), path)
+
+	installed, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, binary, installed)
+
+	fi, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), fi.Mode().Perm())
+
+	files, err := ioutil.ReadDir(driversDir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestInstallDriverRefusesCoreDrivers(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	binary := []byte("#!/bin/sh\n")
+	src := filepath.Join(tmpDir, localbinary.BinaryName("virtualbox"))
+	assert.NoError(t, ioutil.WriteFile(src, binary, 0755))
+
+	_, err = installDriver(src, checksumOf(binary), tmpDir)
+	assert.EqualError(t, err, `The "virtualbox" driver is a core driver, it can't be replaced by a plugin`)
+
+	src = filepath.Join(tmpDir, "foo")
+	assert.NoError(t, ioutil.WriteFile(src, binary, 0755))
+
+	_, err = installDriver(src, checksumOf(binary), tmpDir)
+	assert.EqualError(t, err, `The name of the driver binary "foo" doesn't start with "docker-machine-driver-"`)
+}
+
+func TestInstallDriverFromTarGz(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	binary := []byte("#!/bin/sh\n")
+
+	archive := &bytes.Buffer{}
+	gzw := gzip.NewWriter(archive)
+	tw := tar.NewWriter(gzw)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{
+		{"foo/README.md", []byte("Foo")},
+		{"foo/docker-machine-driver-foo", binary},
+	} {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0755, Size: int64(len(f.data)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write(f.data)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gzw.Close())
+
+	src := filepath.Join(tmpDir, "foo.tar.gz")
+	assert.NoError(t, ioutil.WriteFile(src, archive.Bytes(), 0644))
+
+	path, err := installDriver(src, checksumOf(archive.Bytes()), filepath.Join(tmpDir, "drivers"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(tmpDir, "drivers", localbinary.BinaryName("foo")), path)
+
+	installed, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, binary, installed)
+}
+
+func TestInstallDriverFromZip(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	archive := &bytes.Buffer{}
+	zw := zip.NewWriter(archive)
+	for _, name := range []string{"docker-machine-driver-foo", "docker-machine-driver-bar"} {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte("#!/bin/sh\n"))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+
+	src := filepath.Join(tmpDir, "drivers.zip")
+	assert.NoError(t, ioutil.WriteFile(src, archive.Bytes(), 0644))
+
+	_, err = installDriver(src, checksumOf(archive.Bytes()), filepath.Join(tmpDir, "drivers"))
+	assert.EqualError(t, err, "Several driver binaries in "+src+": docker-machine-driver-foo, docker-machine-driver-bar")
+}
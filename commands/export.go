@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"errors"
+	"os"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/bundle"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+func cmdExport(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		c.ShowHelp()
+		return ErrExpectedOneMachine
+	}
+
+	name := c.Args().First()
+
+	// The machine is exported as it is stored, its driver plugin isn't
+	// needed.
+	store, err := openStore(c.GlobalString("store-url"))
+	if err != nil {
+		return err
+	}
+
+	h, err := store.Load(name)
+	if err != nil {
+		return err
+	}
+
+	b, err := bundle.New(h, mcndirs.GetBaseDir())
+	if err != nil {
+		return err
+	}
+
+	output := c.String("output")
+	if output == "" {
+		output = name + ".tar.gz"
+	}
+
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := b.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	log.Infof("Exported %q to %s", name, output)
+	log.Warn("The bundle holds the keys of the machine and of its CA, and the credentials of its driver. Keep it safe")
+
+	return nil
+}
+
+func cmdImport(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		c.ShowHelp()
+		return errors.New("Error: Expected the path of the bundle as an argument")
+	}
+
+	f, err := os.Open(c.Args().First())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	b, err := bundle.Read(f)
+	if err != nil {
+		return err
+	}
+
+	name := c.String("name")
+	if name == "" {
+		name = b.Name
+	}
+	if !host.ValidateHostName(name) {
+		return mcnerror.ErrInvalidHostname
+	}
+
+	store, err := openStore(c.GlobalString("store-url"))
+	if err != nil {
+		return err
+	}
+
+	storePath := mcndirs.GetBaseDir()
+	machineDir := bundle.MachineDir(storePath, name)
+
+	exists, err := store.Exists(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(machineDir); exists || err == nil {
+		return mcnerror.ErrHostAlreadyExists{
+			Name: name,
+		}
+	}
+
+	h, err := b.Host(storePath, name)
+	if err != nil {
+		return err
+	}
+
+	if err := b.WriteFiles(storePath, name); err != nil {
+		os.RemoveAll(machineDir)
+		return err
+	}
+
+	if err := store.Save(h); err != nil {
+		os.RemoveAll(machineDir)
+		return err
+	}
+
+	log.Infof("Imported %q", name)
+
+	return nil
+}
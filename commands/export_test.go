@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdExportImport(t *testing.T) {
+	defer func(baseDir string) { mcndirs.BaseDir = baseDir }(mcndirs.BaseDir)
+
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	mcndirs.BaseDir = tmpDir
+
+	certsDir := filepath.Join(tmpDir, "certs")
+	assert.NoError(t, os.MkdirAll(certsDir, 0700))
+	for _, name := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(certsDir, name), []byte(name), 0600))
+	}
+
+	h, err := hosttest.GetDefaultTestHost()
+	assert.NoError(t, err)
+	h.HostOptions.AuthOptions.CaCertPath = filepath.Join(certsDir, "ca.pem")
+	h.HostOptions.AuthOptions.ClientCertPath = filepath.Join(certsDir, "cert.pem")
+	h.HostOptions.AuthOptions.ClientKeyPath = filepath.Join(certsDir, "key.pem")
+
+	store := persist.NewFilestore(tmpDir, "", "")
+	assert.NoError(t, store.Save(h))
+
+	bundlePath := filepath.Join(tmpDir, "bundle.tar.gz")
+	err = cmdExport(&commandstest.FakeCommandLine{
+		CliArgs:     []string{h.Name},
+		LocalFlags:  &commandstest.FakeFlagger{Data: map[string]interface{}{"output": bundlePath}},
+		GlobalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+	}, &libmachinetest.FakeAPI{})
+	assert.NoError(t, err)
+
+	importCommandLine := func(name string) CommandLine {
+		return &commandstest.FakeCommandLine{
+			CliArgs:     []string{bundlePath},
+			LocalFlags:  &commandstest.FakeFlagger{Data: map[string]interface{}{"name": name}},
+			GlobalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+		}
+	}
+
+	err = cmdImport(importCommandLine(""), &libmachinetest.FakeAPI{})
+	assert.Equal(t, mcnerror.ErrHostAlreadyExists{Name: h.Name}, err)
+
+	err = cmdImport(importCommandLine("imported"), &libmachinetest.FakeAPI{})
+	assert.NoError(t, err)
+
+	imported, err := store.Load("imported")
+	assert.NoError(t, err)
+
+	importedCertsDir := filepath.Join(tmpDir, "machines", "imported", "certs")
+	assert.Equal(t, filepath.Join(importedCertsDir, "ca.pem"), imported.HostOptions.AuthOptions.CaCertPath)
+
+	ca, err := ioutil.ReadFile(imported.HostOptions.AuthOptions.CaCertPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "ca.pem", string(ca))
+}
@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/history"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const historyDefaultFormat = "table {{ .Time }}\t{{ .User }}\t{{ .Command }}\t{{ .Duration }}\t{{ .Outcome }}\t{{ .Details }}\t{{ .Error }}"
+
+var historyHeaders = map[string]string{
+	"Time":     "TIME",
+	"User":     "USER",
+	"Command":  "COMMAND",
+	"Duration": "DURATION",
+	"Outcome":  "OUTCOME",
+	"Details":  "DETAILS",
+	"Error":    "ERROR",
+}
+
+// HistoryItem is an entry of the history of a machine, as printed by the
+// history command.
+type HistoryItem struct {
+	Time     string
+	User     string
+	Command  string
+	Duration string
+	Outcome  string
+	Details  string
+	Error    string
+}
+
+func cmdHistory(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		c.ShowHelp()
+		return ErrExpectedOneMachine
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	exists, err := api.Exists(target)
+	if err != nil {
+		return fmt.Errorf("Error checking if host %q exists: %s", target, err)
+	}
+	if !exists {
+		return mcnerror.ErrHostDoesNotExist{Name: target}
+	}
+
+	entries, err := history.Read(historyDir(api, target))
+	if err != nil {
+		return err
+	}
+
+	return printHistory(os.Stdout, entries, c.String("format"))
+}
+
+func printHistory(out io.Writer, entries []*history.Entry, format string) error {
+	if format == "" {
+		format = historyDefaultFormat
+	}
+
+	template, table, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+
+	w := out
+	if table {
+		tabWriter := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+		defer tabWriter.Flush()
+
+		w = tabWriter
+
+		if err := template.Execute(w, historyHeaders); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		if err := template.Execute(w, newHistoryItem(entry)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newHistoryItem(entry *history.Entry) HistoryItem {
+	details := []string{}
+	for key, value := range entry.Details {
+		details = append(details, key+"="+value)
+	}
+	sort.Strings(details)
+
+	return HistoryItem{
+		Time:     entry.Time.Local().Format(time.RFC3339),
+		User:     entry.User,
+		Command:  entry.Command,
+		Duration: entry.Duration.String(),
+		Outcome:  entry.Outcome,
+		Details:  strings.Join(details, " "),
+		Error:    entry.Error,
+	}
+}
+
+func historyDir(api libmachine.API, name string) string {
+	return filepath.Join(api.GetMachinesDir(), name)
+}
+
+// recordHistory finishes the entry with the outcome of the command and
+// appends it to the history of the machine. The command isn't failed when
+// its history can't be written, e.g. once the machine is removed.
+func recordHistory(api libmachine.API, name string, entry *history.Entry, err error) {
+	entry.Finish(err)
+
+	if appendErr := history.Append(historyDir(api, name), entry); appendErr != nil {
+		log.Debugf("Error recording %s in the history of %q: %s", entry.Command, name, appendErr)
+	}
+}
+
+// recordDockerVersion records the version of Docker running on the machine
+// as a detail of the entry, when the machine is running.
+func recordDockerVersion(h *host.Host, entry *history.Entry, key string) {
+	if s, err := h.Driver.GetState(); err != nil || s != state.Running {
+		return
+	}
+
+	dockerVersion, err := h.DockerVersion()
+	if err != nil {
+		log.Debugf("Error getting the Docker version of %q: %s", h.Name, err)
+		return
+	}
+
+	entry.SetDetail(key, dockerVersion)
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/errdriver"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/history"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunMachineActionRecordsHistory(t *testing.T) {
+	machinesDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(machinesDir)
+
+	for _, name := range []string{"foo", "bar"} {
+		assert.NoError(t, os.Mkdir(filepath.Join(machinesDir, name), 0700))
+	}
+
+	foo := &host.Host{
+		Name:   "foo",
+		Driver: &fakedriver.Driver{MockState: state.Running},
+	}
+	bar := &host.Host{
+		Name:   "bar",
+		Driver: errdriver.NewDriver("bar"),
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts:       []*host.Host{foo, bar},
+		MachinesDir: machinesDir,
+	}
+
+	assert.NoError(t, runMachineAction(context.Background(), api, "ip", foo))
+	assert.NoError(t, runMachineAction(context.Background(), api, "stop", foo))
+	assert.Error(t, runMachineAction(context.Background(), api, "start", bar))
+
+	entries, err := history.Read(filepath.Join(machinesDir, "foo"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "stop", entries[0].Command)
+	assert.Equal(t, history.OutcomeSucceeded, entries[0].Outcome)
+
+	entries, err = history.Read(filepath.Join(machinesDir, "bar"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "start", entries[0].Command)
+	assert.Equal(t, history.OutcomeFailed, entries[0].Outcome)
+	assert.NotEmpty(t, entries[0].Error)
+}
+
+func TestPrintHistory(t *testing.T) {
+	entries := []*history.Entry{
+		{
+			Time:     time.Date(2016, 10, 1, 12, 0, 0, 0, time.UTC),
+			User:     "jdoe@laptop",
+			Command:  "upgrade",
+			Duration: 90 * time.Second,
+			Outcome:  history.OutcomeFailed,
+			Error:    "Connection refused",
+			Details:  map[string]string{"to": "1.12.1", "from": "1.12.0"},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, printHistory(out, entries, "{{ .Command }} {{ .Duration }} {{ .Outcome }} {{ .Details }} {{ .Error }}"))
+	assert.Equal(t, "upgrade 1m30s failed from=1.12.0 to=1.12.1 Connection refused\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, printHistory(out, entries, "table {{ .User }}\t{{ .Command }}"))
+	assert.Equal(t, "USER          COMMAND\njdoe@laptop   upgrade\n", out.String())
+}
+
+func TestCmdHistoryRequiresExistingMachine(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"unknown"},
+	}
+
+	err := cmdHistory(commandLine, &libmachinetest.FakeAPI{})
+
+	assert.Equal(t, mcnerror.ErrHostDoesNotExist{Name: "unknown"}, err)
+}
+
+func TestRemoveMachineRecordsFailures(t *testing.T) {
+	machinesDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(machinesDir)
+
+	assert.NoError(t, os.Mkdir(filepath.Join(machinesDir, "foo"), 0700))
+
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "foo",
+				Driver: &failingRemoveDriver{Driver: &fakedriver.Driver{}},
+			},
+		},
+		MachinesDir: machinesDir,
+	}
+
+	assert.Error(t, removeMachine(context.Background(), "foo", api, false))
+
+	entries, err := history.Read(filepath.Join(machinesDir, "foo"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "rm", entries[0].Command)
+	assert.Equal(t, history.OutcomeFailed, entries[0].Outcome)
+}
+
+type failingRemoveDriver struct {
+	*fakedriver.Driver
+}
+
+func (d *failingRemoveDriver) Remove() error {
+	return errors.New("Remove failed")
+}
@@ -7,6 +7,8 @@ import (
 	"text/template"
 
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/secrets"
 )
 
 var funcMap = template.FuncMap{
@@ -20,6 +22,15 @@ var funcMap = template.FuncMap{
 	},
 }
 
+// inspectedHost is a host as shown by inspect, telling at which stage its
+// creation stopped when it is only partly created. The secrets of its driver
+// are redacted unless asked otherwise.
+type inspectedHost struct {
+	*host.Host
+	Driver             json.RawMessage
+	PendingCreateStage host.CreateStage `json:",omitempty"`
+}
+
 func cmdInspect(c CommandLine, api libmachine.API) error {
 	if len(c.Args()) > 1 {
 		c.ShowHelp()
@@ -31,7 +42,12 @@ func cmdInspect(c CommandLine, api libmachine.API) error {
 		return err
 	}
 
-	host, err := api.Load(target)
+	h, err := api.Load(target)
+	if err != nil {
+		return err
+	}
+
+	host, err := inspectHost(h, c.Bool("show-secrets"))
 	if err != nil {
 		return err
 	}
@@ -70,3 +86,24 @@ func cmdInspect(c CommandLine, api libmachine.API) error {
 
 	return nil
 }
+
+func inspectHost(h *host.Host, showSecrets bool) (inspectedHost, error) {
+	inspected := inspectedHost{Host: h}
+	inspected.PendingCreateStage, _ = h.PendingCreateStage()
+
+	driver, err := json.Marshal(h.Driver)
+	if err != nil {
+		return inspected, err
+	}
+
+	if !showSecrets {
+		driver, err = secrets.Redact(driver, h.SecretFields)
+		if err != nil {
+			return inspected, err
+		}
+	}
+
+	inspected.Driver = driver
+
+	return inspected, nil
+}
@@ -1,10 +1,12 @@
 package commands
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/docker/machine/commands/commandstest"
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
 	"github.com/docker/machine/libmachine/libmachinetest"
 	"github.com/stretchr/testify/assert"
 )
@@ -29,3 +31,20 @@ func TestCmdInspect(t *testing.T) {
 		assert.Equal(t, tc.expectedErr, err)
 	}
 }
+
+func TestInspectedHostShowsPendingCreateStage(t *testing.T) {
+	h := &host.Host{
+		Name:            "foo",
+		CompletedStages: []host.CreateStage{host.StageCertificates},
+	}
+	inspected := inspectedHost{Host: h}
+	inspected.PendingCreateStage, _ = h.PendingCreateStage()
+
+	jsonHost, err := json.Marshal(inspected)
+	assert.NoError(t, err)
+
+	obj := make(map[string]interface{})
+	assert.NoError(t, json.Unmarshal(jsonHost, &obj))
+	assert.Equal(t, "foo", obj["Name"])
+	assert.Equal(t, string(host.StagePreCreateCheck), obj["PendingCreateStage"])
+}
@@ -374,6 +374,14 @@ func attemptGetHostState(h *host.Host, stateQueryChan chan<- HostListItem) {
 	if hostError == drivers.ErrHostIsNotRunning.Error() {
 		hostError = ""
 	}
+	if stage, pending := h.PendingCreateStage(); pending {
+		partlyCreated := fmt.Sprintf("Partly created, stopped before stage %s", stage)
+		if hostError == "" {
+			hostError = partlyCreated
+		} else {
+			hostError = fmt.Sprintf("%s, %s", partlyCreated, hostError)
+		}
+	}
 
 	var swarmOptions *swarm.Options
 	var engineOptions *engine.Options
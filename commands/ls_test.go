@@ -494,6 +494,52 @@ func TestGetHostStateError(t *testing.T) {
 	assert.Nil(t, hostItem.SwarmOptions)
 }
 
+func TestGetHostStatePartlyCreated(t *testing.T) {
+	hosts := []*host.Host{
+		{
+			Name: "foo",
+			Driver: &fakedriver.Driver{
+				MockState: state.Running,
+			},
+			CompletedStages: []host.CreateStage{
+				host.StageCertificates,
+				host.StagePreCreateCheck,
+				host.StageDriverCreate,
+			},
+		},
+	}
+
+	hostItem := getHostListItems(hosts, nil, 10*time.Second)[0]
+
+	assert.Equal(t, "foo", hostItem.Name)
+	assert.Equal(t, "Partly created, stopped before stage wait-for-running", hostItem.Error)
+}
+
+func TestGetHostStatePartlyCreatedKeepsError(t *testing.T) {
+	defer func(versioner mcndockerclient.DockerVersioner) { mcndockerclient.CurrentDockerVersioner = versioner }(mcndockerclient.CurrentDockerVersioner)
+	mcndockerclient.CurrentDockerVersioner = &mcndockerclient.FakeDockerVersioner{Err: errors.New("connection refused")}
+
+	hosts := []*host.Host{
+		{
+			Name: "foo",
+			Driver: &fakedriver.Driver{
+				MockState: state.Running,
+				MockIP:    "1.2.3.4",
+			},
+			CompletedStages: []host.CreateStage{
+				host.StageCertificates,
+				host.StagePreCreateCheck,
+				host.StageDriverCreate,
+				host.StageWaitForRunning,
+			},
+		},
+	}
+
+	hostItem := getHostListItems(hosts, nil, 10*time.Second)[0]
+
+	assert.Equal(t, "Partly created, stopped before stage provision, connection refused", hostItem.Error)
+}
+
 func TestGetSomeHostInError(t *testing.T) {
 	defer func(versioner mcndockerclient.DockerVersioner) { mcndockerclient.CurrentDockerVersioner = versioner }(mcndockerclient.CurrentDockerVersioner)
 	mcndockerclient.CurrentDockerVersioner = &mcndockerclient.FakeDockerVersioner{Version: "1.9"}
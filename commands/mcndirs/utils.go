@@ -25,3 +25,14 @@ func GetMachineDir() string {
 func GetMachineCertDir() string {
 	return filepath.Join(GetBaseDir(), "certs")
 }
+
+// GetDriversDir returns the directory the driver plugins are installed in.
+func GetDriversDir() string {
+	return filepath.Join(GetBaseDir(), "drivers")
+}
+
+// GetPidDir returns the directory the running plugin processes are recorded
+// in.
+func GetPidDir() string {
+	return filepath.Join(GetBaseDir(), "pids")
+}
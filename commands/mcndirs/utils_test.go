@@ -68,3 +68,25 @@ func TestGetMachineCertDir(t *testing.T) {
 	}
 	BaseDir = ""
 }
+
+func TestGetDriversDir(t *testing.T) {
+	root := "/tmp"
+	BaseDir = root
+	driversDir := GetDriversDir()
+
+	if driversDir != path.Join(root, "drivers") {
+		t.Fatalf("expected drivers dir %s; received %s", path.Join(root, "drivers"), driversDir)
+	}
+	BaseDir = ""
+}
+
+func TestGetPidDir(t *testing.T) {
+	root := "/tmp"
+	BaseDir = root
+	pidDir := GetPidDir()
+
+	if pidDir != path.Join(root, "pids") {
+		t.Fatalf("expected pid dir %s; received %s", path.Join(root, "pids"), pidDir)
+	}
+	BaseDir = ""
+}
@@ -0,0 +1,11 @@
+package commands
+
+import "github.com/docker/machine/libmachine"
+
+func cmdPause(c CommandLine, api libmachine.API) error {
+	return runAction("pause", c, api)
+}
+
+func cmdUnpause(c CommandLine, api libmachine.API) error {
+	return runAction("unpause", c, api)
+}
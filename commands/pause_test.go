@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// pausingDriver pauses its machine and keeps snapshots of it.
+type pausingDriver struct {
+	*fakedriver.Driver
+	snapshots []drivers.Snapshot
+}
+
+func (d *pausingDriver) Pause() error {
+	d.MockState = state.Paused
+	return nil
+}
+
+func (d *pausingDriver) Unpause() error {
+	d.MockState = state.Running
+	return nil
+}
+
+func (d *pausingDriver) CreateSnapshot(name string) error {
+	d.snapshots = append(d.snapshots, drivers.Snapshot{Name: name})
+	return nil
+}
+
+func (d *pausingDriver) ListSnapshots() ([]drivers.Snapshot, error) {
+	return d.snapshots, nil
+}
+
+func (d *pausingDriver) RestoreSnapshot(name string) error {
+	return nil
+}
+
+func (d *pausingDriver) RemoveSnapshot(name string) error {
+	for i, snapshot := range d.snapshots {
+		if snapshot.Name == name {
+			d.snapshots = append(d.snapshots[:i], d.snapshots[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func TestCmdPause(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"paused"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "paused",
+				Driver: &pausingDriver{Driver: &fakedriver.Driver{MockState: state.Running}},
+			},
+			{
+				Name:   "running",
+				Driver: &pausingDriver{Driver: &fakedriver.Driver{MockState: state.Running}},
+			},
+		},
+	}
+
+	assert.NoError(t, cmdPause(commandLine, api))
+
+	assert.Equal(t, state.Paused, libmachinetest.State(api, "paused"))
+	assert.Equal(t, state.Running, libmachinetest.State(api, "running"))
+
+	assert.NoError(t, cmdUnpause(commandLine, api))
+
+	assert.Equal(t, state.Running, libmachinetest.State(api, "paused"))
+}
+
+func TestCmdPauseNotSupported(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machine"},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "machine",
+				DriverName: "fakedriver",
+				Driver:     &fakedriver.Driver{MockState: state.Running},
+			},
+		},
+	}
+
+	err := cmdPause(commandLine, api)
+
+	assert.EqualError(t, err, `Machine "machine" doesn't support pause, its "fakedriver" driver doesn't offer it`)
+	assert.Equal(t, state.Running, libmachinetest.State(api, "machine"))
+}
@@ -0,0 +1,52 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/hostpool"
+)
+
+var errExpectedInventory = errors.New("Error: Expected the inventory of the pool, use --inventory or set POOL_INVENTORY")
+
+// cmdPoolStatus lists the hosts of the inventory of the pool driver, with
+// the machines they are leased to.
+func cmdPoolStatus(c CommandLine, api libmachine.API) error {
+	inventory := c.String("inventory")
+	if inventory == "" {
+		return errExpectedInventory
+	}
+
+	pool, err := hostpool.Open(inventory, c.String("lease-dir"))
+	if err != nil {
+		return err
+	}
+
+	return printPoolStatus(os.Stdout, pool)
+}
+
+func printPoolStatus(out io.Writer, pool *hostpool.Pool) error {
+	leases, err := pool.Leases()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "HOST\tADDRESS\tMACHINE\tSTORAGE PATH\tLEASED AT")
+	for _, h := range pool.Inventory.Hosts {
+		lease, leased := leases[h.Name]
+		if !leased {
+			fmt.Fprintf(w, "%s\t%s\t-\t\t\n", h.Name, h.Address)
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", h.Name, h.Address, lease.Machine, lease.StorePath, lease.LeasedAt.Local().Format(time.RFC3339))
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/hostpool"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdPoolStatusWithoutInventory(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+	}
+
+	err := cmdPoolStatus(commandLine, &libmachinetest.FakeAPI{})
+
+	assert.Equal(t, errExpectedInventory, err)
+}
+
+func TestPrintPoolStatus(t *testing.T) {
+	leaseDir, err := ioutil.TempDir("", "pool-status-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(leaseDir)
+
+	pool := &hostpool.Pool{
+		Inventory: &hostpool.Inventory{
+			Hosts: []hostpool.Host{
+				{Name: "builder-01", Address: "10.0.0.11"},
+				{Name: "builder-02", Address: "10.0.0.12"},
+			},
+		},
+		LeaseDir: leaseDir,
+	}
+
+	_, err = pool.Lease("ci-1", "/home/ci/.docker/machine")
+	assert.NoError(t, err)
+	leases, err := pool.Leases()
+	assert.NoError(t, err)
+	leasedAt := leases["builder-01"].LeasedAt.Local().Format(time.RFC3339)
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, printPoolStatus(out, pool))
+
+	assert.Equal(t, "HOST         ADDRESS     MACHINE   STORAGE PATH               LEASED AT\n"+
+		"builder-01   10.0.0.11   ci-1      /home/ci/.docker/machine   "+leasedAt+"\n"+
+		"builder-02   10.0.0.12   -                                    \n", out.String())
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/history"
+)
+
+var errNoResize = errors.New("Error: Expected at least one of --cpus, --memory or --disk-size")
+
+func cmdResize(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		c.ShowHelp()
+		return ErrExpectedOneMachine
+	}
+
+	opts := drivers.ResizeOptions{
+		CPUs:       c.Int("cpus"),
+		MemoryMB:   c.Int("memory"),
+		DiskSizeMB: c.Int("disk-size"),
+	}
+	if opts == (drivers.ResizeOptions{}) {
+		c.ShowHelp()
+		return errNoResize
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := loadCapableHost(api, target, drivers.CapabilityResize)
+	if err != nil {
+		return err
+	}
+
+	entry := history.NewEntry("resize")
+	recordResize(entry, opts)
+
+	return runOperation(c.Ctx(), api, "resize", h, entry, func() error {
+		return drivers.Resize(h.Driver, opts)
+	})
+}
+
+// recordResize records the resources changed by the resize in the entry.
+func recordResize(entry *history.Entry, opts drivers.ResizeOptions) {
+	if opts.CPUs > 0 {
+		entry.SetDetail("cpus", strconv.Itoa(opts.CPUs))
+	}
+	if opts.MemoryMB > 0 {
+		entry.SetDetail("memory", strconv.Itoa(opts.MemoryMB))
+	}
+	if opts.DiskSizeMB > 0 {
+		entry.SetDetail("disk-size", strconv.Itoa(opts.DiskSizeMB))
+	}
+}
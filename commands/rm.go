@@ -1,13 +1,16 @@
 package commands
 
 import (
+	"context"
 	"fmt"
-
+	"os"
 	"strings"
 
 	"errors"
 
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/history"
 	"github.com/docker/machine/libmachine/log"
 )
 
@@ -22,25 +25,25 @@ func cmdRm(c CommandLine, api libmachine.API) error {
 
 	force := c.Bool("force")
 	confirm := c.Bool("y")
-	var errorOccurred []string
 
 	if !userConfirm(confirm, force) {
 		return nil
 	}
 
-	for _, hostName := range c.Args() {
-		err := removeRemoteMachine(hostName, api)
-		if err != nil {
-			errorOccurred = collectError(fmt.Sprintf("Error removing host %q: %s", hostName, err), force, errorOccurred)
-		}
+	ctx := c.Ctx()
+	hostNames := uniqueNames(c.Args())
+	results := runForeachMachine(ctx, hostNames, c.Int("parallel"), func(index int) error {
+		return removeMachine(ctx, hostNames[index], api, force)
+	})
+	if len(results) > 1 {
+		printActionSummary(os.Stderr, results)
+	}
 
-		if err == nil || force {
-			removeErr := removeLocalMachine(hostName, api)
-			if removeErr != nil {
-				errorOccurred = collectError(fmt.Sprintf("Can't remove \"%s\"", hostName), force, errorOccurred)
-			} else {
-				log.Infof("Successfully removed %s", hostName)
-			}
+	// The errors already name their machine, and were logged when forced.
+	var errorOccurred []string
+	for _, result := range results {
+		if result.Err != nil {
+			errorOccurred = append(errorOccurred, result.Err.Error())
 		}
 	}
 
@@ -64,13 +67,55 @@ func userConfirm(confirm bool, force bool) bool {
 	return sure
 }
 
-func removeRemoteMachine(hostName string, api libmachine.API) error {
-	currentHost, loaderr := api.Load(hostName)
-	if loaderr != nil {
-		return loaderr
+// removeMachine removes the machine at its provider and from the store,
+// holding its lock all along. Only the failures are recorded in its history,
+// which is removed with the machine otherwise.
+func removeMachine(ctx context.Context, hostName string, api libmachine.API, force bool) error {
+	entry := history.NewEntry("rm")
+
+	err := runRemove(ctx, hostName, api, force)
+	if err != nil {
+		recordHistory(api, hostName, entry, err)
+	}
+
+	return err
+}
+
+func runRemove(ctx context.Context, hostName string, api libmachine.API, force bool) error {
+	var errorOccurred []string
+
+	currentHost, err := api.Load(hostName)
+	if err == nil {
+		unlock, lockErr := currentHost.Lock(ctx, "rm")
+		if lockErr != nil {
+			// Don't remove anything behind the back of the lock holder,
+			// even when forced.
+			message := fmt.Sprintf("Error removing host %q: %s", hostName, lockErr)
+			collectError(message, force, nil)
+			return errors.New(message)
+		}
+		defer unlock()
+
+		err = drivers.RemoveContext(ctx, currentHost.Driver)
+	}
+	if err != nil {
+		errorOccurred = collectError(fmt.Sprintf("Error removing host %q: %s", hostName, err), force, errorOccurred)
+	}
+
+	if err == nil || force {
+		removeErr := removeLocalMachine(hostName, api)
+		if removeErr != nil {
+			errorOccurred = collectError(fmt.Sprintf("Can't remove \"%s\"", hostName), force, errorOccurred)
+		} else {
+			log.Infof("Successfully removed %s", hostName)
+		}
+	}
+
+	if len(errorOccurred) > 0 {
+		return errors.New(strings.Join(errorOccurred, "\n"))
 	}
 
-	return currentHost.Driver.Remove()
+	return nil
 }
 
 func removeLocalMachine(hostName string, api libmachine.API) error {
@@ -231,3 +231,33 @@ func TestDontRemoveMachineIsRemovalFailsAndNotForced(t *testing.T) {
 
 	assert.True(t, libmachinetest.Exists(api, "machineToRemove1"))
 }
+
+func TestCmdRmOneMachineAtATime(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs: []string{"machineToRemove1", "machineToRemove2", "machineToRemove1"},
+		LocalFlags: &commandstest.FakeFlagger{
+			Data: map[string]interface{}{
+				"y":        true,
+				"parallel": 1,
+			},
+		},
+	}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machineToRemove1",
+				Driver: &fakedriver.Driver{},
+			},
+			{
+				Name:   "machineToRemove2",
+				Driver: &DriverWithRemoveWhichFail{},
+			},
+		},
+	}
+
+	err := cmdRm(commandLine, api)
+	assert.EqualError(t, err, "Error removing host \"machineToRemove2\": unknown error")
+
+	assert.False(t, libmachinetest.Exists(api, "machineToRemove1"))
+	assert.True(t, libmachinetest.Exists(api, "machineToRemove2"))
+}
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"errors"
+	"os"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/secrets"
+)
+
+const (
+	secretsPassphraseEnv    = "MACHINE_SECRETS_PASSPHRASE"
+	secretsNewPassphraseEnv = "MACHINE_SECRETS_NEW_PASSPHRASE"
+)
+
+// newKeyring returns the keyring derived from the keyfile or, if there is
+// none, from the passphrase. It returns nil if both are empty.
+func newKeyring(keyfile, passphrase string) (*secrets.Keyring, error) {
+	if keyfile != "" {
+		return secrets.NewKeyringFromFile(keyfile)
+	}
+	if passphrase != "" {
+		return secrets.NewKeyring([]byte(passphrase))
+	}
+	return nil, nil
+}
+
+// cmdSecretsRekey saves all the machines again with their secrets encrypted
+// with the new key. The secrets of the machines which were created before
+// they were encrypted are looked up with their driver first.
+func cmdSecretsRekey(c CommandLine, api libmachine.API) error {
+	newKey, err := newKeyring(c.String("new-keyfile"), os.Getenv(secretsNewPassphraseEnv))
+	if err != nil {
+		return err
+	}
+	if newKey == nil {
+		return errors.New("Error: Expected the new key, use --new-keyfile or set " + secretsNewPassphraseEnv)
+	}
+
+	store, err := openStore(c.GlobalString("store-url"))
+	if err != nil {
+		return err
+	}
+
+	names, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	// All the machines are decrypted before any of them is encrypted with
+	// the new key, so that they are never left with mixed keys when one of
+	// them can't be decrypted.
+	hosts := []*host.Host{}
+	for _, name := range names {
+		h, err := store.Load(name)
+		if err != nil {
+			return err
+		}
+
+		if err := recordSecretFields(api, h); err != nil {
+			log.Warnf("Error finding the secrets of %q, only the known ones are encrypted: %s", name, err)
+		}
+
+		hosts = append(hosts, h)
+	}
+
+	persist.SetKeyring(newKey)
+
+	saveAll := func(s persist.Store) error {
+		for _, h := range hosts {
+			if err := s.Save(h); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if updater, ok := store.(persist.Updater); ok {
+		err = updater.Update(saveAll)
+	} else {
+		err = saveAll(store)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Encrypted the secrets of %d machine(s) with the new key", len(hosts))
+
+	return nil
+}
+
+// recordSecretFields records the secrets of the machine, as told by its
+// driver.
+func recordSecretFields(api libmachine.API, h *host.Host) error {
+	loaded, err := api.Load(h.Name)
+	if err != nil {
+		return err
+	}
+
+	return h.RecordSecretFields(loaded.Driver.GetCreateFlags())
+}
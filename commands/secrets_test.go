@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/secrets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewKeyring(t *testing.T) {
+	k, err := newKeyring("", "")
+	assert.NoError(t, err)
+	assert.Nil(t, k)
+
+	k, err = newKeyring("", "passphrase")
+	assert.NoError(t, err)
+	assert.NotNil(t, k)
+
+	_, err = newKeyring("/not/a/keyfile", "passphrase")
+	assert.Error(t, err)
+}
+
+func TestInspectHostRedactsSecrets(t *testing.T) {
+	h, err := hosttest.GetDefaultTestHost()
+	assert.NoError(t, err)
+	h.SecretFields = []string{"URL"}
+
+	inspected, err := inspectHost(h, false)
+	assert.NoError(t, err)
+
+	driver := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(inspected.Driver, &driver))
+	assert.Equal(t, secrets.Redacted, driver["URL"])
+	assert.Equal(t, hosttest.DefaultHostName, driver["MachineName"])
+
+	inspected, err = inspectHost(h, true)
+	assert.NoError(t, err)
+
+	assert.NoError(t, json.Unmarshal(inspected.Driver, &driver))
+	assert.Equal(t, "unix:///var/run/docker.sock", driver["URL"])
+}
+
+func TestCmdSecretsRekeyRequiresNewKey(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+	}
+
+	err := cmdSecretsRekey(commandLine, &libmachinetest.FakeAPI{})
+
+	assert.Error(t, err)
+}
+
+func TestCmdSecretsRekey(t *testing.T) {
+	defer func(baseDir string) { mcndirs.BaseDir = baseDir }(mcndirs.BaseDir)
+	defer persist.SetKeyring(nil)
+
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	mcndirs.BaseDir = tmpDir
+
+	oldKey, err := secrets.NewKeyring([]byte("old"))
+	assert.NoError(t, err)
+	persist.SetKeyring(oldKey)
+
+	h, err := hosttest.GetDefaultTestHost()
+	assert.NoError(t, err)
+	h.SecretFields = []string{"URL"}
+
+	store := persist.NewFilestore(tmpDir, "", "")
+	assert.NoError(t, store.Save(h))
+
+	keyfile := filepath.Join(tmpDir, "new.key")
+	assert.NoError(t, ioutil.WriteFile(keyfile, []byte("new"), 0600))
+
+	commandLine := &commandstest.FakeCommandLine{
+		LocalFlags:  &commandstest.FakeFlagger{Data: map[string]interface{}{"new-keyfile": keyfile}},
+		GlobalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+	}
+
+	err = cmdSecretsRekey(commandLine, &libmachinetest.FakeAPI{})
+	assert.NoError(t, err)
+
+	persist.SetKeyring(oldKey)
+	_, err = store.Load(h.Name)
+	assert.Equal(t, secrets.ErrWrongKey, err)
+
+	newKey, err := secrets.NewKeyringFromFile(keyfile)
+	assert.NoError(t, err)
+	persist.SetKeyring(newKey)
+
+	loaded, err := store.Load(h.Name)
+	assert.NoError(t, err)
+	assert.Contains(t, string(loaded.RawDriver), "unix:///var/run/docker.sock")
+}
@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/history"
+	"github.com/docker/machine/libmachine/host"
+)
+
+var errExpectedMachineAndSnapshot = errors.New("Error: Expected a machine name and a snapshot name as arguments")
+
+func cmdSnapshotCreate(c CommandLine, api libmachine.API) error {
+	return runSnapshotOperation(c, api, "snapshot-create", drivers.CreateSnapshot)
+}
+
+func cmdSnapshotRestore(c CommandLine, api libmachine.API) error {
+	return runSnapshotOperation(c, api, "snapshot-restore", drivers.RestoreSnapshot)
+}
+
+func cmdSnapshotRm(c CommandLine, api libmachine.API) error {
+	return runSnapshotOperation(c, api, "snapshot-rm", drivers.RemoveSnapshot)
+}
+
+// runSnapshotOperation runs an operation on the snapshot of the machine,
+// both named on the command line.
+func runSnapshotOperation(c CommandLine, api libmachine.API, operation string, run func(drivers.Driver, string) error) error {
+	if len(c.Args()) != 2 {
+		c.ShowHelp()
+		return errExpectedMachineAndSnapshot
+	}
+
+	name := c.Args()[1]
+
+	h, err := loadCapableHost(api, c.Args().First(), drivers.CapabilitySnapshot)
+	if err != nil {
+		return err
+	}
+
+	entry := history.NewEntry(operation)
+	entry.SetDetail("snapshot", name)
+
+	return runOperation(c.Ctx(), api, operation, h, entry, func() error {
+		return run(h.Driver, name)
+	})
+}
+
+func cmdSnapshotLs(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) > 1 {
+		c.ShowHelp()
+		return ErrExpectedOneMachine
+	}
+
+	target, err := targetHost(c, api)
+	if err != nil {
+		return err
+	}
+
+	h, err := loadCapableHost(api, target, drivers.CapabilitySnapshot)
+	if err != nil {
+		return err
+	}
+
+	return printSnapshots(os.Stdout, h)
+}
+
+func printSnapshots(out io.Writer, h *host.Host) error {
+	snapshots, err := drivers.ListSnapshots(h.Driver)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 5, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCREATED")
+	for _, snapshot := range snapshots {
+		created := ""
+		if !snapshot.Created.IsZero() {
+			created = snapshot.Created.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", snapshot.Name, created)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,75 @@
+package commands
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdSnapshot(t *testing.T) {
+	driver := &pausingDriver{Driver: &fakedriver.Driver{}}
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:   "machine",
+				Driver: driver,
+			},
+		},
+	}
+
+	err := cmdSnapshotCreate(&commandstest.FakeCommandLine{CliArgs: []string{"machine", "clean"}}, api)
+	assert.NoError(t, err)
+	err = cmdSnapshotCreate(&commandstest.FakeCommandLine{CliArgs: []string{"machine", "configured"}}, api)
+	assert.NoError(t, err)
+	err = cmdSnapshotRm(&commandstest.FakeCommandLine{CliArgs: []string{"machine", "clean"}}, api)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []drivers.Snapshot{{Name: "configured"}}, driver.snapshots)
+
+	err = cmdSnapshotCreate(&commandstest.FakeCommandLine{CliArgs: []string{"machine"}}, api)
+	assert.Equal(t, errExpectedMachineAndSnapshot, err)
+}
+
+func TestCmdSnapshotNotSupported(t *testing.T) {
+	api := &libmachinetest.FakeAPI{
+		Hosts: []*host.Host{
+			{
+				Name:       "machine",
+				DriverName: "fakedriver",
+				Driver:     &fakedriver.Driver{},
+			},
+		},
+	}
+
+	err := cmdSnapshotLs(&commandstest.FakeCommandLine{CliArgs: []string{"machine"}}, api)
+
+	assert.EqualError(t, err, `Machine "machine" doesn't support snapshot, its "fakedriver" driver doesn't offer it`)
+}
+
+func TestPrintSnapshots(t *testing.T) {
+	created := time.Date(2016, 3, 1, 12, 0, 0, 0, time.Local)
+	h := &host.Host{
+		Name: "machine",
+		Driver: &pausingDriver{
+			Driver: &fakedriver.Driver{},
+			snapshots: []drivers.Snapshot{
+				{Name: "clean", Created: created},
+				{Name: "imported"},
+			},
+		},
+	}
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, printSnapshots(out, h))
+
+	assert.Equal(t, "NAME       CREATED\n"+
+		"clean      "+created.Format(time.RFC3339)+"\n"+
+		"imported   \n", out.String())
+}
@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/persist"
+)
+
+const defaultStoreAddress = "127.0.0.1:2380"
+
+// openStore opens the store at storeURL next to the current storage path.
+// Its machines are used as they are stored, without launching their driver
+// plugins, so that they are copied as is even when a plugin is missing.
+func openStore(storeURL string) (persist.Store, error) {
+	certsDir := mcndirs.GetMachineCertDir()
+	return persist.NewStore(storeURL, persist.NewFilestore(mcndirs.GetBaseDir(), certsDir, certsDir))
+}
+
+func cmdStoreMigrate(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 1 {
+		c.ShowHelp()
+		return errors.New("Error: Expected the URL of the destination store as an argument")
+	}
+
+	from, err := openStore(c.GlobalString("store-url"))
+	if err != nil {
+		return err
+	}
+
+	to, err := openStore(c.Args().First())
+	if err != nil {
+		return err
+	}
+
+	names, err := persist.Migrate(from, to)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Copied %d machine(s) to %s", len(names), c.Args().First())
+	log.Infof("Use '--store-url %s' or set MACHINE_STORE_URL to use them from there", c.Args().First())
+
+	return nil
+}
+
+func cmdStoreLs(c CommandLine, api libmachine.API) error {
+	store, err := openStore(c.GlobalString("store-url"))
+	if err != nil {
+		return err
+	}
+
+	metadataStore, ok := store.(persist.MetadataStore)
+	if !ok {
+		return errors.New("The store doesn't keep who owns the machines, use 'ls' to list them")
+	}
+
+	names, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	return printStoreMetadata(os.Stdout, metadataStore, names)
+}
+
+func printStoreMetadata(w io.Writer, store persist.MetadataStore, names []string) error {
+	tabWriter := tabwriter.NewWriter(w, 5, 1, 3, ' ', 0)
+	defer tabWriter.Flush()
+
+	fmt.Fprintln(tabWriter, "NAME\tOWNER\tREVISION\tUPDATED BY\tUPDATED AT")
+	for _, name := range names {
+		metadata, err := store.Metadata(name)
+		if err != nil {
+			return err
+		}
+
+		updatedAt := "Unknown"
+		if !metadata.UpdatedAt.IsZero() {
+			updatedAt = metadata.UpdatedAt.Local().Format(time.RFC3339)
+		}
+
+		owner := metadata.Owner
+		if owner == "" {
+			owner = "Unknown"
+		}
+
+		fmt.Fprintf(tabWriter, "%s\t%s\t%d\t%s\t%s\n", name, owner, metadata.Revision, metadata.UpdatedBy, updatedAt)
+	}
+
+	return nil
+}
+
+func cmdStoreServe(c CommandLine, api libmachine.API) error {
+	if len(c.Args()) != 0 {
+		c.ShowHelp()
+		return ErrTooManyArguments
+	}
+
+	address := c.String("address")
+	certPath, keyPath := c.String("tls-cert"), c.String("tls-key")
+	withTLS := certPath != "" || keyPath != ""
+	if withTLS && (certPath == "" || keyPath == "") {
+		return errors.New("Both --tls-cert and --tls-key are needed to serve the store over TLS")
+	}
+
+	// The store hands out the keys of the machines, which must not travel
+	// in the clear.
+	if !withTLS && !isLoopbackAddress(address) {
+		return fmt.Errorf("Refusing to serve the store on %s without TLS, pass --tls-cert and --tls-key", address)
+	}
+
+	token := c.String("token")
+	if token == "" {
+		var err error
+		if token, err = randomToken(); err != nil {
+			return err
+		}
+		log.Infof("Clients must set %s=%s", persist.StoreTokenEnv, token)
+	}
+
+	certsDir := mcndirs.GetMachineCertDir()
+	fs := persist.NewFilestore(mcndirs.GetBaseDir(), certsDir, certsDir)
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler: persist.NewStoreHandler(fs, token),
+	}
+
+	ctx := c.Ctx()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	scheme := "http"
+	if withTLS {
+		scheme = "https"
+	}
+	log.Infof("Serving the machines of %s at %s://%s", fs.GetMachinesDir(), scheme, listener.Addr())
+
+	if withTLS {
+		err = server.ServeTLS(listener, certPath, keyPath)
+	} else {
+		err = server.Serve(listener)
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
+}
+
+func isLoopbackAddress(address string) bool {
+	hostname, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+
+	if hostname == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(hostname)
+	return ip != nil && ip.IsLoopback()
+}
+
+func randomToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(token), nil
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/commands/mcndirs"
+	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/libmachinetest"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmdStoreMigrateRequiresDestination(t *testing.T) {
+	commandLine := &commandstest.FakeCommandLine{}
+
+	err := cmdStoreMigrate(commandLine, &libmachinetest.FakeAPI{})
+
+	assert.Error(t, err)
+	assert.True(t, commandLine.HelpShown)
+}
+
+func TestCmdStoreMigrate(t *testing.T) {
+	defer func(baseDir string) { mcndirs.BaseDir = baseDir }(mcndirs.BaseDir)
+
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+	mcndirs.BaseDir = tmpDir
+
+	h, err := hosttest.GetDefaultTestHost()
+	assert.NoError(t, err)
+	assert.NoError(t, persist.NewFilestore(tmpDir, "", "").Save(h))
+
+	dbURL := "db://" + filepath.ToSlash(filepath.Join(tmpDir, "machines.db"))
+	commandLine := &commandstest.FakeCommandLine{
+		CliArgs:     []string{dbURL},
+		GlobalFlags: &commandstest.FakeFlagger{Data: map[string]interface{}{}},
+	}
+
+	err = cmdStoreMigrate(commandLine, &libmachinetest.FakeAPI{})
+	assert.NoError(t, err)
+
+	store, err := persist.NewStore(dbURL, persist.NewFilestore(tmpDir, "", ""))
+	assert.NoError(t, err)
+
+	exists, err := store.Exists(h.Name)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
+type fakeMetadataStore map[string]persist.Metadata
+
+func (s fakeMetadataStore) Metadata(name string) (persist.Metadata, error) {
+	return s[name], nil
+}
+
+func TestPrintStoreMetadata(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := printStoreMetadata(&buf, fakeMetadataStore{
+		"foo": {Owner: "alice@laptop", Revision: 3, UpdatedBy: "bob@desktop"},
+		"bar": {},
+	}, []string{"foo", "bar"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "NAME   OWNER          REVISION   UPDATED BY    UPDATED AT\nfoo    alice@laptop   3          bob@desktop   Unknown\nbar    Unknown        0                        Unknown\n", buf.String())
+}
+
+func TestIsLoopbackAddress(t *testing.T) {
+	assert.True(t, isLoopbackAddress("127.0.0.1:2380"))
+	assert.True(t, isLoopbackAddress("localhost:2380"))
+	assert.True(t, isLoopbackAddress("[::1]:2380"))
+	assert.False(t, isLoopbackAddress("0.0.0.0:2380"))
+	assert.False(t, isLoopbackAddress(":2380"))
+}
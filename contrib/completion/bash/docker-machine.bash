@@ -35,12 +35,16 @@ _docker_machine_drivers() {
     local drivers=(
         amazonec2
         azure
+        container
         digitalocean
         exoscale
         generic
         google
         hyperv
+        lxd
         openstack
+        pool
+        qemu
         rackspace
         softlayer
         virtualbox
@@ -32,7 +32,6 @@ const (
 	driverName                           = "amazonec2"
 	ipRange                              = "0.0.0.0/0"
 	machineSecurityGroupName             = "docker-machine"
-	defaultAmiId                         = "ami-08d4ac5b634553e16"
 	defaultRegion                        = "us-east-1"
 	defaultInstanceType                  = "t2.micro"
 	defaultDeviceName                    = "/dev/sda1"
@@ -137,14 +136,16 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			EnvVar: "AWS_ACCESS_KEY_ID",
 		},
 		mcnflag.StringFlag{
-			Name:   "amazonec2-secret-key",
-			Usage:  "AWS Secret Key",
-			EnvVar: "AWS_SECRET_ACCESS_KEY",
+			Name:      "amazonec2-secret-key",
+			Usage:     "AWS Secret Key",
+			EnvVar:    "AWS_SECRET_ACCESS_KEY",
+			Sensitive: true,
 		},
 		mcnflag.StringFlag{
-			Name:   "amazonec2-session-token",
-			Usage:  "AWS Session Token",
-			EnvVar: "AWS_SESSION_TOKEN",
+			Name:      "amazonec2-session-token",
+			Usage:     "AWS Session Token",
+			EnvVar:    "AWS_SESSION_TOKEN",
+			Sensitive: true,
 		},
 		mcnflag.StringFlag{
 			Name:   "amazonec2-ami",
@@ -335,7 +336,7 @@ func NewDriver(hostName, storePath string) *Driver {
 	id := generateId()
 	driver := &Driver{
 		Id:                   id,
-		AMI:                  defaultAmiId,
+		AMI:                  regionDetails[defaultRegion].AmiId,
 		Region:               defaultRegion,
 		InstanceType:         defaultInstanceType,
 		RootSize:             defaultRootSize,
@@ -0,0 +1,22 @@
+package amazonec2
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/driverstest"
+)
+
+func TestConformance(t *testing.T) {
+	driverstest.Run(t, driverstest.Suite{
+		NewDriver: func(machineName, storePath string) drivers.Driver {
+			driver := NewDriver(machineName, storePath)
+			driver.clientFactory = func() Ec2Client {
+				return &fakeEC2WithLogin{}
+			}
+			driver.awsCredentialsFactory = NewValidAwsCredentials
+			return driver
+		},
+		GeneratedFields: []string{"Id"},
+	})
+}
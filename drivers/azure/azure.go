@@ -229,9 +229,10 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			EnvVar: "AZURE_CLIENT_ID",
 		},
 		mcnflag.StringFlag{
-			Name:   flAzureClientSecret,
-			Usage:  "Azure Service Principal Account password (optional, browser auth is used if not specified)",
-			EnvVar: "AZURE_CLIENT_SECRET",
+			Name:      flAzureClientSecret,
+			Usage:     "Azure Service Principal Account password (optional, browser auth is used if not specified)",
+			EnvVar:    "AZURE_CLIENT_SECRET",
+			Sensitive: true,
 		},
 	}
 }
@@ -0,0 +1,30 @@
+package container
+
+import (
+	"os"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/driverstest"
+)
+
+func TestConformance(t *testing.T) {
+	docker := newFakeDocker()
+	defer docker.Close()
+
+	driverstest.Run(t, driverstest.Suite{
+		NewDriver: func(machineName, storePath string) drivers.Driver {
+			d := NewDriver(machineName, storePath)
+			d.DockerHost = docker.dockerHost()
+			// The SSH key is generated in the directory of the machine.
+			os.MkdirAll(d.ResolveStorePath("."), 0700)
+			return d
+		},
+		FlagValues: map[string]interface{}{
+			"container-image":       testImage,
+			"container-docker-host": docker.dockerHost(),
+		},
+		Lifecycle:     true,
+		RemoveMissing: true,
+	})
+}
@@ -0,0 +1,512 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcndockerclient"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	defaultDockerHost = "unix:///var/run/docker.sock"
+	defaultSSHUser    = "root"
+
+	// machineLabel is set on the containers to the name of their machine.
+	machineLabel = "com.docker.machine.name"
+)
+
+const (
+	sshPort    nat.Port = "22/tcp"
+	enginePort nat.Port = "2376/tcp"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	Image          string
+	DockerHost     string
+	DockerCertPath string
+	ContainerID    string
+}
+
+// NewDriver creates a new container driver with default settings.
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		DockerHost: defaultDockerHost,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+			SSHUser:     defaultSSHUser,
+		},
+	}
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			Name:     "container-image",
+			Usage:    "Image of the container, running sshd and an init system the provisioning supports",
+			EnvVar:   "CONTAINER_IMAGE",
+			Required: true,
+		},
+		mcnflag.StringFlag{
+			Name:   "container-docker-host",
+			Usage:  "URL of the Docker daemon running the container",
+			Value:  defaultDockerHost,
+			EnvVar: "CONTAINER_DOCKER_HOST",
+		},
+		mcnflag.StringFlag{
+			Name:   "container-docker-cert-path",
+			Usage:  "Directory of the ca.pem, cert.pem and key.pem files to connect to the Docker daemon with TLS",
+			EnvVar: "CONTAINER_DOCKER_CERT_PATH",
+		},
+	}
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "container"
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.Image = flags.String("container-image")
+	d.DockerHost = flags.String("container-docker-host")
+	d.DockerCertPath = flags.String("container-docker-cert-path")
+	d.SetSwarmConfigFromFlags(flags)
+
+	if d.Image == "" {
+		return errors.New("container driver requires the --container-image option")
+	}
+
+	if _, err := d.hostAddress(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// hostAddress returns the address of the host running the Docker daemon,
+// which the ports of the containers are published on.
+func (d *Driver) hostAddress() (string, error) {
+	u, err := url.Parse(d.DockerHost)
+	if err != nil {
+		return "", fmt.Errorf("Invalid Docker host %q: %s", d.DockerHost, err)
+	}
+
+	switch u.Scheme {
+	case "unix", "npipe":
+		return "127.0.0.1", nil
+	case "tcp", "http", "https":
+		if u.Hostname() == "" {
+			return "", fmt.Errorf("Invalid Docker host %q: no host", d.DockerHost)
+		}
+		return u.Hostname(), nil
+	}
+
+	return "", fmt.Errorf("Unsupported Docker host %q", d.DockerHost)
+}
+
+// client connects to the Docker daemon running the container.
+func (d *Driver) client() (*client.Client, error) {
+	dockerHost := &mcndockerclient.RemoteDocker{
+		HostURL: d.DockerHost,
+	}
+
+	if d.DockerCertPath != "" {
+		dockerHost.AuthOption = &auth.Options{
+			CaCertPath:     filepath.Join(d.DockerCertPath, "ca.pem"),
+			ClientCertPath: filepath.Join(d.DockerCertPath, "cert.pem"),
+			ClientKeyPath:  filepath.Join(d.DockerCertPath, "key.pem"),
+		}
+	}
+
+	return mcndockerclient.DockerClient(dockerHost)
+}
+
+// PreCreateCheck checks that the Docker daemon can be reached.
+func (d *Driver) PreCreateCheck() error {
+	docker, err := d.client()
+	if err != nil {
+		return err
+	}
+	defer docker.Close()
+
+	if _, err := docker.Ping(context.Background()); err != nil {
+		return fmt.Errorf("Unable to reach the Docker daemon at %s: %s", d.DockerHost, err)
+	}
+
+	return nil
+}
+
+func (d *Driver) Create() error {
+	log.Infof("Creating SSH key...")
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.GetSSHKeyPath() + ".pub")
+	if err != nil {
+		return err
+	}
+
+	docker, err := d.client()
+	if err != nil {
+		return err
+	}
+	defer docker.Close()
+
+	ctx := context.Background()
+
+	if err := d.pullImage(ctx, docker); err != nil {
+		return err
+	}
+
+	log.Infof("Creating container...")
+	config := &dockercontainer.Config{
+		Image:    d.Image,
+		Hostname: d.MachineName,
+		ExposedPorts: nat.PortSet{
+			sshPort:    struct{}{},
+			enginePort: struct{}{},
+		},
+		// The daemon of the machine can't store its images on the overlay
+		// filesystem of the container.
+		Volumes: map[string]struct{}{
+			"/var/lib/docker": {},
+		},
+		Labels: map[string]string{
+			machineLabel: d.MachineName,
+		},
+	}
+	hostConfig := &dockercontainer.HostConfig{
+		Privileged: true,
+		// The ports are published on ports picked by the daemon.
+		PortBindings: nat.PortMap{
+			sshPort:    []nat.PortBinding{{}},
+			enginePort: []nat.PortBinding{{}},
+		},
+	}
+
+	resp, err := docker.ContainerCreate(ctx, config, hostConfig, nil, nil, d.MachineName)
+	if err != nil {
+		return fmt.Errorf("Error creating the container: %s", err)
+	}
+	d.ContainerID = resp.ID
+
+	authorizedKeys, err := authorizedKeysArchive(publicKey)
+	if err != nil {
+		return err
+	}
+
+	if err := docker.CopyToContainer(ctx, d.ContainerID, "/", authorizedKeys, types.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("Error copying the SSH key to the container: %s", err)
+	}
+
+	log.Infof("Starting the container...")
+	return d.Start()
+}
+
+// pullImage pulls the image of the container, unless the daemon has it
+// already.
+func (d *Driver) pullImage(ctx context.Context, docker *client.Client) error {
+	_, _, err := docker.ImageInspectWithRaw(ctx, d.Image)
+	if err == nil {
+		return nil
+	}
+	if !client.IsErrNotFound(err) {
+		return err
+	}
+
+	log.Infof("Pulling image %s...", d.Image)
+	out, err := docker.ImagePull(ctx, d.Image, types.ImagePullOptions{})
+	if err != nil {
+		return fmt.Errorf("Unable to pull image: %s", err)
+	}
+	defer out.Close()
+
+	// The errors of the pull are reported in the progress messages.
+	if err := jsonmessage.DisplayJSONMessagesStream(out, ioutil.Discard, 0, false, nil); err != nil {
+		return fmt.Errorf("Unable to pull image: %s", err)
+	}
+
+	return nil
+}
+
+// authorizedKeysArchive returns a tar archive of the authorized_keys file of
+// root, to extract at the root of the container.
+func authorizedKeysArchive(publicKey []byte) (*bytes.Buffer, error) {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "root/.ssh/",
+		Typeflag: tar.TypeDir,
+		Mode:     0700,
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     "root/.ssh/authorized_keys",
+		Typeflag: tar.TypeReg,
+		Mode:     0600,
+		Size:     int64(len(publicKey)),
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(publicKey); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// inspect returns the details of the container.
+func (d *Driver) inspect() (types.ContainerJSON, error) {
+	docker, err := d.client()
+	if err != nil {
+		return types.ContainerJSON{}, err
+	}
+	defer docker.Close()
+
+	return docker.ContainerInspect(context.Background(), d.ContainerID)
+}
+
+// publishedPort returns the port of the host the port of the running
+// container is published on. The daemon picks it again each time the
+// container starts.
+func (d *Driver) publishedPort(port nat.Port) (int, error) {
+	c, err := d.inspect()
+	if err != nil {
+		return 0, err
+	}
+
+	if c.State == nil || !c.State.Running {
+		return 0, drivers.ErrHostIsNotRunning
+	}
+
+	if c.NetworkSettings != nil {
+		for _, binding := range c.NetworkSettings.Ports[port] {
+			if binding.HostPort != "" {
+				return strconv.Atoi(binding.HostPort)
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("Port %s of the container isn't published", port)
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.hostAddress()
+}
+
+func (d *Driver) GetSSHPort() (int, error) {
+	return d.publishedPort(sshPort)
+}
+
+func (d *Driver) GetSSHUsername() string {
+	if d.SSHUser == "" {
+		d.SSHUser = defaultSSHUser
+	}
+
+	return d.SSHUser
+}
+
+// GetIP returns the address the ports of the container are published on.
+func (d *Driver) GetIP() (string, error) {
+	s, err := d.GetState()
+	if err != nil {
+		return "", err
+	}
+	if s != state.Running {
+		return "", drivers.ErrHostIsNotRunning
+	}
+
+	return d.hostAddress()
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	port, err := d.publishedPort(enginePort)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tcp://%s:%d", ip, port), nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	c, err := d.inspect()
+	if err != nil {
+		return state.Error, err
+	}
+
+	if c.State == nil {
+		return state.None, nil
+	}
+
+	switch c.State.Status {
+	case "running":
+		return state.Running, nil
+	case "paused":
+		return state.Paused, nil
+	case "restarting":
+		return state.Starting, nil
+	case "removing":
+		return state.Stopping, nil
+	case "created", "exited":
+		return state.Stopped, nil
+	case "dead":
+		return state.Error, nil
+	}
+	return state.None, nil
+}
+
+func (d *Driver) Start() error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s == state.Paused {
+		log.Infof("Resuming container ...")
+		return d.Unpause()
+	}
+
+	docker, err := d.client()
+	if err != nil {
+		return err
+	}
+	defer docker.Close()
+
+	return docker.ContainerStart(context.Background(), d.ContainerID, types.ContainerStartOptions{})
+}
+
+func (d *Driver) Stop() error {
+	docker, err := d.client()
+	if err != nil {
+		return err
+	}
+	defer docker.Close()
+
+	return docker.ContainerStop(context.Background(), d.ContainerID, dockercontainer.StopOptions{})
+}
+
+func (d *Driver) Restart() error {
+	docker, err := d.client()
+	if err != nil {
+		return err
+	}
+	defer docker.Close()
+
+	return docker.ContainerRestart(context.Background(), d.ContainerID, dockercontainer.StopOptions{})
+}
+
+func (d *Driver) Kill() error {
+	docker, err := d.client()
+	if err != nil {
+		return err
+	}
+	defer docker.Close()
+
+	return docker.ContainerKill(context.Background(), d.ContainerID, "KILL")
+}
+
+// Remove removes the container with its volumes, the images of its daemon
+// included.
+func (d *Driver) Remove() error {
+	if d.ContainerID == "" {
+		return nil
+	}
+
+	docker, err := d.client()
+	if err != nil {
+		return err
+	}
+	defer docker.Close()
+
+	err = docker.ContainerRemove(context.Background(), d.ContainerID, types.ContainerRemoveOptions{
+		Force:         true,
+		RemoveVolumes: true,
+	})
+	if err != nil && !client.IsErrNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Pause freezes the processes of the container.
+func (d *Driver) Pause() error {
+	docker, err := d.client()
+	if err != nil {
+		return err
+	}
+	defer docker.Close()
+
+	return docker.ContainerPause(context.Background(), d.ContainerID)
+}
+
+// Unpause resumes the processes frozen by Pause.
+func (d *Driver) Unpause() error {
+	docker, err := d.client()
+	if err != nil {
+		return err
+	}
+	defer docker.Close()
+
+	return docker.ContainerUnpause(context.Background(), d.ContainerID)
+}
+
+// ConsoleLog returns the output of the init system of the container.
+func (d *Driver) ConsoleLog() (string, error) {
+	docker, err := d.client()
+	if err != nil {
+		return "", err
+	}
+	defer docker.Close()
+
+	logs, err := docker.ContainerLogs(context.Background(), d.ContainerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer logs.Close()
+
+	var out bytes.Buffer
+	if _, err := stdcopy.StdCopy(&out, &out, logs); err != nil {
+		return "", err
+	}
+
+	return out.String(), nil
+}
@@ -0,0 +1,507 @@
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+const testImage = "example/sshd-dockerd"
+
+// fakeContainer is a container of the fake daemon.
+type fakeContainer struct {
+	id         string
+	name       string
+	config     dockercontainer.Config
+	hostConfig dockercontainer.HostConfig
+	status     string
+	ports      nat.PortMap
+	archives   map[string][]byte
+	logs       string
+}
+
+// fakeDocker is a Docker daemon serving the part of the API the driver
+// uses, with containers which don't run anything.
+type fakeDocker struct {
+	*httptest.Server
+	lock       sync.Mutex
+	images     map[string]bool
+	pulled     []string
+	pullError  string
+	containers map[string]*fakeContainer
+	lastPort   int
+}
+
+var apiVersionPrefix = regexp.MustCompile(`^/v[0-9.]+`)
+
+func newFakeDocker() *fakeDocker {
+	f := &fakeDocker{
+		images:     map[string]bool{},
+		containers: map[string]*fakeContainer{},
+		lastPort:   32767,
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+// dockerHost returns the URL to give the driver to use the fake daemon.
+func (f *fakeDocker) dockerHost() string {
+	return "tcp://" + strings.TrimPrefix(f.URL, "http://")
+}
+
+func (f *fakeDocker) serve(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	path := apiVersionPrefix.ReplaceAllString(r.URL.Path, "")
+
+	switch {
+	case path == "/_ping":
+		w.Header().Set("API-Version", "1.43")
+		fmt.Fprint(w, "OK")
+	case strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/json"):
+		f.inspectImage(w, strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json"))
+	case path == "/images/create" && r.Method == http.MethodPost:
+		f.pullImage(w, r.URL.Query().Get("fromImage")+":"+r.URL.Query().Get("tag"))
+	case path == "/containers/create" && r.Method == http.MethodPost:
+		f.createContainer(w, r)
+	case strings.HasPrefix(path, "/containers/"):
+		parts := strings.SplitN(strings.TrimPrefix(path, "/containers/"), "/", 2)
+		c := f.container(parts[0])
+		if c == nil {
+			writeError(w, http.StatusNotFound, "No such container: "+parts[0])
+			return
+		}
+
+		action := ""
+		if len(parts) == 2 {
+			action = parts[1]
+		}
+		f.containerAction(w, r, c, action)
+	default:
+		writeError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeDocker) inspectImage(w http.ResponseWriter, name string) {
+	if !strings.Contains(name, ":") {
+		name += ":latest"
+	}
+
+	if !f.images[name] {
+		writeError(w, http.StatusNotFound, "No such image: "+name)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, types.ImageInspect{ID: "sha256:" + name})
+}
+
+func (f *fakeDocker) pullImage(w http.ResponseWriter, name string) {
+	f.pulled = append(f.pulled, name)
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(map[string]string{"status": "Pulling from " + name})
+	if f.pullError != "" {
+		enc.Encode(map[string]interface{}{
+			"errorDetail": map[string]string{"message": f.pullError},
+			"error":       f.pullError,
+		})
+		return
+	}
+
+	f.images[name] = true
+	enc.Encode(map[string]string{"status": "Downloaded newer image for " + name})
+}
+
+func (f *fakeDocker) createContainer(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		dockercontainer.Config
+		HostConfig dockercontainer.HostConfig
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if f.container(name) != nil {
+		writeError(w, http.StatusConflict, "Conflict. The container name is already in use")
+		return
+	}
+
+	c := &fakeContainer{
+		id:         fmt.Sprintf("%064d", len(f.containers)+1),
+		name:       name,
+		config:     body.Config,
+		hostConfig: body.HostConfig,
+		status:     "created",
+		archives:   map[string][]byte{},
+	}
+	f.containers[c.id] = c
+
+	writeJSON(w, http.StatusCreated, dockercontainer.CreateResponse{ID: c.id})
+}
+
+// container returns the container with the ID or the name.
+func (f *fakeDocker) container(idOrName string) *fakeContainer {
+	for _, c := range f.containers {
+		if c.id == idOrName || c.name == idOrName {
+			return c
+		}
+	}
+	return nil
+}
+
+// start publishes the ports of the container on new ports, like the daemon
+// does each time a container starts.
+func (f *fakeDocker) start(c *fakeContainer) {
+	c.status = "running"
+	c.ports = nat.PortMap{}
+	for port := range c.hostConfig.PortBindings {
+		f.lastPort++
+		c.ports[port] = []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: strconv.Itoa(f.lastPort)}}
+	}
+}
+
+func (f *fakeDocker) stop(c *fakeContainer) {
+	c.status = "exited"
+	c.ports = nil
+}
+
+func (f *fakeDocker) containerAction(w http.ResponseWriter, r *http.Request, c *fakeContainer, action string) {
+	switch {
+	case action == "" && r.Method == http.MethodDelete:
+		if c.status == "running" && r.URL.Query().Get("force") != "1" {
+			writeError(w, http.StatusConflict, "You cannot remove a running container")
+			return
+		}
+		delete(f.containers, c.id)
+	case action == "json":
+		writeJSON(w, http.StatusOK, types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:   c.id,
+				Name: "/" + c.name,
+				State: &types.ContainerState{
+					Status:  c.status,
+					Running: c.status == "running" || c.status == "paused",
+					Paused:  c.status == "paused",
+				},
+			},
+			NetworkSettings: &types.NetworkSettings{
+				NetworkSettingsBase: types.NetworkSettingsBase{Ports: c.ports},
+			},
+		})
+		return
+	case action == "archive" && r.Method == http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		c.archives[r.URL.Query().Get("path")] = data
+		w.WriteHeader(http.StatusOK)
+		return
+	case action == "logs":
+		w.Header().Set("Content-Type", "application/vnd.docker.multiplexed-stream")
+		w.WriteHeader(http.StatusOK)
+		stdcopy.NewStdWriter(w, stdcopy.Stdout).Write([]byte(c.logs))
+		return
+	case action == "start":
+		if c.status == "running" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if c.status == "paused" {
+			writeError(w, http.StatusConflict, "cannot start a paused container, try unpause instead")
+			return
+		}
+		f.start(c)
+	case action == "stop":
+		if c.status != "running" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		f.stop(c)
+	case action == "restart":
+		f.start(c)
+	case action == "kill":
+		if c.status != "running" {
+			writeError(w, http.StatusConflict, "Container "+c.id+" is not running")
+			return
+		}
+		f.stop(c)
+	case action == "pause":
+		c.status = "paused"
+	case action == "unpause":
+		c.status = "running"
+	default:
+		writeError(w, http.StatusNotFound, "page not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newTestDriver returns a driver of the machine using the fake daemon.
+func newTestDriver(t *testing.T, docker *fakeDocker) *Driver {
+	storePath, err := ioutil.TempDir("", "container-driver-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(storePath) })
+
+	d := NewDriver("default", storePath)
+	if err := os.MkdirAll(d.ResolveStorePath("."), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	err = d.SetConfigFromFlags(&commandstest.FakeFlagger{
+		Data: map[string]interface{}{
+			"container-image":       testImage,
+			"container-docker-host": docker.dockerHost(),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"container-image":       testImage,
+			"container-docker-host": "tcp://192.168.99.1:2376",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, testImage, driver.Image)
+	assert.Equal(t, "tcp://192.168.99.1:2376", driver.DockerHost)
+	assert.Equal(t, "root", driver.GetSSHUsername())
+}
+
+func TestSetConfigFromFlagsRequiresAnImage(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.EqualError(t, err, "container driver requires the --container-image option")
+}
+
+func TestSetConfigFromFlagsUnsupportedDockerHost(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"container-image":       testImage,
+			"container-docker-host": "ssh://user@host",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.EqualError(t, err, `Unsupported Docker host "ssh://user@host"`)
+}
+
+func TestHostAddress(t *testing.T) {
+	for dockerHost, expected := range map[string]string{
+		"unix:///var/run/docker.sock": "127.0.0.1",
+		"npipe:////./pipe/docker":     "127.0.0.1",
+		"tcp://192.168.99.1:2376":     "192.168.99.1",
+		"tcp://docker.example.com":    "docker.example.com",
+	} {
+		driver := NewDriver("default", "path")
+		driver.DockerHost = dockerHost
+
+		address, err := driver.hostAddress()
+
+		assert.NoError(t, err, dockerHost)
+		assert.Equal(t, expected, address, dockerHost)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	docker := newFakeDocker()
+	defer docker.Close()
+	driver := newTestDriver(t, docker)
+
+	err := driver.Create()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{testImage + ":latest"}, docker.pulled)
+
+	c := docker.container(driver.ContainerID)
+	if !assert.NotNil(t, c) {
+		return
+	}
+	assert.Equal(t, "default", c.name)
+	assert.Equal(t, "running", c.status)
+	assert.Equal(t, testImage, c.config.Image)
+	assert.Equal(t, "default", c.config.Labels[machineLabel])
+	assert.True(t, c.hostConfig.Privileged)
+	assert.Contains(t, c.hostConfig.PortBindings, sshPort)
+	assert.Contains(t, c.hostConfig.PortBindings, enginePort)
+
+	publicKey, err := ioutil.ReadFile(driver.GetSSHKeyPath() + ".pub")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"root/.ssh/authorized_keys": publicKey}, archiveFiles(t, c.archives["/"]))
+}
+
+// archiveFiles returns the content of the regular files of the tar archive.
+func archiveFiles(t *testing.T, archive []byte) map[string][]byte {
+	files := map[string][]byte{}
+
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if header.Typeflag == tar.TypeReg {
+			data, err := ioutil.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files[header.Name] = data
+		}
+	}
+}
+
+func TestCreateUsesTheImageOfTheDaemon(t *testing.T) {
+	docker := newFakeDocker()
+	defer docker.Close()
+	docker.images[testImage+":latest"] = true
+	driver := newTestDriver(t, docker)
+
+	err := driver.Create()
+
+	assert.NoError(t, err)
+	assert.Empty(t, docker.pulled)
+}
+
+func TestCreatePullFails(t *testing.T) {
+	docker := newFakeDocker()
+	defer docker.Close()
+	docker.pullError = "manifest unknown"
+	driver := newTestDriver(t, docker)
+
+	err := driver.Create()
+
+	assert.EqualError(t, err, "Unable to pull image: manifest unknown")
+	assert.Empty(t, docker.containers)
+}
+
+func TestGetURLUsesThePublishedPorts(t *testing.T) {
+	docker := newFakeDocker()
+	defer docker.Close()
+	driver := newTestDriver(t, docker)
+	assert.NoError(t, driver.Create())
+
+	c := docker.container(driver.ContainerID)
+	sshHostPort, _ := strconv.Atoi(c.ports[sshPort][0].HostPort)
+	engineHostPort := c.ports[enginePort][0].HostPort
+
+	url, err := driver.GetURL()
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://127.0.0.1:"+engineHostPort, url)
+
+	port, err := driver.GetSSHPort()
+	assert.NoError(t, err)
+	assert.Equal(t, sshHostPort, port)
+
+	// The ports change once the container is restarted.
+	assert.NoError(t, driver.Restart())
+
+	port, err = driver.GetSSHPort()
+	assert.NoError(t, err)
+	assert.NotEqual(t, sshHostPort, port)
+}
+
+func TestGetStateMissingContainer(t *testing.T) {
+	docker := newFakeDocker()
+	defer docker.Close()
+	driver := newTestDriver(t, docker)
+	driver.ContainerID = "unknown"
+
+	s, err := driver.GetState()
+
+	assert.Error(t, err)
+	assert.Equal(t, state.Error, s)
+}
+
+func TestStartResumesAPausedContainer(t *testing.T) {
+	docker := newFakeDocker()
+	defer docker.Close()
+	driver := newTestDriver(t, docker)
+	assert.NoError(t, driver.Create())
+	assert.NoError(t, driver.Pause())
+
+	err := driver.Start()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "running", docker.container(driver.ContainerID).status)
+}
+
+func TestConsoleLog(t *testing.T) {
+	docker := newFakeDocker()
+	defer docker.Close()
+	driver := newTestDriver(t, docker)
+	assert.NoError(t, driver.Create())
+	docker.container(driver.ContainerID).logs = "Welcome to Ubuntu!\n"
+
+	log, err := driver.ConsoleLog()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Welcome to Ubuntu!\n", log)
+}
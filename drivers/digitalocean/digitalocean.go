@@ -53,9 +53,11 @@ const (
 func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 	return []mcnflag.Flag{
 		mcnflag.StringFlag{
-			EnvVar: "DIGITALOCEAN_ACCESS_TOKEN",
-			Name:   "digitalocean-access-token",
-			Usage:  "Digital Ocean access token",
+			EnvVar:    "DIGITALOCEAN_ACCESS_TOKEN",
+			Name:      "digitalocean-access-token",
+			Usage:     "Digital Ocean access token",
+			Sensitive: true,
+			Required:  true,
 		},
 		mcnflag.StringFlag{
 			EnvVar: "DIGITALOCEAN_SSH_USER",
@@ -78,6 +80,8 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Name:   "digitalocean-ssh-port",
 			Usage:  "SSH port",
 			Value:  defaultSSHPort,
+			Min:    mcnflag.Limit(1),
+			Max:    mcnflag.Limit(65535),
 		},
 		mcnflag.StringFlag{
 			EnvVar: "DIGITALOCEAN_IMAGE",
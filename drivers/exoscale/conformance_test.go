@@ -0,0 +1,20 @@
+package exoscale
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/driverstest"
+)
+
+func TestConformance(t *testing.T) {
+	driverstest.Run(t, driverstest.Suite{
+		NewDriver: func(machineName, storePath string) drivers.Driver {
+			return NewDriver(machineName, storePath)
+		},
+		FlagValues: map[string]interface{}{
+			"exoscale-api-key":        "API_KEY",
+			"exoscale-api-secret-key": "API_SECRET_KEY",
+		},
+	})
+}
@@ -72,9 +72,10 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "exoscale API key",
 		},
 		mcnflag.StringFlag{
-			EnvVar: "EXOSCALE_API_SECRET",
-			Name:   "exoscale-api-secret-key",
-			Usage:  "exoscale API secret key",
+			EnvVar:    "EXOSCALE_API_SECRET",
+			Name:      "exoscale-api-secret-key",
+			Usage:     "exoscale API secret key",
+			Sensitive: true,
 		},
 		mcnflag.StringFlag{
 			EnvVar: "EXOSCALE_INSTANCE_PROFILE",
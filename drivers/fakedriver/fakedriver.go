@@ -77,6 +77,7 @@ func (d *Driver) GetState() (state.State, error) {
 }
 
 func (d *Driver) Create() error {
+	d.MockState = state.Running
 	return nil
 }
 
@@ -0,0 +1,24 @@
+package fakedriver
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/driverstest"
+)
+
+func TestConformance(t *testing.T) {
+	driverstest.Run(t, driverstest.Suite{
+		NewDriver: func(machineName, storePath string) drivers.Driver {
+			return &Driver{
+				BaseDriver: &drivers.BaseDriver{
+					MachineName: machineName,
+					StorePath:   storePath,
+				},
+				MockName: machineName,
+				MockIP:   "192.168.99.100",
+			}
+		},
+		Lifecycle: true,
+	})
+}
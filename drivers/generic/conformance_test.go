@@ -0,0 +1,19 @@
+package generic
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/driverstest"
+)
+
+func TestConformance(t *testing.T) {
+	driverstest.Run(t, driverstest.Suite{
+		NewDriver: func(machineName, storePath string) drivers.Driver {
+			return NewDriver(machineName, storePath)
+		},
+		FlagValues: map[string]interface{}{
+			"generic-ip-address": "192.168.99.100",
+		},
+	})
+}
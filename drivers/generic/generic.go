@@ -38,9 +38,10 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			EnvVar: "GENERIC_ENGINE_PORT",
 		},
 		mcnflag.StringFlag{
-			Name:   "generic-ip-address",
-			Usage:  "IP Address of machine",
-			EnvVar: "GENERIC_IP_ADDRESS",
+			Name:     "generic-ip-address",
+			Usage:    "IP Address of machine",
+			EnvVar:   "GENERIC_IP_ADDRESS",
+			Required: true,
 		},
 		mcnflag.StringFlag{
 			Name:   "generic-ssh-user",
@@ -0,0 +1,226 @@
+package lxd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// defaultSockets are the sockets the daemon listens on when installed with
+// snap, with the packages of the distributions, and for Incus.
+var defaultSockets = []string{
+	"/var/snap/lxd/common/lxd/unix.socket",
+	"/var/lib/lxd/unix.socket",
+	"/var/lib/incus/unix.socket",
+}
+
+var errNotFound = errors.New("not found")
+
+// response is the envelope of the responses of the API.
+type response struct {
+	Type       string          `json:"type"`
+	Status     string          `json:"status"`
+	StatusCode int             `json:"status_code"`
+	Operation  string          `json:"operation"`
+	Error      string          `json:"error"`
+	ErrorCode  int             `json:"error_code"`
+	Metadata   json.RawMessage `json:"metadata"`
+}
+
+// operation is the metadata of the background operations.
+type operation struct {
+	ID         string `json:"id"`
+	Status     string `json:"status"`
+	StatusCode int    `json:"status_code"`
+	Err        string `json:"err"`
+}
+
+// client talks to the REST API of LXD, which Incus serves as well.
+type client struct {
+	http    *http.Client
+	baseURL string
+}
+
+// newClient connects to the daemon at the URL: the path of its Unix socket
+// as unix:///var/lib/lxd/unix.socket, or https://host:8443 with the client
+// certificate the daemon trusts. The certificate of the daemon is checked
+// against serverCert when it's given, against the system roots otherwise.
+// The default sockets are tried when the URL is empty.
+func newClient(rawURL, clientCert, clientKey, serverCert string) (*client, error) {
+	if rawURL == "" {
+		socket, err := defaultSocket()
+		if err != nil {
+			return nil, err
+		}
+		rawURL = "unix://" + socket
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid LXD URL %q: %s", rawURL, err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		return &client{
+			http: &http.Client{
+				Transport: &http.Transport{
+					DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+						var d net.Dialer
+						return d.DialContext(ctx, "unix", socket)
+					},
+				},
+			},
+			baseURL: "http://lxd",
+		}, nil
+	case "https":
+		tlsConfig, err := clientTLSConfig(clientCert, clientKey, serverCert)
+		if err != nil {
+			return nil, err
+		}
+		return &client{
+			http: &http.Client{
+				Transport: &http.Transport{TLSClientConfig: tlsConfig},
+			},
+			baseURL: strings.TrimSuffix(rawURL, "/"),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported LXD URL %q, it must start with unix:// or https://", rawURL)
+}
+
+func defaultSocket() (string, error) {
+	for _, socket := range defaultSockets {
+		if _, err := os.Stat(socket); err == nil {
+			return socket, nil
+		}
+	}
+
+	return "", fmt.Errorf("LXD socket not found in %s. Make sure LXD or Incus is installed, or give its URL", strings.Join(defaultSockets, ", "))
+}
+
+func clientTLSConfig(clientCert, clientKey, serverCert string) (*tls.Config, error) {
+	if clientCert == "" || clientKey == "" {
+		return nil, errors.New("A client certificate and key trusted by LXD are needed to connect to it with HTTPS")
+	}
+
+	keypair, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read the client certificate: %s", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{keypair},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if serverCert != "" {
+		pem, err := ioutil.ReadFile(serverCert)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("Unable to read the server certificate %s", serverCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// do sends the request and returns the body of the response.
+func (c *client) do(method, path string, body interface{}) ([]byte, error) {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, &reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		var r response
+		if err := json.Unmarshal(data, &r); err != nil || r.Error == "" {
+			return nil, fmt.Errorf("%s %s failed: %s", method, path, resp.Status)
+		}
+		if r.ErrorCode == http.StatusNotFound {
+			return nil, errNotFound
+		}
+		return nil, fmt.Errorf("%s %s failed: %s", method, path, r.Error)
+	}
+
+	return data, nil
+}
+
+// query sends the request and decodes the metadata of the response into
+// result, unless it's nil. The operations run in the background are waited
+// for.
+func (c *client) query(method, path string, body, result interface{}) error {
+	data, err := c.do(method, path, body)
+	if err != nil {
+		return err
+	}
+
+	var r response
+	if err := json.Unmarshal(data, &r); err != nil {
+		return fmt.Errorf("Invalid response to %s %s: %s", method, path, err)
+	}
+
+	if r.Type == "async" {
+		return c.wait(r.Operation)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(r.Metadata, result)
+}
+
+// wait waits for the operation to be done.
+func (c *client) wait(path string) error {
+	var op operation
+	if err := c.query(http.MethodGet, path+"/wait", nil, &op); err != nil {
+		return err
+	}
+
+	if op.Status != "Success" {
+		if op.Err != "" {
+			return errors.New(op.Err)
+		}
+		return fmt.Errorf("Operation %s ended with the status %s", op.ID, op.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package lxd
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/cert"
+	"github.com/stretchr/testify/assert"
+)
+
+// newHTTPSLXD starts a fake LXD requiring a client certificate, and writes
+// its certificate with a client certificate in the directory.
+func newHTTPSLXD(t *testing.T, dir string) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			writeSync(w, map[string]string{"auth": "untrusted"})
+			return
+		}
+		writeSync(w, map[string]string{"auth": "trusted"})
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+
+	serverCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(filepath.Join(dir, "server.crt"), serverCert, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cert.GenerateCACertificate(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"), "docker-machine", 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	return server
+}
+
+func TestHTTPS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-client-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := newHTTPSLXD(t, dir)
+	defer server.Close()
+
+	driver := NewDriver("default", dir)
+	driver.URL = server.URL
+	driver.ClientCert = filepath.Join(dir, "client.crt")
+	driver.ClientKey = filepath.Join(dir, "client.key")
+	driver.ServerCert = filepath.Join(dir, "server.crt")
+
+	err = driver.PreCreateCheck()
+
+	assert.NoError(t, err)
+}
+
+func TestHTTPSChecksTheServerCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lxd-client-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server := newHTTPSLXD(t, dir)
+	defer server.Close()
+
+	driver := NewDriver("default", dir)
+	driver.URL = server.URL
+	driver.ClientCert = filepath.Join(dir, "client.crt")
+	driver.ClientKey = filepath.Join(dir, "client.key")
+
+	err = driver.PreCreateCheck()
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,29 @@
+package lxd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/driverstest"
+)
+
+func TestConformance(t *testing.T) {
+	lxd := newFakeLXD(t)
+	defer lxd.Close()
+
+	driverstest.Run(t, driverstest.Suite{
+		NewDriver: func(machineName, storePath string) drivers.Driver {
+			d := NewDriver(machineName, storePath)
+			d.URL = lxd.url()
+			// The SSH key is generated in the directory of the machine.
+			os.MkdirAll(d.ResolveStorePath("."), 0700)
+			return d
+		},
+		FlagValues: map[string]interface{}{
+			"lxd-url": lxd.url(),
+		},
+		Lifecycle:     true,
+		RemoveMissing: true,
+	})
+}
@@ -0,0 +1,441 @@
+package lxd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	defaultImage       = "22.04"
+	defaultImageServer = "https://cloud-images.ubuntu.com/releases"
+	defaultCPU         = 1
+	defaultMemory      = 1024
+	defaultSSHUser     = "ubuntu"
+
+	// stopTimeout is how many seconds an instance is given to stop.
+	stopTimeout = 90
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	URL         string
+	ClientCert  string
+	ClientKey   string
+	ServerCert  string
+	Image       string
+	ImageServer string
+	VM          bool
+	CPU         int
+	Memory      int
+}
+
+// NewDriver creates a new LXD driver with default settings.
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		Image:       defaultImage,
+		ImageServer: defaultImageServer,
+		CPU:         defaultCPU,
+		Memory:      defaultMemory,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+			SSHUser:     defaultSSHUser,
+		},
+	}
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			Name:   "lxd-url",
+			Usage:  "URL of LXD, unix:///path/to/unix.socket or https://host:8443. The local socket is looked for by default",
+			EnvVar: "LXD_URL",
+		},
+		mcnflag.StringFlag{
+			Name:   "lxd-client-cert",
+			Usage:  "Client certificate trusted by LXD, to connect to it with HTTPS",
+			EnvVar: "LXD_CLIENT_CERT",
+		},
+		mcnflag.StringFlag{
+			Name:   "lxd-client-key",
+			Usage:  "Key of the client certificate",
+			EnvVar: "LXD_CLIENT_KEY",
+		},
+		mcnflag.StringFlag{
+			Name:   "lxd-server-cert",
+			Usage:  "Certificate of LXD, when it isn't signed by a known authority",
+			EnvVar: "LXD_SERVER_CERT",
+		},
+		mcnflag.StringFlag{
+			Name:   "lxd-image",
+			Usage:  "Alias of the image of the instance, with cloud-init",
+			Value:  defaultImage,
+			EnvVar: "LXD_IMAGE",
+		},
+		mcnflag.StringFlag{
+			Name:   "lxd-image-server",
+			Usage:  "Simplestreams server of the image, empty for an image of LXD",
+			Value:  defaultImageServer,
+			EnvVar: "LXD_IMAGE_SERVER",
+		},
+		mcnflag.BoolFlag{
+			Name:   "lxd-vm",
+			Usage:  "Create a virtual machine rather than a system container",
+			EnvVar: "LXD_VM",
+		},
+		mcnflag.IntFlag{
+			Name:   "lxd-cpu-count",
+			Usage:  "number of CPUs for the instance",
+			Value:  defaultCPU,
+			EnvVar: "LXD_CPU_COUNT",
+			Min:    mcnflag.Limit(1),
+		},
+		mcnflag.IntFlag{
+			Name:   "lxd-memory",
+			Usage:  "Size of memory for the instance in MB",
+			Value:  defaultMemory,
+			EnvVar: "LXD_MEMORY_SIZE",
+			Min:    mcnflag.Limit(1),
+		},
+		mcnflag.StringFlag{
+			Name:   "lxd-ssh-user",
+			Usage:  "SSH user created in the instance",
+			Value:  defaultSSHUser,
+			EnvVar: "LXD_SSH_USER",
+		},
+	}
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "lxd"
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.URL = flags.String("lxd-url")
+	d.ClientCert = flags.String("lxd-client-cert")
+	d.ClientKey = flags.String("lxd-client-key")
+	d.ServerCert = flags.String("lxd-server-cert")
+	d.Image = flags.String("lxd-image")
+	d.ImageServer = flags.String("lxd-image-server")
+	d.VM = flags.Bool("lxd-vm")
+	d.CPU = flags.Int("lxd-cpu-count")
+	d.Memory = flags.Int("lxd-memory")
+	d.SSHUser = flags.String("lxd-ssh-user")
+	d.SetSwarmConfigFromFlags(flags)
+
+	if d.Image == "" {
+		return errors.New("lxd driver requires the --lxd-image option")
+	}
+
+	return nil
+}
+
+func (d *Driver) client() (*client, error) {
+	return newClient(d.URL, d.ClientCert, d.ClientKey, d.ServerCert)
+}
+
+func (d *Driver) instancePath() string {
+	return "/1.0/instances/" + url.PathEscape(d.MachineName)
+}
+
+// PreCreateCheck checks that LXD can be reached and trusts the client.
+func (d *Driver) PreCreateCheck() error {
+	c, err := d.client()
+	if err != nil {
+		return err
+	}
+
+	var server struct {
+		Auth string `json:"auth"`
+	}
+	if err := c.query(http.MethodGet, "/1.0", nil, &server); err != nil {
+		return fmt.Errorf("Unable to reach LXD: %s", err)
+	}
+
+	if server.Auth != "trusted" {
+		return errors.New("LXD doesn't trust the client certificate")
+	}
+
+	return nil
+}
+
+func (d *Driver) Create() error {
+	log.Infof("Creating SSH key...")
+	if err := ssh.GenerateSSHKey(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	publicKey, err := ioutil.ReadFile(d.GetSSHKeyPath() + ".pub")
+	if err != nil {
+		return err
+	}
+
+	c, err := d.client()
+	if err != nil {
+		return err
+	}
+
+	source := map[string]string{
+		"type":  "image",
+		"alias": d.Image,
+	}
+	if d.ImageServer != "" {
+		source["mode"] = "pull"
+		source["server"] = d.ImageServer
+		source["protocol"] = "simplestreams"
+	}
+
+	config := map[string]string{
+		"limits.cpu":           strconv.Itoa(d.CPU),
+		"limits.memory":        fmt.Sprintf("%dMB", d.Memory),
+		"cloud-init.user-data": d.userData(string(publicKey)),
+	}
+
+	instanceType := "virtual-machine"
+	if !d.VM {
+		instanceType = "container"
+		// Docker runs its containers in the system container.
+		config["security.nesting"] = "true"
+	}
+
+	log.Infof("Creating instance from %s...", d.Image)
+	if err := c.query(http.MethodPost, "/1.0/instances", map[string]interface{}{
+		"name":   d.MachineName,
+		"type":   instanceType,
+		"source": source,
+		"config": config,
+	}, nil); err != nil {
+		return fmt.Errorf("Error creating the instance: %s", err)
+	}
+
+	log.Infof("Starting the instance...")
+	if err := d.Start(); err != nil {
+		return err
+	}
+
+	log.Infof("Waiting for the instance to have an IP address...")
+	return mcnutils.WaitFor(func() bool {
+		ip, err := d.GetIP()
+		return err == nil && ip != ""
+	})
+}
+
+// userData is the cloud-init configuration creating the SSH user, who can
+// run sudo like on the VMs of the clouds.
+func (d *Driver) userData(publicKey string) string {
+	return fmt.Sprintf(`#cloud-config
+users:
+  - name: %s
+    sudo: ALL=(ALL) NOPASSWD:ALL
+    shell: /bin/bash
+    ssh_authorized_keys:
+      - %s
+`, d.GetSSHUsername(), strings.TrimSpace(publicKey))
+}
+
+// instanceState is the state of an instance reported by LXD.
+type instanceState struct {
+	Status  string `json:"status"`
+	Network map[string]struct {
+		Addresses []struct {
+			Family  string `json:"family"`
+			Address string `json:"address"`
+			Scope   string `json:"scope"`
+		} `json:"addresses"`
+	} `json:"network"`
+}
+
+func (d *Driver) instanceState() (*instanceState, error) {
+	c, err := d.client()
+	if err != nil {
+		return nil, err
+	}
+
+	var s instanceState
+	if err := c.query(http.MethodGet, d.instancePath()+"/state", nil, &s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// changeState runs the action, e.g. start or stop, on the instance.
+func (d *Driver) changeState(action string, force bool) error {
+	c, err := d.client()
+	if err != nil {
+		return err
+	}
+
+	return c.query(http.MethodPut, d.instancePath()+"/state", map[string]interface{}{
+		"action":  action,
+		"timeout": stopTimeout,
+		"force":   force,
+	}, nil)
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	s, err := d.instanceState()
+	if err != nil {
+		return state.Error, err
+	}
+
+	switch s.Status {
+	case "Running":
+		return state.Running, nil
+	case "Frozen":
+		return state.Paused, nil
+	case "Starting":
+		return state.Starting, nil
+	case "Stopping":
+		return state.Stopping, nil
+	case "Stopped":
+		return state.Stopped, nil
+	case "Error":
+		return state.Error, nil
+	}
+	return state.None, nil
+}
+
+// GetIP returns the global IPv4 address of the instance, on the network of
+// LXD.
+func (d *Driver) GetIP() (string, error) {
+	s, err := d.instanceState()
+	if err != nil {
+		return "", err
+	}
+
+	if s.Status != "Running" {
+		return "", drivers.ErrHostIsNotRunning
+	}
+
+	for name, network := range s.Network {
+		if name == "lo" {
+			continue
+		}
+		for _, addr := range network.Addresses {
+			if addr.Family == "inet" && addr.Scope == "global" {
+				return addr.Address, nil
+			}
+		}
+	}
+
+	return "", errors.New("The instance has no IP address yet")
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+func (d *Driver) GetSSHUsername() string {
+	if d.SSHUser == "" {
+		d.SSHUser = defaultSSHUser
+	}
+
+	return d.SSHUser
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tcp://%s:2376", ip), nil
+}
+
+func (d *Driver) Start() error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	switch s {
+	case state.Running:
+		return nil
+	case state.Paused:
+		log.Infof("Resuming instance ...")
+		return d.Unpause()
+	}
+
+	return d.changeState("start", false)
+}
+
+func (d *Driver) Stop() error {
+	return d.changeState("stop", false)
+}
+
+func (d *Driver) Restart() error {
+	return d.changeState("restart", false)
+}
+
+func (d *Driver) Kill() error {
+	return d.changeState("stop", true)
+}
+
+// Remove deletes the instance, stopping it first as LXD requires.
+func (d *Driver) Remove() error {
+	s, err := d.GetState()
+	if err == errNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if s != state.Stopped {
+		if err := d.Kill(); err != nil {
+			return err
+		}
+	}
+
+	c, err := d.client()
+	if err != nil {
+		return err
+	}
+
+	if err := c.query(http.MethodDelete, d.instancePath(), nil, nil); err != nil && err != errNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// Pause freezes the instance.
+func (d *Driver) Pause() error {
+	return d.changeState("freeze", false)
+}
+
+// Unpause resumes the instance frozen by Pause.
+func (d *Driver) Unpause() error {
+	return d.changeState("unfreeze", false)
+}
+
+// ConsoleLog returns what the instance wrote on its console.
+func (d *Driver) ConsoleLog() (string, error) {
+	c, err := d.client()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := c.do(http.MethodGet, d.instancePath()+"/console", nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
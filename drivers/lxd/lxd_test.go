@@ -0,0 +1,454 @@
+package lxd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeInstance is an instance of the fake LXD.
+type fakeInstance struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"`
+	Source map[string]string `json:"source"`
+	Config map[string]string `json:"config"`
+	status string
+	ip     string
+	log    string
+}
+
+// fakeLXD serves the part of the API of LXD the driver uses, with instances
+// which don't run anything. The operations are done right away.
+type fakeLXD struct {
+	*httptest.Server
+	lock       sync.Mutex
+	dir        string
+	instances  map[string]*fakeInstance
+	operations map[string]string
+	lastIP     int
+	createErr  string
+}
+
+// newFakeLXD starts a fake LXD listening on a Unix socket, like the local
+// daemons.
+func newFakeLXD(t *testing.T) *fakeLXD {
+	dir, err := ioutil.TempDir("", "fake-lxd-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("unix", filepath.Join(dir, "unix.socket"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	f := &fakeLXD{
+		dir:        dir,
+		instances:  map[string]*fakeInstance{},
+		operations: map[string]string{},
+	}
+	f.Server = httptest.NewUnstartedServer(http.HandlerFunc(f.serve))
+	f.Server.Listener = listener
+	f.Start()
+
+	return f
+}
+
+func (f *fakeLXD) Close() {
+	f.Server.Close()
+	os.RemoveAll(f.dir)
+}
+
+// url returns the URL to give the driver to use the fake LXD.
+func (f *fakeLXD) url() string {
+	return "unix://" + filepath.Join(f.dir, "unix.socket")
+}
+
+func writeResponse(w http.ResponseWriter, code int, r response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(r)
+}
+
+func writeSync(w http.ResponseWriter, metadata interface{}) {
+	data, _ := json.Marshal(metadata)
+	writeResponse(w, http.StatusOK, response{Type: "sync", Status: "Success", StatusCode: 200, Metadata: data})
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeResponse(w, code, response{Type: "error", Error: message, ErrorCode: code})
+}
+
+// writeOperation answers with an operation in the background, which ends
+// with the error unless it's empty.
+func (f *fakeLXD) writeOperation(w http.ResponseWriter, err string) {
+	id := fmt.Sprintf("op-%d", len(f.operations)+1)
+	f.operations[id] = err
+	writeResponse(w, http.StatusAccepted, response{
+		Type:       "async",
+		Status:     "Operation created",
+		StatusCode: 100,
+		Operation:  "/1.0/operations/" + id,
+	})
+}
+
+func (f *fakeLXD) serve(w http.ResponseWriter, r *http.Request) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	path := r.URL.Path
+
+	switch {
+	case path == "/1.0":
+		writeSync(w, map[string]string{"auth": "trusted"})
+	case strings.HasPrefix(path, "/1.0/operations/") && strings.HasSuffix(path, "/wait"):
+		id := strings.TrimSuffix(strings.TrimPrefix(path, "/1.0/operations/"), "/wait")
+		err, ok := f.operations[id]
+		switch {
+		case !ok:
+			writeError(w, http.StatusNotFound, "Operation not found")
+		case err != "":
+			writeSync(w, operation{ID: id, Status: "Failure", StatusCode: 400, Err: err})
+		default:
+			writeSync(w, operation{ID: id, Status: "Success", StatusCode: 200})
+		}
+	case path == "/1.0/instances" && r.Method == http.MethodPost:
+		f.createInstance(w, r)
+	case strings.HasPrefix(path, "/1.0/instances/"):
+		parts := strings.SplitN(strings.TrimPrefix(path, "/1.0/instances/"), "/", 2)
+		instance := f.instances[parts[0]]
+		if instance == nil {
+			writeError(w, http.StatusNotFound, "Instance not found")
+			return
+		}
+
+		action := ""
+		if len(parts) == 2 {
+			action = parts[1]
+		}
+		f.instanceAction(w, r, instance, action)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (f *fakeLXD) createInstance(w http.ResponseWriter, r *http.Request) {
+	var instance fakeInstance
+	if err := json.NewDecoder(r.Body).Decode(&instance); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if f.instances[instance.Name] != nil {
+		writeError(w, http.StatusConflict, "Instance already exists")
+		return
+	}
+
+	if f.createErr != "" {
+		f.writeOperation(w, f.createErr)
+		return
+	}
+
+	instance.status = "Stopped"
+	f.instances[instance.Name] = &instance
+	f.writeOperation(w, "")
+}
+
+func (f *fakeLXD) instanceAction(w http.ResponseWriter, r *http.Request, instance *fakeInstance, action string) {
+	switch {
+	case action == "" && r.Method == http.MethodDelete:
+		if instance.status != "Stopped" {
+			f.writeOperation(w, "The instance is currently running, stop it first")
+			return
+		}
+		delete(f.instances, instance.Name)
+		f.writeOperation(w, "")
+	case action == "state" && r.Method == http.MethodGet:
+		s := map[string]interface{}{"status": instance.status}
+		if instance.ip != "" {
+			s["network"] = map[string]interface{}{
+				"lo": map[string]interface{}{
+					"addresses": []map[string]string{{"family": "inet", "address": "127.0.0.1", "scope": "local"}},
+				},
+				"eth0": map[string]interface{}{
+					"addresses": []map[string]string{
+						{"family": "inet6", "address": "fe80::1", "scope": "link"},
+						{"family": "inet", "address": instance.ip, "scope": "global"},
+					},
+				},
+			}
+		}
+		writeSync(w, s)
+	case action == "state" && r.Method == http.MethodPut:
+		var req struct {
+			Action string `json:"action"`
+			Force  bool   `json:"force"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		f.writeOperation(w, f.changeState(instance, req.Action))
+	case action == "console" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		fmt.Fprint(w, instance.log)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// changeState runs the action on the instance, returning the error of the
+// operation.
+func (f *fakeLXD) changeState(instance *fakeInstance, action string) string {
+	switch action {
+	case "start":
+		if instance.status != "Stopped" {
+			return "The instance is already running"
+		}
+		f.lastIP++
+		instance.status = "Running"
+		instance.ip = fmt.Sprintf("10.158.0.%d", f.lastIP)
+	case "restart":
+		if instance.status != "Running" {
+			return "The instance isn't running"
+		}
+	case "stop":
+		if instance.status == "Stopped" {
+			return "The instance is already stopped"
+		}
+		instance.status = "Stopped"
+		instance.ip = ""
+	case "freeze":
+		if instance.status != "Running" {
+			return "The instance isn't running"
+		}
+		instance.status = "Frozen"
+	case "unfreeze":
+		if instance.status != "Frozen" {
+			return "The instance isn't frozen"
+		}
+		instance.status = "Running"
+	default:
+		return "Unknown action " + action
+	}
+
+	return ""
+}
+
+// newTestDriver returns a driver of the machine using the fake LXD.
+func newTestDriver(t *testing.T, lxd *fakeLXD, flags map[string]interface{}) *Driver {
+	storePath, err := ioutil.TempDir("", "lxd-driver-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(storePath) })
+
+	d := NewDriver("default", storePath)
+	if err := os.MkdirAll(d.ResolveStorePath("."), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	data := map[string]interface{}{
+		"lxd-url":          lxd.url(),
+		"lxd-image":        defaultImage,
+		"lxd-image-server": defaultImageServer,
+		"lxd-cpu-count":    defaultCPU,
+		"lxd-memory":       defaultMemory,
+		"lxd-ssh-user":     defaultSSHUser,
+	}
+	for name, value := range flags {
+		data[name] = value
+	}
+
+	if err := d.SetConfigFromFlags(&commandstest.FakeFlagger{Data: data}); err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"lxd-url":       "https://lxd.example.com:8443",
+			"lxd-image":     "debian/12/cloud",
+			"lxd-vm":        true,
+			"lxd-cpu-count": 2,
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, "https://lxd.example.com:8443", driver.URL)
+	assert.Equal(t, "debian/12/cloud", driver.Image)
+	assert.True(t, driver.VM)
+	assert.Equal(t, 2, driver.CPU)
+	assert.Equal(t, "ubuntu", driver.GetSSHUsername())
+}
+
+func TestUnsupportedURL(t *testing.T) {
+	_, err := newClient("http://lxd.example.com:8443", "", "", "")
+
+	assert.EqualError(t, err, `Unsupported LXD URL "http://lxd.example.com:8443", it must start with unix:// or https://`)
+}
+
+func TestHTTPSNeedsAClientCertificate(t *testing.T) {
+	_, err := newClient("https://lxd.example.com:8443", "", "", "")
+
+	assert.EqualError(t, err, "A client certificate and key trusted by LXD are needed to connect to it with HTTPS")
+}
+
+func TestCreateContainer(t *testing.T) {
+	lxd := newFakeLXD(t)
+	defer lxd.Close()
+	driver := newTestDriver(t, lxd, nil)
+
+	err := driver.Create()
+
+	assert.NoError(t, err)
+
+	instance := lxd.instances["default"]
+	if !assert.NotNil(t, instance) {
+		return
+	}
+	assert.Equal(t, "container", instance.Type)
+	assert.Equal(t, map[string]string{
+		"type":     "image",
+		"alias":    defaultImage,
+		"mode":     "pull",
+		"server":   defaultImageServer,
+		"protocol": "simplestreams",
+	}, instance.Source)
+	assert.Equal(t, "true", instance.Config["security.nesting"])
+	assert.Equal(t, "1", instance.Config["limits.cpu"])
+	assert.Equal(t, "1024MB", instance.Config["limits.memory"])
+	assert.Equal(t, "Running", instance.status)
+
+	publicKey, err := ioutil.ReadFile(driver.GetSSHKeyPath() + ".pub")
+	assert.NoError(t, err)
+	userData := instance.Config["cloud-init.user-data"]
+	assert.True(t, strings.HasPrefix(userData, "#cloud-config\n"))
+	assert.Contains(t, userData, "- name: ubuntu\n")
+	assert.Contains(t, userData, "- "+strings.TrimSpace(string(publicKey))+"\n")
+}
+
+func TestCreateVMFromLocalImage(t *testing.T) {
+	lxd := newFakeLXD(t)
+	defer lxd.Close()
+	driver := newTestDriver(t, lxd, map[string]interface{}{
+		"lxd-vm":           true,
+		"lxd-image":        "docker-host",
+		"lxd-image-server": "",
+	})
+
+	err := driver.Create()
+
+	assert.NoError(t, err)
+
+	instance := lxd.instances["default"]
+	if !assert.NotNil(t, instance) {
+		return
+	}
+	assert.Equal(t, "virtual-machine", instance.Type)
+	assert.Equal(t, map[string]string{"type": "image", "alias": "docker-host"}, instance.Source)
+	assert.NotContains(t, instance.Config, "security.nesting")
+}
+
+func TestCreateFails(t *testing.T) {
+	lxd := newFakeLXD(t)
+	defer lxd.Close()
+	lxd.createErr = "Failed getting remote image info: Image not found"
+	driver := newTestDriver(t, lxd, nil)
+
+	err := driver.Create()
+
+	assert.EqualError(t, err, "Error creating the instance: Failed getting remote image info: Image not found")
+}
+
+func TestGetIP(t *testing.T) {
+	lxd := newFakeLXD(t)
+	defer lxd.Close()
+	driver := newTestDriver(t, lxd, nil)
+	assert.NoError(t, driver.Create())
+
+	ip, err := driver.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "10.158.0.1", ip)
+
+	url, err := driver.GetURL()
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://10.158.0.1:2376", url)
+
+	assert.NoError(t, driver.Stop())
+
+	_, err = driver.GetIP()
+	assert.Equal(t, drivers.ErrHostIsNotRunning, err)
+}
+
+func TestGetStateMissingInstance(t *testing.T) {
+	lxd := newFakeLXD(t)
+	defer lxd.Close()
+	driver := newTestDriver(t, lxd, nil)
+
+	s, err := driver.GetState()
+
+	assert.Equal(t, errNotFound, err)
+	assert.Equal(t, state.Error, s)
+}
+
+func TestRemoveRunningInstance(t *testing.T) {
+	lxd := newFakeLXD(t)
+	defer lxd.Close()
+	driver := newTestDriver(t, lxd, nil)
+	assert.NoError(t, driver.Create())
+
+	err := driver.Remove()
+
+	assert.NoError(t, err)
+	assert.Empty(t, lxd.instances)
+}
+
+func TestStartResumesAFrozenInstance(t *testing.T) {
+	lxd := newFakeLXD(t)
+	defer lxd.Close()
+	driver := newTestDriver(t, lxd, nil)
+	assert.NoError(t, driver.Create())
+	assert.NoError(t, driver.Pause())
+
+	err := driver.Start()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Running", lxd.instances["default"].status)
+}
+
+func TestConsoleLog(t *testing.T) {
+	lxd := newFakeLXD(t)
+	defer lxd.Close()
+	driver := newTestDriver(t, lxd, nil)
+	assert.NoError(t, driver.Create())
+	lxd.instances["default"].log = "Cloud-init finished\n"
+
+	log, err := driver.ConsoleLog()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Cloud-init finished\n", log)
+}
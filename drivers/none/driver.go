@@ -31,9 +31,10 @@ func NewDriver(hostName, storePath string) *Driver {
 func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 	return []mcnflag.Flag{
 		mcnflag.StringFlag{
-			Name:  "url",
-			Usage: "URL of host when no driver is selected",
-			Value: "",
+			Name:     "url",
+			Usage:    "URL of host when no driver is selected",
+			Value:    "",
+			Required: true,
 		},
 	}
 }
@@ -0,0 +1,24 @@
+package openstack
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/driverstest"
+)
+
+func TestConformance(t *testing.T) {
+	driverstest.Run(t, driverstest.Suite{
+		NewDriver: func(machineName, storePath string) drivers.Driver {
+			return NewDriver(machineName, storePath)
+		},
+		FlagValues: map[string]interface{}{
+			"openstack-auth-url":  "http://url",
+			"openstack-username":  "user",
+			"openstack-password":  "pwd",
+			"openstack-tenant-id": "ID",
+			"openstack-flavor-id": "ID",
+			"openstack-image-id":  "ID",
+		},
+	})
+}
@@ -99,10 +99,11 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  "",
 		},
 		mcnflag.StringFlag{
-			EnvVar: "OS_PASSWORD",
-			Name:   "openstack-password",
-			Usage:  "OpenStack password",
-			Value:  "",
+			EnvVar:    "OS_PASSWORD",
+			Name:      "openstack-password",
+			Usage:     "OpenStack password",
+			Value:     "",
+			Sensitive: true,
 		},
 		mcnflag.StringFlag{
 			EnvVar: "OS_TENANT_NAME",
@@ -0,0 +1,23 @@
+package pool
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/driverstest"
+)
+
+func TestConformance(t *testing.T) {
+	dir := newTestPool(t)
+
+	driverstest.Run(t, driverstest.Suite{
+		NewDriver: func(machineName, storePath string) drivers.Driver {
+			return NewDriver(machineName, storePath)
+		},
+		FlagValues: map[string]interface{}{
+			"pool-inventory": filepath.Join(dir, "inventory.json"),
+		},
+		RemoveMissing: true,
+	})
+}
@@ -0,0 +1,250 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/hostpool"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	defaultTimeout = 15 * time.Second
+
+	// wipeCommand stops Docker and removes its data and the configuration
+	// written by the provisioning, so that the next machine leasing the
+	// host starts from a clean engine.
+	wipeCommand = "(sudo systemctl stop docker.socket docker || sudo service docker stop || true) && " +
+		"sudo rm -rf /var/lib/docker /etc/docker /etc/systemd/system/docker.service.d/10-machine.conf && " +
+		"(sudo systemctl daemon-reload || true)"
+)
+
+type Driver struct {
+	*drivers.BaseDriver
+	Inventory  string
+	LeaseDir   string
+	PoolHost   string
+	EnginePort int
+	SSHKey     string
+
+	runSSHCommand func(d drivers.Driver, command string) (string, error)
+}
+
+// NewDriver creates and returns a new instance of the driver
+func NewDriver(hostName, storePath string) *Driver {
+	return &Driver{
+		EnginePort:    engine.DefaultPort,
+		runSSHCommand: drivers.RunSSHCommandFromDriver,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.StringFlag{
+			Name:     "pool-inventory",
+			Usage:    "JSON inventory of the hosts of the pool",
+			EnvVar:   "POOL_INVENTORY",
+			Required: true,
+		},
+		mcnflag.StringFlag{
+			Name:   "pool-lease-dir",
+			Usage:  "Directory of the leases of the hosts, next to the inventory by default",
+			EnvVar: "POOL_LEASE_DIR",
+		},
+	}
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "pool"
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return d.GetIP()
+}
+
+func (d *Driver) GetSSHUsername() string {
+	return d.SSHUser
+}
+
+func (d *Driver) GetSSHKeyPath() string {
+	return d.SSHKeyPath
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.Inventory = flags.String("pool-inventory")
+	d.LeaseDir = flags.String("pool-lease-dir")
+	d.SetSwarmConfigFromFlags(flags)
+
+	if d.Inventory == "" {
+		return errors.New("pool driver requires the --pool-inventory option")
+	}
+
+	return nil
+}
+
+func (d *Driver) open() (*hostpool.Pool, error) {
+	return hostpool.Open(d.Inventory, d.LeaseDir)
+}
+
+// PreCreateCheck checks the inventory, and that one of its hosts is free
+// when the machine is created.
+func (d *Driver) PreCreateCheck() error {
+	p, err := d.open()
+	if err != nil {
+		return err
+	}
+
+	leases, err := p.Leases()
+	if err != nil {
+		return err
+	}
+
+	if len(leases) == len(p.Inventory.Hosts) {
+		return fmt.Errorf("All the hosts of %s are leased", d.Inventory)
+	}
+
+	return nil
+}
+
+// Create leases a free host of the pool to the machine.
+func (d *Driver) Create() error {
+	p, err := d.open()
+	if err != nil {
+		return err
+	}
+
+	h, err := p.Lease(d.MachineName, d.StorePath)
+	if err == hostpool.ErrNoFreeHost {
+		return fmt.Errorf("All the hosts of %s are leased", d.Inventory)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Leased host %s (%s)", h.Name, h.Address)
+
+	d.PoolHost = h.Name
+	d.IPAddress = h.Address
+	d.SSHUser = h.SSHUser
+	d.SSHPort = h.SSHPort
+	d.SSHKey = h.SSHKey
+	d.EnginePort = h.EnginePort
+
+	if d.SSHKey == "" {
+		log.Info("No SSH key specified. Assuming an existing key at the default location.")
+		return nil
+	}
+
+	log.Info("Importing SSH key...")
+	d.SSHKeyPath = d.ResolveStorePath(path.Base(d.SSHKey))
+	if err := copySSHKey(d.SSHKey, d.SSHKeyPath); err != nil {
+		if releaseErr := p.Release(d.PoolHost, d.MachineName, d.StorePath); releaseErr != nil {
+			log.Warnf("Unable to release host %s: %s", d.PoolHost, releaseErr)
+		}
+		d.PoolHost = ""
+		return err
+	}
+
+	if err := copySSHKey(d.SSHKey+".pub", d.SSHKeyPath+".pub"); err != nil {
+		log.Infof("Couldn't copy SSH public key : %s", err)
+	}
+
+	return nil
+}
+
+func (d *Driver) GetURL() (string, error) {
+	if err := drivers.MustBeRunning(d); err != nil {
+		return "", err
+	}
+
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tcp://%s", net.JoinHostPort(ip, strconv.Itoa(d.EnginePort))), nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	address := net.JoinHostPort(d.IPAddress, strconv.Itoa(d.SSHPort))
+
+	conn, err := net.DialTimeout("tcp", address, defaultTimeout)
+	if err != nil {
+		return state.Stopped, nil
+	}
+	conn.Close()
+
+	return state.Running, nil
+}
+
+func (d *Driver) Start() error {
+	return errors.New("pool driver does not support start")
+}
+
+func (d *Driver) Stop() error {
+	return errors.New("pool driver does not support stop")
+}
+
+func (d *Driver) Restart() error {
+	_, err := d.runSSHCommand(d, "sudo shutdown -r now")
+	return err
+}
+
+func (d *Driver) Kill() error {
+	return errors.New("pool driver does not support kill")
+}
+
+// Remove wipes the Docker state of the host and returns it to the pool. The
+// host stays leased when it can't be wiped.
+func (d *Driver) Remove() error {
+	if d.PoolHost == "" {
+		return nil
+	}
+
+	p, err := d.open()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Wiping the Docker state of host %s...", d.PoolHost)
+	if _, err := d.runSSHCommand(d, wipeCommand); err != nil {
+		return fmt.Errorf("Unable to wipe host %s, it stays leased: %s", d.PoolHost, err)
+	}
+
+	if err := p.Release(d.PoolHost, d.MachineName, d.StorePath); err != nil {
+		return err
+	}
+
+	d.PoolHost = ""
+
+	return nil
+}
+
+func copySSHKey(src, dst string) error {
+	if err := mcnutils.CopyFile(src, dst); err != nil {
+		return fmt.Errorf("unable to copy ssh key: %s", err)
+	}
+
+	if err := os.Chmod(dst, 0600); err != nil {
+		return fmt.Errorf("unable to set permissions on the ssh key: %s", err)
+	}
+
+	return nil
+}
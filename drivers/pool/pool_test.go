@@ -0,0 +1,214 @@
+package pool
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/docker/machine/commands/commandstest"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/hostpool"
+	"github.com/stretchr/testify/assert"
+)
+
+const testInventory = `{
+	"ssh_user": "builder",
+	"ssh_key": "id_rsa",
+	"hosts": [
+		{"name": "builder-01", "address": "10.0.0.11"},
+		{"name": "builder-02", "address": "10.0.0.12", "engine_port": 2377}
+	]
+}`
+
+// newTestPool writes the inventory, with its SSH key, in a directory which
+// is also the storage path of the machines.
+func newTestPool(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "pool-driver-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for name, content := range map[string]string{
+		"inventory.json": testInventory,
+		"id_rsa":         "private key",
+		"id_rsa.pub":     "public key",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// sshRecorder fakes the SSH commands run on the hosts.
+type sshRecorder struct {
+	lock     sync.Mutex
+	commands []string
+	err      error
+}
+
+func (r *sshRecorder) run(d drivers.Driver, command string) (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.commands = append(r.commands, fmt.Sprintf("%s: %s", d.(*Driver).PoolHost, command))
+	return "", r.err
+}
+
+func newTestDriver(t *testing.T, dir, machineName string, ssh *sshRecorder) *Driver {
+	d := NewDriver(machineName, dir)
+	d.runSSHCommand = ssh.run
+	if err := os.MkdirAll(d.ResolveStorePath("."), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	err := d.SetConfigFromFlags(&commandstest.FakeFlagger{
+		Data: map[string]interface{}{
+			"pool-inventory": filepath.Join(dir, "inventory.json"),
+			"pool-lease-dir": "",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return d
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"pool-inventory": "/etc/builders.json",
+			"pool-lease-dir": "/var/lib/builders",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, "/etc/builders.json", driver.Inventory)
+	assert.Equal(t, "/var/lib/builders", driver.LeaseDir)
+}
+
+func TestSetConfigFromFlagsRequiresAnInventory(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.EqualError(t, err, "pool driver requires the --pool-inventory option")
+}
+
+func TestCreateLeasesAFreeHost(t *testing.T) {
+	dir := newTestPool(t)
+	first := newTestDriver(t, dir, "first", &sshRecorder{})
+	second := newTestDriver(t, dir, "second", &sshRecorder{})
+
+	assert.NoError(t, first.Create())
+	assert.NoError(t, second.Create())
+
+	assert.Equal(t, "builder-01", first.PoolHost)
+	assert.Equal(t, "10.0.0.11", first.IPAddress)
+	assert.Equal(t, "builder", first.GetSSHUsername())
+	assert.Equal(t, 22, first.SSHPort)
+	assert.Equal(t, 2376, first.EnginePort)
+	assert.Equal(t, first.ResolveStorePath("id_rsa"), first.GetSSHKeyPath())
+
+	key, err := ioutil.ReadFile(first.GetSSHKeyPath())
+	assert.NoError(t, err)
+	assert.Equal(t, "private key", string(key))
+
+	assert.Equal(t, "builder-02", second.PoolHost)
+	assert.Equal(t, "10.0.0.12", second.IPAddress)
+	assert.Equal(t, 2377, second.EnginePort)
+}
+
+func TestCreateWithoutFreeHost(t *testing.T) {
+	dir := newTestPool(t)
+	assert.NoError(t, newTestDriver(t, dir, "first", &sshRecorder{}).Create())
+	assert.NoError(t, newTestDriver(t, dir, "second", &sshRecorder{}).Create())
+	driver := newTestDriver(t, dir, "third", &sshRecorder{})
+
+	assert.EqualError(t, driver.PreCreateCheck(), fmt.Sprintf("All the hosts of %s are leased", driver.Inventory))
+	assert.EqualError(t, driver.Create(), fmt.Sprintf("All the hosts of %s are leased", driver.Inventory))
+	assert.Empty(t, driver.PoolHost)
+}
+
+func TestConcurrentCreates(t *testing.T) {
+	dir := newTestPool(t)
+
+	var wg sync.WaitGroup
+	created := make([]*Driver, 5)
+	for i := range created {
+		d := newTestDriver(t, dir, fmt.Sprintf("machine-%d", i), &sshRecorder{})
+		created[i] = d
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.Create()
+		}()
+	}
+	wg.Wait()
+
+	leased := []string{}
+	for _, d := range created {
+		if d.PoolHost != "" {
+			leased = append(leased, d.PoolHost)
+		}
+	}
+	assert.ElementsMatch(t, []string{"builder-01", "builder-02"}, leased)
+}
+
+func TestRemoveWipesAndReleasesTheHost(t *testing.T) {
+	dir := newTestPool(t)
+	ssh := &sshRecorder{}
+	driver := newTestDriver(t, dir, "first", ssh)
+	assert.NoError(t, driver.Create())
+
+	err := driver.Remove()
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"builder-01: " + wipeCommand}, ssh.commands)
+
+	p, err := hostpool.Open(driver.Inventory, "")
+	assert.NoError(t, err)
+	leases, err := p.Leases()
+	assert.NoError(t, err)
+	assert.Empty(t, leases)
+
+	// The host is leased again to the next machine.
+	next := newTestDriver(t, dir, "next", &sshRecorder{})
+	assert.NoError(t, next.Create())
+	assert.Equal(t, "builder-01", next.PoolHost)
+}
+
+func TestRemoveKeepsTheLeaseWhenTheWipeFails(t *testing.T) {
+	dir := newTestPool(t)
+	driver := newTestDriver(t, dir, "first", &sshRecorder{err: errors.New("connection refused")})
+	assert.NoError(t, driver.Create())
+
+	err := driver.Remove()
+
+	assert.EqualError(t, err, "Unable to wipe host builder-01, it stays leased: connection refused")
+
+	p, err := hostpool.Open(driver.Inventory, "")
+	assert.NoError(t, err)
+	leases, err := p.Leases()
+	assert.NoError(t, err)
+	assert.Equal(t, "first", leases["builder-01"].Machine)
+}
@@ -0,0 +1,61 @@
+package qemu
+
+import (
+	"os"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+type DiskCreator interface {
+	Create(size int, publicSSHKeyPath, diskPath string) error
+}
+
+func NewDiskCreator(qemu QemuManager) DiskCreator {
+	return &qemuImgDiskCreator{qemu: qemu}
+}
+
+type qemuImgDiskCreator struct {
+	qemu QemuManager
+}
+
+// Create makes a boot2docker qcow2 disk image of the given size in MB. The
+// raw image holding the SSH key is converted by qemu-img.
+func (c *qemuImgDiskCreator) Create(size int, publicSSHKeyPath, diskPath string) error {
+	log.Debugf("Creating %d MB hard disk image...", size)
+
+	tarBuf, err := mcnutils.MakeDiskImage(publicSSHKeyPath)
+	if err != nil {
+		return err
+	}
+
+	rawPath := diskPath + ".raw"
+	defer os.Remove(rawPath)
+
+	if err := writeRawImage(rawPath, size, tarBuf.Bytes()); err != nil {
+		return err
+	}
+
+	return c.qemu.qemuImg("convert", "-f", "raw", "-O", "qcow2", rawPath, diskPath)
+}
+
+// writeRawImage writes the data at the start of a sparse raw image of the
+// given size in MB.
+func writeRawImage(path string, size int, data []byte) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Truncate(int64(size) << 20); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,62 @@
+package qemu
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// convertingQemuManager checks the raw image qemu-img converts.
+type convertingQemuManager struct {
+	test    *testing.T
+	args    string
+	rawData []byte
+	rawSize int64
+}
+
+func (q *convertingQemuManager) qemuImg(args ...string) error {
+	q.args = strings.Join(args, " ")
+
+	rawPath := args[len(args)-2]
+	fi, err := os.Stat(rawPath)
+	if err != nil {
+		q.test.Fatal(err)
+	}
+	q.rawSize = fi.Size()
+
+	q.rawData, err = ioutil.ReadFile(rawPath)
+	return err
+}
+
+func (q *convertingQemuManager) qemuSystem(binary string, args ...string) error {
+	return nil
+}
+
+func TestDiskCreator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "qemu-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	publicKeyPath := filepath.Join(dir, "id_rsa.pub")
+	assert.NoError(t, ioutil.WriteFile(publicKeyPath, []byte("ssh-rsa AAAA test"), 0600))
+
+	qemu := &convertingQemuManager{test: t}
+	diskPath := filepath.Join(dir, "disk.qcow2")
+
+	err = NewDiskCreator(qemu).Create(2, publicKeyPath, diskPath)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "convert -f raw -O qcow2 "+diskPath+".raw "+diskPath, qemu.args)
+	assert.Equal(t, int64(2<<20), qemu.rawSize)
+	assert.True(t, strings.HasPrefix(string(qemu.rawData), "boot2docker, please format-me"))
+	assert.Contains(t, string(qemu.rawData), "ssh-rsa AAAA test")
+
+	_, err = os.Stat(diskPath + ".raw")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,86 @@
+package qemu
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/ssh"
+)
+
+// B2DUpdater describes the interactions with b2d.
+type B2DUpdater interface {
+	UpdateISOCache(storePath, isoURL string) error
+	CopyIsoToMachineDir(storePath, machineName, isoURL string) error
+}
+
+func NewB2DUpdater() B2DUpdater {
+	return &b2dUtilsUpdater{}
+}
+
+type b2dUtilsUpdater struct{}
+
+func (u *b2dUtilsUpdater) CopyIsoToMachineDir(storePath, machineName, isoURL string) error {
+	return mcnutils.NewB2dUtils(storePath).CopyIsoToMachineDir(isoURL, machineName)
+}
+
+func (u *b2dUtilsUpdater) UpdateISOCache(storePath, isoURL string) error {
+	return mcnutils.NewB2dUtils(storePath).UpdateISOCache(isoURL)
+}
+
+// SSHKeyGenerator describes the generation of ssh keys.
+type SSHKeyGenerator interface {
+	Generate(path string) error
+}
+
+func NewSSHKeyGenerator() SSHKeyGenerator {
+	return &defaultSSHKeyGenerator{}
+}
+
+type defaultSSHKeyGenerator struct{}
+
+func (g *defaultSSHKeyGenerator) Generate(path string) error {
+	return ssh.GenerateSSHKey(path)
+}
+
+// SSHWaiter waits for SSH to be available on a started VM.
+type SSHWaiter interface {
+	Wait(d *Driver) error
+}
+
+func NewSSHWaiter() SSHWaiter {
+	return &defaultSSHWaiter{}
+}
+
+type defaultSSHWaiter struct{}
+
+func (w *defaultSSHWaiter) Wait(d *Driver) error {
+	return drivers.WaitForSSH(d)
+}
+
+// PortFinder finds the free ports of the loopback interface the ports of
+// the VM are forwarded from.
+type PortFinder interface {
+	FreePort(port int) (int, error)
+}
+
+func NewPortFinder() PortFinder {
+	return &tcpPortFinder{}
+}
+
+type tcpPortFinder struct{}
+
+// FreePort returns the port when it is free, another free port otherwise.
+func (f *tcpPortFinder) FreePort(port int) (int, error) {
+	ln, err := net.Listen("tcp4", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil && port != 0 {
+		ln, err = net.Listen("tcp4", "127.0.0.1:0")
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,433 @@
+package qemu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/mcnutils"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const (
+	defaultCPU            = 1
+	defaultMemory         = 1024
+	defaultDiskSize       = 20000
+	defaultBoot2DockerURL = ""
+	defaultQemuBinary     = "qemu-system-x86_64"
+	defaultSSHUser        = "docker"
+
+	// stopAttempts is how many seconds a VM is given to stop.
+	stopAttempts = 90
+)
+
+// kvmDevice is opened by QEMU for the KVM acceleration.
+var kvmDevice = "/dev/kvm"
+
+type Driver struct {
+	*drivers.BaseDriver
+	QemuManager
+	qmp             QMPClient
+	b2dUpdater      B2DUpdater
+	sshKeyGenerator SSHKeyGenerator
+	diskCreator     DiskCreator
+	sshWaiter       SSHWaiter
+	portFinder      PortFinder
+	CPU             int
+	Memory          int
+	DiskSize        int
+	Boot2DockerURL  string
+	QemuBinary      string
+	EnginePort      int
+}
+
+// NewDriver creates a new QEMU driver with default settings.
+func NewDriver(hostName, storePath string) *Driver {
+	qemu := NewQemuManager()
+
+	return &Driver{
+		QemuManager:     qemu,
+		qmp:             NewQMPClient(),
+		b2dUpdater:      NewB2DUpdater(),
+		sshKeyGenerator: NewSSHKeyGenerator(),
+		diskCreator:     NewDiskCreator(qemu),
+		sshWaiter:       NewSSHWaiter(),
+		portFinder:      NewPortFinder(),
+		CPU:             defaultCPU,
+		Memory:          defaultMemory,
+		DiskSize:        defaultDiskSize,
+		QemuBinary:      defaultQemuBinary,
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: hostName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+// GetCreateFlags registers the flags this driver adds to
+// "docker hosts create"
+func (d *Driver) GetCreateFlags() []mcnflag.Flag {
+	return []mcnflag.Flag{
+		mcnflag.IntFlag{
+			Name:   "qemu-memory",
+			Usage:  "Size of memory for host in MB",
+			Value:  defaultMemory,
+			EnvVar: "QEMU_MEMORY_SIZE",
+			Min:    mcnflag.Limit(1),
+		},
+		mcnflag.IntFlag{
+			Name:   "qemu-cpu-count",
+			Usage:  "number of CPUs for the machine (-1 to use the number of CPUs available)",
+			Value:  defaultCPU,
+			EnvVar: "QEMU_CPU_COUNT",
+		},
+		mcnflag.IntFlag{
+			Name:   "qemu-disk-size",
+			Usage:  "Size of disk for host in MB",
+			Value:  defaultDiskSize,
+			EnvVar: "QEMU_DISK_SIZE",
+			Min:    mcnflag.Limit(1),
+		},
+		mcnflag.StringFlag{
+			Name:   "qemu-boot2docker-url",
+			Usage:  "The URL of the boot2docker image. Defaults to the latest available version",
+			Value:  defaultBoot2DockerURL,
+			EnvVar: "QEMU_BOOT2DOCKER_URL",
+		},
+		mcnflag.StringFlag{
+			Name:   "qemu-binary",
+			Usage:  "The QEMU system emulator to run the VM with",
+			Value:  defaultQemuBinary,
+			EnvVar: "QEMU_BINARY",
+		},
+	}
+}
+
+func (d *Driver) GetSSHHostname() (string, error) {
+	return "127.0.0.1", nil
+}
+
+func (d *Driver) GetSSHUsername() string {
+	if d.SSHUser == "" {
+		d.SSHUser = defaultSSHUser
+	}
+
+	return d.SSHUser
+}
+
+// DriverName returns the name of the driver
+func (d *Driver) DriverName() string {
+	return "qemu"
+}
+
+// GetIP returns the address the ports of the VM are forwarded from.
+func (d *Driver) GetIP() (string, error) {
+	s, err := d.GetState()
+	if err != nil {
+		return "", err
+	}
+	if s != state.Running {
+		return "", drivers.ErrHostIsNotRunning
+	}
+
+	return "127.0.0.1", nil
+}
+
+func (d *Driver) GetURL() (string, error) {
+	ip, err := d.GetIP()
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("tcp://%s:%d", ip, d.EnginePort), nil
+}
+
+func (d *Driver) SetConfigFromFlags(flags drivers.DriverOptions) error {
+	d.CPU = flags.Int("qemu-cpu-count")
+	d.Memory = flags.Int("qemu-memory")
+	d.DiskSize = flags.Int("qemu-disk-size")
+	d.Boot2DockerURL = flags.String("qemu-boot2docker-url")
+	d.QemuBinary = flags.String("qemu-binary")
+	d.SetSwarmConfigFromFlags(flags)
+	d.SSHUser = defaultSSHUser
+
+	return nil
+}
+
+// PreCreateCheck checks that the QEMU commands exist and work
+func (d *Driver) PreCreateCheck() error {
+	if err := d.qemuImg("--version"); err != nil {
+		return err
+	}
+
+	if err := d.qemuSystem(d.QemuBinary, "--version"); err != nil {
+		return err
+	}
+
+	// Downloading boot2docker to cache should be done here to make sure
+	// that a download failure will not leave a machine half created.
+	return d.b2dUpdater.UpdateISOCache(d.StorePath, d.Boot2DockerURL)
+}
+
+func (d *Driver) Create() error {
+	if err := d.b2dUpdater.CopyIsoToMachineDir(d.StorePath, d.MachineName, d.Boot2DockerURL); err != nil {
+		return err
+	}
+
+	log.Infof("Creating SSH key...")
+	if err := d.sshKeyGenerator.Generate(d.GetSSHKeyPath()); err != nil {
+		return err
+	}
+
+	log.Infof("Creating disk image...")
+	if err := d.diskCreator.Create(d.DiskSize, d.publicSSHKeyPath(), d.diskPath()); err != nil {
+		return err
+	}
+
+	log.Info("Starting the VM...")
+	return d.Start()
+}
+
+func (d *Driver) Start() error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	switch s {
+	case state.Stopped:
+		if err := d.launch(); err != nil {
+			return err
+		}
+	case state.Paused:
+		log.Infof("Resuming VM ...")
+		if err := d.Unpause(); err != nil {
+			return err
+		}
+	default:
+		log.Infof("VM not in restartable state")
+	}
+
+	log.Infof("Waiting for SSH...")
+	return d.sshWaiter.Wait(d)
+}
+
+// launch runs QEMU, with the SSH and Docker ports of the VM forwarded from
+// the loopback interface so that no privileges are needed.
+func (d *Driver) launch() error {
+	var err error
+	if d.SSHPort, err = d.portFinder.FreePort(d.SSHPort); err != nil {
+		return err
+	}
+	if d.EnginePort, err = d.portFinder.FreePort(d.EnginePort); err != nil {
+		return err
+	}
+
+	cpus := d.CPU
+	if cpus < 1 {
+		cpus = runtime.NumCPU()
+	}
+
+	args := []string{
+		"-name", d.MachineName,
+	}
+
+	if kvmAvailable() {
+		args = append(args, "-machine", "accel=kvm", "-cpu", "host")
+	} else {
+		log.Warnf("KVM is not available, the VM is emulated and will be slow")
+		args = append(args, "-machine", "accel=tcg")
+	}
+
+	args = append(args,
+		"-smp", strconv.Itoa(cpus),
+		"-m", strconv.Itoa(d.Memory),
+		"-boot", "d",
+		"-cdrom", d.isoPath(),
+		"-drive", fmt.Sprintf("file=%s,if=virtio,format=qcow2", d.diskPath()),
+		"-netdev", fmt.Sprintf("user,id=net0,hostfwd=tcp:127.0.0.1:%d-:22,hostfwd=tcp:127.0.0.1:%d-:2376", d.SSHPort, d.EnginePort),
+		"-device", "virtio-net-pci,netdev=net0",
+		"-display", "none",
+		"-serial", "file:"+d.consoleLogPath(),
+		"-qmp", fmt.Sprintf("unix:%s,server,nowait", d.monitorPath()),
+		"-pidfile", d.pidfilePath(),
+		"-daemonize")
+
+	if err := d.qemuSystem(d.QemuBinary, args...); err != nil {
+		return fmt.Errorf("Unable to start the VM: %s", err)
+	}
+
+	return nil
+}
+
+// kvmAvailable tells whether QEMU can use KVM, which is much faster than
+// its own emulation.
+func kvmAvailable() bool {
+	f, err := os.OpenFile(kvmDevice, os.O_RDWR, 0)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Debugf("Unable to use %s: %s", kvmDevice, err)
+		}
+		return false
+	}
+	f.Close()
+
+	return true
+}
+
+func (d *Driver) Stop() error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	switch s {
+	case state.Stopped:
+		return nil
+	case state.Paused:
+		log.Infof("Resuming VM ...")
+		if err := d.Unpause(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := d.qmp.execute(d.monitorPath(), "system_powerdown"); err != nil {
+		return err
+	}
+
+	return d.waitStopped()
+}
+
+// Restart restarts a machine which is known to be running.
+func (d *Driver) Restart() error {
+	if err := d.Stop(); err != nil {
+		return fmt.Errorf("Problem stopping the VM: %s", err)
+	}
+
+	if err := d.Start(); err != nil {
+		return fmt.Errorf("Problem starting the VM: %s", err)
+	}
+
+	return nil
+}
+
+func (d *Driver) Kill() error {
+	// QEMU may exit before answering.
+	if _, err := d.qmp.execute(d.monitorPath(), "quit"); err != nil && err != io.EOF && err != errMonitorNotListening {
+		return err
+	}
+
+	return d.waitStopped()
+}
+
+func (d *Driver) waitStopped() error {
+	return mcnutils.WaitForSpecificOrError(func() (bool, error) {
+		s, err := d.GetState()
+		return s == state.Stopped, err
+	}, stopAttempts, time.Second)
+}
+
+func (d *Driver) Remove() error {
+	s, err := d.GetState()
+	if err != nil {
+		return err
+	}
+
+	if s != state.Stopped {
+		if err := d.Kill(); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Remove(d.diskPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (d *Driver) GetState() (state.State, error) {
+	out, err := d.qmp.execute(d.monitorPath(), "query-status")
+	if err == errMonitorNotListening {
+		return state.Stopped, nil
+	}
+	if err != nil {
+		return state.Error, err
+	}
+
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(out, &status); err != nil {
+		return state.Error, err
+	}
+
+	switch status.Status {
+	case "running":
+		return state.Running, nil
+	case "paused", "suspended":
+		return state.Paused, nil
+	case "prelaunch", "inmigrate":
+		return state.Starting, nil
+	case "shutdown":
+		// QEMU exits right after the VM shut down.
+		return state.Stopping, nil
+	case "internal-error", "io-error", "guest-panicked":
+		return state.Error, nil
+	}
+	return state.None, nil
+}
+
+// Pause freezes the VM, keeping its memory.
+func (d *Driver) Pause() error {
+	_, err := d.qmp.execute(d.monitorPath(), "stop")
+	return err
+}
+
+// Unpause resumes the VM frozen by Pause.
+func (d *Driver) Unpause() error {
+	_, err := d.qmp.execute(d.monitorPath(), "cont")
+	return err
+}
+
+// ConsoleLog returns what the VM wrote on its serial console.
+func (d *Driver) ConsoleLog() (string, error) {
+	data, err := ioutil.ReadFile(d.consoleLogPath())
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func (d *Driver) publicSSHKeyPath() string {
+	return d.GetSSHKeyPath() + ".pub"
+}
+
+func (d *Driver) isoPath() string {
+	return d.ResolveStorePath("boot2docker.iso")
+}
+
+func (d *Driver) diskPath() string {
+	return d.ResolveStorePath("disk.qcow2")
+}
+
+func (d *Driver) monitorPath() string {
+	return d.ResolveStorePath("monitor.sock")
+}
+
+func (d *Driver) pidfilePath() string {
+	return d.ResolveStorePath("qemu.pid")
+}
+
+func (d *Driver) consoleLogPath() string {
+	return d.ResolveStorePath("console.log")
+}
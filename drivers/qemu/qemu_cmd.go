@@ -0,0 +1,64 @@
+package qemu
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+const qemuImgCmd = "qemu-img"
+
+// QemuManager defines the interface to run the QEMU commands.
+type QemuManager interface {
+	qemuImg(args ...string) error
+
+	qemuSystem(binary string, args ...string) error
+}
+
+// QemuCmdManager runs the QEMU commands found in the path.
+type QemuCmdManager struct {
+	runCmd func(cmd *exec.Cmd) error
+}
+
+// NewQemuManager creates a QemuManager instance.
+func NewQemuManager() *QemuCmdManager {
+	return &QemuCmdManager{
+		runCmd: func(cmd *exec.Cmd) error { return cmd.Run() },
+	}
+}
+
+func (q *QemuCmdManager) qemuImg(args ...string) error {
+	return q.run(qemuImgCmd, args...)
+}
+
+// qemuSystem runs the emulator, which is expected to daemonize once the VM
+// is started.
+func (q *QemuCmdManager) qemuSystem(binary string, args ...string) error {
+	return q.run(binary, args...)
+}
+
+func (q *QemuCmdManager) run(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	log.Debugf("COMMAND: %v %v", name, strings.Join(args, " "))
+
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := q.runCmd(cmd)
+	log.Debugf("STDOUT:\n{\n%v}", stdout.String())
+	log.Debugf("STDERR:\n{\n%v}", stderr.String())
+
+	if err != nil {
+		if ee, ok := err.(*exec.Error); ok && ee.Err == exec.ErrNotFound {
+			return fmt.Errorf("%s not found. Make sure QEMU is installed and %s is in the path", name, name)
+		}
+		return fmt.Errorf("%v %v failed:\n%v", name, strings.Join(args, " "), stderr.String())
+	}
+
+	return nil
+}
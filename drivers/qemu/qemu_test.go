@@ -0,0 +1,464 @@
+package qemu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockOperations struct {
+	test          *testing.T
+	expectedCalls []Call
+	call          int
+}
+
+type Call struct {
+	signature string
+	output    string
+	err       error
+}
+
+func (m *MockOperations) qemuImg(args ...string) error {
+	_, err := m.doCall("qemu-img " + strings.Join(args, " "))
+	return err
+}
+
+func (m *MockOperations) qemuSystem(binary string, args ...string) error {
+	_, err := m.doCall(binary + " " + strings.Join(args, " "))
+	return err
+}
+
+func (m *MockOperations) execute(socketPath, command string) (json.RawMessage, error) {
+	output, err := m.doCall("qmp " + socketPath + " " + command)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(output), nil
+}
+
+func (m *MockOperations) UpdateISOCache(storePath, isoURL string) error {
+	_, err := m.doCall("UpdateISOCache " + storePath + " " + isoURL)
+	return err
+}
+
+func (m *MockOperations) CopyIsoToMachineDir(storePath, machineName, isoURL string) error {
+	_, err := m.doCall("CopyIsoToMachineDir " + storePath + " " + machineName + " " + isoURL)
+	return err
+}
+
+func (m *MockOperations) Generate(path string) error {
+	_, err := m.doCall("Generate " + path)
+	return err
+}
+
+func (m *MockOperations) Create(size int, publicSSHKeyPath, diskPath string) error {
+	_, err := m.doCall(fmt.Sprintf("Create %d %s %s", size, publicSSHKeyPath, diskPath))
+	return err
+}
+
+func (m *MockOperations) Wait(d *Driver) error {
+	_, err := m.doCall("WaitSSH")
+	return err
+}
+
+func (m *MockOperations) FreePort(port int) (int, error) {
+	output, err := m.doCall(fmt.Sprintf("FreePort %d", port))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(output)
+}
+
+func (m *MockOperations) doCall(callSignature string) (string, error) {
+	if m.call >= len(m.expectedCalls) {
+		m.test.Fatal("Unexpected call", callSignature)
+	}
+
+	call := m.expectedCalls[m.call]
+	if callSignature != call.signature {
+		m.test.Fatal("Unexpected call", callSignature)
+	}
+
+	m.call++
+
+	return call.output, call.err
+}
+
+func (m *MockOperations) verify() {
+	if m.call != len(m.expectedCalls) {
+		m.test.Fatal("Missing call", m.expectedCalls[m.call].signature)
+	}
+}
+
+func mockCalls(t *testing.T, driver *Driver, expectedCalls []Call) *MockOperations {
+	mockOperations := &MockOperations{
+		test:          t,
+		expectedCalls: expectedCalls,
+	}
+
+	driver.Boot2DockerURL = "http://b2d.org"
+	driver.QemuManager = mockOperations
+	driver.qmp = mockOperations
+	driver.b2dUpdater = mockOperations
+	driver.sshKeyGenerator = mockOperations
+	driver.diskCreator = mockOperations
+	driver.sshWaiter = mockOperations
+	driver.portFinder = mockOperations
+
+	return mockOperations
+}
+
+// withKVM makes KVM available or not while the test runs.
+func withKVM(t *testing.T, available bool) func() {
+	previous := kvmDevice
+
+	dir, err := ioutil.TempDir("", "qemu-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kvmDevice = filepath.Join(dir, "kvm")
+	if available {
+		if err := ioutil.WriteFile(kvmDevice, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return func() {
+		kvmDevice = previous
+		os.RemoveAll(dir)
+	}
+}
+
+const launchArgs = "-smp 1 -m 1024 -boot d -cdrom path/machines/default/boot2docker.iso -drive file=path/machines/default/disk.qcow2,if=virtio,format=qcow2 -netdev user,id=net0,hostfwd=tcp:127.0.0.1:2222-:22,hostfwd=tcp:127.0.0.1:2376-:2376 -device virtio-net-pci,netdev=net0 -display none -serial file:path/machines/default/console.log -qmp unix:path/machines/default/monitor.sock,server,nowait -pidfile path/machines/default/qemu.pid -daemonize"
+
+func TestDriverName(t *testing.T) {
+	assert.Equal(t, "qemu", NewDriver("default", "path").DriverName())
+}
+
+func TestSSHHostname(t *testing.T) {
+	hostname, err := NewDriver("default", "path").GetSSHHostname()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", hostname)
+}
+
+func TestDefaultSSHUsername(t *testing.T) {
+	assert.Equal(t, "docker", NewDriver("default", "path").GetSSHUsername())
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	driver := NewDriver("default", "path")
+
+	checkFlags := &drivers.CheckDriverOptions{
+		FlagsValues: map[string]interface{}{
+			"qemu-cpu-count": 2,
+			"qemu-binary":    "/opt/qemu/bin/qemu-system-x86_64",
+		},
+		CreateFlags: driver.GetCreateFlags(),
+	}
+
+	err := driver.SetConfigFromFlags(checkFlags)
+
+	assert.NoError(t, err)
+	assert.Empty(t, checkFlags.InvalidFlags)
+	assert.Equal(t, 2, driver.CPU)
+	assert.Equal(t, defaultMemory, driver.Memory)
+	assert.Equal(t, defaultDiskSize, driver.DiskSize)
+	assert.Equal(t, "/opt/qemu/bin/qemu-system-x86_64", driver.QemuBinary)
+	assert.Equal(t, "docker", driver.SSHUser)
+}
+
+func TestState(t *testing.T) {
+	var tests = []struct {
+		output string
+		state  state.State
+	}{
+		{`{"status":"running","running":true}`, state.Running},
+		{`{"status":"paused","running":false}`, state.Paused},
+		{`{"status":"suspended","running":false}`, state.Paused},
+		{`{"status":"prelaunch","running":false}`, state.Starting},
+		{`{"status":"shutdown","running":false}`, state.Stopping},
+		{`{"status":"guest-panicked","running":false}`, state.Error},
+		{`{"status":"whatever","running":false}`, state.None},
+	}
+
+	for _, expected := range tests {
+		driver := NewDriver("default", "path")
+		mockCalls(t, driver, []Call{
+			{"qmp path/machines/default/monitor.sock query-status", expected.output, nil},
+		})
+
+		machineState, err := driver.GetState()
+
+		assert.NoError(t, err)
+		assert.Equal(t, expected.state, machineState)
+	}
+}
+
+func TestStateNotRunning(t *testing.T) {
+	driver := NewDriver("default", "path")
+	mockCalls(t, driver, []Call{
+		{"qmp path/machines/default/monitor.sock query-status", "", errMonitorNotListening},
+	})
+
+	machineState, err := driver.GetState()
+
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, machineState)
+}
+
+func TestStateError(t *testing.T) {
+	driver := NewDriver("default", "path")
+	mockCalls(t, driver, []Call{
+		{"qmp path/machines/default/monitor.sock query-status", "", errors.New("Unexpected error")},
+	})
+
+	machineState, err := driver.GetState()
+
+	assert.EqualError(t, err, "Unexpected error")
+	assert.Equal(t, state.Error, machineState)
+}
+
+func TestGetURL(t *testing.T) {
+	driver := NewDriver("default", "path")
+	driver.EnginePort = 2376
+	mockCalls(t, driver, []Call{
+		{"qmp path/machines/default/monitor.sock query-status", `{"status":"running"}`, nil},
+	})
+
+	url, err := driver.GetURL()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://127.0.0.1:2376", url)
+}
+
+func TestGetURLStopped(t *testing.T) {
+	driver := NewDriver("default", "path")
+	mockCalls(t, driver, []Call{
+		{"qmp path/machines/default/monitor.sock query-status", "", errMonitorNotListening},
+	})
+
+	_, err := driver.GetURL()
+
+	assert.Equal(t, drivers.ErrHostIsNotRunning, err)
+}
+
+func TestPreCreateCheck(t *testing.T) {
+	driver := NewDriver("default", "path")
+	mocks := mockCalls(t, driver, []Call{
+		{"qemu-img --version", "", nil},
+		{"qemu-system-x86_64 --version", "", nil},
+		{"UpdateISOCache path http://b2d.org", "", nil},
+	})
+
+	err := driver.PreCreateCheck()
+
+	assert.NoError(t, err)
+	mocks.verify()
+}
+
+func TestPreCreateCheckWithoutQemu(t *testing.T) {
+	driver := NewDriver("default", "path")
+	mockCalls(t, driver, []Call{
+		{"qemu-img --version", "", errors.New("qemu-img not found")},
+	})
+
+	err := driver.PreCreateCheck()
+
+	assert.EqualError(t, err, "qemu-img not found")
+}
+
+func TestCreate(t *testing.T) {
+	defer withKVM(t, false)()
+
+	driver := NewDriver("default", "path")
+	mocks := mockCalls(t, driver, []Call{
+		{"CopyIsoToMachineDir path default http://b2d.org", "", nil},
+		{"Generate path/machines/default/id_rsa", "", nil},
+		{"Create 20000 path/machines/default/id_rsa.pub path/machines/default/disk.qcow2", "", nil},
+		{"qmp path/machines/default/monitor.sock query-status", "", errMonitorNotListening},
+		{"FreePort 0", "2222", nil},
+		{"FreePort 0", "2376", nil},
+		{"qemu-system-x86_64 -name default -machine accel=tcg " + launchArgs, "", nil},
+		{"WaitSSH", "", nil},
+	})
+
+	err := driver.Create()
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2222, driver.SSHPort)
+	assert.Equal(t, 2376, driver.EnginePort)
+	mocks.verify()
+}
+
+func TestStartWithKVM(t *testing.T) {
+	defer withKVM(t, true)()
+
+	driver := NewDriver("default", "path")
+	driver.SSHPort = 2222
+	driver.EnginePort = 2376
+	mocks := mockCalls(t, driver, []Call{
+		{"qmp path/machines/default/monitor.sock query-status", "", errMonitorNotListening},
+		{"FreePort 2222", "2222", nil},
+		{"FreePort 2376", "2376", nil},
+		{"qemu-system-x86_64 -name default -machine accel=kvm -cpu host " + launchArgs, "", nil},
+		{"WaitSSH", "", nil},
+	})
+
+	err := driver.Start()
+
+	assert.NoError(t, err)
+	mocks.verify()
+}
+
+func TestStartFailure(t *testing.T) {
+	defer withKVM(t, false)()
+
+	driver := NewDriver("default", "path")
+	mockCalls(t, driver, []Call{
+		{"qmp path/machines/default/monitor.sock query-status", "", errMonitorNotListening},
+		{"FreePort 0", "2222", nil},
+		{"FreePort 0", "2376", nil},
+		{"qemu-system-x86_64 -name default -machine accel=tcg " + launchArgs, "", errors.New("Could not access KVM kernel module")},
+	})
+
+	err := driver.Start()
+
+	assert.EqualError(t, err, "Unable to start the VM: Could not access KVM kernel module")
+}
+
+func TestStartPaused(t *testing.T) {
+	driver := NewDriver("default", "path")
+	mocks := mockCalls(t, driver, []Call{
+		{"qmp path/machines/default/monitor.sock query-status", `{"status":"paused"}`, nil},
+		{"qmp path/machines/default/monitor.sock cont", "{}", nil},
+		{"WaitSSH", "", nil},
+	})
+
+	err := driver.Start()
+
+	assert.NoError(t, err)
+	mocks.verify()
+}
+
+func TestStop(t *testing.T) {
+	driver := NewDriver("default", "path")
+	mocks := mockCalls(t, driver, []Call{
+		{"qmp path/machines/default/monitor.sock query-status", `{"status":"running"}`, nil},
+		{"qmp path/machines/default/monitor.sock system_powerdown", "{}", nil},
+		{"qmp path/machines/default/monitor.sock query-status", "", errMonitorNotListening},
+	})
+
+	err := driver.Stop()
+
+	assert.NoError(t, err)
+	mocks.verify()
+}
+
+func TestStopPaused(t *testing.T) {
+	driver := NewDriver("default", "path")
+	mocks := mockCalls(t, driver, []Call{
+		{"qmp path/machines/default/monitor.sock query-status", `{"status":"paused"}`, nil},
+		{"qmp path/machines/default/monitor.sock cont", "{}", nil},
+		{"qmp path/machines/default/monitor.sock system_powerdown", "{}", nil},
+		{"qmp path/machines/default/monitor.sock query-status", "", errMonitorNotListening},
+	})
+
+	err := driver.Stop()
+
+	assert.NoError(t, err)
+	mocks.verify()
+}
+
+func TestKill(t *testing.T) {
+	driver := NewDriver("default", "path")
+	mocks := mockCalls(t, driver, []Call{
+		{"qmp path/machines/default/monitor.sock quit", "", io.EOF},
+		{"qmp path/machines/default/monitor.sock query-status", "", errMonitorNotListening},
+	})
+
+	err := driver.Kill()
+
+	assert.NoError(t, err)
+	mocks.verify()
+}
+
+func TestPause(t *testing.T) {
+	driver := NewDriver("default", "path")
+	mocks := mockCalls(t, driver, []Call{
+		{"qmp path/machines/default/monitor.sock stop", "{}", nil},
+		{"qmp path/machines/default/monitor.sock cont", "{}", nil},
+	})
+
+	assert.NoError(t, drivers.Pause(driver))
+	assert.NoError(t, drivers.Unpause(driver))
+	mocks.verify()
+}
+
+func TestRemoveStopped(t *testing.T) {
+	driver := NewDriver("default", "path")
+	mocks := mockCalls(t, driver, []Call{
+		{"qmp path/machines/default/monitor.sock query-status", "", errMonitorNotListening},
+	})
+
+	err := driver.Remove()
+
+	assert.NoError(t, err)
+	mocks.verify()
+}
+
+func TestRemoveRunning(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "qemu-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storePath)
+
+	driver := NewDriver("default", storePath)
+	assert.NoError(t, os.MkdirAll(driver.ResolveStorePath("."), 0700))
+	assert.NoError(t, ioutil.WriteFile(driver.diskPath(), []byte("disk"), 0600))
+
+	monitorPath := driver.monitorPath()
+	mocks := mockCalls(t, driver, []Call{
+		{"qmp " + monitorPath + " query-status", `{"status":"running"}`, nil},
+		{"qmp " + monitorPath + " quit", "{}", nil},
+		{"qmp " + monitorPath + " query-status", "", errMonitorNotListening},
+	})
+
+	err = driver.Remove()
+
+	assert.NoError(t, err)
+	mocks.verify()
+
+	_, err = os.Stat(driver.diskPath())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestConsoleLog(t *testing.T) {
+	storePath, err := ioutil.TempDir("", "qemu-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storePath)
+
+	driver := NewDriver("default", storePath)
+	assert.NoError(t, os.MkdirAll(driver.ResolveStorePath("."), 0700))
+	assert.NoError(t, ioutil.WriteFile(driver.consoleLogPath(), []byte("Booting boot2docker\n"), 0600))
+
+	out, err := drivers.ConsoleLog(driver)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Booting boot2docker\n", out)
+}
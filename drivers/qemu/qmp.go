@@ -0,0 +1,101 @@
+package qemu
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+const qmpTimeout = 10 * time.Second
+
+// errMonitorNotListening is returned when nothing listens on the QMP socket,
+// i.e. when QEMU isn't running.
+var errMonitorNotListening = errors.New("QMP monitor not listening")
+
+// QMPClient runs commands through the QEMU Machine Protocol.
+type QMPClient interface {
+	// execute runs the command through the monitor listening on the socket
+	// and returns its result.
+	execute(socketPath, command string) (json.RawMessage, error)
+}
+
+func NewQMPClient() QMPClient {
+	return &socketQMPClient{}
+}
+
+type socketQMPClient struct{}
+
+type qmpCommand struct {
+	Execute string `json:"execute"`
+}
+
+type qmpError struct {
+	Class string `json:"class"`
+	Desc  string `json:"desc"`
+}
+
+// qmpMessage is the greeting, an event, or the response to a command.
+type qmpMessage struct {
+	QMP    json.RawMessage `json:"QMP"`
+	Event  string          `json:"event"`
+	Return json.RawMessage `json:"return"`
+	Error  *qmpError       `json:"error"`
+}
+
+func (c *socketQMPClient) execute(socketPath, command string) (json.RawMessage, error) {
+	conn, err := net.DialTimeout("unix", socketPath, qmpTimeout)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, syscall.ECONNREFUSED) {
+			return nil, errMonitorNotListening
+		}
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(qmpTimeout)); err != nil {
+		return nil, err
+	}
+
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+
+	var greeting qmpMessage
+	if err := dec.Decode(&greeting); err != nil {
+		return nil, fmt.Errorf("Error reading the QMP greeting: %s", err)
+	}
+	if greeting.QMP == nil {
+		return nil, errors.New("Unexpected QMP greeting")
+	}
+
+	if _, err := runQMPCommand(enc, dec, "qmp_capabilities"); err != nil {
+		return nil, err
+	}
+
+	return runQMPCommand(enc, dec, command)
+}
+
+// runQMPCommand sends the command and waits for its response, skipping the
+// events sent in the meantime.
+func runQMPCommand(enc *json.Encoder, dec *json.Decoder, command string) (json.RawMessage, error) {
+	if err := enc.Encode(qmpCommand{Execute: command}); err != nil {
+		return nil, err
+	}
+
+	for {
+		var msg qmpMessage
+		if err := dec.Decode(&msg); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case msg.Error != nil:
+			return nil, fmt.Errorf("QMP command %s failed: %s", command, msg.Error.Desc)
+		case msg.Return != nil:
+			return msg.Return, nil
+		}
+	}
+}
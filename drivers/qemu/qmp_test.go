@@ -0,0 +1,78 @@
+package qemu
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// serveQMP answers the commands of one client like QEMU, with the given
+// responses after the capabilities negotiation.
+func serveQMP(t *testing.T, responses ...string) (string, func()) {
+	dir, err := ioutil.TempDir("", "qmp-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	socketPath := filepath.Join(dir, "monitor.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Skipf("Unix sockets are not available: %s", err)
+	}
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		conn.Write([]byte(`{"QMP": {"version": {"qemu": {"major": 8, "minor": 2, "micro": 0}}, "capabilities": []}}` + "\r\n"))
+
+		for _, response := range append([]string{`{"return": {}}`}, responses...) {
+			var cmd map[string]interface{}
+			if err := json.NewDecoder(r).Decode(&cmd); err != nil {
+				return
+			}
+			conn.Write([]byte(response + "\r\n"))
+		}
+	}()
+
+	return socketPath, func() {
+		listener.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestQMPExecute(t *testing.T) {
+	socketPath, cleanup := serveQMP(t, `{"event": "RESUME", "data": {}}`+"\r\n"+`{"return": {"status": "running", "running": true}}`)
+	defer cleanup()
+
+	out, err := NewQMPClient().execute(socketPath, "query-status")
+
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"status": "running", "running": true}`, string(out))
+}
+
+func TestQMPExecuteError(t *testing.T) {
+	socketPath, cleanup := serveQMP(t, `{"error": {"class": "CommandNotFound", "desc": "The command foo has not been found"}}`)
+	defer cleanup()
+
+	_, err := NewQMPClient().execute(socketPath, "foo")
+
+	assert.EqualError(t, err, "QMP command foo failed: The command foo has not been found")
+}
+
+func TestQMPExecuteNotListening(t *testing.T) {
+	_, err := NewQMPClient().execute(filepath.Join(os.TempDir(), "missing", "monitor.sock"), "query-status")
+
+	assert.Equal(t, errMonitorNotListening, err)
+}
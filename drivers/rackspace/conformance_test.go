@@ -0,0 +1,21 @@
+package rackspace
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/driverstest"
+)
+
+func TestConformance(t *testing.T) {
+	driverstest.Run(t, driverstest.Suite{
+		NewDriver: func(machineName, storePath string) drivers.Driver {
+			return NewDriver(machineName, storePath)
+		},
+		FlagValues: map[string]interface{}{
+			"rackspace-region":   "REGION",
+			"rackspace-username": "user",
+			"rackspace-api-key":  "KEY",
+		},
+	})
+}
@@ -37,10 +37,11 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  "",
 		},
 		mcnflag.StringFlag{
-			EnvVar: "OS_API_KEY",
-			Name:   "rackspace-api-key",
-			Usage:  "Rackspace API key",
-			Value:  "",
+			EnvVar:    "OS_API_KEY",
+			Name:      "rackspace-api-key",
+			Usage:     "Rackspace API key",
+			Value:     "",
+			Sensitive: true,
 		},
 		mcnflag.StringFlag{
 			EnvVar: "OS_REGION_NAME",
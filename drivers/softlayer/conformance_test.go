@@ -0,0 +1,21 @@
+package softlayer
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/driverstest"
+)
+
+func TestConformance(t *testing.T) {
+	driverstest.Run(t, driverstest.Suite{
+		NewDriver: func(machineName, storePath string) drivers.Driver {
+			return NewDriver(machineName, storePath)
+		},
+		FlagValues: map[string]interface{}{
+			"softlayer-user":    "user",
+			"softlayer-api-key": "key",
+			"softlayer-domain":  "example.com",
+		},
+	})
+}
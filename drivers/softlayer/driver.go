@@ -100,14 +100,17 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Value:  defaultDiskSize,
 		},
 		mcnflag.StringFlag{
-			EnvVar: "SOFTLAYER_USER",
-			Name:   "softlayer-user",
-			Usage:  "softlayer user account name",
+			EnvVar:   "SOFTLAYER_USER",
+			Name:     "softlayer-user",
+			Usage:    "softlayer user account name",
+			Required: true,
 		},
 		mcnflag.StringFlag{
-			EnvVar: "SOFTLAYER_API_KEY",
-			Name:   "softlayer-api-key",
-			Usage:  "softlayer user API key",
+			EnvVar:    "SOFTLAYER_API_KEY",
+			Name:      "softlayer-api-key",
+			Usage:     "softlayer user API key",
+			Sensitive: true,
+			Required:  true,
 		},
 		mcnflag.StringFlag{
 			EnvVar: "SOFTLAYER_REGION",
@@ -127,9 +130,10 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "hostname for the machine - defaults to machine name",
 		},
 		mcnflag.StringFlag{
-			EnvVar: "SOFTLAYER_DOMAIN",
-			Name:   "softlayer-domain",
-			Usage:  "domain name for machine",
+			EnvVar:   "SOFTLAYER_DOMAIN",
+			Name:     "softlayer-domain",
+			Usage:    "domain name for machine",
+			Required: true,
 		},
 		mcnflag.StringFlag{
 			EnvVar: "SOFTLAYER_API_ENDPOINT",
@@ -161,12 +165,12 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 		mcnflag.IntFlag{
 			EnvVar: "SOFTLAYER_PUBLIC_VLAN_ID",
 			Name:   "softlayer-public-vlan-id",
-			Usage:  "",
+			Usage:  "ID of the public VLAN of the machine",
 		},
 		mcnflag.IntFlag{
 			EnvVar: "SOFTLAYER_PRIVATE_VLAN_ID",
 			Name:   "softlayer-private-vlan-id",
-			Usage:  "",
+			Usage:  "ID of the private VLAN of the machine",
 		},
 		mcnflag.IntFlag{
 			EnvVar: "SOFTLAYER_NETWORK_MAX_SPEED",
@@ -0,0 +1,24 @@
+package virtualbox
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/driverstest"
+)
+
+func TestConformance(t *testing.T) {
+	driverstest.Run(t, driverstest.Suite{
+		NewDriver: func(machineName, storePath string) drivers.Driver {
+			driver := NewDriver(machineName, storePath)
+			driver.VBoxManager = &VBoxManagerMock{
+				args:   "showvminfo " + machineName + " --machinereadable",
+				stdErr: "VBoxManage: error: Could not find a registered machine named '" + machineName + "'",
+				err:    errors.New("exit status 1"),
+			}
+			return driver
+		},
+		RemoveMissing: true,
+	})
+}
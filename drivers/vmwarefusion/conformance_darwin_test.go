@@ -0,0 +1,13 @@
+package vmwarefusion
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers/driverstest"
+)
+
+func TestConformance(t *testing.T) {
+	driverstest.Run(t, driverstest.Suite{
+		NewDriver: NewDriver,
+	})
+}
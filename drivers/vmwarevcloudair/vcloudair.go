@@ -57,9 +57,10 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "vCloud Air username",
 		},
 		mcnflag.StringFlag{
-			EnvVar: "VCLOUDAIR_PASSWORD",
-			Name:   "vmwarevcloudair-password",
-			Usage:  "vCloud Air password",
+			EnvVar:    "VCLOUDAIR_PASSWORD",
+			Name:      "vmwarevcloudair-password",
+			Usage:     "vCloud Air password",
+			Sensitive: true,
 		},
 		mcnflag.StringFlag{
 			EnvVar: "VCLOUDAIR_COMPUTEID",
@@ -123,9 +123,10 @@ func (d *Driver) GetCreateFlags() []mcnflag.Flag {
 			Usage:  "vSphere username",
 		},
 		mcnflag.StringFlag{
-			EnvVar: "VSPHERE_PASSWORD",
-			Name:   "vmwarevsphere-password",
-			Usage:  "vSphere password",
+			EnvVar:    "VSPHERE_PASSWORD",
+			Name:      "vmwarevsphere-password",
+			Usage:     "vSphere password",
+			Sensitive: true,
 		},
 		mcnflag.StringSliceFlag{
 			EnvVar: "VSPHERE_NETWORK",
@@ -0,0 +1,380 @@
+// Package bundle packages a machine with everything needed to use it from
+// another store: its configuration, its SSH keys, its certificates and the
+// CA they were signed with.
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/persist"
+	"github.com/docker/machine/libmachine/version"
+)
+
+const (
+	manifestName = "bundle.json"
+	configName   = "config.json"
+
+	// The files of the machine directory and the ones of the CA are kept
+	// in these directories of the bundles.
+	machineDirName = "machine"
+	certsDirName   = "certs"
+
+	maxFileSize = 10 << 20
+)
+
+// Manifest describes a bundle.
+type Manifest struct {
+	Version       int
+	ConfigVersion int
+	Name          string
+	// StorePath is the path of the store the machine was exported from,
+	// which is replaced in the paths of its configuration when imported.
+	StorePath string
+}
+
+// Bundle is a machine as exported from a store.
+type Bundle struct {
+	Manifest
+	Config       []byte
+	MachineFiles map[string][]byte
+	CertFiles    map[string][]byte
+}
+
+// entry is a file of the tarball.
+type entry struct {
+	name string
+	data []byte
+}
+
+// certFile is a file of the CA and client certificates, as found in the
+// authentication options.
+type certFile struct {
+	name     string
+	path     func(o *auth.Options) *string
+	optional bool
+}
+
+var certFiles = []certFile{
+	{"ca.pem", func(o *auth.Options) *string { return &o.CaCertPath }, false},
+	{"ca-key.pem", func(o *auth.Options) *string { return &o.CaPrivateKeyPath }, true},
+	{"cert.pem", func(o *auth.Options) *string { return &o.ClientCertPath }, false},
+	{"key.pem", func(o *auth.Options) *string { return &o.ClientKeyPath }, false},
+}
+
+// New bundles the machine found in the store at storePath. The machine must
+// be loaded as it is stored, with a host.RawDataDriver.
+func New(h *host.Host, storePath string) (*Bundle, error) {
+	if h.HostOptions == nil || h.HostOptions.AuthOptions == nil {
+		return nil, fmt.Errorf("Machine %q has no certificates to export", h.Name)
+	}
+
+	config, err := json.MarshalIndent(h, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	machineFiles, err := persist.ReadMachineFiles(MachineDir(storePath, h.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	certs := map[string][]byte{}
+	for _, f := range certFiles {
+		filePath := *f.path(h.HostOptions.AuthOptions)
+
+		data, err := ioutil.ReadFile(filePath)
+		if os.IsNotExist(err) && f.optional {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error reading %s: %s", f.name, err)
+		}
+
+		certs[f.name] = data
+	}
+
+	return &Bundle{
+		Manifest: Manifest{
+			Version:       version.BundleVersion,
+			ConfigVersion: h.ConfigVersion,
+			Name:          h.Name,
+			StorePath:     storePath,
+		},
+		Config:       config,
+		MachineFiles: machineFiles,
+		CertFiles:    certs,
+	}, nil
+}
+
+// Write writes the bundle as a gzipped tarball.
+func (b *Bundle) Write(w io.Writer) error {
+	manifest, err := json.MarshalIndent(b.Manifest, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	files := []entry{
+		{manifestName, manifest},
+		{configName, b.Config},
+	}
+	for _, dir := range []struct {
+		name  string
+		files map[string][]byte
+	}{
+		{machineDirName, b.MachineFiles},
+		{certsDirName, b.CertFiles},
+	} {
+		for _, name := range sortedNames(dir.files) {
+			files = append(files, entry{path.Join(dir.name, name), dir.files[name]})
+		}
+	}
+
+	now := time.Now()
+	for _, f := range files {
+		header := &tar.Header{
+			Name:    f.name,
+			Mode:    0600,
+			Size:    int64(len(f.data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// Read reads a bundle written by Write, refusing the ones made by a newer
+// version of docker-machine.
+func Read(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the bundle: %s", err)
+	}
+	defer gz.Close()
+
+	b := &Bundle{
+		MachineFiles: map[string][]byte{},
+		CertFiles:    map[string][]byte{},
+	}
+	var manifest []byte
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the bundle: %s", err)
+		}
+
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			return nil, fmt.Errorf("Unexpected entry %q in the bundle", header.Name)
+		}
+
+		data, err := ioutil.ReadAll(io.LimitReader(tr, maxFileSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the bundle: %s", err)
+		}
+		if len(data) > maxFileSize {
+			return nil, fmt.Errorf("The file %q of the bundle is too large", header.Name)
+		}
+
+		// The names end up in paths, they mustn't lead out of the
+		// machine directory.
+		dir, name := path.Split(header.Name)
+		if name == "" || name == "." || name == ".." {
+			return nil, fmt.Errorf("Unexpected file %q in the bundle", header.Name)
+		}
+
+		switch strings.TrimSuffix(dir, "/") {
+		case "":
+			switch name {
+			case manifestName:
+				manifest = data
+			case configName:
+				b.Config = data
+			default:
+				return nil, fmt.Errorf("Unexpected file %q in the bundle", header.Name)
+			}
+		case machineDirName:
+			b.MachineFiles[name] = data
+		case certsDirName:
+			b.CertFiles[name] = data
+		default:
+			return nil, fmt.Errorf("Unexpected file %q in the bundle", header.Name)
+		}
+	}
+
+	if manifest == nil || b.Config == nil {
+		return nil, errors.New("The file isn't a machine bundle")
+	}
+
+	if err := json.Unmarshal(manifest, &b.Manifest); err != nil {
+		return nil, fmt.Errorf("Error reading the manifest of the bundle: %s", err)
+	}
+
+	if b.Version > version.BundleVersion || b.ConfigVersion > version.ConfigVersion {
+		return nil, errors.New("The bundle was made by a newer version of docker-machine, you should upgrade your Docker Machine client")
+	}
+
+	return b, nil
+}
+
+// MachineDir returns the directory of the named machine in the store at
+// storePath.
+func MachineDir(storePath, name string) string {
+	return filepath.Join(storePath, "machines", name)
+}
+
+// Host returns the machine of the bundle as it is imported, under the given
+// name, in the store at storePath. The paths of its configuration are
+// rewritten for that store. Its CA and client certificates are kept in the
+// certs directory of the machine, so that they don't replace the ones of
+// the store.
+func (b *Bundle) Host(storePath, name string) (*host.Host, error) {
+	config, err := relocate(b.Config, b.StorePath, storePath, b.Name, name)
+	if err != nil {
+		return nil, err
+	}
+
+	h, _, err := host.MigrateHost(&host.Host{Name: name}, config, storePath)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the configuration of the bundle: %s", err)
+	}
+
+	h.Name = name
+
+	if h.HostOptions == nil || h.HostOptions.AuthOptions == nil {
+		return nil, errors.New("The machine of the bundle has no certificates")
+	}
+
+	certsDir := filepath.Join(MachineDir(storePath, name), certsDirName)
+
+	authOptions := h.HostOptions.AuthOptions
+	authOptions.CertDir = certsDir
+	for _, f := range certFiles {
+		*f.path(authOptions) = filepath.Join(certsDir, f.name)
+	}
+
+	return h, nil
+}
+
+// WriteFiles writes the keys and certificates of the bundle in the directory
+// of the named machine, in the store at storePath.
+func (b *Bundle) WriteFiles(storePath, name string) error {
+	machineDir := MachineDir(storePath, name)
+
+	if err := persist.WriteMachineFiles(machineDir, b.MachineFiles); err != nil {
+		return err
+	}
+
+	return persist.WriteMachineFiles(filepath.Join(machineDir, certsDirName), b.CertFiles)
+}
+
+// relocate rewrites the paths found in the configuration, from the machine
+// directory and the store they were in to the ones the machine is imported
+// in. The paths can come from another operating system.
+func relocate(config []byte, fromStore, toStore, fromName, toName string) ([]byte, error) {
+	var root interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.UseNumber()
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("Error reading the configuration of the bundle: %s", err)
+	}
+
+	fromStore = strings.TrimSuffix(toSlash(fromStore), "/")
+	fromMachineDir := fromStore + "/machines/" + fromName
+	toMachineDir := MachineDir(toStore, toName)
+
+	root = rewrite(root, "", func(key, value string) string {
+		if key == "MachineName" && value == fromName {
+			return toName
+		}
+		if newValue, ok := replacePrefix(value, fromMachineDir, toMachineDir); ok {
+			return newValue
+		}
+		if newValue, ok := replacePrefix(value, fromStore, toStore); ok {
+			return newValue
+		}
+		return value
+	})
+
+	return json.Marshal(root)
+}
+
+// rewrite replaces the strings found in value with what fn returns, given
+// the key of their object.
+func rewrite(value interface{}, key string, fn func(key, value string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return fn(key, v)
+	case map[string]interface{}:
+		for k, child := range v {
+			v[k] = rewrite(child, k, fn)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = rewrite(child, key, fn)
+		}
+	}
+	return value
+}
+
+func replacePrefix(value, from, to string) (string, bool) {
+	if from == "" {
+		return value, false
+	}
+
+	slashed := toSlash(value)
+	if slashed == from {
+		return to, true
+	}
+	if !strings.HasPrefix(slashed, from+"/") {
+		return value, false
+	}
+
+	return filepath.Join(to, filepath.FromSlash(strings.TrimPrefix(slashed, from+"/"))), true
+}
+
+func toSlash(p string) string {
+	return strings.Replace(p, `\`, "/", -1)
+}
+
+func sortedNames(files map[string][]byte) []string {
+	names := []string{}
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
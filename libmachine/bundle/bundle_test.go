@@ -0,0 +1,148 @@
+package bundle
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/version"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBundle(t *testing.T) *Bundle {
+	storePath, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(storePath)
+
+	certsDir := filepath.Join(storePath, "certs")
+	machineDir := MachineDir(storePath, hosttest.DefaultHostName)
+	assert.NoError(t, os.MkdirAll(certsDir, 0700))
+	assert.NoError(t, os.MkdirAll(machineDir, 0700))
+
+	for _, name := range []string{"ca.pem", "cert.pem", "key.pem"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(certsDir, name), []byte(name), 0600))
+	}
+	for _, name := range []string{"id_rsa", "server.pem", "disk.vmdk"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(machineDir, name), []byte(name), 0600))
+	}
+
+	h, err := hosttest.GetDefaultTestHost()
+	assert.NoError(t, err)
+	h.Driver = none.NewDriver(h.Name, storePath)
+	h.HostOptions.AuthOptions.CertDir = certsDir
+	h.HostOptions.AuthOptions.CaCertPath = filepath.Join(certsDir, "ca.pem")
+	h.HostOptions.AuthOptions.CaPrivateKeyPath = filepath.Join(certsDir, "ca-key.pem")
+	h.HostOptions.AuthOptions.ClientCertPath = filepath.Join(certsDir, "cert.pem")
+	h.HostOptions.AuthOptions.ClientKeyPath = filepath.Join(certsDir, "key.pem")
+	h.HostOptions.AuthOptions.ServerCertPath = filepath.Join(machineDir, "server.pem")
+	h.HostOptions.AuthOptions.StorePath = machineDir
+
+	b, err := New(h, storePath)
+	assert.NoError(t, err)
+
+	return b
+}
+
+func TestWriteRead(t *testing.T) {
+	b := newTestBundle(t)
+
+	var buf bytes.Buffer
+	assert.NoError(t, b.Write(&buf))
+
+	read, err := Read(&buf)
+	assert.NoError(t, err)
+
+	assert.Equal(t, version.BundleVersion, read.Version)
+	assert.Equal(t, version.ConfigVersion, read.ConfigVersion)
+	assert.Equal(t, hosttest.DefaultHostName, read.Name)
+	assert.Equal(t, b.Config, read.Config)
+	assert.Equal(t, map[string][]byte{"id_rsa": []byte("id_rsa"), "server.pem": []byte("server.pem")}, read.MachineFiles)
+	assert.Equal(t, map[string][]byte{"ca.pem": []byte("ca.pem"), "cert.pem": []byte("cert.pem"), "key.pem": []byte("key.pem")}, read.CertFiles)
+}
+
+func TestHostRelocatesPaths(t *testing.T) {
+	b := newTestBundle(t)
+
+	storePath := filepath.Join("/", "new", "store")
+	h, err := b.Host(storePath, "bar")
+	assert.NoError(t, err)
+
+	machineDir := filepath.Join(storePath, "machines", "bar")
+	certsDir := filepath.Join(machineDir, "certs")
+
+	assert.Equal(t, "bar", h.Name)
+	assert.Equal(t, certsDir, h.HostOptions.AuthOptions.CertDir)
+	assert.Equal(t, filepath.Join(certsDir, "ca.pem"), h.HostOptions.AuthOptions.CaCertPath)
+	assert.Equal(t, filepath.Join(certsDir, "ca-key.pem"), h.HostOptions.AuthOptions.CaPrivateKeyPath)
+	assert.Equal(t, filepath.Join(machineDir, "server.pem"), h.HostOptions.AuthOptions.ServerCertPath)
+	assert.Equal(t, machineDir, h.HostOptions.AuthOptions.StorePath)
+
+	driver := none.NewDriver("", "")
+	assert.NoError(t, json.Unmarshal(h.Driver.(*host.RawDataDriver).Data, &driver))
+	assert.Equal(t, "bar", driver.MachineName)
+	assert.Equal(t, storePath, driver.StorePath)
+}
+
+func TestReplacePrefixFromWindows(t *testing.T) {
+	relocated, ok := replacePrefix(`C:\Users\foo\.docker\machine\machines\bar\id_rsa`, "C:/Users/foo/.docker/machine", "/store")
+
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join("/store", "machines", "bar", "id_rsa"), relocated)
+
+	unchanged, ok := replacePrefix(`C:\Users\foo\.docker\machinery`, "C:/Users/foo/.docker/machine", "/store")
+
+	assert.False(t, ok)
+	assert.Equal(t, `C:\Users\foo\.docker\machinery`, unchanged)
+}
+
+func writeTarball(t *testing.T, files map[string]string) *bytes.Buffer {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for name, data := range files {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(data))}))
+		_, err := tw.Write([]byte(data))
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, gz.Close())
+
+	return &buf
+}
+
+func TestReadRefusesUnexpectedFiles(t *testing.T) {
+	_, err := Read(writeTarball(t, map[string]string{
+		"bundle.json":     `{"Version": 1}`,
+		"config.json":     `{}`,
+		"machine/../../x": "x",
+	}))
+
+	assert.Error(t, err)
+}
+
+func TestReadRefusesNewerBundles(t *testing.T) {
+	_, err := Read(writeTarball(t, map[string]string{
+		"bundle.json": `{"Version": 1000}`,
+		"config.json": `{}`,
+	}))
+
+	assert.Error(t, err)
+}
+
+func TestReadRefusesOtherFiles(t *testing.T) {
+	_, err := Read(writeTarball(t, map[string]string{
+		"config.json": `{}`,
+	}))
+
+	assert.Error(t, err)
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"fmt"
 	"io/ioutil"
 	"math/big"
 	"net"
@@ -292,3 +293,39 @@ func CheckCertificateDate(certPath string) (bool, error) {
 
 	return true, nil
 }
+
+// VerifyCertificate checks that the certificate was signed by the CA.
+func VerifyCertificate(certPath, caCertPath string) error {
+	cert, err := readCertificate(certPath)
+	if err != nil {
+		return err
+	}
+
+	caCert, err := readCertificate(caCertPath)
+	if err != nil {
+		return err
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:     roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+func readCertificate(certPath string) (*x509.Certificate, error) {
+	certBytes, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pemBlock, _ := pem.Decode(certBytes)
+	if pemBlock == nil {
+		return nil, fmt.Errorf("Failed to decode PEM data of %s", certPath)
+	}
+
+	return x509.ParseCertificate(pemBlock.Bytes)
+}
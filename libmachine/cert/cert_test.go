@@ -79,3 +79,46 @@ func TestGenerateCert(t *testing.T) {
 		t.Fatalf("key not created at %s", keyPath)
 	}
 }
+
+func TestVerifyCertificate(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	caCertPath := filepath.Join(tmpDir, "ca.pem")
+	caKeyPath := filepath.Join(tmpDir, "ca-key.pem")
+	otherCaCertPath := filepath.Join(tmpDir, "other-ca.pem")
+	otherCaKeyPath := filepath.Join(tmpDir, "other-ca-key.pem")
+	certPath := filepath.Join(tmpDir, "cert.pem")
+	keyPath := filepath.Join(tmpDir, "key.pem")
+
+	if err := GenerateCACertificate(caCertPath, caKeyPath, "test-org", 1024); err != nil {
+		t.Fatal(err)
+	}
+	if err := GenerateCACertificate(otherCaCertPath, otherCaKeyPath, "test-org", 1024); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{
+		Hosts:     []string{"localhost"},
+		CertFile:  certPath,
+		CAKeyFile: caKeyPath,
+		CAFile:    caCertPath,
+		KeyFile:   keyPath,
+		Org:       "test-org",
+		Bits:      1024,
+	}
+	if err := GenerateCert(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyCertificate(certPath, caCertPath); err != nil {
+		t.Fatalf("Expected the certificate to be signed by the CA: %s", err)
+	}
+
+	if err := VerifyCertificate(certPath, otherCaCertPath); err == nil {
+		t.Fatal("Expected the certificate not to be signed by the other CA")
+	}
+}
@@ -0,0 +1,231 @@
+package drivers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+// Capability names an optional feature of the drivers, offered by the
+// drivers implementing the matching interface.
+type Capability string
+
+const (
+	// CapabilityPause is offered by the drivers implementing Pauser.
+	CapabilityPause Capability = "pause"
+
+	// CapabilitySnapshot is offered by the drivers implementing Snapshotter.
+	CapabilitySnapshot Capability = "snapshot"
+
+	// CapabilityResize is offered by the drivers implementing Resizer.
+	CapabilityResize Capability = "resize"
+
+	// CapabilityConsoleLog is offered by the drivers implementing
+	// ConsoleLogReader.
+	CapabilityConsoleLog Capability = "console-log"
+
+	// CapabilityUpgrade is offered by the drivers implementing Upgrader.
+	CapabilityUpgrade Capability = "upgrade"
+)
+
+// Pauser is implemented by the drivers which can freeze a host without
+// stopping it.
+type Pauser interface {
+	// Pause freezes the host, keeping its memory
+	Pause() error
+
+	// Unpause resumes the host frozen by Pause
+	Unpause() error
+}
+
+// Snapshot is a saved state of a host.
+type Snapshot struct {
+	Name    string
+	Created time.Time
+}
+
+// Snapshotter is implemented by the drivers which can save the state of a
+// host and go back to it.
+type Snapshotter interface {
+	// CreateSnapshot saves the current state of the host under the name
+	CreateSnapshot(name string) error
+
+	// ListSnapshots returns the snapshots of the host, the oldest first
+	ListSnapshots() ([]Snapshot, error)
+
+	// RestoreSnapshot brings the host back to the named snapshot
+	RestoreSnapshot(name string) error
+
+	// RemoveSnapshot removes the named snapshot
+	RemoveSnapshot(name string) error
+}
+
+// ResizeOptions are the new resources of a host. The resources left to 0
+// are unchanged.
+type ResizeOptions struct {
+	CPUs       int
+	MemoryMB   int
+	DiskSizeMB int
+}
+
+// Resizer is implemented by the drivers which can change the resources of
+// an existing host.
+type Resizer interface {
+	// Resize changes the resources of the host
+	Resize(opts ResizeOptions) error
+}
+
+// ConsoleLogReader is implemented by the drivers which can read what the
+// host wrote to its console, e.g. to find why it doesn't boot.
+type ConsoleLogReader interface {
+	// ConsoleLog returns the output of the console of the host
+	ConsoleLog() (string, error)
+}
+
+// Upgrader is implemented by the drivers which can upgrade the OS of a host
+// themselves, e.g. by replacing its boot image.
+type Upgrader interface {
+	// Upgrade upgrades the host
+	Upgrade() error
+}
+
+// CapabilitiesDriver is implemented by the drivers which implement the
+// interfaces of the capabilities without always offering them, e.g. the RPC
+// client of a driver plugin.
+type CapabilitiesDriver interface {
+	// GetCapabilities returns the capabilities offered by the driver
+	GetCapabilities() []Capability
+}
+
+// GetCapabilities returns the capabilities offered by d.
+func GetCapabilities(d Driver) []Capability {
+	if cd, ok := d.(CapabilitiesDriver); ok {
+		return cd.GetCapabilities()
+	}
+
+	capabilities := []Capability{}
+	if _, ok := d.(Pauser); ok {
+		capabilities = append(capabilities, CapabilityPause)
+	}
+	if _, ok := d.(Snapshotter); ok {
+		capabilities = append(capabilities, CapabilitySnapshot)
+	}
+	if _, ok := d.(Resizer); ok {
+		capabilities = append(capabilities, CapabilityResize)
+	}
+	if _, ok := d.(ConsoleLogReader); ok {
+		capabilities = append(capabilities, CapabilityConsoleLog)
+	}
+	if _, ok := d.(Upgrader); ok {
+		capabilities = append(capabilities, CapabilityUpgrade)
+	}
+
+	return capabilities
+}
+
+// HasCapability tells whether d offers the capability.
+func HasCapability(d Driver, capability Capability) bool {
+	for _, c := range GetCapabilities(d) {
+		if c == capability {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ErrCapabilityNotSupported is returned when a driver is asked for a
+// capability it doesn't offer.
+type ErrCapabilityNotSupported struct {
+	DriverName string
+	Capability Capability
+}
+
+func (e ErrCapabilityNotSupported) Error() string {
+	return fmt.Sprintf("The %q driver doesn't support %s", e.DriverName, e.Capability)
+}
+
+// ErrorKind classifies the error as mcnerror.KindNotSupported.
+func (e ErrCapabilityNotSupported) ErrorKind() mcnerror.ErrorKind {
+	return mcnerror.KindNotSupported
+}
+
+func notSupported(d Driver, capability Capability) error {
+	return ErrCapabilityNotSupported{
+		DriverName: d.DriverName(),
+		Capability: capability,
+	}
+}
+
+// Pause freezes the host through d.
+func Pause(d Driver) error {
+	if !HasCapability(d, CapabilityPause) {
+		return notSupported(d, CapabilityPause)
+	}
+	return d.(Pauser).Pause()
+}
+
+// Unpause resumes the host through d.
+func Unpause(d Driver) error {
+	if !HasCapability(d, CapabilityPause) {
+		return notSupported(d, CapabilityPause)
+	}
+	return d.(Pauser).Unpause()
+}
+
+// CreateSnapshot saves the state of the host through d.
+func CreateSnapshot(d Driver, name string) error {
+	if !HasCapability(d, CapabilitySnapshot) {
+		return notSupported(d, CapabilitySnapshot)
+	}
+	return d.(Snapshotter).CreateSnapshot(name)
+}
+
+// ListSnapshots returns the snapshots of the host through d.
+func ListSnapshots(d Driver) ([]Snapshot, error) {
+	if !HasCapability(d, CapabilitySnapshot) {
+		return nil, notSupported(d, CapabilitySnapshot)
+	}
+	return d.(Snapshotter).ListSnapshots()
+}
+
+// RestoreSnapshot brings the host back to a snapshot through d.
+func RestoreSnapshot(d Driver, name string) error {
+	if !HasCapability(d, CapabilitySnapshot) {
+		return notSupported(d, CapabilitySnapshot)
+	}
+	return d.(Snapshotter).RestoreSnapshot(name)
+}
+
+// RemoveSnapshot removes a snapshot of the host through d.
+func RemoveSnapshot(d Driver, name string) error {
+	if !HasCapability(d, CapabilitySnapshot) {
+		return notSupported(d, CapabilitySnapshot)
+	}
+	return d.(Snapshotter).RemoveSnapshot(name)
+}
+
+// Resize changes the resources of the host through d.
+func Resize(d Driver, opts ResizeOptions) error {
+	if !HasCapability(d, CapabilityResize) {
+		return notSupported(d, CapabilityResize)
+	}
+	return d.(Resizer).Resize(opts)
+}
+
+// ConsoleLog returns the output of the console of the host through d.
+func ConsoleLog(d Driver) (string, error) {
+	if !HasCapability(d, CapabilityConsoleLog) {
+		return "", notSupported(d, CapabilityConsoleLog)
+	}
+	return d.(ConsoleLogReader).ConsoleLog()
+}
+
+// Upgrade upgrades the host through d.
+func Upgrade(d Driver) error {
+	if !HasCapability(d, CapabilityUpgrade) {
+		return notSupported(d, CapabilityUpgrade)
+	}
+	return d.(Upgrader).Upgrade()
+}
@@ -0,0 +1,84 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/stretchr/testify/assert"
+)
+
+type snapshotDriver struct {
+	*MockDriver
+	snapshots []Snapshot
+}
+
+func (d *snapshotDriver) CreateSnapshot(name string) error {
+	d.snapshots = append(d.snapshots, Snapshot{Name: name})
+	return nil
+}
+
+func (d *snapshotDriver) ListSnapshots() ([]Snapshot, error) {
+	return d.snapshots, nil
+}
+
+func (d *snapshotDriver) RestoreSnapshot(name string) error {
+	return nil
+}
+
+func (d *snapshotDriver) RemoveSnapshot(name string) error {
+	return nil
+}
+
+func (d *snapshotDriver) ConsoleLog() (string, error) {
+	return "booted", nil
+}
+
+// hidingDriver implements all the capabilities but only offers pausing.
+type hidingDriver struct {
+	*snapshotDriver
+}
+
+func (d *hidingDriver) Pause() error {
+	return nil
+}
+
+func (d *hidingDriver) Unpause() error {
+	return nil
+}
+
+func (d *hidingDriver) GetCapabilities() []Capability {
+	return []Capability{CapabilityPause}
+}
+
+func TestGetCapabilities(t *testing.T) {
+	assert.Equal(t, []Capability{}, GetCapabilities(&MockDriver{}))
+	assert.Equal(t, []Capability{CapabilitySnapshot, CapabilityConsoleLog}, GetCapabilities(&snapshotDriver{MockDriver: &MockDriver{}}))
+	assert.Equal(t, []Capability{CapabilityPause}, GetCapabilities(&hidingDriver{&snapshotDriver{MockDriver: &MockDriver{}}}))
+}
+
+func TestCapabilityCalls(t *testing.T) {
+	d := &snapshotDriver{MockDriver: &MockDriver{calls: &CallRecorder{}}}
+
+	assert.NoError(t, CreateSnapshot(d, "before-upgrade"))
+
+	snapshots, err := ListSnapshots(d)
+	assert.NoError(t, err)
+	assert.Equal(t, []Snapshot{{Name: "before-upgrade"}}, snapshots)
+
+	consoleLog, err := ConsoleLog(d)
+	assert.NoError(t, err)
+	assert.Equal(t, "booted", consoleLog)
+}
+
+func TestCapabilityNotSupported(t *testing.T) {
+	d := &hidingDriver{&snapshotDriver{MockDriver: &MockDriver{driverName: "hiding", calls: &CallRecorder{}}}}
+
+	assert.NoError(t, Pause(d))
+
+	err := CreateSnapshot(d, "before-upgrade")
+	assert.EqualError(t, err, `The "hiding" driver doesn't support snapshot`)
+	assert.Equal(t, mcnerror.KindNotSupported, mcnerror.Kind(err))
+
+	err = Resize(d, ResizeOptions{CPUs: 2})
+	assert.Equal(t, ErrCapabilityNotSupported{DriverName: "hiding", Capability: CapabilityResize}, err)
+}
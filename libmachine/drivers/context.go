@@ -0,0 +1,153 @@
+package drivers
+
+import "context"
+
+// ContextDriver is implemented by drivers whose long running operations can
+// be aborted before they complete, e.g. the RPC client of a driver plugin.
+type ContextDriver interface {
+	// CreateContext creates a host, giving up when ctx is done
+	CreateContext(ctx context.Context) error
+
+	// KillContext stops a host forcefully, giving up when ctx is done
+	KillContext(ctx context.Context) error
+
+	// RemoveContext removes a host, giving up when ctx is done
+	RemoveContext(ctx context.Context) error
+
+	// RestartContext restarts a host, giving up when ctx is done
+	RestartContext(ctx context.Context) error
+
+	// StartContext starts a host, giving up when ctx is done
+	StartContext(ctx context.Context) error
+
+	// StopContext stops a host gracefully, giving up when ctx is done
+	StopContext(ctx context.Context) error
+}
+
+// CreateContext creates the host through d. Drivers which do not implement
+// ContextDriver only observe ctx before the call is made.
+func CreateContext(ctx context.Context, d Driver) error {
+	if cd, ok := d.(ContextDriver); ok {
+		return cd.CreateContext(ctx)
+	}
+	return callContext(ctx, d.Create)
+}
+
+// KillContext kills the host through d, see CreateContext.
+func KillContext(ctx context.Context, d Driver) error {
+	if cd, ok := d.(ContextDriver); ok {
+		return cd.KillContext(ctx)
+	}
+	return callContext(ctx, d.Kill)
+}
+
+// RemoveContext removes the host through d, see CreateContext.
+func RemoveContext(ctx context.Context, d Driver) error {
+	if cd, ok := d.(ContextDriver); ok {
+		return cd.RemoveContext(ctx)
+	}
+	return callContext(ctx, d.Remove)
+}
+
+// RestartContext restarts the host through d, see CreateContext.
+func RestartContext(ctx context.Context, d Driver) error {
+	if cd, ok := d.(ContextDriver); ok {
+		return cd.RestartContext(ctx)
+	}
+	return callContext(ctx, d.Restart)
+}
+
+// StartContext starts the host through d, see CreateContext.
+func StartContext(ctx context.Context, d Driver) error {
+	if cd, ok := d.(ContextDriver); ok {
+		return cd.StartContext(ctx)
+	}
+	return callContext(ctx, d.Start)
+}
+
+// StopContext stops the host through d, see CreateContext.
+func StopContext(ctx context.Context, d Driver) error {
+	if cd, ok := d.(ContextDriver); ok {
+		return cd.StopContext(ctx)
+	}
+	return callContext(ctx, d.Stop)
+}
+
+func callContext(ctx context.Context, action func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return action()
+}
+
+// WithContext returns d bound to ctx. The SSH commands run and the waits done
+// through the returned driver, e.g. by a provisioner, are aborted as soon as
+// ctx is done, and so are its lifecycle calls.
+func WithContext(ctx context.Context, d Driver) Driver {
+	return &contextBoundDriver{
+		Driver: d,
+		ctx:    ctx,
+	}
+}
+
+// ContextOf returns the context d was bound to by WithContext, or
+// context.Background() if it wasn't.
+func ContextOf(d Driver) context.Context {
+	if bound, ok := d.(*contextBoundDriver); ok {
+		return bound.ctx
+	}
+	return context.Background()
+}
+
+type contextBoundDriver struct {
+	Driver
+	ctx context.Context
+}
+
+func (d *contextBoundDriver) Create() error {
+	return CreateContext(d.ctx, d.Driver)
+}
+
+func (d *contextBoundDriver) Kill() error {
+	return KillContext(d.ctx, d.Driver)
+}
+
+func (d *contextBoundDriver) Remove() error {
+	return RemoveContext(d.ctx, d.Driver)
+}
+
+func (d *contextBoundDriver) Restart() error {
+	return RestartContext(d.ctx, d.Driver)
+}
+
+func (d *contextBoundDriver) Start() error {
+	return StartContext(d.ctx, d.Driver)
+}
+
+func (d *contextBoundDriver) Stop() error {
+	return StopContext(d.ctx, d.Driver)
+}
+
+func (d *contextBoundDriver) CreateContext(ctx context.Context) error {
+	return CreateContext(ctx, d.Driver)
+}
+
+func (d *contextBoundDriver) KillContext(ctx context.Context) error {
+	return KillContext(ctx, d.Driver)
+}
+
+func (d *contextBoundDriver) RemoveContext(ctx context.Context) error {
+	return RemoveContext(ctx, d.Driver)
+}
+
+func (d *contextBoundDriver) RestartContext(ctx context.Context) error {
+	return RestartContext(ctx, d.Driver)
+}
+
+func (d *contextBoundDriver) StartContext(ctx context.Context) error {
+	return StartContext(ctx, d.Driver)
+}
+
+func (d *contextBoundDriver) StopContext(ctx context.Context) error {
+	return StopContext(ctx, d.Driver)
+}
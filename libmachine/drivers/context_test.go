@@ -0,0 +1,31 @@
+package drivers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextOf(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := &MockDriver{calls: &CallRecorder{}}
+
+	assert.Equal(t, context.Background(), ContextOf(d))
+	assert.Equal(t, ctx, ContextOf(WithContext(ctx, d)))
+}
+
+func TestWithContextAbortsCallsOnceDone(t *testing.T) {
+	callRecorder := &CallRecorder{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d := WithContext(ctx, &MockDriver{calls: callRecorder})
+
+	assert.NoError(t, d.Start())
+	cancel()
+	assert.Equal(t, context.Canceled, d.Stop())
+
+	assert.Equal(t, []string{"Start"}, callRecorder.calls)
+}
@@ -0,0 +1,420 @@
+// Package driverstest checks that a driver honours the contract of
+// drivers.Driver. The in-tree drivers run it, the drivers of the plugins can
+// run it the same way:
+//
+//	func TestConformance(t *testing.T) {
+//		driverstest.Run(t, driverstest.Suite{
+//			NewDriver: func(machineName, storePath string) drivers.Driver {
+//				return NewDriver(machineName, storePath)
+//			},
+//			FlagValues: map[string]interface{}{
+//				"foo-access-token": "token",
+//			},
+//		})
+//	}
+package driverstest
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/rpc"
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/docker/machine/libmachine/state"
+)
+
+const machineName = "conformance"
+
+// Suite describes the driver to check.
+type Suite struct {
+	// NewDriver returns a new driver of the named machine, as the plugin
+	// of the driver does. Its backend must be faked when the lifecycle
+	// is checked.
+	NewDriver func(machineName, storePath string) drivers.Driver
+
+	// FlagValues are the values of the create flags which the defaults
+	// don't do for, e.g. the required ones. The defaults of the others
+	// are used.
+	FlagValues map[string]interface{}
+
+	// GeneratedFields are the fields of the configuration which NewDriver
+	// sets to values of its own, e.g. a random ID, rather than to the
+	// defaults of the create flags.
+	GeneratedFields []string
+
+	// Lifecycle tells whether the machines can be created, stopped,
+	// started and removed with the backend of the drivers returned by
+	// NewDriver. The lifecycle isn't checked otherwise.
+	Lifecycle bool
+
+	// RemoveMissing tells whether the backend of the drivers returned by
+	// NewDriver knows no machine, so that removing one which doesn't
+	// exist can be checked.
+	RemoveMissing bool
+}
+
+// Run checks the driver described by the suite, each part of the contract
+// in a subtest.
+func Run(t *testing.T, s Suite) {
+	storePath, err := ioutil.TempDir("", "machine-conformance-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(storePath)
+
+	newDriver := func() drivers.Driver {
+		return s.NewDriver(machineName, storePath)
+	}
+
+	t.Run("Names", func(t *testing.T) { checkNames(t, newDriver()) })
+	t.Run("CreateFlags", func(t *testing.T) { checkCreateFlags(t, newDriver(), s.FlagValues) })
+	t.Run("SetConfigFromFlags", func(t *testing.T) { checkSetConfigFromFlags(t, newDriver, s.FlagValues, s.GeneratedFields) })
+	t.Run("ConfigJSON", func(t *testing.T) { checkConfigJSON(t, newDriver, s.FlagValues) })
+	t.Run("ConfigRPC", func(t *testing.T) { checkConfigRPC(t, newDriver, s.FlagValues) })
+
+	if s.RemoveMissing {
+		t.Run("RemoveMissing", func(t *testing.T) { checkRemoveMissing(t, newDriver()) })
+	}
+
+	if s.Lifecycle {
+		t.Run("Lifecycle", func(t *testing.T) { checkLifecycle(t, newDriver, s.FlagValues) })
+	}
+}
+
+// flagOptions returns the options the create command gives the driver when
+// only the flag values are set.
+func flagOptions(d drivers.Driver, values map[string]interface{}) *drivers.CheckDriverOptions {
+	return &drivers.CheckDriverOptions{
+		FlagsValues: values,
+		CreateFlags: d.GetCreateFlags(),
+	}
+}
+
+// configure configures a new driver with the flag values.
+func configure(t *testing.T, newDriver func() drivers.Driver, values map[string]interface{}) drivers.Driver {
+	d := newDriver()
+
+	if err := d.SetConfigFromFlags(flagOptions(d, values)); err != nil {
+		t.Fatalf("SetConfigFromFlags failed with the default flag values: %s", err)
+	}
+
+	return d
+}
+
+func checkNames(t *testing.T, d drivers.Driver) {
+	if d.DriverName() == "" {
+		t.Error("DriverName is empty")
+	}
+
+	if name := d.GetMachineName(); name != machineName {
+		t.Errorf("GetMachineName returns %q rather than the name given to the driver, %q", name, machineName)
+	}
+}
+
+func checkCreateFlags(t *testing.T, d drivers.Driver, flagValues map[string]interface{}) {
+	flags := d.GetCreateFlags()
+
+	values := map[string]interface{}{}
+	seen := map[string]bool{}
+	for _, f := range flags {
+		m := mcnflag.MetadataOf(f)
+		if m.Name == "" {
+			t.Errorf("A create flag has no name: %#v", f)
+			continue
+		}
+		if seen[m.Name] {
+			t.Errorf("The create flag --%s is declared twice", m.Name)
+		}
+		seen[m.Name] = true
+
+		if m.Usage == "" {
+			t.Errorf("The create flag --%s has no usage", m.Name)
+		}
+
+		values[m.Name] = m.Default
+	}
+
+	for name, value := range flagValues {
+		if !seen[name] {
+			t.Errorf("The flag value of --%s isn't one of the create flags", name)
+		}
+		values[name] = value
+	}
+
+	if err := mcnflag.Validate(flags, values); err != nil {
+		t.Errorf("The default flag values are invalid: %s", err)
+	}
+
+	// The flags are sent to the client with gob by the plugins.
+	sent, err := sendFlags(flags)
+	if err != nil {
+		t.Fatalf("The create flags can't be sent over RPC: %s", err)
+	}
+	if len(sent) != len(flags) {
+		t.Fatalf("%d create flags are sent over RPC rather than %d", len(sent), len(flags))
+	}
+	for i := range flags {
+		if want, got := mcnflag.MetadataOf(flags[i]), mcnflag.MetadataOf(sent[i]); !reflect.DeepEqual(want, got) {
+			t.Errorf("The create flag --%s is %+v once sent over RPC rather than %+v", want.Name, got, want)
+		}
+	}
+}
+
+// sendFlags encodes and decodes the flags as the RPC server of the plugins
+// does.
+func sendFlags(flags []mcnflag.Flag) ([]mcnflag.Flag, error) {
+	server := rpcdriver.NewRPCServerDriver(&flagsDriver{flags: flags})
+
+	var reply []mcnflag.Flag
+	if err := server.GetCreateFlags(nil, &reply); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(&reply); err != nil {
+		return nil, err
+	}
+
+	var sent []mcnflag.Flag
+	if err := gob.NewDecoder(buf).Decode(&sent); err != nil {
+		return nil, err
+	}
+
+	return sent, nil
+}
+
+// flagsDriver only returns its create flags.
+type flagsDriver struct {
+	drivers.Driver
+	flags []mcnflag.Flag
+}
+
+func (d *flagsDriver) GetCreateFlags() []mcnflag.Flag {
+	return d.flags
+}
+
+func checkSetConfigFromFlags(t *testing.T, newDriver func() drivers.Driver, flagValues map[string]interface{}, generatedFields []string) {
+	d := newDriver()
+
+	opts := flagOptions(d, flagValues)
+	if err := d.SetConfigFromFlags(opts); err != nil {
+		t.Fatalf("SetConfigFromFlags failed with the default flag values: %s", err)
+	}
+
+	for _, name := range opts.InvalidFlags {
+		t.Errorf("SetConfigFromFlags reads --%s with a type other than the one of the flag", name)
+	}
+
+	// The fields set by NewDriver are the defaults of the machines created
+	// without the create command, e.g. through libmachine, which must be
+	// the same as the defaults of the create flags.
+	fresh, err := configFields(newDriver())
+	if err != nil {
+		t.Fatal(err)
+	}
+	configured, err := configFields(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range generatedFields {
+		delete(fresh, name)
+	}
+
+	for _, field := range differentDefaults("", fresh, configured) {
+		t.Errorf("NewDriver sets %s to %v, but the defaults of the create flags set it to %v", field.name, field.fresh, field.configured)
+	}
+}
+
+type differentField struct {
+	name       string
+	fresh      interface{}
+	configured interface{}
+}
+
+// differentDefaults returns the fields set by NewDriver, i.e. not zero in
+// fresh, which are different in configured. The fields of the nested
+// structures are compared one by one, the fields left to the flag values
+// being zero in fresh.
+func differentDefaults(prefix string, fresh, configured map[string]interface{}) []differentField {
+	different := []differentField{}
+	for field, value := range fresh {
+		if isZero(value) {
+			continue
+		}
+
+		nested, isStruct := value.(map[string]interface{})
+		configuredNested, bothStructs := configured[field].(map[string]interface{})
+		if isStruct && bothStructs {
+			different = append(different, differentDefaults(prefix+field+".", nested, configuredNested)...)
+			continue
+		}
+
+		if !reflect.DeepEqual(value, configured[field]) {
+			different = append(different, differentField{prefix + field, value, configured[field]})
+		}
+	}
+
+	return different
+}
+
+// configFields returns the fields of the configuration of the driver.
+func configFields(d drivers.Driver) (map[string]interface{}, error) {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+func isZero(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+
+	return reflect.DeepEqual(value, reflect.Zero(v.Type()).Interface())
+}
+
+func checkConfigJSON(t *testing.T, newDriver func() drivers.Driver, flagValues map[string]interface{}) {
+	d := configure(t, newDriver, flagValues)
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("The configuration can't be saved: %s", err)
+	}
+
+	loaded := newDriver()
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatalf("The configuration can't be loaded: %s", err)
+	}
+
+	reloaded, err := json.Marshal(loaded)
+	if err != nil {
+		t.Fatalf("The loaded configuration can't be saved: %s", err)
+	}
+
+	if !bytes.Equal(data, reloaded) {
+		t.Errorf("The configuration is\n%s\nonce loaded rather than\n%s", reloaded, data)
+	}
+}
+
+func checkConfigRPC(t *testing.T, newDriver func() drivers.Driver, flagValues map[string]interface{}) {
+	server := rpcdriver.NewRPCServerDriver(configure(t, newDriver, flagValues))
+
+	var data []byte
+	if err := server.GetConfigRaw(nil, &data); err != nil {
+		t.Fatalf("GetConfigRaw failed: %s", err)
+	}
+
+	// The client restores the configuration in the plugin it launches
+	// for the machine.
+	restarted := rpcdriver.NewRPCServerDriver(newDriver())
+	if err := restarted.SetConfigRaw(data, nil); err != nil {
+		t.Fatalf("SetConfigRaw failed: %s", err)
+	}
+
+	var restored []byte
+	if err := restarted.GetConfigRaw(nil, &restored); err != nil {
+		t.Fatalf("GetConfigRaw failed once the configuration was restored: %s", err)
+	}
+
+	if !bytes.Equal(data, restored) {
+		t.Errorf("The configuration is\n%s\nonce restored over RPC rather than\n%s", restored, data)
+	}
+}
+
+func checkRemoveMissing(t *testing.T, d drivers.Driver) {
+	if err := d.Remove(); err != nil {
+		t.Errorf("Remove fails when the machine doesn't exist: %s", err)
+	}
+}
+
+func checkLifecycle(t *testing.T, newDriver func() drivers.Driver, flagValues map[string]interface{}) {
+	d := configure(t, newDriver, flagValues)
+
+	if err := d.PreCreateCheck(); err != nil {
+		t.Fatalf("PreCreateCheck failed: %s", err)
+	}
+	if err := d.Create(); err != nil {
+		t.Fatalf("Create failed: %s", err)
+	}
+
+	expectState(t, d, "Create", state.Running)
+	if url, err := d.GetURL(); err != nil || url == "" {
+		t.Errorf("GetURL returns %q, %v when the machine is running", url, err)
+	}
+	if ip, err := d.GetIP(); err != nil || ip == "" {
+		t.Errorf("GetIP returns %q, %v when the machine is running", ip, err)
+	}
+
+	if drivers.HasCapability(d, drivers.CapabilityPause) {
+		if err := drivers.Pause(d); err != nil {
+			t.Fatalf("Pause failed: %s", err)
+		}
+		expectState(t, d, "Pause", state.Paused)
+
+		if err := drivers.Unpause(d); err != nil {
+			t.Fatalf("Unpause failed: %s", err)
+		}
+		expectState(t, d, "Unpause", state.Running)
+	}
+
+	if err := d.Stop(); err != nil {
+		t.Fatalf("Stop failed: %s", err)
+	}
+	expectState(t, d, "Stop", state.Stopped)
+	if url, err := d.GetURL(); err == nil {
+		t.Errorf("GetURL returns %q rather than an error when the machine is stopped", url)
+	}
+
+	if err := d.Start(); err != nil {
+		t.Fatalf("Start failed: %s", err)
+	}
+	expectState(t, d, "Start", state.Running)
+
+	if err := d.Restart(); err != nil {
+		t.Fatalf("Restart failed: %s", err)
+	}
+	expectState(t, d, "Restart", state.Running)
+
+	if err := d.Kill(); err != nil {
+		t.Fatalf("Kill failed: %s", err)
+	}
+	expectState(t, d, "Kill", state.Stopped)
+
+	if err := d.Remove(); err != nil {
+		t.Fatalf("Remove failed: %s", err)
+	}
+	if err := d.Remove(); err != nil {
+		t.Errorf("Remove fails once the machine was removed: %s", err)
+	}
+}
+
+func expectState(t *testing.T, d drivers.Driver, operation string, expected state.State) {
+	s, err := d.GetState()
+	if err != nil {
+		t.Fatalf("GetState failed after %s: %s", operation, err)
+	}
+
+	if s != expected {
+		t.Errorf("GetState returns %s rather than %s after %s", s, expected, operation)
+	}
+}
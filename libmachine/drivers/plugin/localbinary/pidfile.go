@@ -0,0 +1,147 @@
+package localbinary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/machine/libmachine/lock"
+	"github.com/docker/machine/libmachine/log"
+)
+
+const pidfileExt = ".json"
+
+var (
+	pidDir = ""
+
+	processAlive = lock.IsProcessAlive
+	isPlugin     = isPluginProcess
+)
+
+// SetPidDir sets the directory the plugin processes are recorded in while
+// they run, so that the ones left behind by a client which crashed can be
+// found. The processes aren't recorded when it is empty.
+func SetPidDir(dir string) {
+	pidDir = dir
+}
+
+// PluginProcess is a plugin process recorded in the pid directory.
+type PluginProcess struct {
+	PID        int
+	ClientPID  int
+	DriverName string
+	BinaryPath string
+	Started    time.Time
+
+	pidfile string
+}
+
+func pidfilePath(pid int) string {
+	return filepath.Join(pidDir, strconv.Itoa(pid)+pidfileExt)
+}
+
+// writePidfile records the plugin process, failing to do so only prevents
+// it from being found if it is left behind.
+func writePidfile(p PluginProcess) {
+	if pidDir == "" {
+		return
+	}
+
+	data, err := json.Marshal(p)
+	if err == nil {
+		if err = os.MkdirAll(pidDir, 0700); err == nil {
+			err = ioutil.WriteFile(pidfilePath(p.PID), data, 0600)
+		}
+	}
+
+	if err != nil {
+		log.Debugf("Error recording the plugin process %d: %s", p.PID, err)
+	}
+}
+
+func removePidfile(pid int) {
+	if pidDir == "" {
+		return
+	}
+
+	if err := os.Remove(pidfilePath(pid)); err != nil && !os.IsNotExist(err) {
+		log.Debugf("Error removing the record of the plugin process %d: %s", pid, err)
+	}
+}
+
+// OrphanedPlugins returns the plugin processes still running although the
+// client which launched them exited. The records of the plugin processes
+// which exited are removed.
+func OrphanedPlugins() ([]PluginProcess, error) {
+	if pidDir == "" {
+		return nil, nil
+	}
+
+	files, err := ioutil.ReadDir(pidDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	orphans := []PluginProcess{}
+	for _, fi := range files {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), pidfileExt) {
+			continue
+		}
+
+		path := filepath.Join(pidDir, fi.Name())
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var p PluginProcess
+		if err := json.Unmarshal(data, &p); err != nil {
+			log.Debugf("Removing the invalid record of a plugin process %s: %s", path, err)
+			os.Remove(path)
+			continue
+		}
+		p.pidfile = path
+
+		// The PID may have been reused since the plugin exited.
+		if !processAlive(p.PID) || !isPlugin(p.PID, p.BinaryPath) {
+			log.Debugf("Removing the record of the plugin process %d, which exited", p.PID)
+			os.Remove(path)
+			continue
+		}
+
+		if processAlive(p.ClientPID) {
+			continue
+		}
+
+		orphans = append(orphans, p)
+	}
+
+	return orphans, nil
+}
+
+// Kill kills the orphaned plugin process and removes its record.
+func (p PluginProcess) Kill() error {
+	process, err := os.FindProcess(p.PID)
+	if err != nil {
+		return err
+	}
+
+	if err := process.Kill(); err != nil {
+		return fmt.Errorf("Error killing the plugin process %d: %s", p.PID, err)
+	}
+
+	if p.pidfile != "" {
+		os.Remove(p.pidfile)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package localbinary
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrphanedPlugins(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	defer SetPidDir("")
+	SetPidDir(dir)
+
+	defer func(alive func(int) bool, plugin func(int, string) bool) {
+		processAlive, isPlugin = alive, plugin
+	}(processAlive, isPlugin)
+
+	// 1 is a client still running, 10 to 13 are plugins, 12 got its PID
+	// reused by another binary and 13 exited.
+	processAlive = func(pid int) bool { return pid == 1 || (pid >= 10 && pid <= 12) }
+	isPlugin = func(pid int, binaryPath string) bool { return pid != 12 }
+
+	started := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	for _, p := range []PluginProcess{
+		{PID: 10, ClientPID: 1, DriverName: "foo", BinaryPath: "/bin/docker-machine-driver-foo", Started: started},
+		{PID: 11, ClientPID: 2, DriverName: "bar", BinaryPath: "/bin/docker-machine-driver-bar", Started: started},
+		{PID: 12, ClientPID: 2, DriverName: "baz", BinaryPath: "/bin/docker-machine-driver-baz", Started: started},
+		{PID: 13, ClientPID: 2, DriverName: "qux", BinaryPath: "/bin/docker-machine-driver-qux", Started: started},
+	} {
+		writePidfile(p)
+	}
+
+	orphans, err := OrphanedPlugins()
+	assert.NoError(t, err)
+	assert.Equal(t, []PluginProcess{
+		{PID: 11, ClientPID: 2, DriverName: "bar", BinaryPath: "/bin/docker-machine-driver-bar", Started: started, pidfile: filepath.Join(dir, "11.json")},
+	}, orphans)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	removePidfile(10)
+	removePidfile(11)
+
+	orphans, err = OrphanedPlugins()
+	assert.NoError(t, err)
+	assert.Empty(t, orphans)
+}
+
+func TestOrphanedPluginsWithoutPidDir(t *testing.T) {
+	orphans, err := OrphanedPlugins()
+	assert.NoError(t, err)
+	assert.Empty(t, orphans)
+}
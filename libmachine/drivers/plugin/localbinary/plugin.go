@@ -2,10 +2,14 @@ package localbinary
 
 import (
 	"bufio"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"time"
 
@@ -17,10 +21,10 @@ var (
 	// plugin server.
 	defaultTimeout               = 10 * time.Second
 	CurrentBinaryIsDockerMachine = false
-	CoreDrivers                  = []string{"amazonec2", "azure", "digitalocean",
-		"exoscale", "generic", "google", "hyperv", "none", "openstack",
-		"rackspace", "softlayer", "virtualbox", "vmwarefusion",
-		"vmwarevcloudair", "vmwarevsphere"}
+	CoreDrivers                  = []string{"amazonec2", "azure", "container",
+		"digitalocean", "exoscale", "generic", "google", "hyperv", "lxd",
+		"none", "openstack", "pool", "qemu", "rackspace", "softlayer",
+		"virtualbox", "vmwarefusion", "vmwarevcloudair", "vmwarevsphere"}
 )
 
 const (
@@ -29,8 +33,43 @@ const (
 	PluginEnvKey        = "MACHINE_PLUGIN_TOKEN"
 	PluginEnvVal        = "42"
 	PluginEnvDriverName = "MACHINE_PLUGIN_DRIVER_NAME"
+
+	// PluginEnvAuthToken hands the plugin the token each of the calls of
+	// the client carries. The plugins launched with a token serve on a Unix
+	// socket only the user can open, when available.
+	PluginEnvAuthToken = "MACHINE_PLUGIN_AUTH_TOKEN"
+
+	// PluginEnvIgnoreInterrupt tells the plugin that the client handles
+	// interrupts itself, and closes the plugin once it is done cleaning up.
+	PluginEnvIgnoreInterrupt = "MACHINE_PLUGIN_IGNORE_INTERRUPT"
 )
 
+// BinaryPrefix starts the names of the driver plugin binaries, followed by
+// the name of their driver.
+const BinaryPrefix = "docker-machine-driver-"
+
+var clientHandlesInterrupt = false
+
+var pluginDir = ""
+
+// SetPluginDir sets the directory the driver plugins are installed in by
+// docker-machine. It is searched before the PATH.
+func SetPluginDir(dir string) {
+	pluginDir = dir
+}
+
+// PluginDir returns the directory the driver plugins are installed in.
+func PluginDir() string {
+	return pluginDir
+}
+
+// SetClientHandlesInterrupt tells whether the client handles interrupts,
+// e.g. Ctrl-C, by winding down the current operation. The plugins launched
+// afterwards then ignore interrupts instead of dying underneath the client.
+func SetClientHandlesInterrupt(handles bool) {
+	clientHandlesInterrupt = handles
+}
+
 type PluginStreamer interface {
 	// Return a channel for receiving the output of the stream line by
 	// line.
@@ -71,6 +110,7 @@ type Plugin struct {
 	Executor    McnBinaryExecutor
 	Addr        string
 	MachineName string
+	Token       string
 	addrCh      chan string
 	stopCh      chan struct{}
 	timeout     time.Duration
@@ -79,6 +119,7 @@ type Plugin struct {
 type Executor struct {
 	pluginStdout, pluginStderr io.ReadCloser
 	DriverName                 string
+	Token                      string
 	cmd                        *exec.Cmd
 	binaryPath                 string
 }
@@ -92,46 +133,102 @@ func (e ErrPluginBinaryNotFound) Error() string {
 	return fmt.Sprintf("Driver %q not found. Do you have the plugin binary %q accessible in your PATH?", e.driverName, e.driverPath)
 }
 
+// IsCoreDriver tells whether the driver is built in docker-machine.
+func IsCoreDriver(driverName string) bool {
+	for _, coreDriver := range CoreDrivers {
+		if coreDriver == driverName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BinaryName returns the name of the plugin binary of a driver which is not
+// a core driver.
+func BinaryName(driverName string) string {
+	name := BinaryPrefix + driverName
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	return name
+}
+
 // driverPath finds the path of a driver binary by its name.
 //   - If the driver is a core driver, there is no separate driver binary. We reuse current binary if it's `docker-machine`
 //
 // or we assume `docker-machine` is in the PATH.
-//   - If the driver is NOT a core driver, then the separate binary must be in the PATH and it's name must be
+//   - If the driver is NOT a core driver, then the separate binary must be in the plugin directory or in the PATH and
 //
-// `docker-machine-driver-driverName`
+// it's name must be `docker-machine-driver-driverName`
 func driverPath(driverName string) string {
-	for _, coreDriver := range CoreDrivers {
-		if coreDriver == driverName {
-			if CurrentBinaryIsDockerMachine {
-				return os.Args[0]
-			}
-
-			return "docker-machine"
+	if IsCoreDriver(driverName) {
+		if CurrentBinaryIsDockerMachine {
+			return os.Args[0]
 		}
+
+		return "docker-machine"
 	}
 
 	return fmt.Sprintf("docker-machine-driver-%s", driverName)
 }
 
-func NewPlugin(driverName string) (*Plugin, error) {
+// FindDriver returns the path of the binary serving the driver, looking in
+// the plugin directory before the PATH.
+func FindDriver(driverName string) (string, error) {
 	driverPath := driverPath(driverName)
+
+	if pluginDir != "" && !IsCoreDriver(driverName) {
+		installedPath := filepath.Join(pluginDir, BinaryName(driverName))
+		if fi, err := os.Stat(installedPath); err == nil && !fi.IsDir() {
+			return installedPath, nil
+		}
+	}
+
 	binaryPath, err := exec.LookPath(driverPath)
 	if err != nil {
-		return nil, ErrPluginBinaryNotFound{driverName, driverPath}
+		return "", ErrPluginBinaryNotFound{driverName, driverPath}
+	}
+
+	return binaryPath, nil
+}
+
+func NewPlugin(driverName string) (*Plugin, error) {
+	binaryPath, err := FindDriver(driverName)
+	if err != nil {
+		return nil, err
 	}
 
 	log.Debugf("Found binary path at %s", binaryPath)
 
+	token, err := newToken()
+	if err != nil {
+		return nil, fmt.Errorf("Error generating the token of the plugin: %s", err)
+	}
+
 	return &Plugin{
 		stopCh: make(chan struct{}),
 		addrCh: make(chan string, 1),
+		Token:  token,
 		Executor: &Executor{
 			DriverName: driverName,
+			Token:      token,
 			binaryPath: binaryPath,
 		},
 	}, nil
 }
 
+// newToken returns a random token, a new one for each launch of a plugin.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
 func (lbe *Executor) Start() (*bufio.Scanner, *bufio.Scanner, error) {
 	var err error
 
@@ -154,16 +251,39 @@ func (lbe *Executor) Start() (*bufio.Scanner, *bufio.Scanner, error) {
 
 	os.Setenv(PluginEnvKey, PluginEnvVal)
 	os.Setenv(PluginEnvDriverName, lbe.DriverName)
+	if clientHandlesInterrupt {
+		os.Setenv(PluginEnvIgnoreInterrupt, "1")
+	} else {
+		os.Unsetenv(PluginEnvIgnoreInterrupt)
+	}
+
+	// The token is only handed over to this plugin, not to the ones
+	// launched concurrently.
+	lbe.cmd.Env = os.Environ()
+	if lbe.Token != "" {
+		lbe.cmd.Env = append(lbe.cmd.Env, PluginEnvAuthToken+"="+lbe.Token)
+	}
 
 	if err := lbe.cmd.Start(); err != nil {
 		return nil, nil, fmt.Errorf("Error starting plugin binary: %s", err)
 	}
 
+	writePidfile(PluginProcess{
+		PID:        lbe.cmd.Process.Pid,
+		ClientPID:  os.Getpid(),
+		DriverName: lbe.DriverName,
+		BinaryPath: lbe.binaryPath,
+		Started:    time.Now(),
+	})
+
 	return outScanner, errScanner, nil
 }
 
 func (lbe *Executor) Close() error {
-	if err := lbe.cmd.Wait(); err != nil {
+	err := lbe.cmd.Wait()
+	removePidfile(lbe.cmd.Process.Pid)
+
+	if err != nil {
 		return fmt.Errorf("Error waiting for binary close: %s", err)
 	}
 
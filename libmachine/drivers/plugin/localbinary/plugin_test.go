@@ -4,11 +4,12 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
-	"os"
-
 	"github.com/docker/machine/libmachine/log"
 	"github.com/stretchr/testify/assert"
 )
@@ -157,3 +158,35 @@ func TestExecServer(t *testing.T) {
 		t.Fatalf("Error serving: %s", err)
 	}
 }
+
+func TestFindDriverInPluginDir(t *testing.T) {
+	pluginDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(pluginDir)
+
+	pathDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(pathDir)
+
+	for _, dir := range []string{pluginDir, pathDir} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, BinaryName("foo")), []byte{}, 0755))
+	}
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(pathDir, BinaryName("bar")), []byte{}, 0755))
+
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", pathDir)
+
+	defer SetPluginDir("")
+	SetPluginDir(pluginDir)
+
+	path, err := FindDriver("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(pluginDir, BinaryName("foo")), path)
+
+	path, err = FindDriver("bar")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(pathDir, BinaryName("bar")), path)
+
+	_, err = FindDriver("baz")
+	assert.Equal(t, ErrPluginBinaryNotFound{"baz", "docker-machine-driver-baz"}, err)
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package localbinary
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// isPluginProcess tells whether the process runs the plugin binary, rather
+// than being another process which got its PID.
+func isPluginProcess(pid int, binaryPath string) bool {
+	out, err := exec.Command("ps", "-o", "args=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return false
+	}
+
+	return strings.HasPrefix(strings.TrimSpace(string(out)), binaryPath)
+}
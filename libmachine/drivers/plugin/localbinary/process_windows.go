@@ -0,0 +1,19 @@
+package localbinary
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// isPluginProcess tells whether the process runs the plugin binary, rather
+// than being another process which got its PID.
+func isPluginProcess(pid int, binaryPath string) bool {
+	out, err := exec.Command("tasklist", "/FI", fmt.Sprintf("PID eq %d", pid), "/FO", "CSV", "/NH").Output()
+	if err != nil {
+		return false
+	}
+
+	return strings.Contains(strings.ToLower(string(out)), strings.ToLower(`"`+filepath.Base(binaryPath)+`"`))
+}
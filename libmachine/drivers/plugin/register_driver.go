@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/rpc"
 	"os"
+	"os/signal"
 	"time"
 
 	"github.com/docker/machine/libmachine/drivers"
@@ -17,9 +18,26 @@ import (
 
 var (
 	heartbeatTimeout = 10 * time.Second
+
+	// heartbeatRetries is how many heartbeat timeouts in a row the plugin
+	// waits for while the client is still running, e.g. when it was
+	// suspended or is busy.
+	heartbeatRetries = 3
 )
 
+// RegisterDriver serves the driver as a plugin, for a single machine. The
+// client launches a plugin for each of the machines of the driver.
 func RegisterDriver(d drivers.Driver) {
+	serve(d, nil)
+}
+
+// RegisterDriverFactory serves the drivers created by newDriver as a plugin,
+// for all the machines of the driver the client uses.
+func RegisterDriverFactory(newDriver func(hostName, storePath string) drivers.Driver) {
+	serve(newDriver("", ""), newDriver)
+}
+
+func serve(d drivers.Driver, newDriver func(hostName, storePath string) drivers.Driver) {
 	if os.Getenv(localbinary.PluginEnvKey) != localbinary.PluginEnvVal {
 		fmt.Fprintf(os.Stderr, `This is a Docker Machine plugin binary.
 Plugin binaries are not intended to be invoked directly.
@@ -32,32 +50,88 @@ Please use this plugin through the main 'docker-machine' binary.
 	log.SetDebug(true)
 	os.Setenv("MACHINE_DEBUG", "1")
 
+	// A Ctrl-C in the terminal is delivered to the whole process group.
+	// When the client handles it, it decides how to wind down a cancelled
+	// operation and closes the plugin when it is done, so don't die
+	// underneath it.
+	if os.Getenv(localbinary.PluginEnvIgnoreInterrupt) == "1" {
+		signal.Ignore(os.Interrupt)
+	}
+
 	rpcd := rpcdriver.NewRPCServerDriver(d)
+	if newDriver != nil {
+		rpcd.ServeInstances(rpc.DefaultServer, func() drivers.Driver {
+			return newDriver("", "")
+		})
+	}
+
+	// The clients speaking version 2 of the protocol get the logs as
+	// events, the older ones scrape them from the output.
+	fallbackLogger := log.NewFmtMachineLogger()
+	fallbackLogger.SetDebug(true)
+	log.SetLogger(rpcd.Logger(fallbackLogger))
+
 	rpc.RegisterName(rpcdriver.RPCServiceNameV0, rpcd)
 	rpc.RegisterName(rpcdriver.RPCServiceNameV1, rpcd)
-	rpc.HandleHTTP()
 
-	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	listener, addr, err := listen()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading RPC server: %s\n", err)
 		os.Exit(1)
 	}
-	defer listener.Close()
 
-	fmt.Println(listener.Addr())
+	// The deferred calls don't run when exiting.
+	exit := func(code int) {
+		listener.Close()
+		os.Exit(code)
+	}
 
-	go http.Serve(listener, nil)
+	fmt.Println(addr)
+
+	// The plugin is reparented when the client exits, at least outside of
+	// Windows, so there is no point waiting for its heartbeats anymore.
+	clientPID := os.Getppid()
+	missedHeartbeats := 0
 
 	for {
 		select {
 		case <-rpcd.CloseCh:
 			log.Debug("Closing plugin on server side")
-			os.Exit(0)
+			exit(0)
 		case <-rpcd.HeartbeatCh:
-			continue
+			missedHeartbeats = 0
 		case <-time.After(heartbeatTimeout):
-			// TODO: Add heartbeat retry logic
-			os.Exit(1)
+			missedHeartbeats++
+			if missedHeartbeats > heartbeatRetries || os.Getppid() != clientPID {
+				exit(1)
+			}
 		}
 	}
 }
+
+// listen serves the RPC server for the client. The clients which hand over
+// a token get an authenticated connection, the older ones the HTTP server
+// on the loopback interface.
+func listen() (net.Listener, string, error) {
+	if token := os.Getenv(localbinary.PluginEnvAuthToken); token != "" {
+		listener, addr, err := rpcdriver.ListenAuthenticated()
+		if err != nil {
+			return nil, "", err
+		}
+
+		go rpcdriver.ServeAuthenticated(rpc.DefaultServer, listener, token)
+
+		return listener, addr, nil
+	}
+
+	rpc.HandleHTTP()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", err
+	}
+
+	go http.Serve(listener, nil)
+
+	return listener, listener.Addr().String(), nil
+}
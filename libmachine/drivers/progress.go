@@ -0,0 +1,28 @@
+package drivers
+
+import "context"
+
+// Progress is how far a long running operation of a driver got, e.g. the
+// download of an image. Total is 0 when it isn't known.
+type Progress struct {
+	Stage   string
+	Current int64
+	Total   int64
+}
+
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx the drivers report the progress of
+// their operations to, through fn.
+func WithProgress(ctx context.Context, fn func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress reports the progress of the operation run with ctx, if
+// someone is listening. The drivers implementing ContextDriver can call it
+// with the context they are given.
+func ReportProgress(ctx context.Context, p Progress) {
+	if fn, ok := ctx.Value(progressKey{}).(func(Progress)); ok {
+		fn(p)
+	}
+}
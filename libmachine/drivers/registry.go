@@ -0,0 +1,76 @@
+package drivers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory creates a driver for the machine, e.g. the NewDriver function of
+// the driver packages.
+type Factory func(machineName, storePath string) Driver
+
+// Registry keeps the drivers built into a program, by name. The programs
+// embedding libmachine register their drivers there so that they are run
+// in-process rather than as plugins.
+type Registry struct {
+	lock      sync.RWMutex
+	factories map[string]Factory
+}
+
+// DefaultRegistry is the registry used by the libmachine clients unless they
+// are given their own.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		factories: map[string]Factory{},
+	}
+}
+
+// Register makes the driver created by the factory available under the
+// name. It panics when the name is already registered, like
+// database/sql.Register.
+func (r *Registry) Register(name string, factory Factory) {
+	if factory == nil {
+		panic(fmt.Sprintf("drivers: Register of a nil factory for %q", name))
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, dup := r.factories[name]; dup {
+		panic(fmt.Sprintf("drivers: Register called twice for %q", name))
+	}
+	r.factories[name] = factory
+}
+
+// Lookup returns the factory registered under the name.
+func (r *Registry) Lookup(name string) (Factory, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	factory, ok := r.factories[name]
+	return factory, ok
+}
+
+// Names returns the registered names, sorted.
+func (r *Registry) Names() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	names := []string{}
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Register makes the driver created by the factory available under the name
+// in the DefaultRegistry.
+func Register(name string, factory Factory) {
+	DefaultRegistry.Register(name, factory)
+}
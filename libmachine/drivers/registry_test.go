@@ -0,0 +1,40 @@
+package drivers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newMockDriver(machineName, storePath string) Driver {
+	return &MockDriver{calls: &CallRecorder{}, machineName: machineName}
+}
+
+func TestRegistryLookup(t *testing.T) {
+	r := NewRegistry()
+	r.Register("mock", newMockDriver)
+
+	factory, ok := r.Lookup("mock")
+	assert.True(t, ok)
+	assert.Equal(t, "machine", factory("machine", "").GetMachineName())
+
+	_, ok = r.Lookup("unknown")
+	assert.False(t, ok)
+}
+
+func TestRegistryNames(t *testing.T) {
+	r := NewRegistry()
+	r.Register("b", newMockDriver)
+	r.Register("a", newMockDriver)
+
+	assert.Equal(t, []string{"a", "b"}, r.Names())
+	assert.Equal(t, []string{}, NewRegistry().Names())
+}
+
+func TestRegistryRegisterTwice(t *testing.T) {
+	r := NewRegistry()
+	r.Register("mock", newMockDriver)
+
+	assert.Panics(t, func() { r.Register("mock", newMockDriver) })
+	assert.Panics(t, func() { r.Register("nil", nil) })
+}
@@ -1,6 +1,9 @@
 package rpcdriver
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/rpc"
 	"sync"
@@ -9,7 +12,6 @@ import (
 	"io"
 
 	"github.com/docker/machine/libmachine/drivers"
-	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/state"
@@ -17,7 +19,21 @@ import (
 )
 
 var (
-	heartbeatInterval = 5 * time.Second
+	heartbeatInterval  = 5 * time.Second
+	abortConfigTimeout = 5 * time.Second
+
+	// cancelTimeout is how long a cancelled call is given to stop before
+	// the plugin is shut down.
+	cancelTimeout = 5 * time.Second
+
+	// eventsWaitTimeout is how long the events sent by a call are waited
+	// for once it completed.
+	eventsWaitTimeout = time.Second
+)
+
+const (
+	pluginOut = "(%s) %s"
+	pluginErr = "(%s) DBG | %s"
 )
 
 type RPCClientDriverFactory interface {
@@ -25,22 +41,44 @@ type RPCClientDriverFactory interface {
 	io.Closer
 }
 
+// DefaultRPCClientDriverFactory launches the plugins of the drivers. The
+// plugins which can serve several machines are launched once per driver,
+// the others once per machine.
 type DefaultRPCClientDriverFactory struct {
-	openedDrivers     []*RPCClientDriver
+	openedProcesses   []*pluginProcess
+	sharedProcesses   map[string]*pluginProcess
 	openedDriversLock sync.Locker
 }
 
 func NewRPCClientDriverFactory() RPCClientDriverFactory {
 	return &DefaultRPCClientDriverFactory{
-		openedDrivers:     []*RPCClientDriver{},
+		openedProcesses:   []*pluginProcess{},
+		sharedProcesses:   map[string]*pluginProcess{},
 		openedDriversLock: &sync.Mutex{},
 	}
 }
 
 type RPCClientDriver struct {
-	plugin          localbinary.DriverPlugin
-	heartbeatDoneCh chan bool
-	Client          *InternalClient
+	process *pluginProcess
+
+	// reopen launches the plugin again once it died, it is nil when the
+	// plugin can't be restarted.
+	reopen      func() (*InternalClient, *pluginProcess, error)
+	restartLock sync.Mutex
+
+	// config is the last configuration of the driver known to the client.
+	// It is restored when the plugin is restarted, and handed out when the
+	// plugin was aborted.
+	config     []byte
+	aborted    bool
+	configLock sync.Mutex
+
+	// capabilities are the capabilities offered by the plugin, once it
+	// told them.
+	capabilities     []drivers.Capability
+	capabilitiesLock sync.Mutex
+
+	Client *InternalClient
 }
 
 type RPCCall struct {
@@ -50,9 +88,10 @@ type RPCCall struct {
 }
 
 type InternalClient struct {
-	MachineName    string
-	RPCClient      *rpc.Client
-	rpcServiceName string
+	MachineName     string
+	RPCClient       *rpc.Client
+	rpcServiceName  string
+	protocolVersion int
 }
 
 const (
@@ -83,19 +122,76 @@ const (
 	RestartMethod            = `.Restart`
 	KillMethod               = `.Kill`
 	UpgradeMethod            = `.Upgrade`
+	GetCapabilitiesMethod    = `.GetCapabilities`
+	PauseMethod              = `.Pause`
+	UnpauseMethod            = `.Unpause`
+	CreateSnapshotMethod     = `.CreateSnapshot`
+	ListSnapshotsMethod      = `.ListSnapshots`
+	RestoreSnapshotMethod    = `.RestoreSnapshot`
+	RemoveSnapshotMethod     = `.RemoveSnapshot`
+	ResizeMethod             = `.Resize`
+	ConsoleLogMethod         = `.ConsoleLog`
 )
 
 func (ic *InternalClient) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	if serviceMethod != HeartbeatMethod && serviceMethod != EventsMethod {
+		log.Debugf("(%s) Calling %+v", ic.MachineName, serviceMethod)
+	}
+	return decodeError(ic.RPCClient.Call(ic.rpcServiceName+serviceMethod, args, reply))
+}
+
+// CallContext behaves like Call, but stops waiting for the reply and returns
+// the context's error as soon as ctx is done.
+func (ic *InternalClient) CallContext(ctx context.Context, serviceMethod string, args interface{}, reply interface{}) error {
 	if serviceMethod != HeartbeatMethod {
 		log.Debugf("(%s) Calling %+v", ic.MachineName, serviceMethod)
 	}
-	return ic.RPCClient.Call(ic.rpcServiceName+serviceMethod, args, reply)
+
+	call := ic.RPCClient.Go(ic.rpcServiceName+serviceMethod, args, reply, make(chan *rpc.Call, 1))
+
+	select {
+	case <-call.Done:
+		return decodeError(call.Error)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 func (ic *InternalClient) switchToV0() {
 	ic.rpcServiceName = RPCServiceNameV0
 }
 
+// NegotiateVersion agrees with the plugin on the newest version of the
+// protocol both of them speak. The plugins predating version 2 are asked
+// for their version, the ones predating 0.5.1 under RPCServiceNameV0.
+func (ic *InternalClient) NegotiateVersion() (int, error) {
+	var serverVersion int
+
+	if err := ic.Call(NegotiateVersionMethod, version.APIVersion, &serverVersion); err != nil {
+		log.Debugf("(%s) Plugin doesn't negotiate the protocol version, asking for its version: %s", ic.MachineName, err)
+
+		if err := ic.Call(GetVersionMethod, struct{}{}, &serverVersion); err != nil {
+			// We try to play nice with old pre 0.5.1 client, by
+			// gracefully trying old RPCServiceName, we do this only
+			// once, and keep the result for future calls.
+			log.Debugf(err.Error())
+			log.Debugf("Client (%s) with %s does not work, re-attempting with %s", ic.MachineName, RPCServiceNameV1, RPCServiceNameV0)
+			ic.switchToV0()
+			if err := ic.Call(GetVersionMethod, struct{}{}, &serverVersion); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if serverVersion < 1 || serverVersion > version.APIVersion {
+		return 0, fmt.Errorf("Driver binary uses an incompatible API version (%d)", serverVersion)
+	}
+
+	ic.protocolVersion = serverVersion
+
+	return serverVersion, nil
+}
+
 func NewInternalClient(rpcclient *rpc.Client) *InternalClient {
 	return &InternalClient{
 		RPCClient:      rpcclient,
@@ -107,128 +203,323 @@ func (f *DefaultRPCClientDriverFactory) Close() error {
 	f.openedDriversLock.Lock()
 	defer f.openedDriversLock.Unlock()
 
-	for _, openedDriver := range f.openedDrivers {
-		if err := openedDriver.close(); err != nil {
+	for _, process := range f.openedProcesses {
+		if err := process.close(); err != nil {
 			// No need to display an error.
 			// There's nothing we can do and it doesn't add value to the user.
 		}
 	}
-	f.openedDrivers = []*RPCClientDriver{}
+	f.openedProcesses = []*pluginProcess{}
+	f.sharedProcesses = map[string]*pluginProcess{}
 
 	return nil
 }
 
 func (f *DefaultRPCClientDriverFactory) NewRPCClientDriver(driverName string, rawDriver []byte) (*RPCClientDriver, error) {
-	mcnName := ""
+	machineName := configMachineName(rawDriver)
 
-	p, err := localbinary.NewPlugin(driverName)
+	client, process, err := f.openClient(driverName, machineName)
 	if err != nil {
 		return nil, err
 	}
 
-	go func() {
-		if err := p.Serve(); err != nil {
-			// TODO: Is this best approach?
-			log.Warn(err)
-			return
-		}
-	}()
-
-	addr, err := p.Address()
-	if err != nil {
-		return nil, fmt.Errorf("Error attempting to get plugin server address for RPC: %s", err)
+	c := &RPCClientDriver{
+		Client:  client,
+		process: process,
+		reopen: func() (*InternalClient, *pluginProcess, error) {
+			return f.openClient(driverName, machineName)
+		},
 	}
 
-	rpcclient, err := rpc.DialHTTP("tcp", addr)
-	if err != nil {
+	if err := c.SetConfigRaw(rawDriver); err != nil {
 		return nil, err
 	}
 
-	c := &RPCClientDriver{
-		Client:          NewInternalClient(rpcclient),
-		heartbeatDoneCh: make(chan bool),
+	mcnName := c.GetMachineName()
+	c.Client.MachineName = mcnName
+	if !process.shared {
+		process.setLogName(mcnName)
 	}
 
+	return c, nil
+}
+
+// openClient returns the client of the named machine, from the process
+// already serving the machines of the driver if there is one.
+func (f *DefaultRPCClientDriverFactory) openClient(driverName, machineName string) (*InternalClient, *pluginProcess, error) {
 	f.openedDriversLock.Lock()
-	f.openedDrivers = append(f.openedDrivers, c)
-	f.openedDriversLock.Unlock()
+	defer f.openedDriversLock.Unlock()
 
-	var serverVersion int
-	if err := c.Client.Call(GetVersionMethod, struct{}{}, &serverVersion); err != nil {
-		// this is the first call we make to the server. We try to play nice with old pre 0.5.1 client,
-		// by gracefully trying old RPCServiceName, we do this only once, and keep the result for future calls.
-		log.Debugf(err.Error())
-		log.Debugf("Client (%s) with %s does not work, re-attempting with %s", c.Client.MachineName, RPCServiceNameV1, RPCServiceNameV0)
-		c.Client.switchToV0()
-		if err := c.Client.Call(GetVersionMethod, struct{}{}, &serverVersion); err != nil {
-			return nil, err
+	if process, ok := f.sharedProcesses[driverName]; ok && !process.isClosed() {
+		client, err := process.openInstance(machineName)
+		if err != nil {
+			return nil, nil, err
 		}
+		return client, process, nil
 	}
 
-	if serverVersion != version.APIVersion {
-		return nil, fmt.Errorf("Driver binary uses an incompatible API version (%d)", serverVersion)
+	process, err := startPluginProcess(driverName)
+	if err != nil {
+		return nil, nil, err
 	}
-	log.Debug("Using API Version ", serverVersion)
+	f.openedProcesses = append(f.openedProcesses, process)
 
-	go func(c *RPCClientDriver) {
-		for {
-			select {
-			case <-c.heartbeatDoneCh:
-				return
-			case <-time.After(heartbeatInterval):
-				if err := c.Client.Call(HeartbeatMethod, struct{}{}, nil); err != nil {
-					log.Warnf("Wrapper Docker Machine process exiting due to closed plugin server (%s)", err)
-					if err := c.close(); err != nil {
-						log.Warn(err)
-					}
-				}
-			}
-		}
-	}(c)
+	// The plugins which don't serve several machines, e.g. the ones built
+	// before they could, are dedicated to this machine.
+	if process.client.protocolVersion < 2 {
+		return process.client, process, nil
+	}
 
-	if err := c.SetConfigRaw(rawDriver); err != nil {
-		return nil, err
+	client, err := process.openInstance(machineName)
+	if err != nil {
+		log.Debugf("(%s) Plugin serves a single machine: %s", driverName, err)
+		return process.client, process, nil
 	}
 
-	mcnName = c.GetMachineName()
-	p.MachineName = mcnName
-	c.Client.MachineName = mcnName
-	c.plugin = p
+	process.shared = true
+	process.setLogName(driverName)
+	f.sharedProcesses[driverName] = process
 
-	return c, nil
+	return client, process, nil
+}
+
+// configMachineName returns the name of the machine found in the
+// configuration of its driver, as set by drivers.BaseDriver.
+func configMachineName(rawDriver []byte) string {
+	var config struct {
+		MachineName string
+	}
+
+	if err := json.Unmarshal(rawDriver, &config); err != nil {
+		return ""
+	}
+
+	return config.MachineName
 }
 
 func (c *RPCClientDriver) MarshalJSON() ([]byte, error) {
-	return c.GetConfigRaw()
+	data, err := c.GetConfigRaw()
+	if err != nil {
+		c.configLock.Lock()
+		defer c.configLock.Unlock()
+
+		if c.aborted && c.config != nil {
+			// The plugin was shut down because an operation was
+			// cancelled, hand out the last configuration we got from
+			// it so that the host can still be saved.
+			return c.config, nil
+		}
+	}
+
+	return data, err
 }
 
 func (c *RPCClientDriver) UnmarshalJSON(data []byte) error {
 	return c.SetConfigRaw(data)
 }
 
-func (c *RPCClientDriver) close() error {
-	c.heartbeatDoneCh <- true
-	close(c.heartbeatDoneCh)
+// APIVersion returns the version of the protocol agreed on with the plugin.
+func (c *RPCClientDriver) APIVersion() int {
+	return c.Client.protocolVersion
+}
 
-	log.Debug("Making call to close driver server")
+// abort shuts the plugin server down after an operation was cancelled. The
+// driver cannot be trusted to stop on its own, so the plugin process is
+// closed, which stops whatever it was doing, including for the other
+// machines it serves. The configuration is fetched beforehand so that it can
+// still be persisted, since it may hold identifiers of resources the aborted
+// operation already allocated.
+func (c *RPCClientDriver) abort() {
+	ctx, cancel := context.WithTimeout(context.Background(), abortConfigTimeout)
+	defer cancel()
 
-	if err := c.Client.Call(CloseMethod, struct{}{}, nil); err != nil {
-		log.Debugf("Failed to make call to close driver server: %s", err)
+	var data []byte
+	if err := c.Client.CallContext(ctx, GetConfigRawMethod, struct{}{}, &data); err != nil {
+		// The machine might have been allocated already, without us
+		// knowing its identifiers anymore.
+		log.Warnf("(%s) Could not save the driver configuration before aborting, resources created so far may have to be removed by hand: %s", c.Client.MachineName, err)
 	} else {
-		log.Debug("Successfully made call to close driver server")
+		c.setConfig(data)
+	}
+
+	c.configLock.Lock()
+	c.aborted = true
+	c.configLock.Unlock()
+
+	if err := c.process.close(); err != nil {
+		log.Debugf("Error closing aborted plugin: %s", err)
 	}
+}
 
-	log.Debug("Making call to close connection to plugin binary")
+func (c *RPCClientDriver) setConfig(data []byte) {
+	c.configLock.Lock()
+	defer c.configLock.Unlock()
+
+	c.config = data
+}
+
+// refreshConfig gets the configuration of the driver after a call which may
+// have changed it, so that the plugin can be restarted with it.
+func (c *RPCClientDriver) refreshConfig() {
+	var data []byte
+	if err := c.Client.Call(GetConfigRawMethod, struct{}{}, &data); err != nil {
+		log.Debugf("(%s) Error getting the driver configuration: %s", c.Client.MachineName, err)
+		return
+	}
 
-	return c.plugin.Close()
+	c.setConfig(data)
+}
+
+// pluginDied tells whether the call failed because the plugin exited, or
+// stopped answering, rather than because it was aborted.
+func (c *RPCClientDriver) pluginDied(err error) bool {
+	if err != rpc.ErrShutdown && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false
+	}
+
+	c.configLock.Lock()
+	defer c.configLock.Unlock()
+
+	return !c.aborted
+}
+
+// restart launches the plugin again after the given process died, and
+// restores the last configuration of the driver known to the client. The
+// plugin is only restarted once when several calls noticed it died.
+func (c *RPCClientDriver) restart(died *pluginProcess) error {
+	c.restartLock.Lock()
+	defer c.restartLock.Unlock()
+
+	if c.process != died {
+		return nil
+	}
+
+	c.configLock.Lock()
+	config := c.config
+	c.configLock.Unlock()
+
+	if c.reopen == nil || config == nil {
+		return errors.New("The plugin can't be restarted")
+	}
+
+	log.Warnf("(%s) The driver plugin exited, restarting it", c.Client.MachineName)
+
+	if err := died.close(); err != nil {
+		log.Debugf("Error closing dead plugin: %s", err)
+	}
+
+	client, process, err := c.reopen()
+	if err != nil {
+		return err
+	}
+
+	client.MachineName = c.Client.MachineName
+	if err := client.Call(SetConfigRawMethod, config, nil); err != nil {
+		return err
+	}
+
+	if !process.shared {
+		process.setLogName(client.MachineName)
+	}
+
+	c.Client = client
+	c.process = process
+
+	return nil
+}
+
+// idempotentCall makes a call which can be made again without harm, e.g.
+// "GetState". It is made again in a new plugin when the plugin died.
+func (c *RPCClientDriver) idempotentCall(method string, args interface{}, reply interface{}) error {
+	process := c.process
+
+	err := c.Client.Call(method, args, reply)
+	if !c.pluginDied(err) {
+		return err
+	}
+
+	if restartErr := c.restart(process); restartErr != nil {
+		log.Debugf("(%s) Error restarting the plugin: %s", c.Client.MachineName, restartErr)
+		return err
+	}
+
+	return c.Client.Call(method, args, reply)
+}
+
+// rpcContextCall makes a call which takes no arguments and returns nothing,
+// e.g. "Create", aborting the plugin if ctx is done before it completes.
+func (c *RPCClientDriver) rpcContextCall(ctx context.Context, method string) error {
+	if c.Client.protocolVersion >= 2 {
+		err := c.runContext(ctx, method)
+		if ctx.Err() == nil {
+			c.refreshConfig()
+		}
+		return err
+	}
+
+	err := c.Client.CallContext(ctx, method, struct{}{}, nil)
+	if ctx.Err() != nil {
+		log.Debugf("(%s) Call to %s cancelled, shutting down plugin", c.Client.MachineName, method)
+		c.abort()
+		return ctx.Err()
+	}
+
+	c.refreshConfig()
+
+	return err
+}
+
+// runContext runs the operation through RunMethod, with version 2 of the
+// protocol. When ctx is done, the call is cancelled in the plugin, which is
+// only shut down if the driver doesn't stop in time. The progress of the
+// operation is reported to ctx.
+func (c *RPCClientDriver) runContext(ctx context.Context, method string) error {
+	id := c.process.nextCallID()
+
+	c.process.setProgressHandler(id, func(p drivers.Progress) {
+		log.Debugf("(%s) %s: %d/%d", c.Client.MachineName, p.Stage, p.Current, p.Total)
+		drivers.ReportProgress(ctx, p)
+	})
+	defer c.process.setProgressHandler(id, nil)
+
+	log.Debugf("(%s) Calling %+v", c.Client.MachineName, method)
+
+	reply := &RunReply{}
+	call := c.Client.RPCClient.Go(c.Client.rpcServiceName+RunMethod, &RunArgs{ID: id, Method: method}, reply, make(chan *rpc.Call, 1))
+
+	select {
+	case <-call.Done:
+		// The logs of the operation are printed before its outcome.
+		c.process.waitEvents(reply.LastEvent, eventsWaitTimeout)
+		return decodeError(call.Error)
+	case <-ctx.Done():
+	}
+
+	log.Debugf("(%s) Call to %s cancelled, cancelling it in the plugin", c.Client.MachineName, method)
+
+	cancelCtx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	defer cancel()
+
+	if err := c.Client.CallContext(cancelCtx, CancelMethod, id, nil); err == nil {
+		select {
+		case <-call.Done:
+			return ctx.Err()
+		case <-cancelCtx.Done():
+		}
+	}
+
+	log.Debugf("(%s) Call to %s didn't stop, shutting down plugin", c.Client.MachineName, method)
+	c.abort()
+
+	return ctx.Err()
 }
 
 // Helper method to make requests which take no arguments and return simply a
-// string, e.g. "GetIP".
+// string, e.g. "GetIP". They are all idempotent.
 func (c *RPCClientDriver) rpcStringCall(method string) (string, error) {
 	var info string
 
-	if err := c.Client.Call(method, struct{}{}, &info); err != nil {
+	if err := c.idempotentCall(method, struct{}{}, &info); err != nil {
 		return "", err
 	}
 
@@ -238,7 +529,7 @@ func (c *RPCClientDriver) rpcStringCall(method string) (string, error) {
 func (c *RPCClientDriver) GetCreateFlags() []mcnflag.Flag {
 	var flags []mcnflag.Flag
 
-	if err := c.Client.Call(GetCreateFlagsMethod, struct{}{}, &flags); err != nil {
+	if err := c.idempotentCall(GetCreateFlagsMethod, struct{}{}, &flags); err != nil {
 		log.Warnf("Error attempting call to get create flags: %s", err)
 	}
 
@@ -246,16 +537,24 @@ func (c *RPCClientDriver) GetCreateFlags() []mcnflag.Flag {
 }
 
 func (c *RPCClientDriver) SetConfigRaw(data []byte) error {
-	return c.Client.Call(SetConfigRawMethod, data, nil)
+	if err := c.Client.Call(SetConfigRawMethod, data, nil); err != nil {
+		return err
+	}
+
+	c.setConfig(data)
+
+	return nil
 }
 
 func (c *RPCClientDriver) GetConfigRaw() ([]byte, error) {
 	var data []byte
 
-	if err := c.Client.Call(GetConfigRawMethod, struct{}{}, &data); err != nil {
+	if err := c.idempotentCall(GetConfigRawMethod, struct{}{}, &data); err != nil {
 		return nil, err
 	}
 
+	c.setConfig(data)
+
 	return data, nil
 }
 
@@ -270,7 +569,13 @@ func (c *RPCClientDriver) DriverName() string {
 }
 
 func (c *RPCClientDriver) SetConfigFromFlags(flags drivers.DriverOptions) error {
-	return c.Client.Call(SetConfigFromFlagsMethod, &flags, nil)
+	if err := c.Client.Call(SetConfigFromFlagsMethod, &flags, nil); err != nil {
+		return err
+	}
+
+	c.refreshConfig()
+
+	return nil
 }
 
 func (c *RPCClientDriver) GetURL() (string, error) {
@@ -308,7 +613,7 @@ func (c *RPCClientDriver) GetSSHKeyPath() string {
 func (c *RPCClientDriver) GetSSHPort() (int, error) {
 	var port int
 
-	if err := c.Client.Call(GetSSHPortMethod, struct{}{}, &port); err != nil {
+	if err := c.idempotentCall(GetSSHPortMethod, struct{}{}, &port); err != nil {
 		return 0, err
 	}
 
@@ -327,7 +632,7 @@ func (c *RPCClientDriver) GetSSHUsername() string {
 func (c *RPCClientDriver) GetState() (state.State, error) {
 	var s state.State
 
-	if err := c.Client.Call(GetStateMethod, struct{}{}, &s); err != nil {
+	if err := c.idempotentCall(GetStateMethod, struct{}{}, &s); err != nil {
 		return state.Error, err
 	}
 
@@ -339,29 +644,145 @@ func (c *RPCClientDriver) PreCreateCheck() error {
 }
 
 func (c *RPCClientDriver) Create() error {
-	return c.Client.Call(CreateMethod, struct{}{}, nil)
+	return c.rpcContextCall(context.Background(), CreateMethod)
 }
 
 func (c *RPCClientDriver) Remove() error {
-	return c.Client.Call(RemoveMethod, struct{}{}, nil)
+	return c.rpcContextCall(context.Background(), RemoveMethod)
 }
 
 func (c *RPCClientDriver) Start() error {
-	return c.Client.Call(StartMethod, struct{}{}, nil)
+	return c.rpcContextCall(context.Background(), StartMethod)
 }
 
 func (c *RPCClientDriver) Stop() error {
-	return c.Client.Call(StopMethod, struct{}{}, nil)
+	return c.rpcContextCall(context.Background(), StopMethod)
 }
 
 func (c *RPCClientDriver) Restart() error {
-	return c.Client.Call(RestartMethod, struct{}{}, nil)
+	return c.rpcContextCall(context.Background(), RestartMethod)
 }
 
 func (c *RPCClientDriver) Kill() error {
-	return c.Client.Call(KillMethod, struct{}{}, nil)
+	return c.rpcContextCall(context.Background(), KillMethod)
+}
+
+// GetCapabilities returns the capabilities offered by the plugin. The
+// plugins which predate the capabilities offer none.
+func (c *RPCClientDriver) GetCapabilities() []drivers.Capability {
+	c.capabilitiesLock.Lock()
+	defer c.capabilitiesLock.Unlock()
+
+	if c.capabilities != nil {
+		return c.capabilities
+	}
+
+	var capabilities []drivers.Capability
+	if err := c.idempotentCall(GetCapabilitiesMethod, struct{}{}, &capabilities); err != nil {
+		log.Debugf("(%s) Plugin doesn't tell its capabilities: %s", c.Client.MachineName, err)
+		return []drivers.Capability{}
+	}
+
+	if capabilities == nil {
+		capabilities = []drivers.Capability{}
+	}
+	c.capabilities = capabilities
+
+	return capabilities
+}
+
+// capabilityCall makes a call to a method of the capability, failing with
+// drivers.ErrCapabilityNotSupported when the plugin doesn't offer it rather
+// than with the error of the RPC server.
+func (c *RPCClientDriver) capabilityCall(capability drivers.Capability, method string, args interface{}, reply interface{}) error {
+	if !drivers.HasCapability(c, capability) {
+		return drivers.ErrCapabilityNotSupported{
+			DriverName: c.DriverName(),
+			Capability: capability,
+		}
+	}
+
+	err := c.Client.Call(method, args, reply)
+	c.refreshConfig()
+
+	return err
 }
 
 func (c *RPCClientDriver) Upgrade() error {
-	return c.Client.Call(UpgradeMethod, struct{}{}, nil)
+	return c.capabilityCall(drivers.CapabilityUpgrade, UpgradeMethod, struct{}{}, nil)
+}
+
+func (c *RPCClientDriver) Pause() error {
+	return c.capabilityCall(drivers.CapabilityPause, PauseMethod, struct{}{}, nil)
+}
+
+func (c *RPCClientDriver) Unpause() error {
+	return c.capabilityCall(drivers.CapabilityPause, UnpauseMethod, struct{}{}, nil)
+}
+
+func (c *RPCClientDriver) CreateSnapshot(name string) error {
+	return c.capabilityCall(drivers.CapabilitySnapshot, CreateSnapshotMethod, name, nil)
+}
+
+func (c *RPCClientDriver) ListSnapshots() ([]drivers.Snapshot, error) {
+	if !drivers.HasCapability(c, drivers.CapabilitySnapshot) {
+		return nil, drivers.ErrCapabilityNotSupported{
+			DriverName: c.DriverName(),
+			Capability: drivers.CapabilitySnapshot,
+		}
+	}
+
+	var snapshots []drivers.Snapshot
+	if err := c.idempotentCall(ListSnapshotsMethod, struct{}{}, &snapshots); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
+
+func (c *RPCClientDriver) RestoreSnapshot(name string) error {
+	return c.capabilityCall(drivers.CapabilitySnapshot, RestoreSnapshotMethod, name, nil)
+}
+
+func (c *RPCClientDriver) RemoveSnapshot(name string) error {
+	return c.capabilityCall(drivers.CapabilitySnapshot, RemoveSnapshotMethod, name, nil)
+}
+
+func (c *RPCClientDriver) Resize(opts drivers.ResizeOptions) error {
+	return c.capabilityCall(drivers.CapabilityResize, ResizeMethod, &opts, nil)
+}
+
+func (c *RPCClientDriver) ConsoleLog() (string, error) {
+	if !drivers.HasCapability(c, drivers.CapabilityConsoleLog) {
+		return "", drivers.ErrCapabilityNotSupported{
+			DriverName: c.DriverName(),
+			Capability: drivers.CapabilityConsoleLog,
+		}
+	}
+
+	return c.rpcStringCall(ConsoleLogMethod)
+}
+
+func (c *RPCClientDriver) CreateContext(ctx context.Context) error {
+	return c.rpcContextCall(ctx, CreateMethod)
+}
+
+func (c *RPCClientDriver) RemoveContext(ctx context.Context) error {
+	return c.rpcContextCall(ctx, RemoveMethod)
+}
+
+func (c *RPCClientDriver) StartContext(ctx context.Context) error {
+	return c.rpcContextCall(ctx, StartMethod)
+}
+
+func (c *RPCClientDriver) StopContext(ctx context.Context) error {
+	return c.rpcContextCall(ctx, StopMethod)
+}
+
+func (c *RPCClientDriver) RestartContext(ctx context.Context) error {
+	return c.rpcContextCall(ctx, RestartMethod)
+}
+
+func (c *RPCClientDriver) KillContext(ctx context.Context) error {
+	return c.rpcContextCall(ctx, KillMethod)
 }
@@ -0,0 +1,195 @@
+package rpcdriver
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/stretchr/testify/assert"
+)
+
+type blockingServer struct {
+	releaseCh chan struct{}
+}
+
+func (b *blockingServer) Create(_, _ *struct{}) error {
+	<-b.releaseCh
+	return nil
+}
+
+func newTestInternalClient(t *testing.T, rcvr interface{}) *InternalClient {
+	server := rpc.NewServer()
+	if err := server.RegisterName(RPCServiceNameV1, rcvr); err != nil {
+		t.Fatal(err)
+	}
+
+	clientConn, serverConn := net.Pipe()
+	go server.ServeConn(serverConn)
+
+	return NewInternalClient(rpc.NewClient(clientConn))
+}
+
+func TestInternalClientCallContextCancelled(t *testing.T) {
+	server := &blockingServer{releaseCh: make(chan struct{})}
+	defer close(server.releaseCh)
+
+	client := newTestInternalClient(t, server)
+	defer client.RPCClient.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error)
+	go func() {
+		errCh <- client.CallContext(ctx, CreateMethod, struct{}{}, nil)
+	}()
+
+	cancel()
+
+	assert.Equal(t, context.Canceled, <-errCh)
+}
+
+func TestInternalClientCallContext(t *testing.T) {
+	server := &blockingServer{releaseCh: make(chan struct{})}
+	close(server.releaseCh)
+
+	client := newTestInternalClient(t, server)
+	defer client.RPCClient.Close()
+
+	assert.NoError(t, client.CallContext(context.Background(), CreateMethod, struct{}{}, nil))
+}
+
+// restartWithFakeDriver makes the plugin of the client driver restart with a
+// new fake driver, returned by the function.
+func restartWithFakeDriver(t *testing.T, c *RPCClientDriver) func() *fakedriver.Driver {
+	var restarted *fakedriver.Driver
+	c.reopen = func() (*InternalClient, *pluginProcess, error) {
+		restarted = &fakedriver.Driver{}
+		process, _ := newTestProcess(t, NewRPCServerDriver(restarted))
+		return process.client, process, nil
+	}
+
+	return func() *fakedriver.Driver { return restarted }
+}
+
+func TestIdempotentCallsRestartTheDeadPlugin(t *testing.T) {
+	c := newTestClientDriver(t, NewRPCServerDriver(&fakedriver.Driver{}))
+	defer closeTestClientDriver(c)
+	restarted := restartWithFakeDriver(t, c)
+
+	assert.NoError(t, c.SetConfigRaw([]byte(`{"MockName": "foo", "MockState": 1}`)))
+
+	died := c.process
+	died.rpcClient.Close()
+
+	s, err := c.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Running, s)
+	assert.NotEqual(t, died, c.process)
+	assert.Equal(t, "foo", restarted().MockName)
+}
+
+func TestOperationsDontRestartTheDeadPlugin(t *testing.T) {
+	c := newTestClientDriver(t, NewRPCServerDriver(&fakedriver.Driver{}))
+	defer closeTestClientDriver(c)
+	restarted := restartWithFakeDriver(t, c)
+
+	assert.NoError(t, c.SetConfigRaw([]byte(`{"MockName": "foo"}`)))
+
+	died := c.process
+	died.rpcClient.Close()
+
+	assert.Equal(t, rpc.ErrShutdown, c.Start())
+	assert.Equal(t, died, c.process)
+	assert.Nil(t, restarted())
+}
+
+func TestAbortedPluginIsntRestarted(t *testing.T) {
+	server := NewRPCServerDriver(&fakedriver.Driver{})
+	go func() { <-server.CloseCh }()
+
+	c := newTestClientDriver(t, server)
+	restarted := restartWithFakeDriver(t, c)
+
+	assert.NoError(t, c.SetConfigRaw([]byte(`{"MockName": "foo"}`)))
+
+	c.abort()
+	c.process.rpcClient.Close()
+
+	_, err := c.GetState()
+	assert.Equal(t, rpc.ErrShutdown, err)
+	assert.Nil(t, restarted())
+
+	data, err := c.MarshalJSON()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"MockName": "foo", "MockState": 0, "MockIP": ""}`, string(data))
+}
+
+// pausingDriver pauses the machine and keeps snapshots of it.
+type pausingDriver struct {
+	*fakedriver.Driver
+	Snapshots []drivers.Snapshot
+}
+
+func (d *pausingDriver) Pause() error {
+	d.MockState = state.Paused
+	return nil
+}
+
+func (d *pausingDriver) Unpause() error {
+	d.MockState = state.Running
+	return nil
+}
+
+func (d *pausingDriver) CreateSnapshot(name string) error {
+	d.Snapshots = append(d.Snapshots, drivers.Snapshot{Name: name})
+	return nil
+}
+
+func (d *pausingDriver) ListSnapshots() ([]drivers.Snapshot, error) {
+	return d.Snapshots, nil
+}
+
+func (d *pausingDriver) RestoreSnapshot(name string) error {
+	return nil
+}
+
+func (d *pausingDriver) RemoveSnapshot(name string) error {
+	d.Snapshots = nil
+	return nil
+}
+
+func TestCapabilitiesOverRPC(t *testing.T) {
+	c := newTestClientDriver(t, NewRPCServerDriver(&pausingDriver{Driver: &fakedriver.Driver{MockState: state.Running}}))
+	defer closeTestClientDriver(c)
+
+	assert.Equal(t, []drivers.Capability{drivers.CapabilityPause, drivers.CapabilitySnapshot, drivers.CapabilityUpgrade}, drivers.GetCapabilities(c))
+
+	assert.NoError(t, drivers.Pause(c))
+	s, err := c.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Paused, s)
+
+	assert.NoError(t, drivers.CreateSnapshot(c, "clean"))
+	snapshots, err := drivers.ListSnapshots(c)
+	assert.NoError(t, err)
+	assert.Equal(t, []drivers.Snapshot{{Name: "clean"}}, snapshots)
+
+	err = drivers.Resize(c, drivers.ResizeOptions{CPUs: 4})
+	assert.EqualError(t, err, `The "Driver" driver doesn't support resize`)
+	assert.Equal(t, mcnerror.KindNotSupported, mcnerror.Kind(err))
+
+	_, err = c.ConsoleLog()
+	assert.Equal(t, drivers.ErrCapabilityNotSupported{DriverName: "Driver", Capability: drivers.CapabilityConsoleLog}, err)
+}
+
+func TestOlderPluginsOfferNoCapability(t *testing.T) {
+	c := newTestClientDriver(t, &v1Server{})
+	defer closeTestClientDriver(c)
+
+	assert.Equal(t, []drivers.Capability{}, drivers.GetCapabilities(c))
+	assert.False(t, drivers.HasCapability(c, drivers.CapabilityUpgrade))
+}
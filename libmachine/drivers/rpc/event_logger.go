@@ -0,0 +1,95 @@
+package rpcdriver
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// eventLogger sends the messages logged in a plugin to the client as
+// events, once they agreed on version 2 of the protocol. The older clients
+// scrape them from the output of the plugin.
+type eventLogger struct {
+	server   *RPCServerDriver
+	fallback log.MachineLogger
+}
+
+func (l *eventLogger) send(level, message string) bool {
+	if l.server.version() < 2 {
+		return false
+	}
+
+	l.server.eventQueue().push(Event{
+		Type:    EventLog,
+		Level:   level,
+		Message: strings.TrimSuffix(message, "\n"),
+	})
+
+	return true
+}
+
+func (l *eventLogger) SetDebug(debug bool) {
+	l.fallback.SetDebug(debug)
+}
+
+func (l *eventLogger) SetOutWriter(out io.Writer) {
+	l.fallback.SetOutWriter(out)
+}
+
+func (l *eventLogger) SetErrWriter(err io.Writer) {
+	l.fallback.SetErrWriter(err)
+}
+
+func (l *eventLogger) Debug(args ...interface{}) {
+	if !l.send(LevelDebug, fmt.Sprintln(args...)) {
+		l.fallback.Debug(args...)
+	}
+}
+
+func (l *eventLogger) Debugf(fmtString string, args ...interface{}) {
+	if !l.send(LevelDebug, fmt.Sprintf(fmtString, args...)) {
+		l.fallback.Debugf(fmtString, args...)
+	}
+}
+
+func (l *eventLogger) Error(args ...interface{}) {
+	if !l.send(LevelError, fmt.Sprintln(args...)) {
+		l.fallback.Error(args...)
+	}
+}
+
+func (l *eventLogger) Errorf(fmtString string, args ...interface{}) {
+	if !l.send(LevelError, fmt.Sprintf(fmtString, args...)) {
+		l.fallback.Errorf(fmtString, args...)
+	}
+}
+
+func (l *eventLogger) Info(args ...interface{}) {
+	if !l.send(LevelInfo, fmt.Sprintln(args...)) {
+		l.fallback.Info(args...)
+	}
+}
+
+func (l *eventLogger) Infof(fmtString string, args ...interface{}) {
+	if !l.send(LevelInfo, fmt.Sprintf(fmtString, args...)) {
+		l.fallback.Infof(fmtString, args...)
+	}
+}
+
+func (l *eventLogger) Warn(args ...interface{}) {
+	if !l.send(LevelWarn, fmt.Sprintln(args...)) {
+		l.fallback.Warn(args...)
+	}
+}
+
+func (l *eventLogger) Warnf(fmtString string, args ...interface{}) {
+	if !l.send(LevelWarn, fmt.Sprintf(fmtString, args...)) {
+		l.fallback.Warnf(fmtString, args...)
+	}
+}
+
+func (l *eventLogger) History() []string {
+	return l.fallback.History()
+}
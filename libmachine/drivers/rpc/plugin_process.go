@@ -0,0 +1,287 @@
+package rpcdriver
+
+import (
+	"fmt"
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/drivers/plugin/localbinary"
+	"github.com/docker/machine/libmachine/log"
+)
+
+// OpenInstanceMethod asks a plugin for the driver of a machine, when it
+// serves several of them.
+const OpenInstanceMethod = `.OpenInstance`
+
+// pluginProcess is a plugin launched by the client. It serves one machine,
+// or all the machines of its driver when it is shared.
+type pluginProcess struct {
+	plugin    localbinary.DriverPlugin
+	rpcClient *rpc.Client
+
+	// client calls the plugin itself, rather than the driver of one of the
+	// machines it serves.
+	client *InternalClient
+	shared bool
+
+	heartbeatDoneCh chan bool
+	closeOnce       sync.Once
+	closeErr        error
+
+	// The state of the calls made through RunMethod since version 2 of
+	// the protocol, and of the events they sent.
+	lastCallID       uint64
+	eventsLock       sync.Mutex
+	logName          string
+	lastEvent        uint64
+	eventsChanged    chan struct{}
+	progressHandlers map[uint64]func(drivers.Progress)
+}
+
+// startPluginProcess launches the plugin of the driver and agrees with it on
+// the version of the protocol.
+func startPluginProcess(driverName string) (*pluginProcess, error) {
+	p, err := localbinary.NewPlugin(driverName)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		if err := p.Serve(); err != nil {
+			// TODO: Is this best approach?
+			log.Warn(err)
+			return
+		}
+	}()
+
+	addr, err := p.Address()
+	if err != nil {
+		return nil, fmt.Errorf("Error attempting to get plugin server address for RPC: %s", err)
+	}
+
+	rpcclient, err := dialPlugin(addr, p.Token)
+	if err != nil {
+		return nil, err
+	}
+
+	process := newPluginProcess(p, rpcclient, driverName)
+
+	// This is the first call we make to the server.
+	serverVersion, err := process.client.NegotiateVersion()
+	if err != nil {
+		process.close()
+		return nil, err
+	}
+	log.Debug("Using API Version ", serverVersion)
+
+	process.start()
+
+	return process, nil
+}
+
+func newPluginProcess(p localbinary.DriverPlugin, rpcClient *rpc.Client, driverName string) *pluginProcess {
+	client := NewInternalClient(rpcClient)
+	client.MachineName = driverName
+
+	return &pluginProcess{
+		plugin:          p,
+		rpcClient:       rpcClient,
+		client:          client,
+		heartbeatDoneCh: make(chan bool),
+		logName:         driverName,
+	}
+}
+
+// start keeps the plugin alive, and polls its events once it agreed on
+// version 2 of the protocol.
+func (p *pluginProcess) start() {
+	go func() {
+		for {
+			select {
+			case <-p.heartbeatDoneCh:
+				return
+			case <-time.After(heartbeatInterval):
+				if err := p.client.Call(HeartbeatMethod, struct{}{}, nil); err != nil {
+					// The idempotent calls of the drivers restart it.
+					log.Debugf("(%s) Plugin server closed: %s", p.client.MachineName, err)
+					if err := p.close(); err != nil {
+						log.Warn(err)
+					}
+				}
+			}
+		}
+	}()
+
+	if p.client.protocolVersion >= 2 {
+		go p.pollEvents()
+	}
+}
+
+// openInstance returns a client of the driver of the named machine, when
+// the plugin serves several machines.
+func (p *pluginProcess) openInstance(machineName string) (*InternalClient, error) {
+	var serviceName string
+	if err := p.client.Call(OpenInstanceMethod, machineName, &serviceName); err != nil {
+		return nil, err
+	}
+
+	client := NewInternalClient(p.rpcClient)
+	client.rpcServiceName = serviceName
+	client.protocolVersion = p.client.protocolVersion
+	client.MachineName = machineName
+
+	return client, nil
+}
+
+// setLogName sets the name the messages of the plugin are prefixed with:
+// the name of the machine it serves, or of its driver when it is shared.
+func (p *pluginProcess) setLogName(name string) {
+	p.eventsLock.Lock()
+	p.logName = name
+	p.eventsLock.Unlock()
+
+	if lbp, ok := p.plugin.(*localbinary.Plugin); ok {
+		lbp.MachineName = name
+	}
+}
+
+func (p *pluginProcess) close() error {
+	p.closeOnce.Do(func() {
+		close(p.heartbeatDoneCh)
+
+		log.Debug("Making call to close driver server")
+
+		if err := p.client.Call(CloseMethod, struct{}{}, nil); err != nil {
+			log.Debugf("Failed to make call to close driver server: %s", err)
+		} else {
+			log.Debug("Successfully made call to close driver server")
+		}
+
+		log.Debug("Making call to close connection to plugin binary")
+
+		if p.plugin != nil {
+			p.closeErr = p.plugin.Close()
+		}
+	})
+
+	return p.closeErr
+}
+
+func (p *pluginProcess) isClosed() bool {
+	select {
+	case <-p.heartbeatDoneCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// nextCallID returns the ID of a call made through RunMethod, unique among
+// the machines served by the plugin.
+func (p *pluginProcess) nextCallID() uint64 {
+	return atomic.AddUint64(&p.lastCallID, 1)
+}
+
+func (p *pluginProcess) setProgressHandler(id uint64, fn func(drivers.Progress)) {
+	p.eventsLock.Lock()
+	defer p.eventsLock.Unlock()
+
+	if p.progressHandlers == nil {
+		p.progressHandlers = map[uint64]func(drivers.Progress){}
+	}
+
+	if fn == nil {
+		delete(p.progressHandlers, id)
+	} else {
+		p.progressHandlers[id] = fn
+	}
+}
+
+// pollEvents handles the events sent by a version 2 plugin until it is
+// closed.
+func (p *pluginProcess) pollEvents() {
+	for {
+		p.eventsLock.Lock()
+		after := p.lastEvent
+		p.eventsLock.Unlock()
+
+		var events []Event
+		if err := p.client.Call(EventsMethod, &EventsArgs{After: after}, &events); err != nil {
+			if !p.isClosed() {
+				log.Debugf("(%s) Stopped polling the plugin events: %s", p.client.MachineName, err)
+			}
+			return
+		}
+
+		for _, e := range events {
+			p.handleEvent(e)
+		}
+
+		if len(events) > 0 {
+			p.eventsLock.Lock()
+			p.lastEvent = events[len(events)-1].Seq
+			if p.eventsChanged != nil {
+				close(p.eventsChanged)
+				p.eventsChanged = nil
+			}
+			p.eventsLock.Unlock()
+		}
+
+		if p.isClosed() {
+			return
+		}
+	}
+}
+
+func (p *pluginProcess) handleEvent(e Event) {
+	p.eventsLock.Lock()
+	logName := p.logName
+	fn := p.progressHandlers[e.CallID]
+	p.eventsLock.Unlock()
+
+	switch e.Type {
+	case EventLog:
+		switch e.Level {
+		case LevelDebug:
+			log.Debugf(pluginErr, logName, e.Message)
+		case LevelWarn:
+			log.Warnf(pluginOut, logName, e.Message)
+		case LevelError:
+			log.Errorf(pluginOut, logName, e.Message)
+		default:
+			log.Infof(pluginOut, logName, e.Message)
+		}
+	case EventProgress:
+		if fn != nil {
+			fn(e.Progress)
+		}
+	}
+}
+
+// waitEvents waits until the events up to seq are handled, or timeout.
+func (p *pluginProcess) waitEvents(seq uint64, timeout time.Duration) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		p.eventsLock.Lock()
+		if p.lastEvent >= seq {
+			p.eventsLock.Unlock()
+			return
+		}
+		if p.eventsChanged == nil {
+			p.eventsChanged = make(chan struct{})
+		}
+		changed := p.eventsChanged
+		p.eventsLock.Unlock()
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return
+		}
+	}
+}
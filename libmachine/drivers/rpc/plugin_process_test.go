@@ -0,0 +1,52 @@
+package rpcdriver
+
+import (
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPluginServesSeveralMachines(t *testing.T) {
+	server := NewRPCServerDriver(&fakedriver.Driver{})
+	process, rpcServer := newTestProcess(t, server)
+	defer closeTestProcess(process)
+
+	server.ServeInstances(rpcServer, func() drivers.Driver {
+		return &fakedriver.Driver{}
+	})
+
+	foo, err := process.openInstance("foo")
+	assert.NoError(t, err)
+	bar, err := process.openInstance("bar")
+	assert.NoError(t, err)
+	assert.NotEqual(t, foo.rpcServiceName, bar.rpcServiceName)
+	assert.Equal(t, process.client.protocolVersion, foo.protocolVersion)
+
+	fooDriver := &RPCClientDriver{Client: foo, process: process}
+	barDriver := &RPCClientDriver{Client: bar, process: process}
+	assert.NoError(t, fooDriver.SetConfigRaw([]byte(`{"MockName": "foo"}`)))
+	assert.NoError(t, barDriver.SetConfigRaw([]byte(`{"MockName": "bar"}`)))
+
+	assert.Equal(t, "foo", fooDriver.GetMachineName())
+	assert.Equal(t, "bar", barDriver.GetMachineName())
+
+	again, err := process.openInstance("foo")
+	assert.NoError(t, err)
+	assert.Equal(t, foo.rpcServiceName, again.rpcServiceName)
+}
+
+func TestPluginServesSingleMachine(t *testing.T) {
+	process, _ := newTestProcess(t, NewRPCServerDriver(&fakedriver.Driver{}))
+	defer closeTestProcess(process)
+
+	_, err := process.openInstance("foo")
+	assert.EqualError(t, err, "The plugin serves a single machine")
+}
+
+func TestConfigMachineName(t *testing.T) {
+	assert.Equal(t, "foo", configMachineName([]byte(`{"MachineName": "foo", "SSHPort": 22}`)))
+	assert.Equal(t, "", configMachineName([]byte(`{}`)))
+	assert.Equal(t, "", configMachineName([]byte(`"raw"`)))
+}
@@ -0,0 +1,225 @@
+package rpcdriver
+
+import (
+	"context"
+	"encoding/json"
+	"net/rpc"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+// Version 2 of the protocol adds to version 1:
+//
+//   - the long running operations, e.g. Create, run through Run with an ID
+//     and can be cancelled with Cancel without shutting the plugin down,
+//   - the logs of the driver and the progress of its operations are sent to
+//     the client as events, which it polls with Events, rather than scraped
+//     from the output of the plugin,
+//   - the errors keep their mcnerror.ErrorKind.
+//
+// The client and the plugin agree on the version with NegotiateVersion. The
+// plugins which don't know it speak version 1, or version 0 if they don't
+// even answer to RPCServiceNameV1.
+//
+// Whatever the version, the plugins tell the optional capabilities of their
+// driver, e.g. drivers.CapabilityPause, with GetCapabilities. The plugins
+// which don't know it offer none.
+const (
+	NegotiateVersionMethod = `.NegotiateVersion`
+	RunMethod              = `.Run`
+	CancelMethod           = `.Cancel`
+	EventsMethod           = `.Events`
+)
+
+const (
+	// EventLog is a message logged by the driver.
+	EventLog = "log"
+
+	// EventProgress is the progress of an operation run through Run.
+	EventProgress = "progress"
+)
+
+// The levels of the log events.
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+const (
+	// maxEvents is how many events the plugins keep for the client to
+	// poll, the older ones are dropped.
+	maxEvents = 1000
+
+	// errorPrefix starts the errors of a version 2 plugin, which carry
+	// their kind.
+	errorPrefix = "machine-driver-error:"
+)
+
+var (
+	// eventsPollTimeout is how long Events waits for new events before
+	// returning none.
+	eventsPollTimeout = time.Second
+)
+
+// RunArgs are the arguments of Run.
+type RunArgs struct {
+	// ID identifies the call for Cancel and in the progress events. It is
+	// chosen by the client.
+	ID uint64
+
+	// Method is the operation to run, e.g. CreateMethod.
+	Method string
+}
+
+// RunReply is the reply of Run.
+type RunReply struct {
+	// LastEvent is the sequence number of the last event sent before the
+	// operation completed, so that the client can wait for them.
+	LastEvent uint64
+}
+
+// EventsArgs are the arguments of Events.
+type EventsArgs struct {
+	// After is the sequence number of the last event the client got.
+	After uint64
+}
+
+// Event is sent by a version 2 plugin to the client.
+type Event struct {
+	Seq  uint64
+	Type string
+
+	// CallID is the ID of the call the event belongs to, 0 for the logs.
+	CallID uint64
+
+	Level    string `json:",omitempty"`
+	Message  string `json:",omitempty"`
+	Progress drivers.Progress
+}
+
+// wireError is how the errors of the version 2 plugins go over the wire.
+type wireError struct {
+	Kind    mcnerror.ErrorKind
+	Message string
+}
+
+// encodeError returns the error as sent by a version 2 plugin, keeping its
+// kind.
+func encodeError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	kind := mcnerror.Kind(err)
+	switch {
+	case kind != mcnerror.KindUnknown:
+	case err == context.Canceled || err == context.DeadlineExceeded:
+		kind = mcnerror.KindCancelled
+	default:
+		if _, ok := err.(drivers.NotSupported); ok {
+			kind = mcnerror.KindNotSupported
+		}
+	}
+
+	data, jsonErr := json.Marshal(wireError{Kind: kind, Message: err.Error()})
+	if jsonErr != nil {
+		return err
+	}
+
+	return rpc.ServerError(errorPrefix + string(data))
+}
+
+// decodeError returns the error sent by a version 2 plugin as an
+// mcnerror.ErrDriver, the other errors are left as they are.
+func decodeError(err error) error {
+	serverErr, ok := err.(rpc.ServerError)
+	if !ok || !strings.HasPrefix(string(serverErr), errorPrefix) {
+		return err
+	}
+
+	var decoded wireError
+	if json.Unmarshal([]byte(strings.TrimPrefix(string(serverErr), errorPrefix)), &decoded) != nil {
+		return err
+	}
+
+	return mcnerror.ErrDriver{
+		Kind:    decoded.Kind,
+		Message: decoded.Message,
+	}
+}
+
+// eventQueue keeps the last events of a plugin until the client polls them.
+type eventQueue struct {
+	lock    sync.Mutex
+	seq     uint64
+	events  []Event
+	changed chan struct{}
+}
+
+func newEventQueue() *eventQueue {
+	return &eventQueue{
+		changed: make(chan struct{}),
+	}
+}
+
+// push adds the event to the queue and returns its sequence number.
+func (q *eventQueue) push(e Event) uint64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.seq++
+	e.Seq = q.seq
+
+	q.events = append(q.events, e)
+	if len(q.events) > maxEvents {
+		q.events = q.events[len(q.events)-maxEvents:]
+	}
+
+	close(q.changed)
+	q.changed = make(chan struct{})
+
+	return e.Seq
+}
+
+// last returns the sequence number of the last event pushed.
+func (q *eventQueue) last() uint64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.seq
+}
+
+// wait returns the events after the given sequence number, waiting for some
+// until timeout.
+func (q *eventQueue) wait(after uint64, timeout time.Duration) []Event {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		q.lock.Lock()
+		events := []Event{}
+		for _, e := range q.events {
+			if e.Seq > after {
+				events = append(events, e)
+			}
+		}
+		changed := q.changed
+		q.lock.Unlock()
+
+		if len(events) > 0 {
+			return events
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return events
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package rpcdriver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/version"
+	"github.com/stretchr/testify/assert"
+)
+
+// contextDriver starts the machine until it is cancelled, reporting its
+// progress.
+type contextDriver struct {
+	*fakedriver.Driver
+	startedCh chan struct{}
+	startErr  error
+}
+
+func (d *contextDriver) run(ctx context.Context) error {
+	drivers.ReportProgress(ctx, drivers.Progress{Stage: "Booting", Current: 1, Total: 2})
+
+	if d.startedCh == nil {
+		return d.startErr
+	}
+
+	close(d.startedCh)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (d *contextDriver) CreateContext(ctx context.Context) error  { return d.run(ctx) }
+func (d *contextDriver) KillContext(ctx context.Context) error    { return d.run(ctx) }
+func (d *contextDriver) RemoveContext(ctx context.Context) error  { return d.run(ctx) }
+func (d *contextDriver) RestartContext(ctx context.Context) error { return d.run(ctx) }
+func (d *contextDriver) StartContext(ctx context.Context) error   { return d.run(ctx) }
+func (d *contextDriver) StopContext(ctx context.Context) error    { return d.run(ctx) }
+
+func (d *contextDriver) GetState() (state.State, error) {
+	return state.Error, mcnerror.ErrDriver{Kind: mcnerror.KindNotFound, Message: "Instance not found"}
+}
+
+type v1Server struct{}
+
+func (s *v1Server) GetVersion(_ *struct{}, reply *int) error {
+	*reply = 1
+	return nil
+}
+
+func (s *v1Server) GetState(_ *struct{}, reply *state.State) error {
+	return errors.New("Instance not found")
+}
+
+func newTestProcess(t *testing.T, rcvr interface{}) (*pluginProcess, *rpc.Server) {
+	server := rpc.NewServer()
+	if err := server.RegisterName(RPCServiceNameV1, rcvr); err != nil {
+		t.Fatal(err)
+	}
+
+	clientConn, serverConn := net.Pipe()
+	go server.ServeConn(serverConn)
+
+	process := newPluginProcess(nil, rpc.NewClient(clientConn), "test")
+
+	serverVersion, err := process.client.NegotiateVersion()
+	assert.NoError(t, err)
+	if serverVersion >= 2 {
+		go process.pollEvents()
+	}
+
+	return process, server
+}
+
+func newTestClientDriver(t *testing.T, rcvr interface{}) *RPCClientDriver {
+	process, _ := newTestProcess(t, rcvr)
+
+	return &RPCClientDriver{
+		Client:  process.client,
+		process: process,
+	}
+}
+
+// closeTestProcess closes the connection to the plugin, leaving it running
+// since there is no plugin process to close.
+func closeTestProcess(p *pluginProcess) {
+	close(p.heartbeatDoneCh)
+	p.rpcClient.Close()
+}
+
+func closeTestClientDriver(c *RPCClientDriver) {
+	closeTestProcess(c.process)
+}
+
+func TestNegotiateVersion(t *testing.T) {
+	server := NewRPCServerDriver(&fakedriver.Driver{})
+
+	var serverVersion int
+	assert.NoError(t, server.GetVersion(nil, &serverVersion))
+	assert.Equal(t, 1, serverVersion)
+
+	c := newTestClientDriver(t, server)
+	defer closeTestClientDriver(c)
+
+	assert.Equal(t, version.APIVersion, c.Client.protocolVersion)
+
+	assert.NoError(t, server.GetVersion(nil, &serverVersion))
+	assert.Equal(t, version.APIVersion, serverVersion)
+
+	olderClient := 1
+	assert.NoError(t, server.NegotiateVersion(&olderClient, &serverVersion))
+	assert.Equal(t, 1, serverVersion)
+}
+
+func TestNegotiateVersionWithV1Plugin(t *testing.T) {
+	c := newTestClientDriver(t, &v1Server{})
+	defer closeTestClientDriver(c)
+
+	assert.Equal(t, 1, c.Client.protocolVersion)
+
+	_, err := c.GetState()
+	assert.EqualError(t, err, "Instance not found")
+	assert.Equal(t, mcnerror.KindUnknown, mcnerror.Kind(err))
+}
+
+func TestErrorsKeepTheirKind(t *testing.T) {
+	c := newTestClientDriver(t, NewRPCServerDriver(&contextDriver{
+		Driver:   &fakedriver.Driver{},
+		startErr: context.DeadlineExceeded,
+	}))
+	defer closeTestClientDriver(c)
+
+	_, err := c.GetState()
+	assert.Equal(t, mcnerror.ErrDriver{Kind: mcnerror.KindNotFound, Message: "Instance not found"}, err)
+
+	err = c.Start()
+	assert.Equal(t, mcnerror.KindCancelled, mcnerror.Kind(err))
+}
+
+func TestRunReportsProgress(t *testing.T) {
+	c := newTestClientDriver(t, NewRPCServerDriver(&contextDriver{Driver: &fakedriver.Driver{}}))
+	defer closeTestClientDriver(c)
+
+	var (
+		lock     sync.Mutex
+		progress []drivers.Progress
+	)
+	ctx := drivers.WithProgress(context.Background(), func(p drivers.Progress) {
+		lock.Lock()
+		defer lock.Unlock()
+		progress = append(progress, p)
+	})
+
+	assert.NoError(t, c.StartContext(ctx))
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, []drivers.Progress{{Stage: "Booting", Current: 1, Total: 2}}, progress)
+}
+
+func TestRunCancelledWithoutShuttingThePluginDown(t *testing.T) {
+	driver := &contextDriver{
+		Driver:    &fakedriver.Driver{},
+		startedCh: make(chan struct{}),
+	}
+	c := newTestClientDriver(t, NewRPCServerDriver(driver))
+	defer closeTestClientDriver(c)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-driver.startedCh
+		cancel()
+	}()
+
+	// The plugin of the test driver would panic if it was shut down.
+	assert.Equal(t, context.Canceled, c.CreateContext(ctx))
+
+	_, err := c.GetState()
+	assert.Equal(t, mcnerror.KindNotFound, mcnerror.Kind(err))
+}
+
+func TestEventLogger(t *testing.T) {
+	server := NewRPCServerDriver(&fakedriver.Driver{})
+	fallback := log.NewFmtMachineLogger()
+	logger := server.Logger(fallback)
+
+	logger.Infof("Before negotiation")
+	assert.Equal(t, []string{"Before negotiation"}, fallback.History())
+
+	clientVersion := 2
+	var serverVersion int
+	assert.NoError(t, server.NegotiateVersion(&clientVersion, &serverVersion))
+
+	logger.Infof("Creating %s", "VM")
+	logger.Debug("Using", "SSH")
+
+	assert.Equal(t, []string{"Before negotiation"}, fallback.History())
+	assert.Equal(t, []Event{
+		{Seq: 1, Type: EventLog, Level: LevelInfo, Message: "Creating VM"},
+		{Seq: 2, Type: EventLog, Level: LevelDebug, Message: "Using SSH"},
+	}, server.eventQueue().wait(0, 0))
+}
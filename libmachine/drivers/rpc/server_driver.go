@@ -1,10 +1,14 @@
 package rpcdriver
 
 import (
+	"context"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/rpc"
 	"runtime/debug"
+	"sync"
 
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
@@ -84,6 +88,29 @@ type RPCServerDriver struct {
 	ActualDriver drivers.Driver
 	CloseCh      chan bool
 	HeartbeatCh  chan bool
+
+	// lock guards the version of the protocol agreed on with the client,
+	// the calls running through Run, the events and the instances.
+	lock            sync.Mutex
+	protocolVersion int
+	calls           map[uint64]context.CancelFunc
+	events          *eventQueue
+
+	// The drivers of the machines served besides ActualDriver, see
+	// ServeInstances.
+	server      *rpc.Server
+	newInstance func() drivers.Driver
+	instances   map[string]string
+}
+
+// runMethods are the operations which can be run through Run.
+var runMethods = map[string]func(context.Context, drivers.Driver) error{
+	CreateMethod:  drivers.CreateContext,
+	KillMethod:    drivers.KillContext,
+	RemoveMethod:  drivers.RemoveContext,
+	RestartMethod: drivers.RestartContext,
+	StartMethod:   drivers.StartContext,
+	StopMethod:    drivers.StopContext,
 }
 
 func NewRPCServerDriver(d drivers.Driver) *RPCServerDriver {
@@ -95,15 +122,188 @@ func NewRPCServerDriver(d drivers.Driver) *RPCServerDriver {
 }
 
 func (r *RPCServerDriver) Close(_, _ *struct{}) error {
-	r.CloseCh <- true
+	// The instances of the other machines don't close the plugin.
+	if r.CloseCh != nil {
+		r.CloseCh <- true
+	}
+	return nil
+}
+
+// ServeInstances lets the plugin serve the drivers of several machines,
+// created with newInstance and registered on server by OpenInstance.
+func (r *RPCServerDriver) ServeInstances(server *rpc.Server, newInstance func() drivers.Driver) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.server = server
+	r.newInstance = newInstance
+}
+
+// OpenInstance returns the name of the service the driver of the named
+// machine is served under, creating it the first time. The instances share
+// the version of the protocol and the events of the plugin.
+func (r *RPCServerDriver) OpenInstance(machineName *string, reply *string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.newInstance == nil {
+		return errors.New("The plugin serves a single machine")
+	}
+
+	if serviceName, ok := r.instances[*machineName]; ok {
+		*reply = serviceName
+		return nil
+	}
+
+	if r.events == nil {
+		r.events = newEventQueue()
+	}
+
+	instance := &RPCServerDriver{
+		ActualDriver:    r.newInstance(),
+		HeartbeatCh:     r.HeartbeatCh,
+		protocolVersion: r.protocolVersion,
+		events:          r.events,
+	}
+
+	serviceName := fmt.Sprintf("%s/%d", RPCServiceNameV1, len(r.instances)+1)
+	if err := r.server.RegisterName(serviceName, instance); err != nil {
+		return err
+	}
+
+	if r.instances == nil {
+		r.instances = map[string]string{}
+	}
+	r.instances[*machineName] = serviceName
+	*reply = serviceName
+
 	return nil
 }
 
+// GetVersion returns the version of the protocol agreed on with the client,
+// or 1 if it didn't negotiate any: the clients which only know GetVersion
+// expect the version they speak.
 func (r *RPCServerDriver) GetVersion(_ *struct{}, reply *int) error {
-	*reply = version.APIVersion
+	*reply = r.version()
+	if *reply == 0 {
+		*reply = 1
+	}
 	return nil
 }
 
+// NegotiateVersion agrees with the client on the newest version of the
+// protocol both of them speak.
+func (r *RPCServerDriver) NegotiateVersion(clientVersion *int, reply *int) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.protocolVersion = version.APIVersion
+	if *clientVersion < r.protocolVersion {
+		r.protocolVersion = *clientVersion
+	}
+	*reply = r.protocolVersion
+
+	return nil
+}
+
+func (r *RPCServerDriver) version() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.protocolVersion
+}
+
+func (r *RPCServerDriver) eventQueue() *eventQueue {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.events == nil {
+		r.events = newEventQueue()
+	}
+	return r.events
+}
+
+// sendError returns the error of the driver as it is sent to the client,
+// with its kind since version 2.
+func (r *RPCServerDriver) sendError(err error) error {
+	if r.version() < 2 {
+		return err
+	}
+	return encodeError(err)
+}
+
+// Run runs one of the long running operations of the driver, e.g.
+// CreateMethod, until it completes or is cancelled by Cancel. Its progress
+// is sent as events.
+func (r *RPCServerDriver) Run(args *RunArgs, reply *RunReply) (err error) {
+	defer func() {
+		reply.LastEvent = r.eventQueue().last()
+		err = r.sendError(err)
+	}()
+	defer trapPanic(&err)
+
+	run, ok := runMethods[args.Method]
+	if !ok {
+		return fmt.Errorf("Unknown method %q", args.Method)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r.lock.Lock()
+	if r.calls == nil {
+		r.calls = map[uint64]context.CancelFunc{}
+	}
+	r.calls[args.ID] = cancel
+	r.lock.Unlock()
+
+	defer func() {
+		r.lock.Lock()
+		delete(r.calls, args.ID)
+		r.lock.Unlock()
+	}()
+
+	ctx = drivers.WithProgress(ctx, func(p drivers.Progress) {
+		r.eventQueue().push(Event{
+			Type:     EventProgress,
+			CallID:   args.ID,
+			Progress: p,
+		})
+	})
+
+	return run(ctx, r.ActualDriver)
+}
+
+// Cancel cancels the call made through Run with the given ID. The drivers
+// implementing drivers.ContextDriver stop what they are doing, the others
+// complete the operation.
+func (r *RPCServerDriver) Cancel(id *uint64, _ *struct{}) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if cancel, ok := r.calls[*id]; ok {
+		cancel()
+	}
+
+	return nil
+}
+
+// Events returns the events sent after the given one, waiting for a while
+// if there is none yet.
+func (r *RPCServerDriver) Events(args *EventsArgs, reply *[]Event) error {
+	*reply = r.eventQueue().wait(args.After, eventsPollTimeout)
+	return nil
+}
+
+// Logger returns a logger which sends the messages to the client as events
+// once it agreed on version 2 of the protocol, and to fallback until then.
+func (r *RPCServerDriver) Logger(fallback log.MachineLogger) log.MachineLogger {
+	return &eventLogger{
+		server:   r,
+		fallback: fallback,
+	}
+}
+
 func (r *RPCServerDriver) GetConfigRaw(_ *struct{}, reply *[]byte) error {
 	driverData, err := json.Marshal(r.ActualDriver)
 	if err != nil {
@@ -135,6 +335,7 @@ func (r *RPCServerDriver) Create(_, _ *struct{}) (err error) {
 	// have been known to happen and cause issues.  Therefore, we recover
 	// and do not crash the RPC server completely in the case of a panic
 	// during create.
+	defer func() { err = r.sendError(err) }()
 	defer trapPanic(&err)
 
 	err = r.ActualDriver.Create()
@@ -150,7 +351,7 @@ func (r *RPCServerDriver) DriverName(_ *struct{}, reply *string) error {
 func (r *RPCServerDriver) GetIP(_ *struct{}, reply *string) error {
 	ip, err := r.ActualDriver.GetIP()
 	*reply = ip
-	return err
+	return r.sendError(err)
 }
 
 func (r *RPCServerDriver) GetMachineName(_ *struct{}, reply *string) error {
@@ -161,7 +362,7 @@ func (r *RPCServerDriver) GetMachineName(_ *struct{}, reply *string) error {
 func (r *RPCServerDriver) GetSSHHostname(_ *struct{}, reply *string) error {
 	hostname, err := r.ActualDriver.GetSSHHostname()
 	*reply = hostname
-	return err
+	return r.sendError(err)
 }
 
 func (r *RPCServerDriver) GetSSHKeyPath(_ *struct{}, reply *string) error {
@@ -173,7 +374,7 @@ func (r *RPCServerDriver) GetSSHKeyPath(_ *struct{}, reply *string) error {
 func (r *RPCServerDriver) GetSSHPort(_ *struct{}, reply *int) error {
 	port, err := r.ActualDriver.GetSSHPort()
 	*reply = port
-	return err
+	return r.sendError(err)
 }
 
 func (r *RPCServerDriver) GetSSHUsername(_ *struct{}, reply *string) error {
@@ -184,44 +385,90 @@ func (r *RPCServerDriver) GetSSHUsername(_ *struct{}, reply *string) error {
 func (r *RPCServerDriver) GetURL(_ *struct{}, reply *string) error {
 	info, err := r.ActualDriver.GetURL()
 	*reply = info
-	return err
+	return r.sendError(err)
 }
 
 func (r *RPCServerDriver) GetState(_ *struct{}, reply *state.State) error {
 	s, err := r.ActualDriver.GetState()
 	*reply = s
-	return err
+	return r.sendError(err)
 }
 
 func (r *RPCServerDriver) Kill(_ *struct{}, _ *struct{}) error {
-	return r.ActualDriver.Kill()
+	return r.sendError(r.ActualDriver.Kill())
 }
 
 func (r *RPCServerDriver) PreCreateCheck(_ *struct{}, _ *struct{}) error {
-	return r.ActualDriver.PreCreateCheck()
+	return r.sendError(r.ActualDriver.PreCreateCheck())
 }
 
 func (r *RPCServerDriver) Remove(_ *struct{}, _ *struct{}) error {
-	return r.ActualDriver.Remove()
+	return r.sendError(r.ActualDriver.Remove())
 }
 
 func (r *RPCServerDriver) Restart(_ *struct{}, _ *struct{}) error {
-	return r.ActualDriver.Restart()
+	return r.sendError(r.ActualDriver.Restart())
 }
 
 func (r *RPCServerDriver) SetConfigFromFlags(flags *drivers.DriverOptions, _ *struct{}) error {
-	return r.ActualDriver.SetConfigFromFlags(*flags)
+	return r.sendError(r.ActualDriver.SetConfigFromFlags(*flags))
 }
 
 func (r *RPCServerDriver) Start(_ *struct{}, _ *struct{}) error {
-	return r.ActualDriver.Start()
+	return r.sendError(r.ActualDriver.Start())
 }
 
 func (r *RPCServerDriver) Stop(_ *struct{}, _ *struct{}) error {
-	return r.ActualDriver.Stop()
+	return r.sendError(r.ActualDriver.Stop())
 }
 
 func (r *RPCServerDriver) Heartbeat(_ *struct{}, _ *struct{}) error {
 	r.HeartbeatCh <- true
 	return nil
 }
+
+// GetCapabilities returns the capabilities offered by the driver.
+func (r *RPCServerDriver) GetCapabilities(_ *struct{}, reply *[]drivers.Capability) error {
+	*reply = drivers.GetCapabilities(r.ActualDriver)
+	return nil
+}
+
+func (r *RPCServerDriver) Upgrade(_ *struct{}, _ *struct{}) error {
+	return r.sendError(drivers.Upgrade(r.ActualDriver))
+}
+
+func (r *RPCServerDriver) Pause(_ *struct{}, _ *struct{}) error {
+	return r.sendError(drivers.Pause(r.ActualDriver))
+}
+
+func (r *RPCServerDriver) Unpause(_ *struct{}, _ *struct{}) error {
+	return r.sendError(drivers.Unpause(r.ActualDriver))
+}
+
+func (r *RPCServerDriver) CreateSnapshot(name *string, _ *struct{}) error {
+	return r.sendError(drivers.CreateSnapshot(r.ActualDriver, *name))
+}
+
+func (r *RPCServerDriver) ListSnapshots(_ *struct{}, reply *[]drivers.Snapshot) error {
+	snapshots, err := drivers.ListSnapshots(r.ActualDriver)
+	*reply = snapshots
+	return r.sendError(err)
+}
+
+func (r *RPCServerDriver) RestoreSnapshot(name *string, _ *struct{}) error {
+	return r.sendError(drivers.RestoreSnapshot(r.ActualDriver, *name))
+}
+
+func (r *RPCServerDriver) RemoveSnapshot(name *string, _ *struct{}) error {
+	return r.sendError(drivers.RemoveSnapshot(r.ActualDriver, *name))
+}
+
+func (r *RPCServerDriver) Resize(opts *drivers.ResizeOptions, _ *struct{}) error {
+	return r.sendError(drivers.Resize(r.ActualDriver, *opts))
+}
+
+func (r *RPCServerDriver) ConsoleLog(_ *struct{}, reply *string) error {
+	consoleLog, err := drivers.ConsoleLog(r.ActualDriver)
+	*reply = consoleLog
+	return r.sendError(err)
+}
@@ -0,0 +1,210 @@
+package rpcdriver
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/gob"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/libmachine/log"
+)
+
+// The plugins launched with a token print the address they listen on with
+// the network to dial. The ones launched by older clients, or built before
+// the tokens, print the bare TCP address of an HTTP RPC server.
+const (
+	unixAddrPrefix = "unix:"
+	tcpAddrPrefix  = "tcp:"
+)
+
+var errInvalidToken = errors.New("invalid token")
+
+// socketListener removes the private directory of its socket once closed.
+type socketListener struct {
+	net.Listener
+	dir string
+}
+
+func (l *socketListener) Close() error {
+	err := l.Listener.Close()
+	os.RemoveAll(l.dir)
+	return err
+}
+
+// ListenAuthenticated listens for the connections of the client on a Unix
+// socket in a directory only the user can open, or on the loopback interface
+// when Unix sockets are not available. It returns the address to hand over
+// to the client.
+func ListenAuthenticated() (net.Listener, string, error) {
+	dir, err := ioutil.TempDir("", "docker-machine-plugin-")
+	if err != nil {
+		return nil, "", err
+	}
+
+	listener, err := net.Listen("unix", filepath.Join(dir, "plugin.sock"))
+	if err == nil {
+		return &socketListener{Listener: listener, dir: dir}, unixAddrPrefix + listener.Addr().String(), nil
+	}
+
+	os.RemoveAll(dir)
+	log.Debugf("Listening on TCP, Unix sockets are not available: %s", err)
+
+	listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, "", err
+	}
+
+	return listener, tcpAddrPrefix + listener.Addr().String(), nil
+}
+
+// ServeAuthenticated serves the connections accepted by the listener, until
+// it is closed. Each of the calls must carry the token, the connections
+// making a call without it are closed.
+func ServeAuthenticated(server *rpc.Server, listener net.Listener, token string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.ServeCodec(newTokenServerCodec(conn, token))
+	}
+}
+
+// dialPlugin connects to the plugin listening on the address, with the
+// token it was launched with.
+func dialPlugin(addr, token string) (*rpc.Client, error) {
+	var network string
+	switch {
+	case strings.HasPrefix(addr, unixAddrPrefix):
+		network = "unix"
+	case strings.HasPrefix(addr, tcpAddrPrefix):
+		network = "tcp"
+	default:
+		// The plugin doesn't know about tokens.
+		return rpc.DialHTTP("tcp", addr)
+	}
+
+	conn, err := net.Dial(network, addr[len(network)+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return rpc.NewClientWithCodec(newTokenClientCodec(conn, token)), nil
+}
+
+// tokenClientCodec is the gob codec of net/rpc, sending the token ahead of
+// each of the requests.
+type tokenClientCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	token  string
+}
+
+func newTokenClientCodec(conn io.ReadWriteCloser, token string) rpc.ClientCodec {
+	encBuf := bufio.NewWriter(conn)
+	return &tokenClientCodec{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(encBuf),
+		encBuf: encBuf,
+		token:  token,
+	}
+}
+
+func (c *tokenClientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	if err := c.enc.Encode(c.token); err != nil {
+		return err
+	}
+	if err := c.enc.Encode(r); err != nil {
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *tokenClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	return c.dec.Decode(r)
+}
+
+func (c *tokenClientCodec) ReadResponseBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *tokenClientCodec) Close() error {
+	return c.rwc.Close()
+}
+
+// tokenServerCodec is the gob codec of net/rpc, checking the token sent
+// ahead of each of the requests.
+type tokenServerCodec struct {
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	token  string
+	closed bool
+}
+
+func newTokenServerCodec(conn io.ReadWriteCloser, token string) rpc.ServerCodec {
+	encBuf := bufio.NewWriter(conn)
+	return &tokenServerCodec{
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(encBuf),
+		encBuf: encBuf,
+		token:  token,
+	}
+}
+
+func (c *tokenServerCodec) ReadRequestHeader(r *rpc.Request) error {
+	var token string
+	if err := c.dec.Decode(&token); err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(c.token)) != 1 {
+		// The server stops reading the connection.
+		return errInvalidToken
+	}
+	return c.dec.Decode(r)
+}
+
+func (c *tokenServerCodec) ReadRequestBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *tokenServerCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	if err := c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			// The response couldn't be encoded, don't leave the client
+			// waiting for it.
+			c.Close()
+		}
+		return err
+	}
+	if err := c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return err
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *tokenServerCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
@@ -0,0 +1,78 @@
+package rpcdriver
+
+import (
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/stretchr/testify/assert"
+)
+
+func newAuthenticatedServer(t *testing.T, token string) (string, func()) {
+	server := rpc.NewServer()
+	if err := server.RegisterName(RPCServiceNameV1, NewRPCServerDriver(&fakedriver.Driver{
+		BaseDriver: &drivers.BaseDriver{},
+		MockName:   "authenticated",
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, addr, err := ListenAuthenticated()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ServeAuthenticated(server, listener, token)
+
+	return addr, func() { listener.Close() }
+}
+
+func TestAuthenticatedCalls(t *testing.T) {
+	addr, closeServer := newAuthenticatedServer(t, "secret")
+	defer closeServer()
+
+	rpcClient, err := dialPlugin(addr, "secret")
+	assert.NoError(t, err)
+	defer rpcClient.Close()
+
+	var name string
+	assert.NoError(t, NewInternalClient(rpcClient).Call(GetMachineNameMethod, struct{}{}, &name))
+	assert.Equal(t, "authenticated", name)
+}
+
+func TestCallsWithoutTheTokenAreRefused(t *testing.T) {
+	addr, closeServer := newAuthenticatedServer(t, "secret")
+	defer closeServer()
+
+	rpcClient, err := dialPlugin(addr, "guess")
+	assert.NoError(t, err)
+	defer rpcClient.Close()
+
+	var data []byte
+	assert.Error(t, NewInternalClient(rpcClient).Call(GetConfigRawMethod, struct{}{}, &data))
+	assert.Empty(t, data)
+}
+
+func TestListenAuthenticatedOnPrivateSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping the permissions of the socket directory")
+	}
+
+	addr, closeServer := newAuthenticatedServer(t, "secret")
+
+	assert.True(t, strings.HasPrefix(addr, unixAddrPrefix))
+
+	dir := filepath.Dir(strings.TrimPrefix(addr, unixAddrPrefix))
+	fi, err := os.Stat(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0700), fi.Mode().Perm())
+
+	closeServer()
+
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"sync"
 
 	"encoding/json"
@@ -167,6 +168,118 @@ func (d *SerialDriver) Stop() error {
 	return d.Driver.Stop()
 }
 
+// CreateContext creates a host, giving up when ctx is done
+func (d *SerialDriver) CreateContext(ctx context.Context) error {
+	d.Lock()
+	defer d.Unlock()
+	return CreateContext(ctx, d.Driver)
+}
+
+// KillContext stops a host forcefully, giving up when ctx is done
+func (d *SerialDriver) KillContext(ctx context.Context) error {
+	d.Lock()
+	defer d.Unlock()
+	return KillContext(ctx, d.Driver)
+}
+
+// RemoveContext removes a host, giving up when ctx is done
+func (d *SerialDriver) RemoveContext(ctx context.Context) error {
+	d.Lock()
+	defer d.Unlock()
+	return RemoveContext(ctx, d.Driver)
+}
+
+// RestartContext restarts a host, giving up when ctx is done
+func (d *SerialDriver) RestartContext(ctx context.Context) error {
+	d.Lock()
+	defer d.Unlock()
+	return RestartContext(ctx, d.Driver)
+}
+
+// StartContext starts a host, giving up when ctx is done
+func (d *SerialDriver) StartContext(ctx context.Context) error {
+	d.Lock()
+	defer d.Unlock()
+	return StartContext(ctx, d.Driver)
+}
+
+// StopContext stops a host gracefully, giving up when ctx is done
+func (d *SerialDriver) StopContext(ctx context.Context) error {
+	d.Lock()
+	defer d.Unlock()
+	return StopContext(ctx, d.Driver)
+}
+
+// GetCapabilities returns the capabilities offered by the driver
+func (d *SerialDriver) GetCapabilities() []Capability {
+	d.Lock()
+	defer d.Unlock()
+	return GetCapabilities(d.Driver)
+}
+
+// Pause freezes the host
+func (d *SerialDriver) Pause() error {
+	d.Lock()
+	defer d.Unlock()
+	return Pause(d.Driver)
+}
+
+// Unpause resumes the host frozen by Pause
+func (d *SerialDriver) Unpause() error {
+	d.Lock()
+	defer d.Unlock()
+	return Unpause(d.Driver)
+}
+
+// CreateSnapshot saves the current state of the host under the name
+func (d *SerialDriver) CreateSnapshot(name string) error {
+	d.Lock()
+	defer d.Unlock()
+	return CreateSnapshot(d.Driver, name)
+}
+
+// ListSnapshots returns the snapshots of the host
+func (d *SerialDriver) ListSnapshots() ([]Snapshot, error) {
+	d.Lock()
+	defer d.Unlock()
+	return ListSnapshots(d.Driver)
+}
+
+// RestoreSnapshot brings the host back to the named snapshot
+func (d *SerialDriver) RestoreSnapshot(name string) error {
+	d.Lock()
+	defer d.Unlock()
+	return RestoreSnapshot(d.Driver, name)
+}
+
+// RemoveSnapshot removes the named snapshot
+func (d *SerialDriver) RemoveSnapshot(name string) error {
+	d.Lock()
+	defer d.Unlock()
+	return RemoveSnapshot(d.Driver, name)
+}
+
+// Resize changes the resources of the host
+func (d *SerialDriver) Resize(opts ResizeOptions) error {
+	d.Lock()
+	defer d.Unlock()
+	return Resize(d.Driver, opts)
+}
+
+// ConsoleLog returns the output of the console of the host
+func (d *SerialDriver) ConsoleLog() (string, error) {
+	d.Lock()
+	defer d.Unlock()
+	return ConsoleLog(d.Driver)
+}
+
+// Upgrade upgrades the host
+func (d *SerialDriver) Upgrade() error {
+	d.Lock()
+	defer d.Unlock()
+	return Upgrade(d.Driver)
+}
+
 func (d *SerialDriver) MarshalJSON() ([]byte, error) {
 	return json.Marshal(d.Driver)
 }
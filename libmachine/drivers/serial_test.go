@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"testing"
 
 	"github.com/docker/machine/libmachine/mcnflag"
@@ -294,3 +295,58 @@ func TestSerialDriverStop(t *testing.T) {
 
 	assert.Equal(t, []string{"Lock", "Stop", "Unlock"}, callRecorder.calls)
 }
+
+func TestSerialDriverCreateContext(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockDriver{calls: callRecorder}, &MockLocker{calls: callRecorder})
+	CreateContext(context.Background(), driver)
+
+	assert.Equal(t, []string{"Lock", "Create", "Unlock"}, callRecorder.calls)
+}
+
+func TestSerialDriverStopContextCancelled(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	driver := newSerialDriverWithLock(&MockDriver{calls: callRecorder}, &MockLocker{calls: callRecorder})
+	err := StopContext(ctx, driver)
+
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []string{"Lock", "Unlock"}, callRecorder.calls)
+}
+
+type MockPauser struct {
+	*MockDriver
+}
+
+func (d *MockPauser) Pause() error {
+	d.calls.record("Pause")
+	return nil
+}
+
+func (d *MockPauser) Unpause() error {
+	d.calls.record("Unpause")
+	return nil
+}
+
+func TestSerialDriverPause(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockPauser{&MockDriver{calls: callRecorder}}, &MockLocker{calls: callRecorder})
+	err := Pause(driver)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Lock", "Unlock", "Lock", "Pause", "Unlock"}, callRecorder.calls)
+}
+
+func TestSerialDriverPauseNotSupported(t *testing.T) {
+	callRecorder := &CallRecorder{}
+
+	driver := newSerialDriverWithLock(&MockDriver{driverName: "DRIVER", calls: callRecorder}, &MockLocker{calls: callRecorder})
+	err := Pause(driver)
+
+	assert.Equal(t, ErrCapabilityNotSupported{DriverName: "DRIVER", Capability: CapabilityPause}, err)
+}
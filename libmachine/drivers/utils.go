@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/docker/machine/libmachine/log"
@@ -33,7 +34,15 @@ func GetSSHClientFromDriver(d Driver) (ssh.Client, error) {
 
 }
 
+// RunSSHCommandFromDriver runs command over SSH on the host of d. The session
+// is aborted when the context d was bound to with WithContext is done.
 func RunSSHCommandFromDriver(d Driver, command string) (string, error) {
+	return RunSSHCommandFromDriverContext(ContextOf(d), d, command)
+}
+
+// RunSSHCommandFromDriverContext runs command over SSH like
+// RunSSHCommandFromDriver, aborting the session when ctx is done.
+func RunSSHCommandFromDriverContext(ctx context.Context, d Driver, command string) (string, error) {
 	client, err := GetSSHClientFromDriver(d)
 	if err != nil {
 		return "", err
@@ -41,7 +50,15 @@ func RunSSHCommandFromDriver(d Driver, command string) (string, error) {
 
 	log.Debugf("About to run SSH command:\n%s", command)
 
-	output, err := client.Output(command)
+	var output string
+	if cc, ok := client.(ssh.ContextClient); ok {
+		output, err = cc.OutputContext(ctx, command)
+	} else {
+		output, err = client.Output(command)
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return "", ctxErr
+	}
 	log.Debugf("SSH cmd err, output: %v: %s", err, output)
 	if err != nil {
 		return "", fmt.Errorf(`ssh command error:
@@ -53,10 +70,10 @@ output  : %s`, command, err, output)
 	return output, nil
 }
 
-func sshAvailableFunc(d Driver) func() bool {
+func sshAvailableFunc(ctx context.Context, d Driver) func() bool {
 	return func() bool {
 		log.Debug("Getting to WaitForSSH function...")
-		if _, err := RunSSHCommandFromDriver(d, "exit 0"); err != nil {
+		if _, err := RunSSHCommandFromDriverContext(ctx, d, "exit 0"); err != nil {
 			log.Debugf("Error getting ssh command 'exit 0' : %s", err)
 			return false
 		}
@@ -65,8 +82,17 @@ func sshAvailableFunc(d Driver) func() bool {
 }
 
 func WaitForSSH(d Driver) error {
+	return WaitForSSHContext(ContextOf(d), d)
+}
+
+// WaitForSSHContext waits for SSH to be available like WaitForSSH, giving up
+// as soon as ctx is done.
+func WaitForSSHContext(ctx context.Context, d Driver) error {
 	// Try to dial SSH for 30 seconds before timing out.
-	if err := mcnutils.WaitFor(sshAvailableFunc(d)); err != nil {
+	if err := mcnutils.WaitForContext(ctx, sshAvailableFunc(ctx, d)); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
 		return fmt.Errorf("Too many retries waiting for SSH to be available.  Last error: %s", err)
 	}
 	return nil
@@ -8,9 +8,18 @@ import (
 
 	"github.com/docker/machine/drivers/virtualbox"
 	"github.com/docker/machine/libmachine"
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/log"
 )
 
+func init() {
+	// Run the driver in-process, without a docker-machine-driver-virtualbox
+	// binary.
+	drivers.Register("virtualbox", func(hostName, storePath string) drivers.Driver {
+		return virtualbox.NewDriver(hostName, storePath)
+	})
+}
+
 func usage() {
 	fmt.Println("Usage: go run main.go <example>\n" +
 		"Available examples: create streaming.")
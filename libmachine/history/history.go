@@ -0,0 +1,122 @@
+// Package history keeps the journal of the operations run on a machine, in
+// its directory.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+const (
+	// FileName is the name of the journal in the machine directory.
+	FileName = "history.jsonl"
+
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+)
+
+// Entry is an operation run on a machine.
+type Entry struct {
+	Time     time.Time
+	User     string
+	Command  string
+	Duration time.Duration
+	Outcome  string
+	Error    string            `json:",omitempty"`
+	Details  map[string]string `json:",omitempty"`
+}
+
+// NewEntry starts the entry of a command run now by the current user.
+func NewEntry(command string) *Entry {
+	return &Entry{
+		Time:    time.Now().UTC(),
+		User:    mcnutils.CurrentUser(),
+		Command: command,
+	}
+}
+
+// SetDetail records a detail of the operation, such as the versions an
+// upgrade moved from and to.
+func (e *Entry) SetDetail(key, value string) {
+	if e.Details == nil {
+		e.Details = map[string]string{}
+	}
+	e.Details[key] = value
+}
+
+// Finish records how long the command took and its outcome.
+func (e *Entry) Finish(err error) {
+	e.Duration = time.Since(e.Time).Round(time.Millisecond)
+
+	if err != nil {
+		e.Outcome = OutcomeFailed
+		e.Error = err.Error()
+	} else {
+		e.Outcome = OutcomeSucceeded
+	}
+}
+
+// Append appends the entry to the journal of the machine directory. The
+// directory isn't created if it doesn't exist, e.g. once the machine was
+// removed.
+func Append(dir string, e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, FileName), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	// The entry is written at once, so that the entries appended by
+	// concurrent processes don't mix.
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Read returns the entries of the journal of the machine directory, oldest
+// first.
+func Read(dir string) ([]*Entry, error) {
+	entries := []*Entry{}
+
+	f, err := os.Open(filepath.Join(dir, FileName))
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		entry := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return nil, fmt.Errorf("Error reading line %d of the history: %s", line, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
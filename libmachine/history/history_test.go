@@ -0,0 +1,71 @@
+package history
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendAndRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	entries, err := Read(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	start := NewEntry("start")
+	start.Finish(nil)
+	assert.NoError(t, Append(dir, start))
+
+	upgrade := NewEntry("upgrade")
+	upgrade.SetDetail("from", "1.12.0")
+	upgrade.Finish(errors.New("Connection refused"))
+	assert.NoError(t, Append(dir, upgrade))
+
+	entries, err = Read(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	assert.Equal(t, "start", entries[0].Command)
+	assert.Equal(t, OutcomeSucceeded, entries[0].Outcome)
+	assert.Empty(t, entries[0].Error)
+	assert.Equal(t, start.User, entries[0].User)
+	assert.True(t, start.Time.Equal(entries[0].Time))
+
+	assert.Equal(t, "upgrade", entries[1].Command)
+	assert.Equal(t, OutcomeFailed, entries[1].Outcome)
+	assert.Equal(t, "Connection refused", entries[1].Error)
+	assert.Equal(t, map[string]string{"from": "1.12.0"}, entries[1].Details)
+}
+
+func TestAppendDoesNotCreateDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	removed := filepath.Join(dir, "removed")
+
+	entry := NewEntry("rm")
+	entry.Finish(nil)
+	assert.Error(t, Append(removed, entry))
+
+	_, err = os.Stat(removed)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReadCorruptHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, FileName), []byte("{}\n{\"Command\":"), 0600))
+
+	_, err = Read(dir)
+	assert.EqualError(t, err, "Error reading line 2 of the history: unexpected end of JSON input")
+}
@@ -1,19 +1,26 @@
 package host
 
 import (
+	"context"
+	"encoding/json"
+	"os"
 	"regexp"
+	"sort"
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/cert"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/lock"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcndockerclient"
 	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/mcnflag"
 	"github.com/docker/machine/libmachine/mcnutils"
 	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/provision/pkgaction"
 	"github.com/docker/machine/libmachine/provision/serviceaction"
+	"github.com/docker/machine/libmachine/secrets"
 	"github.com/docker/machine/libmachine/ssh"
 	"github.com/docker/machine/libmachine/state"
 	"github.com/docker/machine/libmachine/swarm"
@@ -23,6 +30,7 @@ import (
 var (
 	validHostNamePattern                  = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9\-\.]*$`)
 	stdSSHClientCreator  SSHClientCreator = &StandardSSHClientCreator{}
+	waitForLock                           = false
 )
 
 type SSHClientCreator interface {
@@ -37,13 +45,24 @@ func SetSSHClientCreator(creator SSHClientCreator) {
 	stdSSHClientCreator = creator
 }
 
+// SetWaitForLock makes the operations changing a machine wait for another
+// process to release its lock, instead of failing right away.
+func SetWaitForLock(wait bool) {
+	waitForLock = wait
+}
+
 type Host struct {
-	ConfigVersion int
-	Driver        drivers.Driver
-	DriverName    string
-	HostOptions   *Options
-	Name          string
-	RawDriver     []byte `json:"-"`
+	ConfigVersion   int
+	Driver          drivers.Driver
+	DriverName      string
+	HostOptions     *Options
+	Name            string
+	CompletedStages []CreateStage `json:",omitempty"`
+	SecretFields    []string      `json:",omitempty"`
+	RawDriver       []byte        `json:"-"`
+
+	lock      *lock.Lock
+	lockDepth int
 }
 
 type Options struct {
@@ -65,6 +84,33 @@ func ValidateHostName(name string) bool {
 	return validHostNamePattern.MatchString(name)
 }
 
+// RecordSecretFields records which fields of the driver configuration hold
+// the values of the sensitive flags, so that the stores encrypt them.
+func (h *Host) RecordSecretFields(flags []mcnflag.Flag) error {
+	config, err := json.Marshal(h.Driver)
+	if err != nil {
+		return err
+	}
+
+	fields, err := secrets.SensitiveFields(h.DriverName, flags, config)
+	if err != nil {
+		return err
+	}
+
+	known := map[string]bool{}
+	for _, field := range h.SecretFields {
+		known[field] = true
+	}
+	for _, field := range fields {
+		if !known[field] {
+			h.SecretFields = append(h.SecretFields, field)
+		}
+	}
+	sort.Strings(h.SecretFields)
+
+	return nil
+}
+
 func (h *Host) RunSSHCommand(command string) (string, error) {
 	return drivers.RunSSHCommandFromDriver(h.Driver, command)
 }
@@ -92,7 +138,50 @@ func (creator *StandardSSHClientCreator) CreateSSHClient(d drivers.Driver) (ssh.
 	return ssh.NewClient(d.GetSSHUsername(), addr, port, auth)
 }
 
-func (h *Host) runActionForState(action func() error, desiredState state.State) error {
+// Lock takes the lock of the machine on behalf of the given operation, so
+// that other processes don't change it at the same time. The returned
+// function releases the lock. The lock is shared by the operations of h, so
+// that they can be nested.
+func (h *Host) Lock(ctx context.Context, operation string) (func(), error) {
+	if h.lock != nil {
+		h.lockDepth++
+		return h.unlock, nil
+	}
+
+	authOptions := h.AuthOptions()
+	if authOptions == nil || authOptions.StorePath == "" {
+		return func() {}, nil
+	}
+
+	// Nothing to protect if the machine was never saved.
+	if _, err := os.Stat(authOptions.StorePath); os.IsNotExist(err) {
+		return func() {}, nil
+	}
+
+	l, err := lock.Acquire(ctx, h.Name, authOptions.StorePath, operation, waitForLock)
+	if err != nil {
+		return nil, err
+	}
+
+	h.lock = l
+	h.lockDepth = 1
+
+	return h.unlock, nil
+}
+
+func (h *Host) unlock() {
+	h.lockDepth--
+	if h.lockDepth > 0 {
+		return
+	}
+
+	if err := h.lock.Release(); err != nil {
+		log.Warnf("Error releasing the lock of %q: %s", h.Name, err)
+	}
+	h.lock = nil
+}
+
+func (h *Host) runActionForState(ctx context.Context, action func(context.Context, drivers.Driver) error, desiredState state.State) error {
 	if drivers.MachineInState(h.Driver, desiredState)() {
 		return mcnerror.ErrHostAlreadyInState{
 			Name:  h.Name,
@@ -100,36 +189,69 @@ func (h *Host) runActionForState(action func() error, desiredState state.State)
 		}
 	}
 
-	if err := action(); err != nil {
+	if err := action(ctx, h.Driver); err != nil {
 		return err
 	}
 
-	return mcnutils.WaitFor(drivers.MachineInState(h.Driver, desiredState))
+	return mcnutils.WaitForContext(ctx, drivers.MachineInState(h.Driver, desiredState))
 }
 
 func (h *Host) WaitForDocker() error {
-	provisioner, err := provision.DetectProvisioner(h.Driver)
+	return h.WaitForDockerContext(context.Background())
+}
+
+// WaitForDockerContext waits for the Docker daemon of the machine to be
+// available, giving up as soon as ctx is done.
+func (h *Host) WaitForDockerContext(ctx context.Context) error {
+	provisioner, err := provision.DetectProvisionerContext(ctx, h.Driver)
 	if err != nil {
 		return err
 	}
 
-	return provision.WaitForDocker(provisioner, engine.DefaultPort)
+	return provision.WaitForDockerContext(ctx, provisioner, engine.DefaultPort)
 }
 
 func (h *Host) Start() error {
+	return h.StartContext(context.Background())
+}
+
+// StartContext starts the machine and waits for Docker to be available,
+// giving up as soon as ctx is done.
+func (h *Host) StartContext(ctx context.Context) error {
+	unlock, err := h.Lock(ctx, "start")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return h.start(ctx)
+}
+
+func (h *Host) start(ctx context.Context) error {
 	log.Infof("Starting %q...", h.Name)
-	if err := h.runActionForState(h.Driver.Start, state.Running); err != nil {
+	if err := h.runActionForState(ctx, drivers.StartContext, state.Running); err != nil {
 		return err
 	}
 
 	log.Infof("Machine %q was started.", h.Name)
 
-	return h.WaitForDocker()
+	return h.WaitForDockerContext(ctx)
 }
 
 func (h *Host) Stop() error {
+	return h.StopContext(context.Background())
+}
+
+// StopContext stops the machine, giving up as soon as ctx is done.
+func (h *Host) StopContext(ctx context.Context) error {
+	unlock, err := h.Lock(ctx, "stop")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	log.Infof("Stopping %q...", h.Name)
-	if err := h.runActionForState(h.Driver.Stop, state.Stopped); err != nil {
+	if err := h.runActionForState(ctx, drivers.StopContext, state.Stopped); err != nil {
 		return err
 	}
 
@@ -138,8 +260,19 @@ func (h *Host) Stop() error {
 }
 
 func (h *Host) Kill() error {
+	return h.KillContext(context.Background())
+}
+
+// KillContext kills the machine, giving up as soon as ctx is done.
+func (h *Host) KillContext(ctx context.Context) error {
+	unlock, err := h.Lock(ctx, "kill")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	log.Infof("Killing %q...", h.Name)
-	if err := h.runActionForState(h.Driver.Kill, state.Stopped); err != nil {
+	if err := h.runActionForState(ctx, drivers.KillContext, state.Stopped); err != nil {
 		return err
 	}
 
@@ -148,21 +281,33 @@ func (h *Host) Kill() error {
 }
 
 func (h *Host) Restart() error {
+	return h.RestartContext(context.Background())
+}
+
+// RestartContext restarts the machine and waits for Docker to be available,
+// giving up as soon as ctx is done.
+func (h *Host) RestartContext(ctx context.Context) error {
+	unlock, err := h.Lock(ctx, "restart")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	log.Infof("Restarting %q...", h.Name)
 	if drivers.MachineInState(h.Driver, state.Stopped)() {
-		if err := h.Start(); err != nil {
+		if err := h.start(ctx); err != nil {
 			return err
 		}
 	} else if drivers.MachineInState(h.Driver, state.Running)() {
-		if err := h.Driver.Restart(); err != nil {
+		if err := drivers.RestartContext(ctx, h.Driver); err != nil {
 			return err
 		}
-		if err := mcnutils.WaitFor(drivers.MachineInState(h.Driver, state.Running)); err != nil {
+		if err := mcnutils.WaitForContext(ctx, drivers.MachineInState(h.Driver, state.Running)); err != nil {
 			return err
 		}
 	}
 
-	return h.WaitForDocker()
+	return h.WaitForDockerContext(ctx)
 }
 
 func (h *Host) DockerVersion() (string, error) {
@@ -184,6 +329,12 @@ func (h *Host) DockerVersion() (string, error) {
 }
 
 func (h *Host) Upgrade() error {
+	unlock, err := h.Lock(context.Background(), "upgrade")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	machineState, err := h.Driver.GetState()
 	if err != nil {
 		return err
@@ -191,7 +342,7 @@ func (h *Host) Upgrade() error {
 
 	if machineState != state.Running {
 		log.Info("Starting machine so machine can be upgraded...")
-		if err := h.Start(); err != nil {
+		if err := h.start(context.Background()); err != nil {
 			return err
 		}
 	}
@@ -231,7 +382,7 @@ func (h *Host) Upgrade() error {
 		// fine to install Docker from scratch after removing the old
 		// packages, and images/containers etc. should be preserved in
 		// /var/lib/docker)
-		return h.Provision()
+		return h.provision()
 	}
 
 	log.Info("Upgrading docker...")
@@ -255,6 +406,16 @@ func (h *Host) AuthOptions() *auth.Options {
 }
 
 func (h *Host) ConfigureAuth() error {
+	unlock, err := h.Lock(context.Background(), "regenerate-certs")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return h.configureAuth()
+}
+
+func (h *Host) configureAuth() error {
 	provisioner, err := provision.DetectProvisioner(h.Driver)
 	if err != nil {
 		return err
@@ -269,14 +430,30 @@ func (h *Host) ConfigureAuth() error {
 }
 
 func (h *Host) ConfigureAllAuth() error {
+	unlock, err := h.Lock(context.Background(), "regenerate-certs")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	log.Info("Regenerating local certificates")
 	if err := cert.BootstrapCertificates(h.AuthOptions()); err != nil {
 		return err
 	}
-	return h.ConfigureAuth()
+	return h.configureAuth()
 }
 
 func (h *Host) Provision() error {
+	unlock, err := h.Lock(context.Background(), "provision")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return h.provision()
+}
+
+func (h *Host) provision() error {
 	provisioner, err := provision.DetectProvisioner(h.Driver)
 	if err != nil {
 		return err
@@ -1,10 +1,14 @@
 package host
 
 import (
+	"context"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"github.com/docker/machine/drivers/fakedriver"
 	_ "github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/provision"
 	"github.com/docker/machine/libmachine/state"
 )
@@ -55,3 +59,89 @@ func TestStart(t *testing.T) {
 		t.Fatalf("Expected no error but got one: %s", err)
 	}
 }
+
+func TestStopContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	host := &Host{
+		Driver: &fakedriver.Driver{
+			MockState: state.Running,
+		},
+	}
+
+	if err := host.StopContext(ctx); err != context.Canceled {
+		t.Fatalf("Expected %q but got: %v", context.Canceled, err)
+	}
+
+	if host.Driver.(*fakedriver.Driver).MockState != state.Running {
+		t.Fatal("Expected the machine to be left running")
+	}
+}
+
+func TestPendingCreateStage(t *testing.T) {
+	h := &Host{}
+
+	if _, pending := h.PendingCreateStage(); pending {
+		t.Fatal("Expected a host without recorded stages to be fully created")
+	}
+
+	h.CompleteStage(StageCertificates)
+	h.CompleteStage(StagePreCreateCheck)
+	h.CompleteStage(StagePreCreateCheck)
+
+	stage, pending := h.PendingCreateStage()
+	if !pending || stage != StageDriverCreate {
+		t.Fatalf("Expected stage %s to be pending, got %q", StageDriverCreate, stage)
+	}
+	if len(h.CompletedStages) != 2 {
+		t.Fatalf("Expected 2 completed stages, got %d", len(h.CompletedStages))
+	}
+
+	for _, stage := range CreateStages {
+		h.CompleteStage(stage)
+	}
+
+	if h.IsPartiallyCreated() {
+		t.Fatal("Expected the host to be fully created")
+	}
+}
+
+func TestLockIsSharedByNestedOperations(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "host")
+	defer os.RemoveAll(dir)
+
+	h := &Host{
+		Name:        "foo",
+		HostOptions: &Options{AuthOptions: &auth.Options{StorePath: dir}},
+	}
+	other := &Host{
+		Name:        "foo",
+		HostOptions: &Options{AuthOptions: &auth.Options{StorePath: dir}},
+	}
+
+	unlock, err := h.Lock(context.Background(), "upgrade")
+	if err != nil {
+		t.Fatalf("Expected no error but got one: %s", err)
+	}
+	unlockNested, err := h.Lock(context.Background(), "start")
+	if err != nil {
+		t.Fatalf("Expected nested lock to succeed but got: %s", err)
+	}
+
+	if _, err := other.Lock(context.Background(), "stop"); err == nil {
+		t.Fatal("Expected another host to find the machine busy")
+	}
+
+	unlockNested()
+	if _, err := other.Lock(context.Background(), "stop"); err == nil {
+		t.Fatal("Expected the lock to be held until the outer operation is done")
+	}
+
+	unlock()
+	unlockOther, err := other.Lock(context.Background(), "stop")
+	if err != nil {
+		t.Fatalf("Expected the lock to be released but got: %s", err)
+	}
+	unlockOther()
+}
@@ -44,7 +44,10 @@ func getMigratedHostMetadata(data []byte) (*Metadata, error) {
 	return migratedHostMetadata, nil
 }
 
-func MigrateHost(h *Host, data []byte) (*Host, bool, error) {
+// MigrateHost loads the host from its configuration as saved in the store
+// at storePath, migrating it to the current config version if needed. The
+// paths saved relative to the store are made absolute.
+func MigrateHost(h *Host, data []byte, storePath string) (*Host, bool, error) {
 	var (
 		migrationNeeded    = false
 		migrationPerformed = false
@@ -57,7 +60,10 @@ func MigrateHost(h *Host, data []byte) (*Host, bool, error) {
 		return nil, false, err
 	}
 
-	globalStorePath := filepath.Dir(filepath.Dir(migratedHostMetadata.HostOptions.AuthOptions.StorePath))
+	globalStorePath := storePath
+	if globalStorePath == "" {
+		globalStorePath = filepath.Dir(filepath.Dir(migratedHostMetadata.HostOptions.AuthOptions.StorePath))
+	}
 
 	driver := &RawDataDriver{none.NewDriver(h.Name, globalStorePath), nil}
 
@@ -110,11 +116,23 @@ func MigrateHost(h *Host, data []byte) (*Host, bool, error) {
 				driver.Data = h.RawDriver
 				h.Driver = driver
 			case 3:
+				if hostV2 == nil {
+					h.Driver = driver
+					if err := json.Unmarshal(data, &h); err != nil {
+						return nil, migrationPerformed, fmt.Errorf("Error unmarshalling host config version 3: %s", err)
+					}
+				}
+				// The paths are absolute up to version 3. They are
+				// made relative to the store when the host is saved.
 			}
 		}
 	}
 
 	h.RawDriver = driver.Data
 
+	if err := h.resolvePaths(storePath); err != nil {
+		return nil, migrationPerformed, fmt.Errorf("Error resolving the paths of the host: %s", err)
+	}
+
 	return h, migrationPerformed, nil
 }
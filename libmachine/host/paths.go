@@ -0,0 +1,149 @@
+package host
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/machine/libmachine/auth"
+)
+
+// driverPaths are the fields of the drivers whose paths are saved relative
+// to the store, as set by drivers.BaseDriver.
+var driverPaths = []string{"StorePath", "SSHKeyPath"}
+
+// authPaths returns the paths of the authentication options which are saved
+// relative to the store.
+func authPaths(o *auth.Options) []*string {
+	return []*string{
+		&o.CertDir,
+		&o.CaCertPath,
+		&o.CaPrivateKeyPath,
+		&o.ServerCertPath,
+		&o.ServerKeyPath,
+		&o.ClientKeyPath,
+		&o.ClientCertPath,
+		&o.StorePath,
+	}
+}
+
+// WithRelativePaths returns a copy of the host as saved in the store at
+// storePath since config version 4: the paths found in the store are
+// relative to it, so that the store can be moved. The host itself is left
+// untouched.
+func (h *Host) WithRelativePaths(storePath string) (*Host, error) {
+	saved := *h
+
+	if h.HostOptions != nil && h.HostOptions.AuthOptions != nil {
+		options := *h.HostOptions
+		authOptions := *h.HostOptions.AuthOptions
+		for _, p := range authPaths(&authOptions) {
+			*p = relativePath(*p, storePath)
+		}
+		options.AuthOptions = &authOptions
+		saved.HostOptions = &options
+	}
+
+	if h.Driver != nil {
+		data, err := json.Marshal(h.Driver)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err = rewriteDriverPaths(data, func(p string) string {
+			return relativePath(p, storePath)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		saved.Driver = &RawDataDriver{Data: data}
+	}
+
+	return &saved, nil
+}
+
+// resolvePaths makes the paths saved relative to the store at storePath
+// absolute again.
+func (h *Host) resolvePaths(storePath string) error {
+	if h.HostOptions != nil && h.HostOptions.AuthOptions != nil {
+		for _, p := range authPaths(h.HostOptions.AuthOptions) {
+			*p = absolutePath(*p, storePath)
+		}
+	}
+
+	data, err := rewriteDriverPaths(h.RawDriver, func(p string) string {
+		return absolutePath(p, storePath)
+	})
+	if err != nil {
+		return err
+	}
+
+	h.RawDriver = data
+	if driver, ok := h.Driver.(*RawDataDriver); ok {
+		driver.Data = data
+	}
+
+	return nil
+}
+
+// rewriteDriverPaths replaces the paths of the driver configuration with
+// what fn returns. The configuration is left untouched when nothing changed.
+func rewriteDriverPaths(data []byte, fn func(string) string) ([]byte, error) {
+	if len(data) == 0 {
+		return data, nil
+	}
+
+	var config map[string]interface{}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&config); err != nil {
+		// Not all the drivers are kept in a JSON object.
+		return data, nil
+	}
+
+	changed := false
+	for _, field := range driverPaths {
+		value, ok := config[field].(string)
+		if !ok {
+			continue
+		}
+
+		if newValue := fn(value); newValue != value {
+			config[field] = newValue
+			changed = true
+		}
+	}
+
+	if !changed {
+		return data, nil
+	}
+
+	return json.Marshal(config)
+}
+
+// relativePath returns the path relative to the store, with forward slashes
+// so that the store can be shared between operating systems. The paths out
+// of the store are left as they are.
+func relativePath(p, storePath string) string {
+	if p == "" || storePath == "" || !filepath.IsAbs(p) {
+		return p
+	}
+
+	rel, err := filepath.Rel(storePath, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return p
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+func absolutePath(p, storePath string) string {
+	if p == "" || storePath == "" || filepath.IsAbs(p) {
+		return p
+	}
+
+	return filepath.Join(storePath, filepath.FromSlash(p))
+}
@@ -0,0 +1,71 @@
+package host
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/version"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelativePathsRelocate(t *testing.T) {
+	oldStore := filepath.Join("/", "old", "store")
+	newStore := filepath.Join("/", "new", "store")
+	machineDir := filepath.Join(oldStore, "machines", "default")
+
+	h := &Host{
+		ConfigVersion: version.ConfigVersion,
+		Name:          "default",
+		DriverName:    "none",
+		Driver:        none.NewDriver("default", oldStore),
+		HostOptions: &Options{
+			AuthOptions: &auth.Options{
+				CertDir:        filepath.Join(oldStore, "certs"),
+				CaCertPath:     filepath.Join(oldStore, "certs", "ca.pem"),
+				ServerCertPath: filepath.Join(machineDir, "server.pem"),
+				ClientCertPath: filepath.Join("/", "elsewhere", "cert.pem"),
+				StorePath:      machineDir,
+			},
+		},
+	}
+
+	saved, err := h.WithRelativePaths(oldStore)
+	assert.NoError(t, err)
+	assert.Equal(t, "certs/ca.pem", saved.HostOptions.AuthOptions.CaCertPath)
+	assert.Equal(t, "machines/default", saved.HostOptions.AuthOptions.StorePath)
+	assert.Equal(t, filepath.Join("/", "elsewhere", "cert.pem"), saved.HostOptions.AuthOptions.ClientCertPath)
+
+	// The host itself keeps its absolute paths.
+	assert.Equal(t, machineDir, h.HostOptions.AuthOptions.StorePath)
+
+	data, err := json.Marshal(saved)
+	assert.NoError(t, err)
+
+	loaded, migrationPerformed, err := MigrateHost(&Host{Name: "default"}, data, newStore)
+	assert.NoError(t, err)
+	assert.False(t, migrationPerformed)
+
+	newMachineDir := filepath.Join(newStore, "machines", "default")
+	assert.Equal(t, filepath.Join(newStore, "certs"), loaded.HostOptions.AuthOptions.CertDir)
+	assert.Equal(t, filepath.Join(newStore, "certs", "ca.pem"), loaded.HostOptions.AuthOptions.CaCertPath)
+	assert.Equal(t, filepath.Join(newMachineDir, "server.pem"), loaded.HostOptions.AuthOptions.ServerCertPath)
+	assert.Equal(t, filepath.Join("/", "elsewhere", "cert.pem"), loaded.HostOptions.AuthOptions.ClientCertPath)
+	assert.Equal(t, newMachineDir, loaded.HostOptions.AuthOptions.StorePath)
+
+	driver := none.NewDriver("", "")
+	assert.NoError(t, json.Unmarshal(loaded.RawDriver, &driver))
+	assert.Equal(t, newStore, driver.StorePath)
+}
+
+func TestRelativePath(t *testing.T) {
+	store := filepath.Join("/", "store")
+
+	assert.Equal(t, "", relativePath("", store))
+	assert.Equal(t, ".", relativePath(store, store))
+	assert.Equal(t, "machines/foo/id_rsa", relativePath(filepath.Join(store, "machines", "foo", "id_rsa"), store))
+	assert.Equal(t, filepath.Join("/", "store2", "ca.pem"), relativePath(filepath.Join("/", "store2", "ca.pem"), store))
+	assert.Equal(t, filepath.Join("/", "ca.pem"), relativePath(filepath.Join("/", "ca.pem"), store))
+}
@@ -0,0 +1,66 @@
+package host
+
+// CreateStage is a step of the machine creation pipeline. The stages that
+// completed are recorded in the host so that an interrupted or failed
+// creation can be resumed where it stopped. They are cleared once the host
+// is fully created.
+type CreateStage string
+
+const (
+	StageCertificates    CreateStage = "certificates"
+	StagePreCreateCheck  CreateStage = "pre-create-check"
+	StageDriverCreate    CreateStage = "driver-create"
+	StageWaitForRunning  CreateStage = "wait-for-running"
+	StageProvision       CreateStage = "provision"
+	StageCheckConnection CreateStage = "check-connection"
+)
+
+// CreateStages lists the stages of the creation pipeline in the order they run.
+var CreateStages = []CreateStage{
+	StageCertificates,
+	StagePreCreateCheck,
+	StageDriverCreate,
+	StageWaitForRunning,
+	StageProvision,
+	StageCheckConnection,
+}
+
+// StageCompleted tells whether the given creation stage already completed.
+func (h *Host) StageCompleted(stage CreateStage) bool {
+	for _, completed := range h.CompletedStages {
+		if completed == stage {
+			return true
+		}
+	}
+	return false
+}
+
+// CompleteStage records that the given creation stage completed.
+func (h *Host) CompleteStage(stage CreateStage) {
+	if !h.StageCompleted(stage) {
+		h.CompletedStages = append(h.CompletedStages, stage)
+	}
+}
+
+// PendingCreateStage returns the first creation stage that did not complete.
+// Hosts without recorded stages are fully created.
+func (h *Host) PendingCreateStage() (CreateStage, bool) {
+	if len(h.CompletedStages) == 0 {
+		return "", false
+	}
+
+	for _, stage := range CreateStages {
+		if !h.StageCompleted(stage) {
+			return stage, true
+		}
+	}
+
+	return "", false
+}
+
+// IsPartiallyCreated tells whether the creation of the host stopped before
+// all of its stages completed.
+func (h *Host) IsPartiallyCreated() bool {
+	_, pending := h.PendingCreateStage()
+	return pending
+}
@@ -0,0 +1,272 @@
+// Package hostpool leases the servers of an inventory to machines.
+//
+// The leases are files of a directory, one per leased host, created
+// atomically so that concurrent creations never lease the same host.
+package hostpool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+)
+
+// ErrNoFreeHost is returned when all the hosts of the pool are leased.
+var ErrNoFreeHost = errors.New("no free host in the pool")
+
+// validHostName is what the names of the hosts can be, as they name the
+// files of their leases.
+var validHostName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// Host is a server of the pool, with the credentials to connect to it.
+type Host struct {
+	Name       string `json:"name"`
+	Address    string `json:"address"`
+	SSHUser    string `json:"ssh_user,omitempty"`
+	SSHPort    int    `json:"ssh_port,omitempty"`
+	SSHKey     string `json:"ssh_key,omitempty"`
+	EnginePort int    `json:"engine_port,omitempty"`
+}
+
+// Inventory lists the hosts of the pool. Its SSH and engine settings are the
+// defaults of the hosts which don't set them.
+type Inventory struct {
+	SSHUser    string `json:"ssh_user,omitempty"`
+	SSHPort    int    `json:"ssh_port,omitempty"`
+	SSHKey     string `json:"ssh_key,omitempty"`
+	EnginePort int    `json:"engine_port,omitempty"`
+	Hosts      []Host `json:"hosts"`
+}
+
+// LoadInventory reads the JSON inventory file. The paths of the SSH keys
+// are relative to the directory of the file.
+func LoadInventory(path string) (*Inventory, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var inventory Inventory
+	if err := json.Unmarshal(data, &inventory); err != nil {
+		return nil, fmt.Errorf("Invalid inventory %s: %s", path, err)
+	}
+
+	if len(inventory.Hosts) == 0 {
+		return nil, fmt.Errorf("Invalid inventory %s: no hosts", path)
+	}
+
+	dir := filepath.Dir(path)
+	seen := map[string]bool{}
+	for i := range inventory.Hosts {
+		h := &inventory.Hosts[i]
+
+		if !validHostName.MatchString(h.Name) {
+			return nil, fmt.Errorf("Invalid inventory %s: invalid host name %q", path, h.Name)
+		}
+		if seen[h.Name] {
+			return nil, fmt.Errorf("Invalid inventory %s: host %s is listed twice", path, h.Name)
+		}
+		seen[h.Name] = true
+
+		if h.Address == "" {
+			return nil, fmt.Errorf("Invalid inventory %s: host %s has no address", path, h.Name)
+		}
+
+		inventory.setDefaults(h)
+		if h.SSHKey != "" && !filepath.IsAbs(h.SSHKey) {
+			h.SSHKey = filepath.Join(dir, h.SSHKey)
+		}
+	}
+
+	return &inventory, nil
+}
+
+func (inventory *Inventory) setDefaults(h *Host) {
+	if h.SSHUser == "" {
+		h.SSHUser = inventory.SSHUser
+	}
+	if h.SSHUser == "" {
+		h.SSHUser = drivers.DefaultSSHUser
+	}
+
+	if h.SSHPort == 0 {
+		h.SSHPort = inventory.SSHPort
+	}
+	if h.SSHPort == 0 {
+		h.SSHPort = drivers.DefaultSSHPort
+	}
+
+	if h.SSHKey == "" {
+		h.SSHKey = inventory.SSHKey
+	}
+
+	if h.EnginePort == 0 {
+		h.EnginePort = inventory.EnginePort
+	}
+	if h.EnginePort == 0 {
+		h.EnginePort = engine.DefaultPort
+	}
+}
+
+// Lease records which machine a host is leased to.
+type Lease struct {
+	Host      string    `json:"host"`
+	Machine   string    `json:"machine"`
+	StorePath string    `json:"store_path"`
+	LeasedAt  time.Time `json:"leased_at"`
+}
+
+// Pool is the inventory with the leases of its hosts.
+type Pool struct {
+	Inventory *Inventory
+	LeaseDir  string
+}
+
+// DefaultLeaseDir returns the directory of the leases of the hosts of the
+// inventory, when none is given.
+func DefaultLeaseDir(inventoryPath string) string {
+	return inventoryPath + ".leases"
+}
+
+// Open opens the pool of the hosts of the inventory, whose leases are in the
+// directory, or in the default one when it's empty.
+func Open(inventoryPath, leaseDir string) (*Pool, error) {
+	inventory, err := LoadInventory(inventoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if leaseDir == "" {
+		leaseDir = DefaultLeaseDir(inventoryPath)
+	}
+
+	return &Pool{
+		Inventory: inventory,
+		LeaseDir:  leaseDir,
+	}, nil
+}
+
+func (p *Pool) leasePath(hostName string) string {
+	return filepath.Join(p.LeaseDir, hostName)
+}
+
+// Host returns the host of the inventory with the name.
+func (p *Pool) Host(name string) (*Host, error) {
+	for i := range p.Inventory.Hosts {
+		if p.Inventory.Hosts[i].Name == name {
+			return &p.Inventory.Hosts[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("Host %s is not in the inventory", name)
+}
+
+// Lease leases the first free host of the inventory to the machine of the
+// store.
+func (p *Pool) Lease(machine, storePath string) (*Host, error) {
+	if err := os.MkdirAll(p.LeaseDir, 0700); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(&Lease{
+		Machine:   machine,
+		StorePath: storePath,
+		LeasedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The lease is written in full before it's linked to the name of a
+	// host, which fails when the host is already leased, so that the
+	// leases are taken atomically and never read half written.
+	tmp, err := ioutil.TempFile(p.LeaseDir, ".lease-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range p.Inventory.Hosts {
+		h := &p.Inventory.Hosts[i]
+
+		err := os.Link(tmp.Name(), p.leasePath(h.Name))
+		if err == nil {
+			return h, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+	}
+
+	return nil, ErrNoFreeHost
+}
+
+// Release returns the host leased to the machine of the store to the pool.
+func (p *Pool) Release(hostName, machine, storePath string) error {
+	lease, err := p.readLease(hostName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if lease.Machine != machine || lease.StorePath != storePath {
+		return fmt.Errorf("Host %s is leased to machine %s of %s", hostName, lease.Machine, lease.StorePath)
+	}
+
+	if err := os.Remove(p.leasePath(hostName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (p *Pool) readLease(hostName string) (*Lease, error) {
+	data, err := ioutil.ReadFile(p.leasePath(hostName))
+	if err != nil {
+		return nil, err
+	}
+
+	lease := &Lease{}
+	if err := json.Unmarshal(data, lease); err != nil {
+		return nil, fmt.Errorf("Invalid lease of host %s: %s", hostName, err)
+	}
+	lease.Host = hostName
+
+	return lease, nil
+}
+
+// Leases returns the leases of the hosts of the inventory, by host name.
+func (p *Pool) Leases() (map[string]*Lease, error) {
+	leases := map[string]*Lease{}
+
+	for _, h := range p.Inventory.Hosts {
+		lease, err := p.readLease(h.Name)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		leases[h.Name] = lease
+	}
+
+	return leases, nil
+}
@@ -0,0 +1,176 @@
+package hostpool
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeInventory(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "hostpool-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "inventory.json")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+const testInventory = `{
+	"ssh_user": "builder",
+	"ssh_key": "keys/id_rsa",
+	"hosts": [
+		{"name": "builder-01", "address": "10.0.0.11"},
+		{"name": "builder-02", "address": "10.0.0.12", "ssh_user": "root", "ssh_port": 2222, "ssh_key": "/etc/keys/builder-02", "engine_port": 2377}
+	]
+}`
+
+func TestLoadInventory(t *testing.T) {
+	path := writeInventory(t, testInventory)
+
+	inventory, err := LoadInventory(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Host{
+		{
+			Name:       "builder-01",
+			Address:    "10.0.0.11",
+			SSHUser:    "builder",
+			SSHPort:    22,
+			SSHKey:     filepath.Join(filepath.Dir(path), "keys/id_rsa"),
+			EnginePort: 2376,
+		},
+		{
+			Name:       "builder-02",
+			Address:    "10.0.0.12",
+			SSHUser:    "root",
+			SSHPort:    2222,
+			SSHKey:     "/etc/keys/builder-02",
+			EnginePort: 2377,
+		},
+	}, inventory.Hosts)
+}
+
+func TestLoadInventoryInvalid(t *testing.T) {
+	for content, expected := range map[string]string{
+		`{"hosts": []}`: "no hosts",
+		`{"hosts": [{"name": "../builder", "address": "10.0.0.11"}]}`:                                           `invalid host name "../builder"`,
+		`{"hosts": [{"name": "builder", "address": "10.0.0.11"}, {"name": "builder", "address": "10.0.0.12"}]}`: "host builder is listed twice",
+		`{"hosts": [{"name": "builder"}]}`:                                                                      "host builder has no address",
+	} {
+		path := writeInventory(t, content)
+
+		_, err := LoadInventory(path)
+
+		assert.EqualError(t, err, fmt.Sprintf("Invalid inventory %s: %s", path, expected))
+	}
+}
+
+func TestLeaseAndRelease(t *testing.T) {
+	pool, err := Open(writeInventory(t, testInventory), "")
+	assert.NoError(t, err)
+
+	first, err := pool.Lease("first", "/store")
+	assert.NoError(t, err)
+	assert.Equal(t, "builder-01", first.Name)
+
+	second, err := pool.Lease("second", "/store")
+	assert.NoError(t, err)
+	assert.Equal(t, "builder-02", second.Name)
+
+	_, err = pool.Lease("third", "/store")
+	assert.Equal(t, ErrNoFreeHost, err)
+
+	leases, err := pool.Leases()
+	assert.NoError(t, err)
+	assert.Len(t, leases, 2)
+	assert.Equal(t, "first", leases["builder-01"].Machine)
+	assert.Equal(t, "/store", leases["builder-01"].StorePath)
+	assert.Equal(t, "second", leases["builder-02"].Machine)
+
+	assert.NoError(t, pool.Release("builder-01", "first", "/store"))
+
+	third, err := pool.Lease("third", "/store")
+	assert.NoError(t, err)
+	assert.Equal(t, "builder-01", third.Name)
+}
+
+func TestReleaseLeasedToAnotherMachine(t *testing.T) {
+	pool, err := Open(writeInventory(t, testInventory), "")
+	assert.NoError(t, err)
+	_, err = pool.Lease("first", "/store")
+	assert.NoError(t, err)
+
+	err = pool.Release("builder-01", "first", "/other/store")
+
+	assert.EqualError(t, err, "Host builder-01 is leased to machine first of /store")
+
+	leases, err := pool.Leases()
+	assert.NoError(t, err)
+	assert.Len(t, leases, 1)
+}
+
+func TestReleaseFreeHost(t *testing.T) {
+	pool, err := Open(writeInventory(t, testInventory), "")
+	assert.NoError(t, err)
+
+	err = pool.Release("builder-01", "first", "/store")
+
+	assert.NoError(t, err)
+}
+
+func TestConcurrentLeases(t *testing.T) {
+	path := writeInventory(t, testInventory)
+
+	var wg sync.WaitGroup
+	hosts := make(chan string, 10)
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Each creation reads the inventory on its own.
+			pool, err := Open(path, "")
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			h, err := pool.Lease(fmt.Sprintf("machine-%d", i), "/store")
+			if err != nil {
+				errs <- err
+				return
+			}
+			hosts <- h.Name
+		}(i)
+	}
+	wg.Wait()
+	close(hosts)
+	close(errs)
+
+	leased := []string{}
+	for h := range hosts {
+		leased = append(leased, h)
+	}
+	assert.ElementsMatch(t, []string{"builder-01", "builder-02"}, leased)
+
+	for err := range errs {
+		assert.Equal(t, ErrNoFreeHost, err)
+	}
+
+	// Only the leases are left in the directory.
+	files, err := ioutil.ReadDir(DefaultLeaseDir(path))
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+}
@@ -1,6 +1,8 @@
 package libmachine
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 
@@ -30,6 +32,7 @@ type API interface {
 	io.Closer
 	NewHost(driverName string, rawDriver []byte) (*host.Host, error)
 	Create(h *host.Host) error
+	CreateContext(ctx context.Context, h *host.Host) error
 	persist.Store
 	GetMachinesDir() string
 }
@@ -40,6 +43,12 @@ type Client struct {
 	SSHClientType  ssh.ClientType
 	GithubAPIToken string
 	*persist.Filestore
+	// Store keeps the machines, in the Filestore when it is nil. The
+	// machine directories stay in the Filestore in any case.
+	Store persist.Store
+	// Registry holds the drivers run in-process. The drivers it doesn't
+	// know are run as plugins.
+	Registry            *drivers.Registry
 	clientDriverFactory rpcdriver.RPCClientDriverFactory
 }
 
@@ -49,12 +58,29 @@ func NewClient(storePath, certsDir string) *Client {
 		IsDebug:             false,
 		SSHClientType:       ssh.External,
 		Filestore:           persist.NewFilestore(storePath, certsDir, certsDir),
+		Registry:            drivers.DefaultRegistry,
 		clientDriverFactory: rpcdriver.NewRPCClientDriverFactory(),
 	}
 }
 
+// newDriver returns the driver of a machine, run in-process when it is in
+// the registry and as a plugin otherwise.
+func (api *Client) newDriver(driverName string, rawDriver []byte) (drivers.Driver, error) {
+	if api.Registry != nil {
+		if factory, ok := api.Registry.Lookup(driverName); ok {
+			d := factory("", "")
+			if err := json.Unmarshal(rawDriver, d); err != nil {
+				return nil, fmt.Errorf("Error loading the config of the %q driver: %s", driverName, err)
+			}
+			return d, nil
+		}
+	}
+
+	return api.clientDriverFactory.NewRPCClientDriver(driverName, rawDriver)
+}
+
 func (api *Client) NewHost(driverName string, rawDriver []byte) (*host.Host, error) {
-	driver, err := api.clientDriverFactory.NewRPCClientDriver(driverName, rawDriver)
+	driver, err := api.newDriver(driverName, rawDriver)
 	if err != nil {
 		return nil, err
 	}
@@ -88,13 +114,36 @@ func (api *Client) NewHost(driverName string, rawDriver []byte) (*host.Host, err
 	}, nil
 }
 
+func (api *Client) store() persist.Store {
+	if api.Store != nil {
+		return api.Store
+	}
+	return api.Filestore
+}
+
+func (api *Client) Exists(name string) (bool, error) {
+	return api.store().Exists(name)
+}
+
+func (api *Client) List() ([]string, error) {
+	return api.store().List()
+}
+
+func (api *Client) Remove(name string) error {
+	return api.store().Remove(name)
+}
+
+func (api *Client) Save(h *host.Host) error {
+	return api.store().Save(h)
+}
+
 func (api *Client) Load(name string) (*host.Host, error) {
-	h, err := api.Filestore.Load(name)
+	h, err := api.store().Load(name)
 	if err != nil {
 		return nil, err
 	}
 
-	d, err := api.clientDriverFactory.NewRPCClientDriver(h.DriverName, h.RawDriver)
+	d, err := api.newDriver(h.DriverName, h.RawDriver)
 	if err != nil {
 		// Not being able to find a driver binary is a "known error"
 		if _, ok := err.(localbinary.ErrPluginBinaryNotFound); ok {
@@ -116,29 +165,61 @@ func (api *Client) Load(name string) (*host.Host, error) {
 // Create is the wrapper method which covers all of the boilerplate around
 // actually creating, provisioning, and persisting an instance in the store.
 func (api *Client) Create(h *host.Host) error {
-	if err := cert.BootstrapCertificates(h.AuthOptions()); err != nil {
-		return fmt.Errorf("Error generating certificates: %s", err)
+	return api.CreateContext(context.Background(), h)
+}
+
+// CreateContext behaves like Create, but aborts the creation as soon as ctx
+// is done. The host is saved to the store in any case, so that the resources
+// which were already allocated can be removed afterwards.
+func (api *Client) CreateContext(ctx context.Context, h *host.Host) error {
+	// Other processes are kept away from the machine while it is created.
+	// Resumed machines are locked right away, new ones once they are in
+	// the store.
+	unlock, err := h.Lock(ctx, "create")
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
-	log.Info("Running pre-create checks...")
+	if !h.StageCompleted(host.StageCertificates) {
+		if err := cert.BootstrapCertificates(h.AuthOptions()); err != nil {
+			return fmt.Errorf("Error generating certificates: %s", err)
+		}
+		h.CompleteStage(host.StageCertificates)
+	}
+
+	if !h.StageCompleted(host.StagePreCreateCheck) {
+		log.Info("Running pre-create checks...")
 
-	if err := h.Driver.PreCreateCheck(); err != nil {
-		return mcnerror.ErrDuringPreCreate{
-			Cause: err,
+		if err := h.Driver.PreCreateCheck(); err != nil {
+			return mcnerror.ErrDuringPreCreate{
+				Cause: err,
+			}
 		}
+		h.CompleteStage(host.StagePreCreateCheck)
+	}
+
+	if err := h.RecordSecretFields(h.Driver.GetCreateFlags()); err != nil {
+		return fmt.Errorf("Error finding the secrets of the driver: %s", err)
 	}
 
 	if err := api.Save(h); err != nil {
 		return fmt.Errorf("Error saving host to store before attempting creation: %s", err)
 	}
 
+	unlockNew, err := h.Lock(ctx, "create")
+	if err != nil {
+		return err
+	}
+	defer unlockNew()
+
 	log.Info("Creating machine...")
 
-	if err := api.performCreate(h); err != nil {
+	if err := api.performCreate(ctx, h); err != nil {
 		// Try to save machine when Create fails, it can store some critical information like DropletID
 		api.Save(h)
 
-		return fmt.Errorf("Error creating machine: %s", err)
+		return err
 	}
 
 	log.Debug("Reticulating splines...")
@@ -146,46 +227,106 @@ func (api *Client) Create(h *host.Host) error {
 	return nil
 }
 
-func (api *Client) performCreate(h *host.Host) error {
-	if err := h.Driver.Create(); err != nil {
-		return fmt.Errorf("Error in driver during machine creation: %s", err)
+func (api *Client) performCreate(ctx context.Context, h *host.Host) error {
+	if !h.StageCompleted(host.StageDriverCreate) {
+		if err := drivers.CreateContext(ctx, h.Driver); err != nil {
+			return mcnerror.ErrDuringCreate{
+				Cause: fmt.Errorf("Error in driver during machine creation: %s", err),
+			}
+		}
+		h.CompleteStage(host.StageDriverCreate)
+	}
+
+	// From here on the machine exists at the provider, let the caller know
+	// so that it can be cleaned up if we don't get any further.
+	allocated := func(err error) error {
+		return mcnerror.ErrDuringCreate{
+			Cause:     err,
+			Allocated: true,
+		}
+	}
+
+	// Each stage is saved as soon as it completes, so that a creation
+	// which stops halfway can be resumed from the store.
+	completeStage := func(stage host.CreateStage) error {
+		h.CompleteStage(stage)
+		if err := api.Save(h); err != nil {
+			return allocated(fmt.Errorf("Error saving host to store after stage %s: %s", stage, err))
+		}
+		return nil
 	}
 
 	if err := api.Save(h); err != nil {
-		return fmt.Errorf("Error saving host to store after attempting creation: %s", err)
+		return allocated(fmt.Errorf("Error saving host to store after attempting creation: %s", err))
 	}
 
 	// TODO: Not really a fan of just checking "none" or "ci-test" here.
 	if h.Driver.DriverName() == "none" || h.Driver.DriverName() == "ci-test" {
-		return nil
+		h.CompletedStages = nil
+		return api.Save(h)
 	}
 
-	log.Info("Waiting for machine to be running, this may take a few minutes...")
-	if err := mcnutils.WaitFor(drivers.MachineInState(h.Driver, state.Running)); err != nil {
-		return fmt.Errorf("Error waiting for machine to be running: %s", err)
+	if !h.StageCompleted(host.StageWaitForRunning) {
+		log.Info("Waiting for machine to be running, this may take a few minutes...")
+		if err := mcnutils.WaitForContext(ctx, drivers.MachineInState(h.Driver, state.Running)); err != nil {
+			return allocated(fmt.Errorf("Error waiting for machine to be running: %s", err))
+		}
+		if err := completeStage(host.StageWaitForRunning); err != nil {
+			return err
+		}
 	}
 
-	log.Info("Detecting operating system of created instance...")
-	provisioner, err := provision.DetectProvisioner(h.Driver)
-	if err != nil {
-		return fmt.Errorf("Error detecting OS: %s", err)
-	}
+	// The detected provisioner isn't persisted, so detecting it is part of
+	// the provisioning stage.
+	if !h.StageCompleted(host.StageProvision) {
+		log.Info("Detecting operating system of created instance...")
+		provisioner, err := provision.DetectProvisionerContext(ctx, h.Driver)
+		if err != nil {
+			return allocated(fmt.Errorf("Error detecting OS: %s", err))
+		}
 
-	log.Infof("Provisioning with %s...", provisioner.String())
-	if err := provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
-		return fmt.Errorf("Error running provisioning: %s", err)
+		log.Infof("Provisioning with %s...", provisioner.String())
+		if err := provisioner.Provision(*h.HostOptions.SwarmOptions, *h.HostOptions.AuthOptions, *h.HostOptions.EngineOptions); err != nil {
+			return allocated(fmt.Errorf("Error running provisioning: %s", err))
+		}
+		if err := completeStage(host.StageProvision); err != nil {
+			return err
+		}
 	}
 
 	// We should check the connection to docker here
 	log.Info("Checking connection to Docker...")
-	if _, _, err = check.DefaultConnChecker.Check(h, false); err != nil {
-		return fmt.Errorf("Error checking the host: %s", err)
+	if err := checkConnectionContext(ctx, h); err != nil {
+		return allocated(fmt.Errorf("Error checking the host: %s", err))
+	}
+
+	// Fully created hosts don't need to carry their stages around.
+	h.CompletedStages = nil
+	if err := api.Save(h); err != nil {
+		return allocated(fmt.Errorf("Error saving host to store after creation: %s", err))
 	}
 
 	log.Info("Docker is up and running!")
 	return nil
 }
 
+// checkConnectionContext checks the connection to the Docker daemon of h,
+// giving up as soon as ctx is done.
+func checkConnectionContext(ctx context.Context, h *host.Host) error {
+	errCh := make(chan error, 1)
+	go func() {
+		_, _, err := check.DefaultConnChecker.Check(h, false)
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (api *Client) Close() error {
 	return api.clientDriverFactory.Close()
 }
@@ -0,0 +1,248 @@
+package libmachine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/drivers/fakedriver"
+	"github.com/docker/machine/libmachine/auth"
+	"github.com/docker/machine/libmachine/check"
+	"github.com/docker/machine/libmachine/drivers"
+	"github.com/docker/machine/libmachine/engine"
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/provision"
+	"github.com/docker/machine/libmachine/state"
+	"github.com/docker/machine/libmachine/swarm"
+	"github.com/docker/machine/libmachine/version"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingDriver struct {
+	*fakedriver.Driver
+	createErr       error
+	creates         int
+	preCreateChecks int
+}
+
+func (d *countingDriver) PreCreateCheck() error {
+	d.preCreateChecks++
+	return nil
+}
+
+func (d *countingDriver) Create() error {
+	d.creates++
+	return d.createErr
+}
+
+type okConnChecker struct{}
+
+func (okConnChecker) Check(h *host.Host, swarm bool) (string, *auth.Options, error) {
+	return "tcp://1.2.3.4:2376", h.AuthOptions(), nil
+}
+
+type failingProvisioner struct {
+	*provision.FakeProvisioner
+}
+
+func (p *failingProvisioner) Provision(swarmOptions swarm.Options, authOptions auth.Options, engineOptions engine.Options) error {
+	return errors.New("provisioning failed")
+}
+
+func newTestClient(t *testing.T) (*Client, func()) {
+	storePath, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := NewClient(storePath, filepath.Join(storePath, "certs"))
+
+	return api, func() { os.RemoveAll(storePath) }
+}
+
+func newTestHost(api *Client, d drivers.Driver) *host.Host {
+	certDir := filepath.Join(api.GetMachinesDir(), "..", "certs")
+
+	return &host.Host{
+		ConfigVersion: version.ConfigVersion,
+		Name:          "test",
+		Driver:        d,
+		DriverName:    d.DriverName(),
+		HostOptions: &host.Options{
+			EngineOptions: &engine.Options{},
+			SwarmOptions:  &swarm.Options{},
+			AuthOptions: &auth.Options{
+				CertDir:          certDir,
+				CaCertPath:       filepath.Join(certDir, "ca.pem"),
+				CaPrivateKeyPath: filepath.Join(certDir, "ca-key.pem"),
+				ClientCertPath:   filepath.Join(certDir, "cert.pem"),
+				ClientKeyPath:    filepath.Join(certDir, "key.pem"),
+				StorePath:        filepath.Join(api.GetMachinesDir(), "test"),
+			},
+		},
+	}
+}
+
+func newCountingDriver(machineState state.State) *countingDriver {
+	return &countingDriver{
+		Driver: &fakedriver.Driver{
+			BaseDriver: &drivers.BaseDriver{},
+			MockState:  machineState,
+		},
+	}
+}
+
+func TestCreateDriverFailureIsNotAllocated(t *testing.T) {
+	api, cleanup := newTestClient(t)
+	defer cleanup()
+
+	d := newCountingDriver(state.Running)
+	d.createErr = errors.New("quota exceeded")
+
+	err := api.CreateContext(context.Background(), newTestHost(api, d))
+
+	assert.IsType(t, mcnerror.ErrDuringCreate{}, err)
+	assert.False(t, err.(mcnerror.ErrDuringCreate).Allocated)
+}
+
+func TestCreateProvisioningFailureIsAllocated(t *testing.T) {
+	defer provision.SetDetector(&provision.StandardDetector{})
+	provision.SetDetector(&provision.FakeDetector{
+		Provisioner: &failingProvisioner{&provision.FakeProvisioner{}},
+	})
+
+	api, cleanup := newTestClient(t)
+	defer cleanup()
+
+	err := api.CreateContext(context.Background(), newTestHost(api, newCountingDriver(state.Running)))
+
+	assert.IsType(t, mcnerror.ErrDuringCreate{}, err)
+	assert.True(t, err.(mcnerror.ErrDuringCreate).Allocated)
+}
+
+func TestCreateSkipsCompletedStages(t *testing.T) {
+	defer provision.SetDetector(&provision.StandardDetector{})
+	provision.SetDetector(&provision.FakeDetector{Provisioner: &provision.FakeProvisioner{}})
+	defer func(checker check.ConnChecker) { check.DefaultConnChecker = checker }(check.DefaultConnChecker)
+	check.DefaultConnChecker = okConnChecker{}
+
+	api, cleanup := newTestClient(t)
+	defer cleanup()
+
+	d := newCountingDriver(state.Running)
+	h := newTestHost(api, d)
+	h.CompletedStages = []host.CreateStage{
+		host.StageCertificates,
+		host.StagePreCreateCheck,
+		host.StageDriverCreate,
+	}
+
+	err := api.CreateContext(context.Background(), h)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, d.preCreateChecks)
+	assert.Equal(t, 0, d.creates)
+	assert.Empty(t, h.CompletedStages)
+}
+
+func TestCreateClearsStagesOnceCreated(t *testing.T) {
+	defer provision.SetDetector(&provision.StandardDetector{})
+	provision.SetDetector(&provision.FakeDetector{Provisioner: &provision.FakeProvisioner{}})
+	defer func(checker check.ConnChecker) { check.DefaultConnChecker = checker }(check.DefaultConnChecker)
+	check.DefaultConnChecker = okConnChecker{}
+
+	api, cleanup := newTestClient(t)
+	defer cleanup()
+
+	d := newCountingDriver(state.Running)
+
+	err := api.CreateContext(context.Background(), newTestHost(api, d))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, d.preCreateChecks)
+	assert.Equal(t, 1, d.creates)
+
+	saved, err := api.Load("test")
+	assert.NoError(t, err)
+	assert.False(t, saved.IsPartiallyCreated())
+	assert.Empty(t, saved.CompletedStages)
+}
+
+func newFakeDriver(machineName, storePath string) drivers.Driver {
+	return &fakedriver.Driver{
+		BaseDriver: &drivers.BaseDriver{
+			MachineName: machineName,
+			StorePath:   storePath,
+		},
+	}
+}
+
+// newRegistryClient returns a client running the fake driver in-process.
+func newRegistryClient(t *testing.T) (*Client, string, func()) {
+	api, cleanup := newTestClient(t)
+
+	driverName := newFakeDriver("", "").DriverName()
+	api.Registry = drivers.NewRegistry()
+	api.Registry.Register(driverName, newFakeDriver)
+
+	return api, driverName, cleanup
+}
+
+func TestNewHostUsesRegistry(t *testing.T) {
+	api, driverName, cleanup := newRegistryClient(t)
+	defer cleanup()
+
+	rawDriver, err := json.Marshal(&fakedriver.Driver{
+		MockName:  "in-process",
+		MockState: state.Running,
+		MockIP:    "1.2.3.4",
+	})
+	assert.NoError(t, err)
+
+	h, err := api.NewHost(driverName, rawDriver)
+
+	assert.NoError(t, err)
+	assert.IsType(t, &fakedriver.Driver{}, h.Driver)
+	assert.Equal(t, "in-process", h.Name)
+
+	ip, err := h.Driver.GetIP()
+	assert.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", ip)
+}
+
+func TestNewHostInvalidConfig(t *testing.T) {
+	api, driverName, cleanup := newRegistryClient(t)
+	defer cleanup()
+
+	_, err := api.NewHost(driverName, []byte(`{"MockName":`))
+
+	assert.Error(t, err)
+}
+
+func TestLoadUsesRegistry(t *testing.T) {
+	api, driverName, cleanup := newRegistryClient(t)
+	defer cleanup()
+
+	rawDriver, err := json.Marshal(&fakedriver.Driver{
+		MockName:  "test",
+		MockState: state.Stopped,
+	})
+	assert.NoError(t, err)
+
+	h, err := api.NewHost(driverName, rawDriver)
+	assert.NoError(t, err)
+	assert.NoError(t, api.Save(h))
+
+	loaded, err := api.Load("test")
+
+	assert.NoError(t, err)
+	assert.IsType(t, &fakedriver.Driver{}, loaded.Driver)
+
+	s, err := loaded.Driver.GetState()
+	assert.NoError(t, err)
+	assert.Equal(t, state.Stopped, s)
+}
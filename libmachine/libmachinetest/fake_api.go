@@ -1,6 +1,9 @@
 package libmachinetest
 
 import (
+	"context"
+	"sync"
+
 	"github.com/docker/machine/libmachine"
 	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/host"
@@ -10,6 +13,16 @@ import (
 
 type FakeAPI struct {
 	Hosts []*host.Host
+
+	// CreateError is returned by Create and CreateContext.
+	CreateError error
+
+	// MachinesDir is returned by GetMachinesDir.
+	MachinesDir string
+
+	// hostsLock guards Hosts against the commands acting on several
+	// machines at the same time.
+	hostsLock sync.Mutex
 }
 
 func (api *FakeAPI) NewPluginDriver(string, []byte) (drivers.Driver, error) {
@@ -25,10 +38,17 @@ func (api *FakeAPI) NewHost(driverName string, rawDriver []byte) (*host.Host, er
 }
 
 func (api *FakeAPI) Create(h *host.Host) error {
-	return nil
+	return api.CreateError
+}
+
+func (api *FakeAPI) CreateContext(ctx context.Context, h *host.Host) error {
+	return api.CreateError
 }
 
 func (api *FakeAPI) Exists(name string) (bool, error) {
+	api.hostsLock.Lock()
+	defer api.hostsLock.Unlock()
+
 	for _, host := range api.Hosts {
 		if name == host.Name {
 			return true, nil
@@ -43,6 +63,9 @@ func (api *FakeAPI) List() ([]string, error) {
 }
 
 func (api *FakeAPI) Load(name string) (*host.Host, error) {
+	api.hostsLock.Lock()
+	defer api.hostsLock.Unlock()
+
 	for _, host := range api.Hosts {
 		if name == host.Name {
 			return host, nil
@@ -55,6 +78,9 @@ func (api *FakeAPI) Load(name string) (*host.Host, error) {
 }
 
 func (api *FakeAPI) Remove(name string) error {
+	api.hostsLock.Lock()
+	defer api.hostsLock.Unlock()
+
 	newHosts := []*host.Host{}
 
 	for _, host := range api.Hosts {
@@ -72,8 +98,8 @@ func (api *FakeAPI) Save(host *host.Host) error {
 	return nil
 }
 
-func (api FakeAPI) GetMachinesDir() string {
-	return ""
+func (api *FakeAPI) GetMachinesDir() string {
+	return api.MachinesDir
 }
 
 func State(api libmachine.API, name string) state.State {
@@ -0,0 +1,227 @@
+// Package lock implements advisory locks on machine directories, so that
+// two processes don't change the same machine at the same time.
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/docker/machine/libmachine/log"
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+const fileName = "machine.lock"
+
+var (
+	pollInterval = 500 * time.Millisecond
+	processAlive = IsProcessAlive
+)
+
+// Holder describes the process holding a lock.
+type Holder struct {
+	PID       int
+	Operation string
+	Since     time.Time
+}
+
+// Lock is a lock held on a machine directory.
+type Lock struct {
+	path string
+}
+
+// Acquire takes the lock of the machine whose files are in dir, on behalf of
+// the given operation. If another live process holds it, an
+// mcnerror.ErrHostBusy is returned, unless wait is set in which case Acquire
+// waits until the lock is released or ctx is done. Locks left behind by
+// processes that died are cleared.
+//
+// Locks aren't reentrant: a process acquiring a lock it already holds finds
+// the machine busy, see host.Host.Lock for sharing it between operations.
+func Acquire(ctx context.Context, name, dir, operation string, wait bool) (*Lock, error) {
+	return AcquireFile(ctx, name, filepath.Join(dir, fileName), operation, wait)
+}
+
+// AcquireFile behaves like Acquire for a lock kept in the file at path,
+// guarding something else than a machine directory.
+func AcquireFile(ctx context.Context, name, path, operation string, wait bool) (*Lock, error) {
+	holder := Holder{
+		PID:       os.Getpid(),
+		Operation: operation,
+		Since:     time.Now().UTC(),
+	}
+
+	waiting := false
+	for {
+		current, err := tryAcquire(path, holder)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil {
+			return &Lock{path: path}, nil
+		}
+
+		busy := mcnerror.ErrHostBusy{
+			Name:      name,
+			PID:       current.PID,
+			Operation: current.Operation,
+		}
+		if !wait {
+			return nil, busy
+		}
+
+		if !waiting {
+			log.Infof("%s, waiting for it to be released...", busy)
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Release releases the lock.
+func (l *Lock) Release() error {
+	// The lock is gone with the machine directory once the machine is removed.
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// tryAcquire creates the lock file at path for holder. When the lock is held
+// by another live process, its holder is returned.
+func tryAcquire(path string, holder Holder) (*Holder, error) {
+	data, err := json.Marshal(holder)
+	if err != nil {
+		return nil, err
+	}
+
+	// The lock file is written aside and linked into place, so that it is
+	// never seen half-written and the link fails if it already exists.
+	tmp, err := writeTemp(path, data)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+
+	for {
+		err := os.Link(tmp, path)
+		if err == nil {
+			return nil, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		current, err := readHolder(path)
+		if os.IsNotExist(err) {
+			// Released in the meantime.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if processAlive(current.PID) {
+			return current, nil
+		}
+
+		log.Debugf("Clearing stale lock %s held by pid %d running '%s'", path, current.PID, current.Operation)
+		current, err = clearStale(path, *current)
+		if err != nil {
+			return nil, err
+		}
+		if current != nil {
+			return current, nil
+		}
+	}
+}
+
+// clearStale removes the stale lock at path. It is moved away first so that
+// a lock another process took in the meantime can be put back instead of
+// being removed. If it can't be put back because yet another process took
+// the lock, the holder of the displaced lock is returned: the machine is
+// busy.
+func clearStale(path string, stale Holder) (*Holder, error) {
+	moved, err := tempName(path + ".stale")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(path, moved); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer os.Remove(moved)
+
+	current, err := readHolder(moved)
+	if err != nil || (current.PID == stale.PID && current.Since.Equal(stale.Since)) {
+		return nil, nil
+	}
+
+	if err := os.Link(moved, path); err != nil {
+		if os.IsExist(err) {
+			return current, nil
+		}
+		return nil, err
+	}
+
+	return current, nil
+}
+
+// writeTemp writes data to a new file next to path, with a random suffix
+// so that several callers of the same process don't collide.
+func writeTemp(path string, data []byte) (string, error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// tempName returns the name of a file next to path which doesn't exist yet.
+func tempName(path string) (string, error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+
+	return f.Name(), os.Remove(f.Name())
+}
+
+func readHolder(path string) (*Holder, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	holder := &Holder{}
+	if err := json.Unmarshal(data, holder); err != nil {
+		return nil, fmt.Errorf("Error reading lock %s: %s", path, err)
+	}
+
+	return holder, nil
+}
@@ -0,0 +1,132 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireBusy(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "lock")
+	defer os.RemoveAll(dir)
+
+	l, err := Acquire(context.Background(), "foo", dir, "provision", false)
+	assert.NoError(t, err)
+	defer l.Release()
+
+	_, err = Acquire(context.Background(), "foo", dir, "stop", false)
+
+	assert.Equal(t, mcnerror.ErrHostBusy{Name: "foo", PID: os.Getpid(), Operation: "provision"}, err)
+	assert.EqualError(t, err, `Machine "foo" busy, held by pid `+strconv.Itoa(os.Getpid())+` running 'provision'`)
+}
+
+func TestAcquireAfterRelease(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "lock")
+	defer os.RemoveAll(dir)
+
+	l, err := Acquire(context.Background(), "foo", dir, "provision", false)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Release())
+
+	l, err = Acquire(context.Background(), "foo", dir, "stop", false)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Release())
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Empty(t, files)
+}
+
+func TestAcquireClearsStaleLock(t *testing.T) {
+	defer func(alive func(int) bool) { processAlive = alive }(processAlive)
+	processAlive = func(pid int) bool { return pid != 4242 }
+
+	dir, _ := ioutil.TempDir("", "lock")
+	defer os.RemoveAll(dir)
+
+	data, _ := json.Marshal(Holder{PID: 4242, Operation: "provision"})
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, fileName), data, 0600))
+
+	l, err := Acquire(context.Background(), "foo", dir, "stop", false)
+	assert.NoError(t, err)
+	defer l.Release()
+
+	holder, err := readHolder(filepath.Join(dir, fileName))
+	assert.NoError(t, err)
+	assert.Equal(t, os.Getpid(), holder.PID)
+	assert.Equal(t, "stop", holder.Operation)
+}
+
+func TestClearStalePutsBackReplacedLock(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "lock")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, fileName)
+	live := Holder{PID: 1, Operation: "provision", Since: time.Now().UTC()}
+	data, _ := json.Marshal(live)
+	assert.NoError(t, ioutil.WriteFile(path, data, 0600))
+
+	current, err := clearStale(path, Holder{PID: 4242, Operation: "stop"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, current.PID)
+	holder, err := readHolder(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "provision", holder.Operation)
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	defer func(interval time.Duration) { pollInterval = interval }(pollInterval)
+	pollInterval = 10 * time.Millisecond
+
+	dir, _ := ioutil.TempDir("", "lock")
+	defer os.RemoveAll(dir)
+
+	l, err := Acquire(context.Background(), "foo", dir, "provision", false)
+	assert.NoError(t, err)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		l.Release()
+	}()
+
+	l, err = Acquire(context.Background(), "foo", dir, "stop", true)
+	assert.NoError(t, err)
+	assert.NoError(t, l.Release())
+}
+
+func TestAcquireWaitStopsWhenContextDone(t *testing.T) {
+	defer func(interval time.Duration) { pollInterval = interval }(pollInterval)
+	pollInterval = 10 * time.Millisecond
+
+	dir, _ := ioutil.TempDir("", "lock")
+	defer os.RemoveAll(dir)
+
+	l, err := Acquire(context.Background(), "foo", dir, "provision", false)
+	assert.NoError(t, err)
+	defer l.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = Acquire(ctx, "foo", dir, "stop", true)
+
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestReleaseAfterMachineRemoved(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "lock")
+
+	l, err := Acquire(context.Background(), "foo", dir, "rm", false)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.RemoveAll(dir))
+	assert.NoError(t, l.Release())
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package lock
+
+import "syscall"
+
+// IsProcessAlive tells whether the process with the given PID is running.
+func IsProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	// Signal 0 only checks that the process exists. EPERM means it exists
+	// but belongs to another user.
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
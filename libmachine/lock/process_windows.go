@@ -0,0 +1,19 @@
+package lock
+
+import "os"
+
+// IsProcessAlive tells whether the process with the given PID is running.
+func IsProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	// On Windows, FindProcess fails if there is no such process.
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+
+	return true
+}
@@ -72,3 +72,9 @@ func SetErrWriter(err io.Writer) {
 func History() []string {
 	return stripSecrets(logger.History())
 }
+
+// SetLogger replaces the logger the messages go through, e.g. by the driver
+// plugins which send them to the client.
+func SetLogger(l MachineLogger) {
+	logger = l
+}
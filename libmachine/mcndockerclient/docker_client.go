@@ -13,13 +13,21 @@ import (
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
-// DockerClient creates a docker client for a given host.
+// DockerClient creates a docker client for a given host. The connection
+// isn't secured when the host has no auth options, e.g. for a local socket.
 func DockerClient(dockerHost DockerHost) (*client.Client, error) {
 	url, err := dockerHost.URL()
 	if err != nil {
 		return nil, err
 	}
 
+	if dockerHost.AuthOptions() == nil {
+		return client.NewClientWithOpts(
+			client.WithHost(url),
+			client.WithAPIVersionNegotiation(),
+		)
+	}
+
 	tlsConfig, err := cert.ReadTLSConfig(url, dockerHost.AuthOptions())
 	if err != nil {
 		return nil, fmt.Errorf("Unable to read TLS config: %s", err)
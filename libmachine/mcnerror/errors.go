@@ -36,6 +36,44 @@ func (e ErrDuringPreCreate) Error() string {
 	return fmt.Sprintf("Error with pre-create check: %q", e.Cause)
 }
 
+// ErrDuringCreate is returned when a machine could not be created. Allocated
+// tells whether the driver had already created the machine at its provider
+// when the failure happened, in which case it is still there.
+type ErrDuringCreate struct {
+	Cause     error
+	Allocated bool
+}
+
+func (e ErrDuringCreate) Error() string {
+	return fmt.Sprintf("Error creating machine: %s", e.Cause)
+}
+
+// ErrHostBusy is returned when another process holds the lock of a machine.
+type ErrHostBusy struct {
+	Name      string
+	PID       int
+	Operation string
+}
+
+func (e ErrHostBusy) Error() string {
+	return fmt.Sprintf("Machine %q busy, held by pid %d running '%s'", e.Name, e.PID, e.Operation)
+}
+
+// ErrRevisionConflict is returned when a machine is saved to a shared store
+// after someone else changed it. Revision is the one the store is at, 0 if
+// the machine was removed.
+type ErrRevisionConflict struct {
+	Name     string
+	Revision int64
+}
+
+func (e ErrRevisionConflict) Error() string {
+	if e.Revision == 0 {
+		return fmt.Sprintf("Machine %q was removed from the store in the meantime", e.Name)
+	}
+	return fmt.Sprintf("Machine %q was changed in the store in the meantime (now at revision %d), load it again before changing it", e.Name, e.Revision)
+}
+
 type ErrHostAlreadyInState struct {
 	Name  string
 	State state.State
@@ -44,3 +82,46 @@ type ErrHostAlreadyInState struct {
 func (e ErrHostAlreadyInState) Error() string {
 	return fmt.Sprintf("Machine %q is already %s.", e.Name, strings.ToLower(e.State.String()))
 }
+
+// ErrorKind classifies the errors of the drivers, so that their callers can
+// tell e.g. a machine which doesn't exist anymore from a quota exceeded at
+// the provider, even when the driver runs in a plugin.
+type ErrorKind string
+
+const (
+	KindUnknown          ErrorKind = ""
+	KindNotFound         ErrorKind = "not-found"
+	KindAlreadyExists    ErrorKind = "already-exists"
+	KindQuotaExceeded    ErrorKind = "quota-exceeded"
+	KindPermissionDenied ErrorKind = "permission-denied"
+	KindInvalidConfig    ErrorKind = "invalid-config"
+	KindUnavailable      ErrorKind = "unavailable"
+	KindNotSupported     ErrorKind = "not-supported"
+	KindCancelled        ErrorKind = "cancelled"
+)
+
+// ErrDriver is an error of a driver, of a known kind.
+type ErrDriver struct {
+	Kind    ErrorKind
+	Message string
+}
+
+func (e ErrDriver) Error() string {
+	return e.Message
+}
+
+// Kind returns the kind of the error, KindUnknown if it has none.
+func Kind(err error) ErrorKind {
+	switch e := err.(type) {
+	case ErrDriver:
+		return e.Kind
+	case *ErrDriver:
+		return e.Kind
+	case interface {
+		ErrorKind() ErrorKind
+	}:
+		return e.ErrorKind()
+	}
+
+	return KindUnknown
+}
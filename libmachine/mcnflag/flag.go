@@ -12,6 +12,24 @@ type StringFlag struct {
 	Usage  string
 	EnvVar string
 	Value  string
+
+	// Sensitive flags hold secrets such as credentials, which are
+	// encrypted when the machine is stored and hidden by inspect.
+	Sensitive bool
+
+	// Required flags can't be left empty.
+	Required bool
+
+	// Enum lists the allowed values, any value is allowed when it is
+	// empty.
+	Enum []string
+
+	// Pattern is a regular expression the whole value must match.
+	Pattern string
+
+	// DeprecatedNames are former names of the flag, still accepted with a
+	// warning.
+	DeprecatedNames []string
 }
 
 // TODO: Could this be done more succinctly using embedding?
@@ -28,6 +46,20 @@ type StringSliceFlag struct {
 	Usage  string
 	EnvVar string
 	Value  []string
+
+	// Required flags must be given at least once.
+	Required bool
+
+	// Enum lists the allowed values, any value is allowed when it is
+	// empty.
+	Enum []string
+
+	// Pattern is a regular expression each whole value must match.
+	Pattern string
+
+	// DeprecatedNames are former names of the flag, still accepted with a
+	// warning.
+	DeprecatedNames []string
 }
 
 // TODO: Could this be done more succinctly using embedding?
@@ -44,6 +76,14 @@ type IntFlag struct {
 	Usage  string
 	EnvVar string
 	Value  int
+
+	// Min and Max bound the value when they are set, see Limit.
+	Min *int
+	Max *int
+
+	// DeprecatedNames are former names of the flag, still accepted with a
+	// warning.
+	DeprecatedNames []string
 }
 
 // TODO: Could this be done more succinctly using embedding?
@@ -59,6 +99,10 @@ type BoolFlag struct {
 	Name   string
 	Usage  string
 	EnvVar string
+
+	// DeprecatedNames are former names of the flag, still accepted with a
+	// warning.
+	DeprecatedNames []string
 }
 
 // TODO: Could this be done more succinctly using embedding?
@@ -0,0 +1,214 @@
+package mcnflag
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Limit returns a pointer to n, to set the Min or Max of an IntFlag.
+func Limit(n int) *int {
+	return &n
+}
+
+// Metadata is what is known of a flag, whatever its type.
+type Metadata struct {
+	Name            string
+	Type            string
+	Usage           string
+	EnvVar          string `json:",omitempty"`
+	Default         interface{}
+	Required        bool     `json:",omitempty"`
+	Enum            []string `json:",omitempty"`
+	Min             *int     `json:",omitempty"`
+	Max             *int     `json:",omitempty"`
+	Pattern         string   `json:",omitempty"`
+	DeprecatedNames []string `json:",omitempty"`
+	Sensitive       bool     `json:",omitempty"`
+}
+
+// MetadataOf returns the metadata of the flag. The flags of the driver
+// plugins come as pointers, the others as values.
+func MetadataOf(f Flag) Metadata {
+	switch f := f.(type) {
+	case *StringFlag:
+		return MetadataOf(*f)
+	case *StringSliceFlag:
+		return MetadataOf(*f)
+	case *IntFlag:
+		return MetadataOf(*f)
+	case *BoolFlag:
+		return MetadataOf(*f)
+	case StringFlag:
+		return Metadata{
+			Name:            f.Name,
+			Type:            "string",
+			Usage:           f.Usage,
+			EnvVar:          f.EnvVar,
+			Default:         f.Value,
+			Required:        f.Required,
+			Enum:            f.Enum,
+			Pattern:         f.Pattern,
+			DeprecatedNames: f.DeprecatedNames,
+			Sensitive:       f.Sensitive,
+		}
+	case StringSliceFlag:
+		return Metadata{
+			Name:            f.Name,
+			Type:            "string-slice",
+			Usage:           f.Usage,
+			EnvVar:          f.EnvVar,
+			Default:         f.Value,
+			Required:        f.Required,
+			Enum:            f.Enum,
+			Pattern:         f.Pattern,
+			DeprecatedNames: f.DeprecatedNames,
+		}
+	case IntFlag:
+		return Metadata{
+			Name:            f.Name,
+			Type:            "int",
+			Usage:           f.Usage,
+			EnvVar:          f.EnvVar,
+			Default:         f.Value,
+			Min:             f.Min,
+			Max:             f.Max,
+			DeprecatedNames: f.DeprecatedNames,
+		}
+	case BoolFlag:
+		return Metadata{
+			Name:            f.Name,
+			Type:            "bool",
+			Usage:           f.Usage,
+			EnvVar:          f.EnvVar,
+			Default:         false,
+			DeprecatedNames: f.DeprecatedNames,
+		}
+	}
+
+	return Metadata{
+		Name:    f.String(),
+		Default: f.Default(),
+	}
+}
+
+// Usage returns the usage of the flag followed by the constraints on its
+// value, as shown in the help.
+func Usage(f Flag) string {
+	m := MetadataOf(f)
+
+	constraints := []string{}
+	if m.Required {
+		constraints = append(constraints, "required")
+	}
+	if len(m.Enum) > 0 {
+		constraints = append(constraints, "one of "+strings.Join(m.Enum, ", "))
+	}
+	switch {
+	case m.Min != nil && m.Max != nil:
+		constraints = append(constraints, fmt.Sprintf("between %d and %d", *m.Min, *m.Max))
+	case m.Min != nil:
+		constraints = append(constraints, fmt.Sprintf("at least %d", *m.Min))
+	case m.Max != nil:
+		constraints = append(constraints, fmt.Sprintf("at most %d", *m.Max))
+	}
+	if m.Pattern != "" {
+		constraints = append(constraints, "matching "+m.Pattern)
+	}
+	for _, name := range m.DeprecatedNames {
+		constraints = append(constraints, "--"+name+" is deprecated")
+	}
+
+	if len(constraints) == 0 {
+		return m.Usage
+	}
+
+	return fmt.Sprintf("%s (%s)", m.Usage, strings.Join(constraints, "; "))
+}
+
+// ValidationError lists the flags whose values are invalid.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("Invalid driver options:\n  %s", strings.Join(e.Problems, "\n  "))
+}
+
+// Validate checks the values of the flags, keyed by their name, against their
+// metadata. All the problems found are returned in a *ValidationError.
+func Validate(flags []Flag, values map[string]interface{}) error {
+	problems := []string{}
+	for _, f := range flags {
+		problems = append(problems, validate(MetadataOf(f), values[f.String()])...)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+func validate(m Metadata, value interface{}) []string {
+	switch value := value.(type) {
+	case string:
+		if value == "" {
+			if m.Required {
+				return []string{fmt.Sprintf("--%s is required", m.Name)}
+			}
+			return nil
+		}
+		return validateString(m, value)
+	case []string:
+		if len(value) == 0 && m.Required {
+			return []string{fmt.Sprintf("--%s is required", m.Name)}
+		}
+		problems := []string{}
+		for _, v := range value {
+			problems = append(problems, validateString(m, v)...)
+		}
+		return problems
+	case int:
+		if m.Min != nil && value < *m.Min {
+			return []string{fmt.Sprintf("--%s must be at least %d, got %d", m.Name, *m.Min, value)}
+		}
+		if m.Max != nil && value > *m.Max {
+			return []string{fmt.Sprintf("--%s must be at most %d, got %d", m.Name, *m.Max, value)}
+		}
+	case nil:
+		if m.Required {
+			return []string{fmt.Sprintf("--%s is required", m.Name)}
+		}
+	}
+
+	return nil
+}
+
+func validateString(m Metadata, value string) []string {
+	if len(m.Enum) > 0 && !contains(m.Enum, value) {
+		return []string{fmt.Sprintf("--%s must be one of %s, got %q", m.Name, strings.Join(m.Enum, ", "), value)}
+	}
+
+	if m.Pattern != "" {
+		re, err := regexp.Compile("^(?:" + m.Pattern + ")$")
+		if err != nil {
+			return []string{fmt.Sprintf("--%s has an invalid pattern %q: %s", m.Name, m.Pattern, err)}
+		}
+		if !re.MatchString(value) {
+			return []string{fmt.Sprintf("--%s must match %s, got %q", m.Name, m.Pattern, value)}
+		}
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
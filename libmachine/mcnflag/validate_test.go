@@ -0,0 +1,63 @@
+package mcnflag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var validatedFlags = []Flag{
+	&StringFlag{Name: "token", Required: true},
+	&StringFlag{Name: "size", Enum: []string{"small", "large"}},
+	&StringFlag{Name: "region", Pattern: "[a-z]+[0-9]"},
+	&StringSliceFlag{Name: "tags", Pattern: "[a-z]+"},
+	&IntFlag{Name: "cpus", Min: Limit(1), Max: Limit(8)},
+	&BoolFlag{Name: "debug"},
+}
+
+func TestValidate(t *testing.T) {
+	err := Validate(validatedFlags, map[string]interface{}{
+		"token":  "secret",
+		"size":   "small",
+		"region": "nyc1",
+		"tags":   []string{"web", "db"},
+		"cpus":   8,
+		"debug":  true,
+	})
+	assert.NoError(t, err)
+
+	// The empty values of the optional flags aren't checked.
+	err = Validate(validatedFlags, map[string]interface{}{
+		"token": "secret",
+		"size":  "",
+		"cpus":  1,
+	})
+	assert.NoError(t, err)
+}
+
+func TestValidateReportsAllTheProblems(t *testing.T) {
+	err := Validate(validatedFlags, map[string]interface{}{
+		"token":  "",
+		"size":   "medium",
+		"region": "nyc1-2",
+		"tags":   []string{"web", "DB"},
+		"cpus":   0,
+	})
+
+	assert.Equal(t, &ValidationError{Problems: []string{
+		"--token is required",
+		`--size must be one of small, large, got "medium"`,
+		`--region must match [a-z]+[0-9], got "nyc1-2"`,
+		`--tags must match [a-z]+, got "DB"`,
+		"--cpus must be at least 1, got 0",
+	}}, err)
+	assert.EqualError(t, Validate(validatedFlags[:1], map[string]interface{}{}), "Invalid driver options:\n  --token is required")
+}
+
+func TestUsage(t *testing.T) {
+	assert.Equal(t, "Token (required)", Usage(StringFlag{Name: "token", Usage: "Token", Required: true}))
+	assert.Equal(t, "Size (one of small, large; --flavor is deprecated)", Usage(&StringFlag{Name: "size", Usage: "Size", Enum: []string{"small", "large"}, DeprecatedNames: []string{"flavor"}}))
+	assert.Equal(t, "CPUs (between 1 and 8)", Usage(&IntFlag{Name: "cpus", Usage: "CPUs", Min: Limit(1), Max: Limit(8)}))
+	assert.Equal(t, "Port (at least 1)", Usage(&IntFlag{Name: "port", Usage: "Port", Min: Limit(1)}))
+	assert.Equal(t, "Debug", Usage(BoolFlag{Name: "debug", Usage: "Debug"}))
+}
@@ -1,12 +1,14 @@
 package mcnutils
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"io"
 	math_rand "math/rand"
 	"os"
+	"os/user"
 	"runtime"
 	"strconv"
 	"time"
@@ -52,6 +54,21 @@ func GetUsername() string {
 	return u
 }
 
+// CurrentUser names the user of this process and the host it runs on, as
+// recorded in the shared stores and in the history of the machines.
+func CurrentUser() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		name = name + "@" + hostname
+	}
+
+	return name
+}
+
 func CopyFile(src, dst string) error {
 	in, err := os.Open(src)
 	if err != nil {
@@ -80,7 +97,16 @@ func CopyFile(src, dst string) error {
 }
 
 func WaitForSpecificOrError(f func() (bool, error), maxAttempts int, waitInterval time.Duration, devInterval ...time.Duration) error {
+	return WaitForSpecificOrErrorContext(context.Background(), f, maxAttempts, waitInterval, devInterval...)
+}
+
+// WaitForSpecificOrErrorContext behaves like WaitForSpecificOrError but gives
+// up as soon as ctx is done, returning the context's error.
+func WaitForSpecificOrErrorContext(ctx context.Context, f func() (bool, error), maxAttempts int, waitInterval time.Duration, devInterval ...time.Duration) error {
 	for i := 0; i < maxAttempts; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		stop, err := f()
 		if err != nil {
 			return err
@@ -88,22 +114,48 @@ func WaitForSpecificOrError(f func() (bool, error), maxAttempts int, waitInterva
 		if stop {
 			return nil
 		}
-		time.Sleep(waitInterval)
+		wait := waitInterval
 		for _, deviation := range devInterval {
-			time.Sleep(time.Duration(math_rand.Int63n(int64(deviation))))
+			wait += time.Duration(math_rand.Int63n(int64(deviation)))
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
 		}
 	}
 	return fmt.Errorf("Maximum number of retries (%d) exceeded", maxAttempts)
 }
 
 func WaitForSpecific(f func() bool, maxAttempts int, waitInterval time.Duration, devInterval ...time.Duration) error {
-	return WaitForSpecificOrError(func() (bool, error) {
+	return WaitForSpecificContext(context.Background(), f, maxAttempts, waitInterval, devInterval...)
+}
+
+// WaitForSpecificContext behaves like WaitForSpecific but gives up as soon as
+// ctx is done.
+func WaitForSpecificContext(ctx context.Context, f func() bool, maxAttempts int, waitInterval time.Duration, devInterval ...time.Duration) error {
+	return WaitForSpecificOrErrorContext(ctx, func() (bool, error) {
 		return f(), nil
 	}, maxAttempts, waitInterval, devInterval...)
 }
 
 func WaitFor(f func() bool) error {
-	return WaitForSpecific(f, 60, 3*time.Second, 9*time.Second)
+	return WaitForContext(context.Background(), f)
+}
+
+// WaitForContext behaves like WaitFor but gives up as soon as ctx is done.
+func WaitForContext(ctx context.Context, f func() bool) error {
+	return WaitForSpecificContext(ctx, f, 60, 3*time.Second, 9*time.Second)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
 // TruncateID returns a shorten id
@@ -1,11 +1,13 @@
 package mcnutils
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
 )
 
 func TestCopyFile(t *testing.T) {
@@ -99,3 +101,36 @@ func TestShortenIdInvalid(t *testing.T) {
 		t.Fatalf("Id returned is incorrect: truncate on %s returned %s", id, truncID)
 	}
 }
+
+func TestWaitForSpecificContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	attempts := 0
+	err := WaitForSpecificContext(ctx, func() bool {
+		attempts++
+		cancel()
+		return false
+	}, 10, time.Hour)
+
+	if err != context.Canceled {
+		t.Fatalf("expected %q; received %v", context.Canceled, err)
+	}
+	if attempts != 1 {
+		t.Fatalf("expected 1 attempt; received %d", attempts)
+	}
+}
+
+func TestWaitForSpecificContextSucceeds(t *testing.T) {
+	attempts := 0
+	err := WaitForSpecificContext(context.Background(), func() bool {
+		attempts++
+		return attempts == 3
+	}, 10, time.Millisecond)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempts != 3 {
+		t.Fatalf("expected 3 attempts; received %d", attempts)
+	}
+}
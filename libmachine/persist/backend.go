@@ -0,0 +1,200 @@
+package persist
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Backend opens the store found at the given URL. The filestore holding the
+// machine directories is handed over, because the SSH keys and certificates
+// of the machines have to be on disk for the SSH and Docker clients,
+// whichever backend keeps the configurations.
+type Backend func(u *url.URL, fs *Filestore) (Store, error)
+
+// Updater is implemented by the stores able to change several machines at
+// once. Either all the changes made by fn through the given store are
+// persisted or none of them are.
+type Updater interface {
+	Update(fn func(s Store) error) error
+}
+
+var (
+	backendsLock sync.Mutex
+	backends     = map[string]Backend{
+		"file":  openFilestore,
+		"db":    openDbstore,
+		"http":  openHttpstore,
+		"https": openHttpstore,
+	}
+)
+
+// RegisterBackend makes the backend available for the URLs with the given
+// scheme, e.g. for embedders of libmachine bringing their own database.
+func RegisterBackend(scheme string, backend Backend) {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	backends[scheme] = backend
+}
+
+// Backends returns the schemes of the registered backends.
+func Backends() []string {
+	backendsLock.Lock()
+	defer backendsLock.Unlock()
+
+	schemes := []string{}
+	for scheme := range backends {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+
+	return schemes
+}
+
+// NewStore opens the store at storeURL, e.g. file:///path/to/store or
+// db:///path/to/machines.db. An empty URL stands for fs itself.
+func NewStore(storeURL string, fs *Filestore) (Store, error) {
+	if storeURL == "" {
+		return fs, nil
+	}
+
+	u, err := url.Parse(storeURL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid store URL %q: %s", storeURL, err)
+	}
+
+	backendsLock.Lock()
+	backend, ok := backends[u.Scheme]
+	backendsLock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("Unsupported store URL %q, the known schemes are %v", storeURL, Backends())
+	}
+
+	return backend(u, fs)
+}
+
+// openFilestore points fs at the path of the URL, if any.
+func openFilestore(u *url.URL, fs *Filestore) (Store, error) {
+	if u.Path != "" {
+		fs.Path = filepath.FromSlash(u.Path)
+	}
+
+	return fs, nil
+}
+
+// Migrate copies all the machines of the from store into the to store and
+// returns their names. Machines already in the to store are overwritten.
+func Migrate(from, to Store) ([]string, error) {
+	names, err := from.List()
+	if err != nil {
+		return nil, err
+	}
+
+	fromDir, toDir := machinesDir(from), machinesDir(to)
+
+	copyAll := func(s Store) error {
+		for _, name := range names {
+			h, err := from.Load(name)
+			if err != nil {
+				return fmt.Errorf("Error loading %q: %s", name, err)
+			}
+
+			// The keys and certificates have to follow the machine
+			// when the stores don't share their machine directories.
+			if fromDir != "" && toDir != "" && fromDir != toDir {
+				files, err := ReadMachineFiles(filepath.Join(fromDir, name))
+				if err != nil {
+					return fmt.Errorf("Error reading the files of %q: %s", name, err)
+				}
+				if err := WriteMachineFiles(filepath.Join(toDir, name), files); err != nil {
+					return fmt.Errorf("Error writing the files of %q: %s", name, err)
+				}
+			}
+
+			if err := s.Save(h); err != nil {
+				return fmt.Errorf("Error saving %q: %s", name, err)
+			}
+		}
+		return nil
+	}
+
+	if updater, ok := to.(Updater); ok {
+		err = updater.Update(copyAll)
+	} else {
+		err = copyAll(to)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+func machinesDir(s Store) string {
+	if direr, ok := s.(interface {
+		GetMachinesDir() string
+	}); ok {
+		return direr.GetMachinesDir()
+	}
+	return ""
+}
+
+// isMachineFile tells whether the file of a machine directory is one of the
+// keys or certificates of the machine, which are needed to reach it. The
+// other files, such as disk images, belong to the driver.
+func isMachineFile(name string) bool {
+	return name == "id_rsa" || name == "id_rsa.pub" || filepath.Ext(name) == ".pem"
+}
+
+// ReadMachineFiles reads the keys and certificates found in dir.
+func ReadMachineFiles(dir string) (map[string][]byte, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() || !isMachineFile(entry.Name()) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[entry.Name()] = data
+	}
+
+	return files, nil
+}
+
+// WriteMachineFiles writes the given keys and certificates to dir, leaving
+// alone the ones which are already up to date.
+func WriteMachineFiles(dir string, files map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if current, err := ioutil.ReadFile(path); err == nil && bytes.Equal(current, data) {
+			continue
+		}
+
+		if err := ioutil.WriteFile(path, data, 0600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
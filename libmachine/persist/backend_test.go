@@ -0,0 +1,102 @@
+package persist
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/hosttest"
+)
+
+func TestNewStore(t *testing.T) {
+	fs := NewFilestore("/tmp/store", "", "")
+
+	store, err := NewStore("", fs)
+	if err != nil || store != fs {
+		t.Fatalf("Expected the filestore for an empty URL, got %v, %v", store, err)
+	}
+
+	store, err = NewStore("db:///tmp/store/machines.db", fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db, ok := store.(*Dbstore); !ok || db.Path != filepath.FromSlash("/tmp/store/machines.db") {
+		t.Fatalf("Expected a db store, got %#v", store)
+	}
+
+	if _, err := NewStore("db://", fs); err == nil {
+		t.Fatal("Expected an error for a db URL without path")
+	}
+
+	if _, err := NewStore("bogus:///tmp", fs); err == nil {
+		t.Fatal("Expected an error for an unknown scheme")
+	}
+
+	store, err = NewStore("file:///tmp/other", fs)
+	if err != nil || store != fs || fs.Path != filepath.FromSlash("/tmp/other") {
+		t.Fatalf("Expected the filestore to be moved to /tmp/other, got %v, %v", store, err)
+	}
+}
+
+func TestRegisterBackend(t *testing.T) {
+	defer func() {
+		backendsLock.Lock()
+		delete(backends, "test")
+		backendsLock.Unlock()
+	}()
+
+	fs := NewFilestore("/tmp/store", "", "")
+	RegisterBackend("test", func(u *url.URL, fs *Filestore) (Store, error) {
+		return fs, nil
+	})
+
+	store, err := NewStore("test://", fs)
+	if err != nil || store != fs {
+		t.Fatalf("Expected the registered backend to be used, got %v, %v", store, err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	fs := NewFilestore(tmpDir, "", "")
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Save(h); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(fs.GetMachinesDir(), h.Name, "id_rsa"), []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	db := NewDbstore(filepath.Join(tmpDir, "machines.db"), NewFilestore(filepath.Join(tmpDir, "other"), "", ""))
+
+	names, err := Migrate(fs, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != h.Name {
+		t.Fatalf("Expected %s to be migrated, got %v", h.Name, names)
+	}
+
+	loaded, err := db.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.DriverName != "none" {
+		t.Fatalf("Expected driver none, got %s", loaded.DriverName)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(db.GetMachinesDir(), h.Name, "id_rsa"))
+	if err != nil || string(data) != "key" {
+		t.Fatalf("Expected the SSH key to follow the machine, got %q, %v", data, err)
+	}
+}
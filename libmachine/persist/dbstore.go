@@ -0,0 +1,358 @@
+package persist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/lock"
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+const dbVersion = 1
+
+// Dbstore keeps the configurations, keys and certificates of all the
+// machines in a single file. Listing and loading machines only reads that
+// file, and several machines can be changed at once with Update.
+//
+// The keys and certificates are still written to the machine directories of
+// the filestore when the machines are loaded, since that's where the SSH
+// and Docker clients expect them.
+type Dbstore struct {
+	Path string
+	fs   *Filestore
+
+	cacheLock    sync.Mutex
+	cache        *dbContents
+	cacheModTime time.Time
+	cacheSize    int64
+}
+
+type dbContents struct {
+	Version  int
+	Machines map[string]*dbMachine
+}
+
+type dbMachine struct {
+	Config json.RawMessage
+	Files  map[string][]byte `json:",omitempty"`
+}
+
+func NewDbstore(path string, fs *Filestore) *Dbstore {
+	return &Dbstore{
+		Path: path,
+		fs:   fs,
+	}
+}
+
+func openDbstore(u *url.URL, fs *Filestore) (Store, error) {
+	if u.Path == "" {
+		return nil, errors.New("The db store URL needs the path of the database, e.g. db:///path/to/machines.db")
+	}
+
+	return NewDbstore(filepath.FromSlash(u.Path), fs), nil
+}
+
+func (s *Dbstore) GetMachinesDir() string {
+	return s.fs.GetMachinesDir()
+}
+
+func (s *Dbstore) Exists(name string) (bool, error) {
+	contents, err := s.read()
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := contents.Machines[name]
+	return ok, nil
+}
+
+func (s *Dbstore) List() ([]string, error) {
+	contents, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return contents.names(), nil
+}
+
+func (s *Dbstore) Load(name string) (*host.Host, error) {
+	contents, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.load(contents, name, s.Save)
+}
+
+func (s *Dbstore) Save(h *host.Host) error {
+	return s.Update(func(tx Store) error {
+		return tx.Save(h)
+	})
+}
+
+func (s *Dbstore) Remove(name string) error {
+	return s.Update(func(tx Store) error {
+		return tx.Remove(name)
+	})
+}
+
+// Update runs fn with a store whose changes are all written to the database
+// once fn returns, or not at all if it fails. Other processes updating the
+// database wait in the meantime.
+func (s *Dbstore) Update(fn func(s Store) error) error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+
+	l, err := lock.AcquireFile(context.Background(), s.Path, s.Path+".lock", "store update", true)
+	if err != nil {
+		return err
+	}
+	defer l.Release()
+
+	contents, _, err := s.readFile()
+	if err != nil {
+		return err
+	}
+
+	tx := &dbTx{store: s, contents: contents}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	s.cacheLock.Lock()
+	s.cache = nil
+	s.cacheLock.Unlock()
+
+	if err := s.write(contents); err != nil {
+		return err
+	}
+
+	// The machine directories go once the machines are out of the database
+	// for sure.
+	for _, name := range tx.removed {
+		if err := os.RemoveAll(filepath.Join(s.GetMachinesDir(), name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// load loads the named machine from contents, restoring its keys and
+// certificates in its directory. Machines whose configuration had to be
+// migrated are saved with save.
+func (s *Dbstore) load(contents *dbContents, name string, save func(*host.Host) error) (*host.Host, error) {
+	machine, ok := contents.Machines[name]
+	if !ok {
+		return nil, mcnerror.ErrHostDoesNotExist{
+			Name: name,
+		}
+	}
+
+	if err := WriteMachineFiles(filepath.Join(s.GetMachinesDir(), name), machine.Files); err != nil {
+		return nil, fmt.Errorf("Error restoring the files of %q: %s", name, err)
+	}
+
+	h := &host.Host{
+		Name: name,
+	}
+
+	config, err := decryptConfig(machine.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	migratedHost, migrationPerformed, err := host.MigrateHost(h, config, s.fs.Path)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting migrated host: %s", err)
+	}
+
+	*h = *migratedHost
+
+	h.Name = name
+
+	if migrationPerformed {
+		if err := save(h); err != nil {
+			return nil, fmt.Errorf("Error saving config after migration was performed: %s", err)
+		}
+	}
+
+	return h, nil
+}
+
+// read returns the contents of the database, which must not be changed. They
+// are only read again when the file changed.
+func (s *Dbstore) read() (*dbContents, error) {
+	s.cacheLock.Lock()
+	defer s.cacheLock.Unlock()
+
+	fi, err := os.Stat(s.Path)
+	if err == nil && s.cache != nil && fi.ModTime().Equal(s.cacheModTime) && fi.Size() == s.cacheSize {
+		return s.cache, nil
+	}
+
+	contents, fi, err := s.readFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if fi != nil {
+		s.cache, s.cacheModTime, s.cacheSize = contents, fi.ModTime(), fi.Size()
+	}
+
+	return contents, nil
+}
+
+func (s *Dbstore) readFile() (*dbContents, os.FileInfo, error) {
+	contents := &dbContents{
+		Version:  dbVersion,
+		Machines: map[string]*dbMachine{},
+	}
+
+	f, err := os.Open(s.Path)
+	if os.IsNotExist(err) {
+		return contents, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := json.NewDecoder(f).Decode(contents); err != nil {
+		return nil, nil, fmt.Errorf("Error reading the store database %s: %s", s.Path, err)
+	}
+
+	if contents.Version > dbVersion {
+		return nil, nil, fmt.Errorf("The store database %s was written by a newer version of docker-machine", s.Path)
+	}
+
+	if contents.Machines == nil {
+		contents.Machines = map[string]*dbMachine{}
+	}
+
+	return contents, fi, nil
+}
+
+// write replaces the database with contents, in a single rename so that
+// readers never see a partly written database.
+func (s *Dbstore) write(contents *dbContents) error {
+	contents.Version = dbVersion
+
+	data, err := json.Marshal(contents)
+	if err != nil {
+		return err
+	}
+
+	tmpfi, err := ioutil.TempFile(filepath.Dir(s.Path), filepath.Base(s.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpfi.Name())
+
+	if _, err := tmpfi.Write(data); err != nil {
+		tmpfi.Close()
+		return err
+	}
+
+	if err := tmpfi.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpfi.Name(), s.Path); err != nil {
+		// Windows doesn't rename over existing files.
+		if err := os.Remove(s.Path); err != nil {
+			return err
+		}
+		return os.Rename(tmpfi.Name(), s.Path)
+	}
+
+	return nil
+}
+
+func (c *dbContents) names() []string {
+	names := []string{}
+	for name := range c.Machines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// dbTx is the store handed to the functions run by Dbstore.Update.
+type dbTx struct {
+	store    *Dbstore
+	contents *dbContents
+	removed  []string
+}
+
+func (tx *dbTx) Exists(name string) (bool, error) {
+	_, ok := tx.contents.Machines[name]
+	return ok, nil
+}
+
+func (tx *dbTx) List() ([]string, error) {
+	return tx.contents.names(), nil
+}
+
+func (tx *dbTx) Load(name string) (*host.Host, error) {
+	return tx.store.load(tx.contents, name, tx.Save)
+}
+
+func (tx *dbTx) Save(h *host.Host) error {
+	data, err := marshalHost(h, tx.store.fs.Path)
+	if err != nil {
+		return err
+	}
+
+	hostPath := filepath.Join(tx.store.GetMachinesDir(), h.Name)
+
+	// The drivers keep their own files in the machine directory.
+	if err := os.MkdirAll(hostPath, 0700); err != nil {
+		return err
+	}
+
+	files, err := ReadMachineFiles(hostPath)
+	if err != nil {
+		return err
+	}
+
+	// Keep the files which are only left in the database.
+	if previous, ok := tx.contents.Machines[h.Name]; ok {
+		for name, data := range previous.Files {
+			if _, ok := files[name]; !ok {
+				files[name] = data
+			}
+		}
+	}
+
+	tx.contents.Machines[h.Name] = &dbMachine{
+		Config: data,
+		Files:  files,
+	}
+
+	return nil
+}
+
+func (tx *dbTx) Remove(name string) error {
+	delete(tx.contents.Machines, name)
+	tx.removed = append(tx.removed, name)
+
+	return nil
+}
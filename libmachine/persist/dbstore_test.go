@@ -0,0 +1,194 @@
+package persist
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/mcnerror"
+)
+
+func getTestDbstore(t *testing.T) (*Dbstore, func()) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := NewFilestore(tmpDir, filepath.Join(tmpDir, "certs", "ca.pem"), filepath.Join(tmpDir, "certs", "ca-key.pem"))
+
+	return NewDbstore(filepath.Join(tmpDir, "machines.db"), fs), func() { os.RemoveAll(tmpDir) }
+}
+
+func TestDbstoreSaveLoad(t *testing.T) {
+	store, cleanup := getTestDbstore(t)
+	defer cleanup()
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(store.GetMachinesDir(), h.Name, "config.json")); !os.IsNotExist(err) {
+		t.Fatal("Expected the config to be kept in the database only")
+	}
+
+	names, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != h.Name {
+		t.Fatalf("Expected to list %s, got %v", h.Name, names)
+	}
+
+	loaded, err := store.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.DriverName != "none" {
+		t.Fatalf("Expected driver none, got %s", loaded.DriverName)
+	}
+	// Relative paths are relative to the store.
+	expectedCaCertPath := filepath.Join(store.fs.Path, hosttest.HostTestCaCert)
+	if loaded.HostOptions.AuthOptions.CaCertPath != expectedCaCertPath {
+		t.Fatalf("Expected CA cert path %s, got %s", expectedCaCertPath, loaded.HostOptions.AuthOptions.CaCertPath)
+	}
+}
+
+func TestDbstoreRestoresMachineFiles(t *testing.T) {
+	store, cleanup := getTestDbstore(t)
+	defer cleanup()
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostPath := filepath.Join(store.GetMachinesDir(), h.Name)
+	if err := os.MkdirAll(hostPath, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(hostPath, "id_rsa"), []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(hostPath, "disk.vmdk"), []byte("disk"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	os.RemoveAll(hostPath)
+
+	if _, err := store.Load(h.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(hostPath, "id_rsa"))
+	if err != nil || string(data) != "key" {
+		t.Fatalf("Expected the SSH key to be restored, got %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(hostPath, "disk.vmdk")); !os.IsNotExist(err) {
+		t.Fatal("Expected the driver files to be left out of the database")
+	}
+}
+
+func TestDbstoreRemove(t *testing.T) {
+	store, cleanup := getTestDbstore(t)
+	defer cleanup()
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Remove(h.Name); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists, _ := store.Exists(h.Name); exists {
+		t.Fatal("Expected the host to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(store.GetMachinesDir(), h.Name)); !os.IsNotExist(err) {
+		t.Fatal("Expected the machine directory to be removed")
+	}
+	if _, err := store.Load(h.Name); err == nil {
+		t.Fatal("Expected an error loading a removed host")
+	} else if _, ok := err.(mcnerror.ErrHostDoesNotExist); !ok {
+		t.Fatalf("Expected ErrHostDoesNotExist, got %T", err)
+	}
+}
+
+func TestDbstoreUpdateIsAtomic(t *testing.T) {
+	store, cleanup := getTestDbstore(t)
+	defer cleanup()
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.Update(func(tx Store) error {
+		if err := tx.Save(h); err != nil {
+			return err
+		}
+		return mcnerror.ErrHostDoesNotExist{Name: "other"}
+	})
+	if err == nil {
+		t.Fatal("Expected the update to fail")
+	}
+
+	if exists, _ := store.Exists(h.Name); exists {
+		t.Fatal("Expected the changes of a failed update to be dropped")
+	}
+
+	other := &host.Host{Name: "other", DriverName: "none", Driver: h.Driver, HostOptions: h.HostOptions}
+	err = store.Update(func(tx Store) error {
+		if err := tx.Save(h); err != nil {
+			return err
+		}
+		return tx.Save(other)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, _ := store.List()
+	if len(names) != 2 {
+		t.Fatalf("Expected both hosts to be saved, got %v", names)
+	}
+}
+
+func TestDbstoreSeesChangesOfOtherStores(t *testing.T) {
+	store, cleanup := getTestDbstore(t)
+	defer cleanup()
+
+	other := NewDbstore(store.Path, store.fs)
+
+	if names, _ := store.List(); len(names) != 0 {
+		t.Fatalf("Expected an empty store, got %v", names)
+	}
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	if exists, _ := store.Exists(h.Name); !exists {
+		t.Fatal("Expected the host saved through the other store to exist")
+	}
+}
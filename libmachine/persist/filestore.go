@@ -1,7 +1,6 @@
 package persist
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -58,7 +57,7 @@ func (s Filestore) saveToFile(data []byte, file string) error {
 }
 
 func (s Filestore) Save(host *host.Host) error {
-	data, err := json.MarshalIndent(host, "", "    ")
+	data, err := marshalHost(host, s.Path)
 	if err != nil {
 		return err
 	}
@@ -117,7 +116,12 @@ func (s Filestore) loadConfig(h *host.Host) error {
 	// struct in the migration.
 	name := h.Name
 
-	migratedHost, migrationPerformed, err := host.MigrateHost(h, data)
+	config, err := decryptConfig(data)
+	if err != nil {
+		return err
+	}
+
+	migratedHost, migrationPerformed, err := host.MigrateHost(h, config, s.Path)
 	if err != nil {
 		return fmt.Errorf("Error getting migrated host: %s", err)
 	}
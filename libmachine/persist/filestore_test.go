@@ -247,3 +247,77 @@ func TestStoreLoad(t *testing.T) {
 		t.Fatalf("GetURL is not %q, got %q", expectedURL, actualURL)
 	}
 }
+
+func TestStoreMigratesToRelativePaths(t *testing.T) {
+	defer cleanup()
+
+	store := getTestStore()
+	hostPath := filepath.Join(store.GetMachinesDir(), "default")
+	if err := os.MkdirAll(hostPath, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	v3conf := fmt.Sprintf(`{
+    "ConfigVersion": 3,
+    "Driver": {"MachineName": "default", "StorePath": %q},
+    "DriverName": "none",
+    "HostOptions": {
+        "AuthOptions": {
+            "CaCertPath": %q,
+            "StorePath": %q
+        }
+    },
+    "Name": "default"
+}`, store.Path, filepath.Join(store.Path, "certs", "ca.pem"), hostPath)
+
+	if err := ioutil.WriteFile(filepath.Join(hostPath, "config.json"), []byte(v3conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Load("default"); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := ioutil.ReadFile(filepath.Join(hostPath, "config.json.bak"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(backup) != v3conf {
+		t.Fatal("Expected the backup to hold the config before the migration")
+	}
+
+	// The store still works once moved.
+	movedPath := store.Path + "-moved"
+	if err := os.Rename(store.Path, movedPath); err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(movedPath)
+	store.Path = movedPath
+
+	h, err := store.Load("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if h.ConfigVersion != 4 {
+		t.Fatalf("Expected config version 4, got %d", h.ConfigVersion)
+	}
+
+	expectedCaCertPath := filepath.Join(movedPath, "certs", "ca.pem")
+	if h.HostOptions.AuthOptions.CaCertPath != expectedCaCertPath {
+		t.Fatalf("Expected CA cert path %s, got %s", expectedCaCertPath, h.HostOptions.AuthOptions.CaCertPath)
+	}
+
+	expectedStorePath := filepath.Join(movedPath, "machines", "default")
+	if h.HostOptions.AuthOptions.StorePath != expectedStorePath {
+		t.Fatalf("Expected store path %s, got %s", expectedStorePath, h.HostOptions.AuthOptions.StorePath)
+	}
+
+	driver := none.NewDriver("", "")
+	if err := json.Unmarshal(h.RawDriver, &driver); err != nil {
+		t.Fatal(err)
+	}
+	if driver.StorePath != movedPath {
+		t.Fatalf("Expected driver store path %s, got %s", movedPath, driver.StorePath)
+	}
+}
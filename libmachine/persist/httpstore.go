@@ -0,0 +1,374 @@
+package persist
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+const (
+	// StoreTokenEnv names the environment variable holding the token sent
+	// to HTTP stores.
+	StoreTokenEnv = "MACHINE_STORE_TOKEN"
+
+	// StoreCaCertEnv names the environment variable holding the path of the
+	// CA certificate HTTP stores are verified against, in addition to the
+	// system ones.
+	StoreCaCertEnv = "MACHINE_STORE_CA_CERT"
+)
+
+// Metadata tells who keeps a machine in a shared store.
+type Metadata struct {
+	Owner     string
+	Revision  int64
+	UpdatedBy string
+	UpdatedAt time.Time
+}
+
+// MetadataStore is implemented by the stores keeping metadata about their
+// machines.
+type MetadataStore interface {
+	Metadata(name string) (Metadata, error)
+}
+
+// storeRecord is a machine as exchanged with an HTTP store.
+type storeRecord struct {
+	Metadata
+	Config json.RawMessage
+	Files  map[string][]byte `json:",omitempty"`
+}
+
+// Httpstore keeps the machines in a store shared over HTTP, such as the one
+// served by 'docker-machine store serve'. Saving a machine fails if it was
+// changed in the store since it was loaded.
+//
+// The keys and certificates of the machines are transferred along with
+// their configuration, and written to the machine directories of the local
+// filestore.
+type Httpstore struct {
+	URL    *url.URL
+	Token  string
+	client *http.Client
+	fs     *Filestore
+
+	revisionsLock sync.Mutex
+	revisions     map[string]int64
+}
+
+func NewHttpstore(u *url.URL, token string, client *http.Client, fs *Filestore) *Httpstore {
+	return &Httpstore{
+		URL:       u,
+		Token:     token,
+		client:    client,
+		fs:        fs,
+		revisions: map[string]int64{},
+	}
+}
+
+func openHttpstore(u *url.URL, fs *Filestore) (Store, error) {
+	// The keys of the machines must not be sent in the clear.
+	if u.Scheme == "http" && !isLoopback(u.Hostname()) {
+		return nil, fmt.Errorf("Refusing to use the store at %s without TLS, use https", u)
+	}
+
+	tlsConfig := &tls.Config{}
+	if caCertPath := os.Getenv(StoreCaCertEnv); caCertPath != "" {
+		caCert, err := ioutil.ReadFile(caCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading the store CA certificate: %s", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("No certificate found in %s", caCertPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}
+
+	return NewHttpstore(u, os.Getenv(StoreTokenEnv), client, fs), nil
+}
+
+func isLoopback(hostname string) bool {
+	if hostname == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(hostname)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Httpstore) GetMachinesDir() string {
+	return s.fs.GetMachinesDir()
+}
+
+func (s *Httpstore) Exists(name string) (bool, error) {
+	resp, err := s.do("GET", machinePath(name), nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+
+	return false, responseError(resp)
+}
+
+func (s *Httpstore) List() ([]string, error) {
+	resp, err := s.do("GET", "machines", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	names := []string{}
+	if err := json.NewDecoder(resp.Body).Decode(&names); err != nil {
+		return nil, fmt.Errorf("Error reading the machines of the store: %s", err)
+	}
+
+	return names, nil
+}
+
+func (s *Httpstore) Load(name string) (*host.Host, error) {
+	record, err := s.get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := WriteMachineFiles(filepath.Join(s.GetMachinesDir(), name), record.Files); err != nil {
+		return nil, fmt.Errorf("Error restoring the files of %q: %s", name, err)
+	}
+
+	s.setRevision(name, record.Revision)
+
+	h := &host.Host{
+		Name: name,
+	}
+
+	config, err := decryptConfig(record.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	migratedHost, migrationPerformed, err := host.MigrateHost(h, config, s.fs.Path)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting migrated host: %s", err)
+	}
+
+	*h = *migratedHost
+
+	h.Name = name
+
+	if migrationPerformed {
+		if err := s.Save(h); err != nil {
+			return nil, fmt.Errorf("Error saving config after migration was performed: %s", err)
+		}
+	}
+
+	return h, nil
+}
+
+// Metadata returns the metadata of the named machine.
+func (s *Httpstore) Metadata(name string) (Metadata, error) {
+	record, err := s.get(name)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	return record.Metadata, nil
+}
+
+// Save saves the machine if it wasn't changed in the store since it was
+// loaded, or if it's a new machine.
+func (s *Httpstore) Save(h *host.Host) error {
+	data, err := marshalHost(h, s.fs.Path)
+	if err != nil {
+		return err
+	}
+
+	hostPath := filepath.Join(s.GetMachinesDir(), h.Name)
+
+	// The drivers keep their own files in the machine directory.
+	if err := os.MkdirAll(hostPath, 0700); err != nil {
+		return err
+	}
+
+	files, err := ReadMachineFiles(hostPath)
+	if err != nil {
+		return err
+	}
+
+	record := storeRecord{
+		Metadata: Metadata{
+			Revision:  s.revision(h.Name),
+			UpdatedBy: mcnutils.CurrentUser(),
+		},
+		Config: data,
+		Files:  files,
+	}
+
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do("PUT", machinePath(h.Name), body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
+		return responseError(resp)
+	}
+
+	var saved Metadata
+	if err := json.NewDecoder(resp.Body).Decode(&saved); err != nil {
+		return fmt.Errorf("Error reading the answer of the store: %s", err)
+	}
+
+	if resp.StatusCode == http.StatusConflict {
+		return mcnerror.ErrRevisionConflict{
+			Name:     h.Name,
+			Revision: saved.Revision,
+		}
+	}
+
+	s.setRevision(h.Name, saved.Revision)
+
+	return nil
+}
+
+func (s *Httpstore) Remove(name string) error {
+	resp, err := s.do("DELETE", machinePath(name), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return responseError(resp)
+	}
+
+	s.setRevision(name, 0)
+
+	return os.RemoveAll(filepath.Join(s.GetMachinesDir(), name))
+}
+
+func (s *Httpstore) get(name string) (*storeRecord, error) {
+	resp, err := s.do("GET", machinePath(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, mcnerror.ErrHostDoesNotExist{
+			Name: name,
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+
+	record := &storeRecord{}
+	if err := json.NewDecoder(resp.Body).Decode(record); err != nil {
+		return nil, fmt.Errorf("Error reading %q from the store: %s", name, err)
+	}
+
+	return record, nil
+}
+
+func (s *Httpstore) do(method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	u := *s.URL
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + path
+
+	req, err := http.NewRequest(method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error reaching the store: %s", err)
+	}
+
+	return resp, nil
+}
+
+func (s *Httpstore) revision(name string) int64 {
+	s.revisionsLock.Lock()
+	defer s.revisionsLock.Unlock()
+
+	return s.revisions[name]
+}
+
+func (s *Httpstore) setRevision(name string, revision int64) {
+	s.revisionsLock.Lock()
+	defer s.revisionsLock.Unlock()
+
+	if revision == 0 {
+		delete(s.revisions, name)
+	} else {
+		s.revisions[name] = revision
+	}
+}
+
+func machinePath(name string) string {
+	return "machines/" + name
+}
+
+func responseError(resp *http.Response) error {
+	message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if len(message) == 0 {
+		return fmt.Errorf("The store answered %s", resp.Status)
+	}
+
+	return fmt.Errorf("The store answered %s: %s", resp.Status, strings.TrimSpace(string(message)))
+}
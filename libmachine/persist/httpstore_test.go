@@ -0,0 +1,168 @@
+package persist
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/mcnerror"
+	"github.com/docker/machine/libmachine/mcnutils"
+)
+
+// newTestHttpstores serves a filestore and returns two clients of it, each
+// with its own local machine directories.
+func newTestHttpstores(t *testing.T, token string) (*Httpstore, *Httpstore, func()) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(NewStoreHandler(NewFilestore(filepath.Join(tmpDir, "server"), "", ""), "secret"))
+	u, _ := url.Parse(server.URL)
+
+	first := NewHttpstore(u, token, http.DefaultClient, NewFilestore(filepath.Join(tmpDir, "first"), "", ""))
+	second := NewHttpstore(u, token, http.DefaultClient, NewFilestore(filepath.Join(tmpDir, "second"), "", ""))
+
+	return first, second, func() {
+		server.Close()
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func TestHttpstoreSaveLoad(t *testing.T) {
+	first, second, cleanup := newTestHttpstores(t, "secret")
+	defer cleanup()
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostPath := filepath.Join(first.GetMachinesDir(), h.Name)
+	if err := os.MkdirAll(hostPath, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(hostPath, "id_rsa"), []byte("key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := second.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != h.Name {
+		t.Fatalf("Expected to list %s, got %v", h.Name, names)
+	}
+
+	loaded, err := second.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.DriverName != "none" {
+		t.Fatalf("Expected driver none, got %s", loaded.DriverName)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(second.GetMachinesDir(), h.Name, "id_rsa"))
+	if err != nil || string(data) != "key" {
+		t.Fatalf("Expected the SSH key to be transferred, got %q, %v", data, err)
+	}
+
+	metadata, err := second.Metadata(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Revision != 1 || metadata.Owner != mcnutils.CurrentUser() {
+		t.Fatalf("Expected revision 1 owned by %s, got %+v", mcnutils.CurrentUser(), metadata)
+	}
+}
+
+func TestHttpstoreDetectsConflicts(t *testing.T) {
+	first, second, cleanup := newTestHttpstores(t, "secret")
+	defer cleanup()
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	// A machine which already exists can't be created again.
+	err = second.Save(h)
+	if conflict, ok := err.(mcnerror.ErrRevisionConflict); !ok || conflict.Revision != 1 {
+		t.Fatalf("Expected a conflict at revision 1, got %v", err)
+	}
+
+	loaded, err := second.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Save(loaded); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first client didn't see the change of the second one.
+	err = first.Save(h)
+	if conflict, ok := err.(mcnerror.ErrRevisionConflict); !ok || conflict.Revision != 2 {
+		t.Fatalf("Expected a conflict at revision 2, got %v", err)
+	}
+
+	if err := second.Remove(h.Name); err != nil {
+		t.Fatal(err)
+	}
+	if exists, _ := first.Exists(h.Name); exists {
+		t.Fatal("Expected the machine to be removed")
+	}
+
+	err = first.Save(h)
+	if conflict, ok := err.(mcnerror.ErrRevisionConflict); !ok || conflict.Revision != 0 {
+		t.Fatalf("Expected a conflict with a removed machine, got %v", err)
+	}
+}
+
+func TestHttpstoreNeedsToken(t *testing.T) {
+	first, _, cleanup := newTestHttpstores(t, "wrong")
+	defer cleanup()
+
+	if _, err := first.List(); err == nil {
+		t.Fatal("Expected an error with a wrong token")
+	}
+}
+
+func TestStoreHandlerRejectsInvalidNames(t *testing.T) {
+	handler := NewStoreHandler(NewFilestore("/tmp/nonexistent", "", ""), "")
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/machines/..", nil))
+
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("Expected a bad request, got %d", recorder.Code)
+	}
+}
+
+func TestHttpstoreNeedsTLSForRemoteStores(t *testing.T) {
+	fs := NewFilestore("/tmp/store", "", "")
+
+	if _, err := NewStore("http://store.example.com/", fs); err == nil {
+		t.Fatal("Expected plain HTTP to be refused for a remote store")
+	}
+
+	if _, err := NewStore("http://127.0.0.1:2380/", fs); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewStore("https://store.example.com/", fs); err != nil {
+		t.Fatal(err)
+	}
+}
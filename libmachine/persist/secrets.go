@@ -0,0 +1,75 @@
+package persist
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/secrets"
+)
+
+var (
+	keyringLock sync.Mutex
+	keyring     *secrets.Keyring
+)
+
+// SetKeyring sets the key the secrets of the drivers are encrypted with when
+// the machines are saved, and decrypted with when they are loaded. Without a
+// key, the secrets are saved in the clear.
+func SetKeyring(k *secrets.Keyring) {
+	keyringLock.Lock()
+	defer keyringLock.Unlock()
+
+	keyring = k
+}
+
+func currentKeyring() *secrets.Keyring {
+	keyringLock.Lock()
+	defer keyringLock.Unlock()
+
+	return keyring
+}
+
+// marshalHost returns the configuration of the machine as saved in the
+// stores, with its paths relative to storePath and the secrets of its
+// driver encrypted.
+func marshalHost(h *host.Host, storePath string) ([]byte, error) {
+	saved, err := h.WithRelativePaths(storePath)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(saved, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+
+	k := currentKeyring()
+	if k == nil || len(h.SecretFields) == 0 {
+		return data, nil
+	}
+
+	fields := []string{}
+	for _, field := range h.SecretFields {
+		fields = append(fields, "Driver."+field)
+	}
+
+	encrypted, err := secrets.Encrypt(data, fields, k)
+	if err != nil {
+		return nil, err
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, encrypted, "", "    "); err != nil {
+		return nil, err
+	}
+
+	return indented.Bytes(), nil
+}
+
+// decryptConfig decrypts the secrets found in a configuration saved by
+// marshalHost.
+func decryptConfig(data []byte) ([]byte, error) {
+	return secrets.Decrypt(data, currentKeyring())
+}
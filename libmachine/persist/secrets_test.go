@@ -0,0 +1,64 @@
+package persist
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/drivers/none"
+	"github.com/docker/machine/libmachine/hosttest"
+	"github.com/docker/machine/libmachine/secrets"
+)
+
+func TestStoreEncryptsSecrets(t *testing.T) {
+	defer cleanup()
+	defer SetKeyring(nil)
+
+	store := getTestStore()
+
+	k, err := secrets.NewKeyring([]byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetKeyring(k)
+
+	h, err := hosttest.GetDefaultTestHost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SecretFields = []string{"URL"}
+
+	if err := store.Save(h); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(store.GetMachinesDir(), h.Name, "config.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(data), "unix:///var/run/docker.sock") {
+		t.Fatal("Expected the secret not to be saved in the clear")
+	}
+
+	loaded, err := store.Load(h.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	driver := none.NewDriver(h.Name, store.Path)
+	if err := json.Unmarshal(loaded.RawDriver, &driver); err != nil {
+		t.Fatal(err)
+	}
+
+	if driver.URL != "unix:///var/run/docker.sock" {
+		t.Fatalf("Expected the secret to be decrypted, got %q", driver.URL)
+	}
+
+	SetKeyring(nil)
+	if _, err := store.Load(h.Name); err != secrets.ErrNoKey {
+		t.Fatalf("Expected %q, got %v", secrets.ErrNoKey, err)
+	}
+}
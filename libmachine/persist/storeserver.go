@@ -0,0 +1,255 @@
+package persist
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/machine/libmachine/host"
+	"github.com/docker/machine/libmachine/log"
+)
+
+const (
+	metadataFileName = "store.json"
+	maxRecordSize    = 10 << 20
+)
+
+// StoreHandler serves the machines of a filestore to Httpstore clients. The
+// metadata of each machine is kept next to its configuration. Requests must
+// carry the token, if any.
+type StoreHandler struct {
+	fs    *Filestore
+	token string
+
+	// lock serializes the changes, so that revisions are checked and
+	// bumped atomically.
+	lock sync.Mutex
+}
+
+func NewStoreHandler(fs *Filestore, token string) *StoreHandler {
+	return &StoreHandler{
+		fs:    fs,
+		token: token,
+	}
+}
+
+func (h *StoreHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		http.Error(w, "Invalid or missing token", http.StatusUnauthorized)
+		return
+	}
+
+	if r.URL.Path == "/machines" {
+		if r.Method != "GET" {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.list(w)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/machines/")
+	if name == r.URL.Path {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The name ends up in a path, it mustn't lead out of the store.
+	if !host.ValidateHostName(name) {
+		http.Error(w, fmt.Sprintf("Invalid machine name %q", name), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		h.get(w, r, name)
+	case "PUT":
+		h.put(w, r, name)
+	case "DELETE":
+		h.remove(w, name)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *StoreHandler) authorized(r *http.Request) bool {
+	if h.token == "" {
+		return true
+	}
+
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(given), []byte(h.token)) == 1
+}
+
+func (h *StoreHandler) list(w http.ResponseWriter) {
+	names, err := h.fs.List()
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, names)
+}
+
+func (h *StoreHandler) get(w http.ResponseWriter, r *http.Request, name string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	record, err := h.read(name)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+	if record == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, record)
+}
+
+func (h *StoreHandler) put(w http.ResponseWriter, r *http.Request, name string) {
+	record := &storeRecord{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRecordSize)).Decode(record); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid machine: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	current, err := h.readMetadata(name)
+	if err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	if record.Revision != current.Revision {
+		writeJSON(w, http.StatusConflict, current)
+		return
+	}
+
+	saved := Metadata{
+		Owner:     current.Owner,
+		Revision:  current.Revision + 1,
+		UpdatedBy: record.UpdatedBy,
+		UpdatedAt: time.Now().UTC(),
+	}
+	if saved.Owner == "" {
+		saved.Owner = record.UpdatedBy
+	}
+
+	if err := h.write(name, record, saved); err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	log.Debugf("Machine %q saved by %s at revision %d", name, saved.UpdatedBy, saved.Revision)
+
+	writeJSON(w, http.StatusOK, saved)
+}
+
+func (h *StoreHandler) remove(w http.ResponseWriter, name string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if err := h.fs.Remove(name); err != nil {
+		h.fail(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, Metadata{})
+}
+
+// read returns the machine as stored, nil if there is no such machine.
+func (h *StoreHandler) read(name string) (*storeRecord, error) {
+	hostPath := filepath.Join(h.fs.GetMachinesDir(), name)
+
+	config, err := ioutil.ReadFile(filepath.Join(hostPath, "config.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := h.readMetadata(name)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := ReadMachineFiles(hostPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storeRecord{
+		Metadata: metadata,
+		Config:   config,
+		Files:    files,
+	}, nil
+}
+
+// readMetadata returns the metadata of the machine. Machines which aren't in
+// the store are at revision 0, the ones which were put there by other means
+// than the handler at revision 1.
+func (h *StoreHandler) readMetadata(name string) (Metadata, error) {
+	hostPath := filepath.Join(h.fs.GetMachinesDir(), name)
+	metadata := Metadata{}
+
+	if _, err := os.Stat(filepath.Join(hostPath, "config.json")); os.IsNotExist(err) {
+		return metadata, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(hostPath, metadataFileName))
+	if os.IsNotExist(err) {
+		metadata.Revision = 1
+		return metadata, nil
+	}
+	if err != nil {
+		return metadata, err
+	}
+
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return metadata, fmt.Errorf("Error reading the metadata of %q: %s", name, err)
+	}
+
+	return metadata, nil
+}
+
+func (h *StoreHandler) write(name string, record *storeRecord, metadata Metadata) error {
+	hostPath := filepath.Join(h.fs.GetMachinesDir(), name)
+
+	if err := WriteMachineFiles(hostPath, record.Files); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	if err := h.fs.saveToFile(data, filepath.Join(hostPath, metadataFileName)); err != nil {
+		return err
+	}
+
+	return h.fs.saveToFile(record.Config, filepath.Join(hostPath, "config.json"))
+}
+
+func (h *StoreHandler) fail(w http.ResponseWriter, err error) {
+	log.Errorf("Error serving the store: %s", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
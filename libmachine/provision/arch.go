@@ -129,7 +129,7 @@ func (provisioner *ArchProvisioner) Provision(swarmOptions swarm.Options, authOp
 	}
 
 	log.Debug("Waiting for docker daemon")
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+	if err := mcnutils.WaitForContext(drivers.ContextOf(provisioner.Driver), provisioner.dockerDaemonResponding); err != nil {
 		return err
 	}
 
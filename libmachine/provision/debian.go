@@ -116,7 +116,7 @@ func (provisioner *DebianProvisioner) Provision(swarmOptions swarm.Options, auth
 	}
 
 	log.Debug("waiting for docker daemon")
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+	if err := mcnutils.WaitForContext(drivers.ContextOf(provisioner.Driver), provisioner.dockerDaemonResponding); err != nil {
 		return err
 	}
 
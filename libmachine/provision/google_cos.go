@@ -84,7 +84,7 @@ func (p *GoogleCOSProvisioner) Provision(swarmOptions swarm.Options, authOptions
 	}
 
 	log.Debug("Waiting for Docker Daemon")
-	err = mcnutils.WaitFor(p.dockerDaemonResponding)
+	err = mcnutils.WaitForContext(drivers.ContextOf(p.Driver), p.dockerDaemonResponding)
 	if err != nil {
 		return err
 	}
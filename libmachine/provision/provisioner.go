@@ -1,6 +1,7 @@
 package provision
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/docker/machine/libmachine/auth"
@@ -103,6 +104,13 @@ func DetectProvisioner(d drivers.Driver) (Provisioner, error) {
 	return detector.DetectProvisioner(d)
 }
 
+// DetectProvisionerContext detects the provisioner of the host of d like
+// DetectProvisioner. The SSH commands run to detect it, and later on by the
+// returned provisioner, are aborted as soon as ctx is done.
+func DetectProvisionerContext(ctx context.Context, d drivers.Driver) (Provisioner, error) {
+	return detector.DetectProvisioner(drivers.WithContext(ctx, d))
+}
+
 func (detector StandardDetector) DetectProvisioner(d drivers.Driver) (Provisioner, error) {
 	log.Info("Waiting for SSH to be available...")
 	if err := drivers.WaitForSSH(d); err != nil {
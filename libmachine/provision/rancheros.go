@@ -184,7 +184,7 @@ func (provisioner *RancherProvisioner) upgradeIso() error {
 		return err
 	}
 
-	if err := mcnutils.WaitFor(drivers.MachineInState(provisioner.Driver, state.Stopped)); err != nil {
+	if err := mcnutils.WaitForContext(drivers.ContextOf(provisioner.Driver), drivers.MachineInState(provisioner.Driver, state.Stopped)); err != nil {
 		return err
 	}
 
@@ -217,7 +217,7 @@ func (provisioner *RancherProvisioner) upgradeIso() error {
 		return err
 	}
 
-	return mcnutils.WaitFor(drivers.MachineInState(provisioner.Driver, state.Running))
+	return mcnutils.WaitForContext(drivers.ContextOf(provisioner.Driver), drivers.MachineInState(provisioner.Driver, state.Running))
 }
 
 func (provisioner *RancherProvisioner) getLatestISOURL() (string, error) {
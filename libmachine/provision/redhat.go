@@ -161,7 +161,7 @@ func (provisioner *RedHatProvisioner) Provision(swarmOptions swarm.Options, auth
 		return err
 	}
 
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+	if err := mcnutils.WaitForContext(drivers.ContextOf(provisioner.Driver), provisioner.dockerDaemonResponding); err != nil {
 		return err
 	}
 
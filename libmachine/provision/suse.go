@@ -172,7 +172,7 @@ func (provisioner *SUSEProvisioner) Provision(swarmOptions swarm.Options, authOp
 	}
 
 	log.Debug("Waiting for docker daemon")
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+	if err := mcnutils.WaitForContext(drivers.ContextOf(provisioner.Driver), provisioner.dockerDaemonResponding); err != nil {
 		return err
 	}
 
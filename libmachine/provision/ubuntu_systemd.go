@@ -126,7 +126,7 @@ func (provisioner *UbuntuSystemdProvisioner) Provision(swarmOptions swarm.Option
 	}
 
 	log.Debug("waiting for docker daemon")
-	if err := mcnutils.WaitFor(provisioner.dockerDaemonResponding); err != nil {
+	if err := mcnutils.WaitForContext(drivers.ContextOf(provisioner.Driver), provisioner.dockerDaemonResponding); err != nil {
 		return err
 	}
 
@@ -1,6 +1,7 @@
 package provision
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/url"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/docker/machine/libmachine/auth"
 	"github.com/docker/machine/libmachine/cert"
+	"github.com/docker/machine/libmachine/drivers"
 	"github.com/docker/machine/libmachine/engine"
 	"github.com/docker/machine/libmachine/log"
 	"github.com/docker/machine/libmachine/mcnutils"
@@ -281,7 +283,16 @@ func checkDaemonUp(p Provisioner, dockerPort int) func() bool {
 }
 
 func WaitForDocker(p Provisioner, dockerPort int) error {
-	if err := mcnutils.WaitForSpecific(checkDaemonUp(p, dockerPort), 10, 3*time.Second); err != nil {
+	return WaitForDockerContext(drivers.ContextOf(p.GetDriver()), p, dockerPort)
+}
+
+// WaitForDockerContext waits for the Docker daemon like WaitForDocker, giving
+// up as soon as ctx is done.
+func WaitForDockerContext(ctx context.Context, p Provisioner, dockerPort int) error {
+	if err := mcnutils.WaitForSpecificContext(ctx, checkDaemonUp(p, dockerPort), 10, 3*time.Second); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
 		return NewErrDaemonAvailable(err)
 	}
 
@@ -1,6 +1,7 @@
 package provision
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -282,3 +283,12 @@ func TestDockerClientVersion(t *testing.T) {
 		}
 	}
 }
+
+func TestWaitForDockerContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := WaitForDockerContext(ctx, &FakeProvisioner{}, 2376)
+
+	assert.Equal(t, context.Canceled, err)
+}
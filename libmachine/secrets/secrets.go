@@ -0,0 +1,304 @@
+// Package secrets encrypts the secrets found in the configuration of the
+// drivers, such as cloud credentials, before they are stored.
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/docker/machine/libmachine/mcnflag"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// prefix marks the encrypted values, and the version of their format.
+	prefix = "secret:v1:"
+
+	saltSize = 16
+	keySize  = 32
+
+	// Redacted replaces the secrets which aren't shown.
+	Redacted = "<redacted>"
+)
+
+var (
+	// ErrNoKey is returned when encrypted secrets are found but no key was
+	// given to decrypt them.
+	ErrNoKey = errors.New("The machine has encrypted secrets, set MACHINE_SECRETS_PASSPHRASE or MACHINE_SECRETS_KEYFILE to decrypt them")
+
+	// ErrWrongKey is returned when secrets can't be decrypted with the key
+	// which was given.
+	ErrWrongKey = errors.New("The secrets of the machine can't be decrypted with the given passphrase or keyfile")
+
+	// The cost of the key derivation, lowered by the tests.
+	scryptN = 1 << 15
+)
+
+// Keyring encrypts and decrypts secrets with a key derived from a passphrase
+// or from the contents of a keyfile.
+type Keyring struct {
+	material []byte
+}
+
+func NewKeyring(passphrase []byte) (*Keyring, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("The secrets passphrase is empty")
+	}
+
+	return &Keyring{material: passphrase}, nil
+}
+
+func NewKeyringFromFile(path string) (*Keyring, error) {
+	material, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading the secrets keyfile: %s", err)
+	}
+
+	return NewKeyring(bytes.TrimSpace(material))
+}
+
+// IsEncrypted tells whether the value is an encrypted secret.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Encrypt encrypts the plaintext with a key derived using a new random salt.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	aead, err := k.aead(salt)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nil, nonce, []byte(plaintext), nil)
+
+	data := append(append(salt, nonce...), sealed...)
+	return prefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Decrypt decrypts a value returned by Encrypt.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", errors.New("The value isn't an encrypted secret")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, prefix))
+	if err != nil || len(data) < saltSize {
+		return "", errors.New("The encrypted secret is corrupted")
+	}
+
+	aead, err := k.aead(data[:saltSize])
+	if err != nil {
+		return "", err
+	}
+
+	data = data[saltSize:]
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("The encrypted secret is corrupted")
+	}
+
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", ErrWrongKey
+	}
+
+	return string(plaintext), nil
+}
+
+func (k *Keyring) aead(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(k.material, salt, scryptN, 8, 1, keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// SensitiveFields returns the fields of the driver configuration holding the
+// values of the sensitive flags. The value of a flag named <driver>-<name>
+// is expected in a field with the same name in camel case, possibly with a
+// prefix and in a nested object: amazonec2-secret-key is kept in SecretKey
+// and vmwarevcloudair-password in UserPassword. Nested fields are separated
+// by dots.
+func SensitiveFields(driverName string, flags []mcnflag.Flag, config []byte) ([]string, error) {
+	suffixes := []string{}
+	for _, flag := range flags {
+		if f := mcnflag.MetadataOf(flag); f.Sensitive {
+			suffixes = append(suffixes, normalize(strings.TrimPrefix(f.Name, driverName+"-")))
+		}
+	}
+
+	if len(suffixes) == 0 {
+		return nil, nil
+	}
+
+	root, err := decode(config)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []string{}
+	walk(root, "", func(path string, key string, object map[string]interface{}) {
+		if _, ok := object[key].(string); !ok {
+			return
+		}
+
+		for _, suffix := range suffixes {
+			if strings.HasSuffix(normalize(key), suffix) {
+				fields = append(fields, path)
+				return
+			}
+		}
+	})
+	sort.Strings(fields)
+
+	return fields, nil
+}
+
+// Encrypt encrypts the given fields of the configuration, unless they are
+// empty or already encrypted.
+func Encrypt(config []byte, fields []string, k *Keyring) ([]byte, error) {
+	return transform(config, inFields(fields), func(value string) (string, error) {
+		if value == "" || IsEncrypted(value) {
+			return value, nil
+		}
+		return k.Encrypt(value)
+	})
+}
+
+// Decrypt decrypts all the encrypted values of the configuration. The
+// keyring can be nil if there are none.
+func Decrypt(config []byte, k *Keyring) ([]byte, error) {
+	if !bytes.Contains(config, []byte(prefix)) {
+		return config, nil
+	}
+
+	all := func(string) bool { return true }
+
+	return transform(config, all, func(value string) (string, error) {
+		if !IsEncrypted(value) {
+			return value, nil
+		}
+		if k == nil {
+			return "", ErrNoKey
+		}
+		return k.Decrypt(value)
+	})
+}
+
+// Redact replaces the non empty values of the given fields.
+func Redact(config []byte, fields []string) ([]byte, error) {
+	return transform(config, inFields(fields), func(value string) (string, error) {
+		if value == "" {
+			return value, nil
+		}
+		return Redacted, nil
+	})
+}
+
+func inFields(fields []string) func(string) bool {
+	set := map[string]bool{}
+	for _, field := range fields {
+		set[field] = true
+	}
+
+	return func(path string) bool { return set[path] }
+}
+
+// transform replaces the string values of the selected fields with what fn
+// returns. The configuration is left untouched when nothing changed.
+func transform(config []byte, selected func(path string) bool, fn func(string) (string, error)) ([]byte, error) {
+	root, err := decode(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		changed bool
+		errs    error
+	)
+	walk(root, "", func(path string, key string, object map[string]interface{}) {
+		value, ok := object[key].(string)
+		if !ok || errs != nil || !selected(path) {
+			return
+		}
+
+		newValue, err := fn(value)
+		if err != nil {
+			errs = err
+			return
+		}
+
+		if newValue != value {
+			object[key] = newValue
+			changed = true
+		}
+	})
+
+	if errs != nil {
+		return nil, errs
+	}
+	if !changed {
+		return config, nil
+	}
+
+	return json.Marshal(root)
+}
+
+func decode(config []byte) (interface{}, error) {
+	var root interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(config))
+	decoder.UseNumber()
+	if err := decoder.Decode(&root); err != nil {
+		return nil, fmt.Errorf("Error reading the driver configuration: %s", err)
+	}
+
+	return root, nil
+}
+
+// walk calls fn for each field of the objects found in value, with its dotted
+// path.
+func walk(value interface{}, path string, fn func(path string, key string, object map[string]interface{})) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for key, child := range object {
+		childPath := key
+		if path != "" {
+			childPath = path + "." + key
+		}
+
+		fn(childPath, key, object)
+		walk(child, childPath, fn)
+	}
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(name))
+}
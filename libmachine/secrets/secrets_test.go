@@ -0,0 +1,156 @@
+package secrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/machine/libmachine/mcnflag"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	scryptN = 1 << 4
+}
+
+const testConfig = `{
+	"MachineName": "foo",
+	"SecretKey": "s3cr3t",
+	"SessionToken": "",
+	"Retries": 5,
+	"Driver": {
+		"UserPassword": "p4ssw0rd"
+	}
+}`
+
+func TestEncryptDecrypt(t *testing.T) {
+	k, err := NewKeyring([]byte("passphrase"))
+	assert.NoError(t, err)
+
+	encrypted, err := Encrypt([]byte(testConfig), []string{"SecretKey", "SessionToken", "Driver.UserPassword"}, k)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(encrypted), "s3cr3t")
+	assert.NotContains(t, string(encrypted), "p4ssw0rd")
+
+	config := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(encrypted, &config))
+	assert.True(t, IsEncrypted(config["SecretKey"].(string)))
+	assert.Equal(t, "", config["SessionToken"])
+	assert.Equal(t, "foo", config["MachineName"])
+	assert.Equal(t, float64(5), config["Retries"])
+
+	decrypted, err := Decrypt(encrypted, k)
+	assert.NoError(t, err)
+	assert.JSONEq(t, testConfig, string(decrypted))
+}
+
+func TestEncryptTwice(t *testing.T) {
+	k, err := NewKeyring([]byte("passphrase"))
+	assert.NoError(t, err)
+
+	encrypted, err := Encrypt([]byte(testConfig), []string{"SecretKey"}, k)
+	assert.NoError(t, err)
+
+	again, err := Encrypt(encrypted, []string{"SecretKey"}, k)
+	assert.NoError(t, err)
+	assert.Equal(t, encrypted, again)
+}
+
+func TestDecryptWithWrongKey(t *testing.T) {
+	k, err := NewKeyring([]byte("passphrase"))
+	assert.NoError(t, err)
+
+	encrypted, err := Encrypt([]byte(testConfig), []string{"SecretKey"}, k)
+	assert.NoError(t, err)
+
+	other, err := NewKeyring([]byte("other"))
+	assert.NoError(t, err)
+
+	_, err = Decrypt(encrypted, other)
+	assert.Equal(t, ErrWrongKey, err)
+
+	_, err = Decrypt(encrypted, nil)
+	assert.Equal(t, ErrNoKey, err)
+}
+
+func TestDecryptPlaintextWithoutKey(t *testing.T) {
+	decrypted, err := Decrypt([]byte(testConfig), nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, testConfig, string(decrypted))
+}
+
+func TestNewKeyringFromFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "machine-test-")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpDir)
+
+	keyfile := filepath.Join(tmpDir, "key")
+	assert.NoError(t, ioutil.WriteFile(keyfile, []byte("passphrase\n"), 0600))
+
+	fromFile, err := NewKeyringFromFile(keyfile)
+	assert.NoError(t, err)
+
+	encrypted, err := fromFile.Encrypt("s3cr3t")
+	assert.NoError(t, err)
+
+	k, err := NewKeyring([]byte("passphrase"))
+	assert.NoError(t, err)
+
+	plaintext, err := k.Decrypt(encrypted)
+	assert.NoError(t, err)
+	assert.Equal(t, "s3cr3t", plaintext)
+
+	_, err = NewKeyring(nil)
+	assert.Error(t, err)
+}
+
+func TestSensitiveFields(t *testing.T) {
+	flags := []mcnflag.Flag{
+		mcnflag.StringFlag{
+			Name:      "foo-secret-key",
+			Sensitive: true,
+		},
+		&mcnflag.StringFlag{
+			Name:      "foo-password",
+			Sensitive: true,
+		},
+		mcnflag.StringFlag{
+			Name: "foo-machine-name",
+		},
+		mcnflag.IntFlag{
+			Name: "foo-retries",
+		},
+	}
+
+	fields, err := SensitiveFields("foo", flags, []byte(testConfig))
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Driver.UserPassword", "SecretKey"}, fields)
+}
+
+func TestSensitiveFieldsWithoutSensitiveFlags(t *testing.T) {
+	fields, err := SensitiveFields("foo", []mcnflag.Flag{mcnflag.StringFlag{Name: "foo-secret-key"}}, []byte("not json"))
+
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+}
+
+func TestRedact(t *testing.T) {
+	redacted, err := Redact([]byte(testConfig), []string{"SecretKey", "SessionToken", "Driver.UserPassword"})
+	assert.NoError(t, err)
+
+	config := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(redacted, &config))
+	assert.Equal(t, Redacted, config["SecretKey"])
+	assert.Equal(t, "", config["SessionToken"])
+	assert.Equal(t, Redacted, config["Driver"].(map[string]interface{})["UserPassword"])
+	assert.False(t, strings.Contains(string(redacted), "s3cr3t"))
+
+	unchanged, err := Redact([]byte(testConfig), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, testConfig, string(unchanged))
+}
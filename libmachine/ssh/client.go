@@ -1,6 +1,7 @@
 package ssh
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -35,6 +36,12 @@ type Client interface {
 	Wait() error
 }
 
+// ContextClient is implemented by clients that can abort a running command
+// when the given context is done.
+type ContextClient interface {
+	OutputContext(ctx context.Context, command string) (string, error)
+}
+
 type ExternalClient struct {
 	BaseArgs   []string
 	BinaryPath string
@@ -169,7 +176,11 @@ func (client *NativeClient) dialSuccess() bool {
 }
 
 func (client *NativeClient) session(command string) (*ssh.Client, *ssh.Session, error) {
-	if err := mcnutils.WaitFor(client.dialSuccess); err != nil {
+	return client.sessionContext(context.Background())
+}
+
+func (client *NativeClient) sessionContext(ctx context.Context) (*ssh.Client, *ssh.Session, error) {
+	if err := mcnutils.WaitForContext(ctx, client.dialSuccess); err != nil {
 		return nil, nil, fmt.Errorf("Error attempting SSH client dial: %s", err)
 	}
 
@@ -195,6 +206,36 @@ func (client *NativeClient) Output(command string) (string, error) {
 	return string(output), err
 }
 
+// OutputContext runs command like Output does, but closes the underlying
+// connection, and thereby the remote session, as soon as ctx is done.
+func (client *NativeClient) OutputContext(ctx context.Context, command string) (string, error) {
+	conn, session, err := client.sessionContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer closeConn(conn)
+	defer session.Close()
+
+	type result struct {
+		output []byte
+		err    error
+	}
+	resultCh := make(chan result, 1)
+
+	go func() {
+		output, err := session.CombinedOutput(command)
+		resultCh <- result{output, err}
+	}()
+
+	select {
+	case res := <-resultCh:
+		return string(res.output), res.err
+	case <-ctx.Done():
+		closeConn(conn)
+		return "", ctx.Err()
+	}
+}
+
 func (client *NativeClient) OutputWithPty(command string) (string, error) {
 	conn, session, err := client.session(command)
 	if err != nil {
@@ -382,6 +423,10 @@ func getSSHCmd(binaryPath string, args ...string) *exec.Cmd {
 	return exec.Command(binaryPath, args...)
 }
 
+func getSSHCmdContext(ctx context.Context, binaryPath string, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, binaryPath, args...)
+}
+
 func (client *ExternalClient) Output(command string) (string, error) {
 	args := append(client.BaseArgs, command)
 	cmd := getSSHCmd(client.BinaryPath, args...)
@@ -389,6 +434,18 @@ func (client *ExternalClient) Output(command string) (string, error) {
 	return string(output), err
 }
 
+// OutputContext runs command like Output does, but kills the ssh process as
+// soon as ctx is done.
+func (client *ExternalClient) OutputContext(ctx context.Context, command string) (string, error) {
+	args := append(client.BaseArgs, command)
+	cmd := getSSHCmdContext(ctx, client.BinaryPath, args...)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return string(output), ctx.Err()
+	}
+	return string(output), err
+}
+
 func (client *ExternalClient) Shell(args ...string) error {
 	args = append(client.BaseArgs, args...)
 	cmd := getSSHCmd(client.BinaryPath, args...)
@@ -1,11 +1,19 @@
 package version
 
 var (
-	// APIVersion dictates which version of the libmachine API this is.
-	APIVersion = 1
+	// APIVersion dictates which version of the libmachine API this is. The
+	// clients and the driver plugins agree on the newest version both of
+	// them speak, the plugins speaking version 1 are still supported.
+	APIVersion = 2
 
 	// ConfigVersion dictates which version of the config.json format is
 	// used. It needs to be bumped if there is a breaking change, and
 	// therefore migration, introduced to the config file format.
-	ConfigVersion = 3
+	ConfigVersion = 4
+
+	// BundleVersion dictates which version of the format of the bundles
+	// made by 'docker-machine export' is used. It needs to be bumped if the
+	// layout of the bundles changes. The configuration they hold is
+	// versioned by ConfigVersion.
+	BundleVersion = 1
 )